@@ -0,0 +1,208 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/WahyuSiddarta/be_saham_go/helper"
+	"github.com/WahyuSiddarta/be_saham_go/models"
+	"github.com/WahyuSiddarta/be_saham_go/validator"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	bulkStatusOK         = "ok"
+	bulkStatusInvalid    = "invalid"
+	bulkStatusNotFound   = "not_found"
+	bulkStatusFailed     = "failed"
+	bulkStatusRolledBack = "rolled_back"
+)
+
+// BulkItemResult is the outcome of one item of a batch request. Index points
+// into the request's create, update or delete array, depending on Action.
+type BulkItemResult struct {
+	Index   int                         `json:"index"`
+	Action  models.BulkAction           `json:"action"`
+	Id      int                         `json:"id,omitempty"`
+	Status  string                      `json:"status"`
+	Errors  []validator.ValidationError `json:"errors,omitempty"`
+	Warning string                      `json:"warning,omitempty"`
+}
+
+// BulkResponse summarises a batch request with one result per item
+type BulkResponse struct {
+	Mode      models.BulkMode  `json:"mode"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Results   []BulkItemResult `json:"results"`
+}
+
+// bulkDecoder validates one raw create or update item and maps it to the
+// model; loggedAt is only set for creates
+type bulkDecoder[T any] func(raw json.RawMessage, loggedAt time.Time) (T, []validator.ValidationError)
+
+// bulkApplier writes the valid operations of a batch for the current user
+type bulkApplier[T any] func(mode models.BulkMode, ops []models.BulkOperation[T]) ([]models.BulkOutcome, error)
+
+// bulkWarner explains why an item created at loggedAt needs attention, empty when it does not
+type bulkWarner func(loggedAt time.Time) string
+
+// handleBulk validates every item of a BulkRequest, applies the valid ones and
+// reports per-item results, warning on saved creates when warn is set. In
+// transactional mode nothing is written unless every item is valid and every
+// write succeeds.
+func handleBulk[T any](c echo.Context, handlerName string, decode bulkDecoder[T], apply bulkApplier[T], warn bulkWarner) error {
+	req, ok := validator.GetValidatedRequest(c).(*validator.BulkRequest)
+	if !ok {
+		Logger.Error().Msgf("[%s] Failed to cast validated request to BulkRequest", handlerName)
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+	if len(req.Create)+len(req.Update)+len(req.Delete) == 0 {
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Batch has no items", nil)
+	}
+
+	batch := partitionBulk(req, decode)
+	if response, rejected := batch.rejected(); rejected {
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Validation errors", response)
+	}
+
+	var outcomes []models.BulkOutcome
+	if len(batch.ops) > 0 {
+		var err error
+		if outcomes, err = apply(batch.mode, batch.ops); err != nil {
+			Logger.Error().Err(err).Msgf("[%s] Failed to apply batch", handlerName)
+			return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to apply batch", nil)
+		}
+	}
+
+	response, status := batch.response(handlerName, outcomes, warn)
+	if status != http.StatusOK {
+		return helper.ErrorResponse(c, status, "Batch was rolled back", response)
+	}
+	return helper.JsonResponse(c, http.StatusOK, response)
+}
+
+// bulkBatch is a BulkRequest split into the operations to apply and the
+// results of the items that failed validation
+type bulkBatch[T any] struct {
+	mode      models.BulkMode
+	ops       []models.BulkOperation[T]
+	invalid   []BulkItemResult
+	loggedAts map[int]time.Time
+}
+
+// partitionBulk validates every item of req and maps the valid ones to
+// operations; the mode defaults to transactional
+func partitionBulk[T any](req *validator.BulkRequest, decode bulkDecoder[T]) bulkBatch[T] {
+	batch := bulkBatch[T]{mode: models.BulkMode(req.Mode), loggedAts: make(map[int]time.Time, len(req.Create))}
+	if batch.mode == "" {
+		batch.mode = models.BulkTransactional
+	}
+
+	for i, raw := range req.Create {
+		var meta validator.BulkCreateItem
+		errs := validator.ValidateBulkItem(raw, &meta)
+		loggedAt := time.Now()
+		if len(errs) == 0 && meta.LoggedAt != "" {
+			loggedAt, _ = time.Parse(time.RFC3339, meta.LoggedAt)
+		}
+		data, dataErrs := decode(raw, loggedAt)
+		if errs = append(errs, dataErrs...); len(errs) > 0 {
+			batch.invalid = append(batch.invalid, BulkItemResult{Index: i, Action: models.BulkCreate, Status: bulkStatusInvalid, Errors: errs})
+			continue
+		}
+		batch.ops = append(batch.ops, models.BulkOperation[T]{Index: i, Action: models.BulkCreate, Data: data})
+		batch.loggedAts[i] = loggedAt
+	}
+
+	for i, raw := range req.Update {
+		var meta validator.BulkUpdateItem
+		errs := validator.ValidateBulkItem(raw, &meta)
+		data, dataErrs := decode(raw, time.Time{})
+		if errs = append(errs, dataErrs...); len(errs) > 0 {
+			batch.invalid = append(batch.invalid, BulkItemResult{Index: i, Action: models.BulkUpdate, Id: meta.Id, Status: bulkStatusInvalid, Errors: errs})
+			continue
+		}
+		batch.ops = append(batch.ops, models.BulkOperation[T]{Index: i, Action: models.BulkUpdate, Id: meta.Id, Data: data})
+	}
+
+	for i, id := range req.Delete {
+		batch.ops = append(batch.ops, models.BulkOperation[T]{Index: i, Action: models.BulkDelete, Id: id})
+	}
+	return batch
+}
+
+// rejected reports whether a transactional batch has invalid items, and then
+// the response marking every valid item rolled back without applying any
+func (b bulkBatch[T]) rejected() (BulkResponse, bool) {
+	if b.mode != models.BulkTransactional || len(b.invalid) == 0 {
+		return BulkResponse{}, false
+	}
+	response := BulkResponse{Mode: b.mode, Results: append([]BulkItemResult{}, b.invalid...)}
+	for _, op := range b.ops {
+		response.Results = append(response.Results, BulkItemResult{Index: op.Index, Action: op.Action, Id: op.Id, Status: bulkStatusRolledBack})
+	}
+	response.summarise()
+	return response, true
+}
+
+// response reports the invalid items and the outcomes of applying the batch,
+// with the status to answer with: 422 when a transactional batch was rolled back
+func (b bulkBatch[T]) response(handlerName string, outcomes []models.BulkOutcome, warn bulkWarner) (BulkResponse, int) {
+	response := BulkResponse{Mode: b.mode, Results: append([]BulkItemResult{}, b.invalid...)}
+	for _, outcome := range outcomes {
+		result := bulkItemResult(handlerName, outcome)
+		if warn != nil && result.Action == models.BulkCreate && result.Status == bulkStatusOK {
+			result.Warning = warn(b.loggedAts[result.Index])
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	response.summarise()
+	if b.mode == models.BulkTransactional && response.Failed > 0 {
+		return response, http.StatusUnprocessableEntity
+	}
+	return response, http.StatusOK
+}
+
+// bulkItemResult turns a repository outcome into an API result
+func bulkItemResult(handlerName string, outcome models.BulkOutcome) BulkItemResult {
+	result := BulkItemResult{Index: outcome.Index, Action: outcome.Action, Id: outcome.Id, Status: bulkStatusOK}
+	switch {
+	case outcome.Err == nil:
+	case errors.Is(outcome.Err, models.ErrBulkRolledBack):
+		result.Status = bulkStatusRolledBack
+	case errors.Is(outcome.Err, sql.ErrNoRows):
+		result.Status = bulkStatusNotFound
+		result.Errors = []validator.ValidationError{{Field: "id", Message: "Record not found", Tag: "not_found"}}
+	default:
+		Logger.Error().Err(outcome.Err).Msgf("[%s] Failed to %s item %d", handlerName, outcome.Action, outcome.Index)
+		result.Status = bulkStatusFailed
+		result.Errors = []validator.ValidationError{{Field: "item", Message: "Failed to save item", Tag: "database"}}
+	}
+	return result
+}
+
+// summarise counts the results and orders them as create, update, delete by index
+func (r *BulkResponse) summarise() {
+	rank := map[models.BulkAction]int{models.BulkCreate: 0, models.BulkUpdate: 1, models.BulkDelete: 2}
+	sort.SliceStable(r.Results, func(i, j int) bool {
+		if rank[r.Results[i].Action] != rank[r.Results[j].Action] {
+			return rank[r.Results[i].Action] < rank[r.Results[j].Action]
+		}
+		return r.Results[i].Index < r.Results[j].Index
+	})
+
+	r.Succeeded, r.Failed = 0, 0
+	for _, result := range r.Results {
+		if result.Status == bulkStatusOK {
+			r.Succeeded++
+		} else {
+			r.Failed++
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/WahyuSiddarta/be_saham_go/models"
+	"github.com/WahyuSiddarta/be_saham_go/validator"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+)
+
+// bulkTestItem is the item type batches are decoded into by decodeBulkTestItem
+type bulkTestItem struct {
+	Name string `json:"name" validate:"required"`
+}
+
+func decodeBulkTestItem(raw json.RawMessage, loggedAt time.Time) (bulkTestItem, []validator.ValidationError) {
+	var item bulkTestItem
+	errs := validator.ValidateBulkItem(raw, &item)
+	return item, errs
+}
+
+func TestPartitionBulk(t *testing.T) {
+	req := &validator.BulkRequest{
+		Create: []json.RawMessage{
+			json.RawMessage(`{"name":"a","logged_at":"2026-03-02T12:00:00Z"}`),
+			json.RawMessage(`{}`),
+			json.RawMessage(`{"name":"b","logged_at":"yesterday"}`),
+		},
+		Update: []json.RawMessage{
+			json.RawMessage(`{"id":3,"name":"c"}`),
+			json.RawMessage(`{"name":"d"}`),
+		},
+		Delete: []int{9},
+	}
+
+	batch := partitionBulk(req, decodeBulkTestItem)
+	if batch.mode != models.BulkTransactional {
+		t.Errorf("mode = %s, want %s", batch.mode, models.BulkTransactional)
+	}
+
+	var ops []string
+	for _, op := range batch.ops {
+		ops = append(ops, fmt.Sprintf("%s:%d#%d=%s", op.Action, op.Index, op.Id, op.Data.Name))
+	}
+	if want := []string{"create:0#0=a", "update:0#3=c", "delete:0#9="}; !reflect.DeepEqual(ops, want) {
+		t.Errorf("ops = %v, want %v", ops, want)
+	}
+
+	var invalid []string
+	for _, result := range batch.invalid {
+		invalid = append(invalid, fmt.Sprintf("%s:%d=%s", result.Action, result.Index, result.Status))
+	}
+	if want := []string{"create:1=invalid", "create:2=invalid", "update:1=invalid"}; !reflect.DeepEqual(invalid, want) {
+		t.Errorf("invalid = %v, want %v", invalid, want)
+	}
+
+	if want := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC); !batch.loggedAts[0].Equal(want) {
+		t.Errorf("logged at = %v, want %v", batch.loggedAts[0], want)
+	}
+}
+
+func TestHandleBulk(t *testing.T) {
+	nop := zerolog.Nop()
+	Logger = &nop
+
+	tests := []struct {
+		name          string
+		body          string
+		errs          map[models.BulkAction]error
+		wantStatus    int
+		wantApplied   bool
+		wantResults   []string
+		wantSucceeded int
+	}{
+		{
+			name:        "empty batch",
+			body:        `{}`,
+			wantStatus:  http.StatusBadRequest,
+			wantResults: nil,
+		},
+		{
+			name:        "transactional with an invalid item applies nothing",
+			body:        `{"create":[{"name":"a"},{}],"delete":[4]}`,
+			wantStatus:  http.StatusBadRequest,
+			wantResults: []string{"create:0=rolled_back", "create:1=invalid", "delete:0=rolled_back"},
+		},
+		{
+			name:          "partial applies the valid items",
+			body:          `{"mode":"partial","create":[{"name":"a"},{}],"delete":[4]}`,
+			wantStatus:    http.StatusOK,
+			wantApplied:   true,
+			wantResults:   []string{"create:0=ok", "create:1=invalid", "delete:0=ok"},
+			wantSucceeded: 2,
+		},
+		{
+			name:        "transactional write failure is rolled back",
+			body:        `{"create":[{"name":"a"}],"delete":[4]}`,
+			errs:        map[models.BulkAction]error{models.BulkCreate: models.ErrBulkRolledBack, models.BulkDelete: sql.ErrNoRows},
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantApplied: true,
+			wantResults: []string{"create:0=rolled_back", "delete:0=not_found"},
+		},
+		{
+			name:          "partial write failures are reported per item",
+			body:          `{"mode":"partial","create":[{"name":"a"}],"update":[{"id":5,"name":"b"}],"delete":[4]}`,
+			errs:          map[models.BulkAction]error{models.BulkUpdate: errors.New("duplicate key"), models.BulkDelete: sql.ErrNoRows},
+			wantStatus:    http.StatusOK,
+			wantApplied:   true,
+			wantResults:   []string{"create:0=ok", "update:0=failed", "delete:0=not_found"},
+			wantSucceeded: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applied := false
+			apply := func(mode models.BulkMode, ops []models.BulkOperation[bulkTestItem]) ([]models.BulkOutcome, error) {
+				applied = true
+				outcomes := make([]models.BulkOutcome, len(ops))
+				for i, op := range ops {
+					outcomes[i] = models.BulkOutcome{Index: op.Index, Action: op.Action, Id: op.Id, Err: tt.errs[op.Action]}
+				}
+				return outcomes, nil
+			}
+			handler := func(c echo.Context) error {
+				return handleBulk(c, "TestBulk", decodeBulkTestItem, apply, nil)
+			}
+
+			rec := serveUnits(models.MetricUnits(), http.MethodPost, "/bulk", "/bulk", tt.body,
+				handler, validator.ValidateRequest(&validator.BulkRequest{}))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("POST /bulk = %d %s, want %d", rec.Code, rec.Body, tt.wantStatus)
+			}
+			if applied != tt.wantApplied {
+				t.Errorf("applied = %v, want %v", applied, tt.wantApplied)
+			}
+
+			var response struct {
+				Data *BulkResponse `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode %s: %v", rec.Body, err)
+			}
+			if response.Data == nil {
+				if tt.wantResults != nil {
+					t.Fatalf("no results in %s", rec.Body)
+				}
+				return
+			}
+			var results []string
+			for _, result := range response.Data.Results {
+				results = append(results, fmt.Sprintf("%s:%d=%s", result.Action, result.Index, result.Status))
+			}
+			if !reflect.DeepEqual(results, tt.wantResults) {
+				t.Errorf("results = %v, want %v", results, tt.wantResults)
+			}
+			if response.Data.Succeeded != tt.wantSucceeded || response.Data.Failed != len(tt.wantResults)-tt.wantSucceeded {
+				t.Errorf("succeeded %d, failed %d, want %d of %d", response.Data.Succeeded, response.Data.Failed, tt.wantSucceeded, len(tt.wantResults))
+			}
+		})
+	}
+}
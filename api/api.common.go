@@ -3,6 +3,11 @@ package api
 import (
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/WahyuSiddarta/be_saham_go/config"
+	"github.com/WahyuSiddarta/be_saham_go/models"
 
 	"github.com/labstack/echo/v4"
 	"github.com/rs/zerolog"
@@ -54,3 +59,92 @@ func parseLimitOffset(c echo.Context) (int, int) {
 
 	return limit, offset
 }
+
+// parseLocalDateParam reads a YYYY-MM-DD path param (or "today") as midnight in loc.
+func parseLocalDateParam(c echo.Context, name string, loc *time.Location) (time.Time, error) {
+	return parseLocalDate(c.Param(name), loc)
+}
+
+// parseLocalDate reads a YYYY-MM-DD value as midnight in loc; empty or "today" means today in loc.
+func parseLocalDate(value string, loc *time.Location) (time.Time, error) {
+	if value == "" || value == "today" {
+		now := time.Now().In(loc)
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc), nil
+	}
+	return time.ParseInLocation(models.SQLDateFormat, value, loc)
+}
+
+// startOfWeek returns the Monday of the week containing day.
+func startOfWeek(day time.Time) time.Time {
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// defaultChartRangeDays is the chart window used when no from date is given.
+const defaultChartRangeDays = 366
+
+// chartRange is a chart window of local dates, capped by subscription tier.
+type chartRange struct {
+	From    time.Time
+	To      time.Time
+	MaxDays int
+	Capped  bool
+}
+
+// chartRangeLimitDays returns the maximum chart range for a subscription tier.
+func chartRangeLimitDays(level models.UserLevel) int {
+	limits := config.Get().ChartRange
+	switch level {
+	case models.UserLevelPremium:
+		return limits.PremiumDays
+	case models.UserLevelPremiumPlus, models.UserLevelAdmin:
+		return limits.PremiumPlusDays
+	}
+	return limits.FreeDays
+}
+
+// resolveChartRange parses optional YYYY-MM-DD bounds in loc, defaulting to the
+// last defaultChartRangeDays days, and trims the start so the window never
+// exceeds maxDays.
+func resolveChartRange(fromStr, toStr string, loc *time.Location, maxDays int) (chartRange, error) {
+	now := time.Now().In(loc)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if toStr != "" {
+		parsed, err := time.ParseInLocation(models.SQLDateFormat, toStr, loc)
+		if err != nil {
+			return chartRange{}, err
+		}
+		to = parsed
+	}
+
+	from := to.AddDate(0, 0, -(defaultChartRangeDays - 1))
+	if fromStr != "" {
+		parsed, err := time.ParseInLocation(models.SQLDateFormat, fromStr, loc)
+		if err != nil {
+			return chartRange{}, err
+		}
+		from = parsed
+	}
+	if from.After(to) {
+		return chartRange{}, fmt.Errorf("from must not be after to")
+	}
+
+	result := chartRange{From: from, To: to, MaxDays: maxDays}
+	if maxDays > 0 && to.Sub(from).Hours()/24+1 > float64(maxDays) {
+		result.From = to.AddDate(0, 0, -(maxDays - 1))
+		result.Capped = true
+	}
+	return result, nil
+}
+
+// chartGranularityAndMetrics applies defaults to the requested chart granularity and metric list.
+func chartGranularityAndMetrics(granularity, metrics string, defaultMetrics []string) (models.ChartGranularity, []string) {
+	chartGranularity := models.ChartGranularity(granularity)
+	if chartGranularity == "" {
+		chartGranularity = models.ChartGranularityDay
+	}
+	if metrics == "" {
+		return chartGranularity, defaultMetrics
+	}
+	return chartGranularity, strings.Split(strings.ReplaceAll(metrics, " ", ""), ",")
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/WahyuSiddarta/be_saham_go/helper"
+	"github.com/WahyuSiddarta/be_saham_go/models"
+	"github.com/WahyuSiddarta/be_saham_go/validator"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// defaultEnergyBalanceDays is the energy balance window when from is not given
+	defaultEnergyBalanceDays = 30
+	// defaultTDEEWindowDays is the TDEE window when none is requested
+	defaultTDEEWindowDays = 28
+)
+
+// EnergyHandlers combines intake, exercise and bodyweight into energy balance and TDEE
+type EnergyHandlers struct {
+	nutritionRepo   models.NutritionRepository
+	excerciseRepo   models.ExcerciseRecordRepository
+	measurementRepo models.BodyMeasurementRepository
+	userRepo        models.UserRepository
+}
+
+// NewEnergyHandlers creates a new instance of energy handlers
+func NewEnergyHandlers(nutritionRepo models.NutritionRepository, excerciseRepo models.ExcerciseRecordRepository, measurementRepo models.BodyMeasurementRepository, userRepo models.UserRepository) *EnergyHandlers {
+	return &EnergyHandlers{
+		nutritionRepo:   nutritionRepo,
+		excerciseRepo:   excerciseRepo,
+		measurementRepo: measurementRepo,
+		userRepo:        userRepo,
+	}
+}
+
+// GetEnergyBalance returns intake, exercise burn and net calories per day
+func (h *EnergyHandlers) GetEnergyBalance(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+	req := validator.GetValidatedQuery(c).(*validator.EnergyBalanceQuery)
+
+	level, err := h.userRepo.FindUserLevel(userId)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetEnergyBalance] Failed to get user level")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get energy balance", nil)
+	}
+
+	window, err := resolveChartRange(req.From, req.To, models.LoadUserLocation(userId), chartRangeLimitDays(level))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetEnergyBalance] Invalid range")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid range, from must not be after to", nil)
+	}
+	if req.From == "" {
+		if defaultFrom := window.To.AddDate(0, 0, -(defaultEnergyBalanceDays - 1)); defaultFrom.After(window.From) {
+			window.From = defaultFrom
+		}
+	}
+
+	from := window.From.Format(models.SQLDateFormat)
+	to := window.To.Format(models.SQLDateFormat)
+	days, err := h.energyBalanceDays(userId, window.From.AddDate(0, 0, -models.ChartMovingAverageWarmupDays).Format(models.SQLDateFormat), to)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetEnergyBalance] Failed to get energy balance data")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get energy balance", nil)
+	}
+
+	balance := models.BuildEnergyBalance(from, to, days)
+	balance.AttachUnits(validator.GetRequestUnits(c))
+	return helper.JsonResponse(c, http.StatusOK, balance)
+}
+
+// GetTDEEEstimate back-solves maintenance calories from intake and the weight trend
+func (h *EnergyHandlers) GetTDEEEstimate(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+	req := validator.GetValidatedQuery(c).(*validator.TDEEQuery)
+
+	windowDays := req.Window
+	if windowDays == 0 {
+		windowDays = defaultTDEEWindowDays
+	}
+
+	loc := models.LoadUserLocation(userId)
+	to, err := parseLocalDate(req.To, loc)
+	if err != nil {
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid date format, use YYYY-MM-DD", nil)
+	}
+	if req.To == "" {
+		to = to.AddDate(0, 0, -1)
+	}
+
+	// Warm the weight trend up before the window so its first day is already smoothed
+	windowFrom := to.AddDate(0, 0, -(windowDays - 1))
+	days, err := h.energyBalanceDays(userId,
+		windowFrom.AddDate(0, 0, -models.ChartMovingAverageWarmupDays).Format(models.SQLDateFormat),
+		to.Format(models.SQLDateFormat))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetTDEEEstimate] Failed to get energy balance data")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to estimate TDEE", nil)
+	}
+	if len(days) > windowDays {
+		days = days[len(days)-windowDays:]
+	}
+
+	estimate := models.EstimateTDEE(days)
+	estimate.AttachUnits(validator.GetRequestUnits(c))
+	return helper.JsonResponse(c, http.StatusOK, estimate)
+}
+
+// energyBalanceDays loads and joins intake, exercise and bodyweight per local date
+func (h *EnergyHandlers) energyBalanceDays(userId int, from, to string) ([]models.EnergyBalanceDay, error) {
+	nutrition, err := h.nutritionRepo.GetNutritionChartData(userId, from, to)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	exercise, err := h.excerciseRepo.GetDailyCaloric(userId, from, to)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	weights, err := h.measurementRepo.GetDailyBodyweight(userId, from, to)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return models.BuildEnergyBalanceDays(nutrition, exercise, weights), nil
+}
@@ -2,8 +2,14 @@ package api
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/WahyuSiddarta/be_saham_go/config"
 	"github.com/WahyuSiddarta/be_saham_go/helper"
@@ -14,12 +20,15 @@ import (
 
 // AuthHandlers contains all authentication-related handlers
 type ExcerciseHandlers struct {
-	repo models.ExcerciseRecordRepository
+	repo            models.ExcerciseRecordRepository
+	measurementRepo models.BodyMeasurementRepository
+	recordRepo      models.PersonalRecordRepository
+	catalogRepo     models.ExerciseCatalogRepository
 }
 
 // NewExcerciseHandlers creates a new instance of exercise handlers
-func NewExcerciseHandlers(repo models.ExcerciseRecordRepository) *ExcerciseHandlers {
-	return &ExcerciseHandlers{repo: repo}
+func NewExcerciseHandlers(repo models.ExcerciseRecordRepository, measurementRepo models.BodyMeasurementRepository, recordRepo models.PersonalRecordRepository, catalogRepo models.ExerciseCatalogRepository) *ExcerciseHandlers {
+	return &ExcerciseHandlers{repo: repo, measurementRepo: measurementRepo, recordRepo: recordRepo, catalogRepo: catalogRepo}
 }
 
 // Get user excercise records
@@ -44,6 +53,7 @@ func (h *ExcerciseHandlers) GetUserExcercises(c echo.Context) error {
 		hasNext = true
 		userMeasurements = userMeasurements[:limit]
 	}
+	models.AttachExcerciseUnits(userMeasurements, validator.GetRequestUnits(c))
 
 	response := map[string]interface{}{
 		"measurements": userMeasurements,
@@ -64,27 +74,40 @@ func (h *ExcerciseHandlers) AddExercise(c echo.Context) error {
 	}
 
 	measurementRequest := validatedRequest.(*validator.ExcerciseMutationRequest)
-	var Minute *int
-	if measurementRequest.Minute != nil && *measurementRequest.Minute != 0 {
-		Minute = measurementRequest.Minute
+	bodyweight, err := h.bodyweightFor(userId, measurementRequest)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[AddExercise] Failed to get latest bodyweight")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to add exercise record", nil)
 	}
-
-	newMeasurement := models.ExcerciseRecord{
-		Name:      measurementRequest.Name,
-		Minute:    Minute,
-		Intensity: measurementRequest.Intensity,
-		Caloric:   measurementRequest.Caloric,
-		Type:      measurementRequest.Type,
+	entry, err := h.catalogEntryFor(measurementRequest)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[AddExercise] Failed to get catalog exercise")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to add exercise record", nil)
+	}
+	newMeasurement, errs := excerciseRecordFromRequest(measurementRequest, entry, bodyweight)
+	if len(errs) > 0 {
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Validation errors", errs)
 	}
 
-	err := h.repo.Create(userId, newMeasurement)
+	excerciseId, err := h.repo.Create(userId, *newMeasurement)
 	if err != nil {
 		Logger.Error().Err(err).Msg("[AddBodyMeasurement] Failed to add body measurement")
 		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to add body measurement", nil)
 	}
 
 	Logger.Info().Msgf("[AddBodyMeasurement] Added new body measurement for user %d", userId)
-	return helper.JsonResponse(c, http.StatusCreated, map[string]string{"message": "Body measurement added successfully"})
+	units := validator.GetRequestUnits(c)
+	records := refreshPersonalRecords(h.recordRepo, "AddExercise", userId, []models.PersonalRecordExercise{excercisePersonalRecordExercise(newMeasurement)})
+	newRecords := models.PersonalRecordsFrom(records, models.PersonalRecordSourceExcercise, map[int]bool{excerciseId: true})
+	models.AttachPersonalRecordUnits(newRecords, units)
+	return helper.JsonResponse(c, http.StatusCreated, map[string]interface{}{
+		"message":              "Body measurement added successfully",
+		"excercise_id":         excerciseId,
+		"caloric":              math.Round(units.EnergyFromKcal(float64(newMeasurement.Caloric))),
+		"caloric_estimated":    newMeasurement.CaloricEstimated,
+		"new_pr":               len(newRecords) > 0,
+		"new_personal_records": newRecords,
+	})
 }
 func (h *ExcerciseHandlers) UpdateExercise(c echo.Context) error {
 	var userId int = 1
@@ -104,28 +127,49 @@ func (h *ExcerciseHandlers) UpdateExercise(c echo.Context) error {
 		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
 	}
 
-	measurementRequest := validatedRequest.(*validator.ExcerciseMutationRequest)
-	var Minute *int
-	if measurementRequest.Minute != nil && *measurementRequest.Minute != 0 {
-		Minute = measurementRequest.Minute
+	existing, err := h.repo.FindById(userId, excerciseId)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Exercise record not found", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[UpdateExercise] Failed to get exercise record")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to update exercise record", nil)
 	}
 
-	updatedMeasurement := &models.ExcerciseRecord{
-		Name:      measurementRequest.Name,
-		Minute:    Minute,
-		Intensity: measurementRequest.Intensity,
-		Caloric:   measurementRequest.Caloric,
-		Type:      measurementRequest.Type,
+	measurementRequest := validatedRequest.(*validator.ExcerciseMutationRequest)
+	bodyweight, err := h.bodyweightFor(userId, measurementRequest)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[UpdateExercise] Failed to get latest bodyweight")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to update exercise record", nil)
+	}
+	entry, err := h.catalogEntryFor(measurementRequest)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[UpdateExercise] Failed to get catalog exercise")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to update exercise record", nil)
+	}
+	updatedMeasurement, errs := excerciseRecordFromRequest(measurementRequest, entry, bodyweight)
+	if len(errs) > 0 {
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Validation errors", errs)
 	}
 
 	err = h.repo.Update(userId, excerciseId, updatedMeasurement)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Exercise record not found", nil)
+	}
 	if err != nil {
 		Logger.Error().Err(err).Msg("[UpdateExercise] Failed to update exercise record")
 		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to update exercise record", nil)
 	}
 
 	Logger.Info().Msgf("[UpdateExercise] Updated exercise record %d for user %d", excerciseId, userId)
-	return helper.JsonResponse(c, http.StatusOK, map[string]string{"message": "Exercise record updated successfully"})
+	refreshPersonalRecords(h.recordRepo, "UpdateExercise", userId, []models.PersonalRecordExercise{
+		excercisePersonalRecordExercise(existing), excercisePersonalRecordExercise(updatedMeasurement),
+	})
+	return helper.JsonResponse(c, http.StatusOK, map[string]interface{}{
+		"message":           "Exercise record updated successfully",
+		"caloric":           math.Round(validator.GetRequestUnits(c).EnergyFromKcal(float64(updatedMeasurement.Caloric))),
+		"caloric_estimated": updatedMeasurement.CaloricEstimated,
+	})
 }
 
 func (h *ExcerciseHandlers) DeleteExercise(c echo.Context) error {
@@ -139,12 +183,336 @@ func (h *ExcerciseHandlers) DeleteExercise(c echo.Context) error {
 		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid exercise ID", nil)
 	}
 
+	existing, err := h.repo.FindById(userId, exerciseId)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Exercise record not found", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[DeleteExercise] Failed to get exercise record")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete exercise record", nil)
+	}
+
 	err = h.repo.Delete(userId, exerciseId)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Exercise record not found", nil)
+	}
 	if err != nil {
 		Logger.Error().Err(err).Msg("[DeleteExercise] Failed to delete exercise record")
 		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete exercise record", nil)
 	}
 
 	Logger.Info().Msgf("[DeleteExercise] Deleted exercise record %d for user %d", exerciseId, userId)
+	refreshPersonalRecords(h.recordRepo, "DeleteExercise", userId, []models.PersonalRecordExercise{excercisePersonalRecordExercise(existing)})
 	return helper.JsonResponse(c, http.StatusOK, map[string]string{"message": "Exercise record deleted successfully"})
 }
+
+// bodyweightFor returns the latest bodyweight in kg when the request needs it
+// to estimate the caloric, 0 when it doesn't or none has been measured
+func (h *ExcerciseHandlers) bodyweightFor(userId int, req *validator.ExcerciseMutationRequest) (float64, error) {
+	if req.CatalogId == 0 || req.Caloric != 0 {
+		return 0, nil
+	}
+	latest, err := h.measurementRepo.FindLatest(userId)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return latest.Bodyweight, nil
+}
+
+// catalogEntryFor returns the catalog exercise the request logs, nil when it
+// logs none or one that is not in the catalog
+func (h *ExcerciseHandlers) catalogEntryFor(req *validator.ExcerciseMutationRequest) (*models.ExerciseCatalogEntry, error) {
+	if req.CatalogId == 0 {
+		return nil, nil
+	}
+	entry, err := h.catalogRepo.FindById(req.CatalogId)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return entry, err
+}
+
+// excerciseRecordFromRequest maps a validated exercise request; a zero minute is stored as NULL.
+// The catalog entry of the request fills in the name and type, and the caloric
+// when it was left out, estimated as MET × bodyweightKg × duration.
+func excerciseRecordFromRequest(measurementRequest *validator.ExcerciseMutationRequest, entry *models.ExerciseCatalogEntry, bodyweightKg float64) (*models.ExcerciseRecord, []validator.ValidationError) {
+	var Minute *int
+	if measurementRequest.Minute != nil && *measurementRequest.Minute != 0 {
+		Minute = measurementRequest.Minute
+	}
+
+	record := &models.ExcerciseRecord{
+		Name:      measurementRequest.Name,
+		Minute:    Minute,
+		Intensity: measurementRequest.Intensity,
+		Caloric:   measurementRequest.Caloric,
+		Type:      measurementRequest.Type,
+
+		DistanceKm: measurementRequest.DistanceKm,
+	}
+	if measurementRequest.CatalogId == 0 {
+		return record, nil
+	}
+
+	if entry == nil {
+		return nil, []validator.ValidationError{{Field: "catalog_id", Message: "Unknown catalog exercise", Tag: "catalog"}}
+	}
+	record.CatalogId = &entry.CatalogId
+	if record.Name == "" {
+		record.Name = entry.Name
+	}
+	if record.Type == "" {
+		record.Type = string(entry.Category)
+	}
+	if record.Caloric == 0 {
+		if Minute == nil {
+			return nil, []validator.ValidationError{{Field: "minute", Message: "minute is required to estimate caloric", Tag: "required"}}
+		}
+		if bodyweightKg <= 0 {
+			return nil, []validator.ValidationError{{Field: "caloric", Message: "caloric is required until a bodyweight has been measured", Tag: "required"}}
+		}
+		record.Caloric = models.EstimateExerciseCaloric(entry.MET(record.Intensity), bodyweightKg, *Minute)
+		record.CaloricEstimated = true
+	}
+	return record, nil
+}
+
+// excercisePersonalRecordExercise is the exercise the personal records of an exercise record are kept under
+func excercisePersonalRecordExercise(record *models.ExcerciseRecord) models.PersonalRecordExercise {
+	return models.PersonalRecordExercise{CatalogId: record.CatalogId, Name: record.Name}
+}
+
+// BulkExercises creates, updates and deletes many exercise records at once
+func (h *ExcerciseHandlers) BulkExercises(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	units := validator.GetRequestUnits(c)
+	// the latest bodyweight is looked up once, by the first item that needs it,
+	// and each catalog exercise once, by the first item logging it
+	var bodyweight *float64
+	catalog := map[int]*models.ExerciseCatalogEntry{}
+	decode := func(raw json.RawMessage, loggedAt time.Time) (models.ExcerciseRecord, []validator.ValidationError) {
+		var req validator.ExcerciseMutationRequest
+		if errs := validator.ValidateBulkItemInUnits(raw, units, &req); len(errs) > 0 {
+			return models.ExcerciseRecord{}, errs
+		}
+		if bodyweight == nil && req.CatalogId != 0 && req.Caloric == 0 {
+			latest, err := h.bodyweightFor(userId, &req)
+			if err != nil {
+				Logger.Error().Err(err).Msg("[BulkExercises] Failed to get latest bodyweight")
+				return models.ExcerciseRecord{}, []validator.ValidationError{{Field: "caloric", Message: "Failed to estimate caloric", Tag: "database"}}
+			}
+			bodyweight = &latest
+		}
+		var bodyweightKg float64
+		if bodyweight != nil {
+			bodyweightKg = *bodyweight
+		}
+		entry, ok := catalog[req.CatalogId]
+		if !ok {
+			var err error
+			if entry, err = h.catalogEntryFor(&req); err != nil {
+				Logger.Error().Err(err).Msg("[BulkExercises] Failed to get catalog exercise")
+				return models.ExcerciseRecord{}, []validator.ValidationError{{Field: "catalog_id", Message: "Failed to get catalog exercise", Tag: "database"}}
+			}
+			catalog[req.CatalogId] = entry
+		}
+		record, errs := excerciseRecordFromRequest(&req, entry, bodyweightKg)
+		if len(errs) > 0 {
+			return models.ExcerciseRecord{}, errs
+		}
+		record.RecordAt = loggedAt
+		return *record, nil
+	}
+	apply := func(mode models.BulkMode, ops []models.BulkOperation[models.ExcerciseRecord]) ([]models.BulkOutcome, error) {
+		outcomes, err := h.repo.Bulk(userId, mode, ops)
+		if err == nil {
+			// a batch can touch any exercise, so every record is rebuilt
+			if _, recomputeErr := h.recordRepo.RecomputeAll(userId); recomputeErr != nil {
+				Logger.Warn().Err(recomputeErr).Msgf("[BulkExercises] Failed to recompute personal records for user %d", userId)
+			}
+		}
+		return outcomes, err
+	}
+
+	return handleBulk(c, "BulkExercises", decode, apply, nil)
+}
+
+// activityImportMaxBytes caps the size of an uploaded activity file
+const activityImportMaxBytes = 10 << 20
+
+// ImportActivity logs a cardio session from a GPX, TCX or FIT file with its
+// distance, moving time, elevation gain, per-km splits and heart rate, and
+// keeps its route for display
+func (h *ExcerciseHandlers) ImportActivity(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	req, ok := validator.GetValidatedRequest(c).(*validator.ExcerciseActivityImportRequest)
+	if !ok {
+		Logger.Error().Msg("[ImportActivity] Failed to cast validated request to ExcerciseActivityImportRequest")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return helper.ErrorResponse(c, http.StatusBadRequest, "A GPX, TCX or FIT file is required", nil)
+	}
+	if fileHeader.Size > activityImportMaxBytes {
+		return helper.ErrorResponse(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("File must be at most %d bytes", activityImportMaxBytes), nil)
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		Logger.Error().Err(err).Msg("[ImportActivity] Failed to open uploaded file")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Failed to read file", nil)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, activityImportMaxBytes+1))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[ImportActivity] Failed to read uploaded file")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Failed to read file", nil)
+	}
+	if len(data) > activityImportMaxBytes {
+		return helper.ErrorResponse(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("File must be at most %d bytes", activityImportMaxBytes), nil)
+	}
+
+	format := req.Format
+	if format == "" {
+		format = models.DetectActivityFormat(fileHeader.Filename, data)
+	}
+	track, err := models.ParseActivityFile(data, format)
+	if errors.Is(err, models.ErrInvalidImport) {
+		return helper.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[ImportActivity] Failed to parse activity file")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to import activity", nil)
+	}
+	metrics := models.BuildActivityMetrics(track)
+
+	// the session lasts its moving time, or the whole recording when the file has no positions
+	seconds := metrics.MovingSeconds
+	if seconds == 0 {
+		seconds = metrics.ElapsedSeconds
+	}
+	minute := max(int(math.Round(float64(seconds)/60)), 1)
+	mutation := &validator.ExcerciseMutationRequest{
+		CatalogId: req.CatalogId,
+		Name:      req.Name,
+		Minute:    &minute,
+		Caloric:   req.Caloric,
+		Intensity: req.Intensity,
+		Type:      string(models.ExerciseCategoryCardio),
+	}
+	if mutation.CatalogId == 0 {
+		mutation.CatalogId = models.ActivitySportCatalogIds[models.ActivitySportRunning]
+		if catalogId, ok := models.ActivitySportCatalogIds[track.Sport]; ok {
+			mutation.CatalogId = catalogId
+		}
+	}
+	if mutation.Name == "" {
+		mutation.Name = track.Name
+	}
+	if mutation.Intensity == "" {
+		mutation.Intensity = models.IntensityMedium
+	}
+	if metrics.DistanceKm > 0 {
+		mutation.DistanceKm = &metrics.DistanceKm
+	}
+
+	bodyweight, err := h.bodyweightFor(userId, mutation)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[ImportActivity] Failed to get latest bodyweight")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to import activity", nil)
+	}
+	entry, err := h.catalogEntryFor(mutation)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[ImportActivity] Failed to get catalog exercise")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to import activity", nil)
+	}
+	record, errs := excerciseRecordFromRequest(mutation, entry, bodyweight)
+	if len(errs) > 0 {
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Validation errors", errs)
+	}
+	record.UserId = userId
+	record.RecordAt = track.Points[0].Time
+	record.Activity = &metrics
+
+	record.ExcerciseId, err = h.repo.Import(userId, record, models.BuildActivityRoute(track))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[ImportActivity] Failed to import activity")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to import activity", nil)
+	}
+
+	Logger.Info().Msgf("[ImportActivity] Imported %s activity %d for user %d", format, record.ExcerciseId, userId)
+	units := validator.GetRequestUnits(c)
+	record.Units = &units
+	records := refreshPersonalRecords(h.recordRepo, "ImportActivity", userId, []models.PersonalRecordExercise{excercisePersonalRecordExercise(record)})
+	newRecords := models.PersonalRecordsFrom(records, models.PersonalRecordSourceExcercise, map[int]bool{record.ExcerciseId: true})
+	models.AttachPersonalRecordUnits(newRecords, units)
+	return helper.JsonResponse(c, http.StatusCreated, map[string]interface{}{
+		"message":              "Activity imported successfully",
+		"exercise":             record,
+		"new_pr":               len(newRecords) > 0,
+		"new_personal_records": newRecords,
+	})
+}
+
+// GetExerciseRoute returns the route of an exercise record imported from an activity file
+func (h *ExcerciseHandlers) GetExerciseRoute(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	exerciseId, err := strconv.Atoi(c.Param("exercise_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetExerciseRoute] Invalid exercise ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid exercise ID", nil)
+	}
+
+	route, err := h.repo.FindRoute(userId, exerciseId)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Exercise route not found", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetExerciseRoute] Failed to get exercise route")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get exercise route", nil)
+	}
+
+	return helper.JsonResponse(c, http.StatusOK, map[string]interface{}{
+		"excercise_id": exerciseId,
+		"points":       route,
+	})
+}
+
+// GetExerciseCatalog lists the catalog exercises matching the query
+func (h *ExcerciseHandlers) GetExerciseCatalog(c echo.Context) error {
+	req := validator.GetValidatedQuery(c).(*validator.ExerciseCatalogQuery)
+
+	entries, err := h.catalogRepo.Search(req.Q, models.ExerciseCategory(req.Category), req.MuscleGroup)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetExerciseCatalog] Failed to search exercise catalog")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get exercise catalog", nil)
+	}
+	return helper.JsonResponse(c, http.StatusOK, map[string]interface{}{
+		"exercises": entries,
+	})
+}
+
+// GetExerciseCatalogEntry returns one catalog exercise
+func (h *ExcerciseHandlers) GetExerciseCatalogEntry(c echo.Context) error {
+	catalogId, err := strconv.Atoi(c.Param("catalog_id"))
+	if err != nil {
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid catalog ID", nil)
+	}
+
+	entry, err := h.catalogRepo.FindById(catalogId)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Catalog exercise not found", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetExerciseCatalogEntry] Failed to get catalog exercise")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get catalog exercise", nil)
+	}
+	return helper.JsonResponse(c, http.StatusOK, entry)
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/WahyuSiddarta/be_saham_go/config"
+	"github.com/WahyuSiddarta/be_saham_go/helper"
+	"github.com/WahyuSiddarta/be_saham_go/models"
+	"github.com/WahyuSiddarta/be_saham_go/validator"
+	"github.com/labstack/echo/v4"
+)
+
+// FastingHandlers contains all intermittent fasting handlers
+type FastingHandlers struct {
+	repo models.FastingRepository
+}
+
+// NewFastingHandlers creates a new instance of fasting handlers
+func NewFastingHandlers(repo models.FastingRepository) *FastingHandlers {
+	return &FastingHandlers{repo: repo}
+}
+
+// GetFastingStatus returns the active fast, if any, with the current streaks
+func (h *FastingHandlers) GetFastingStatus(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	active, err := h.repo.FindActive(userId)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetFastingStatus] Failed to get active fast")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get fasting status", nil)
+	}
+
+	streak, err := h.fastingStreak(userId)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetFastingStatus] Failed to get fasting streak")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get fasting status", nil)
+	}
+
+	response := map[string]interface{}{
+		"fasting": active != nil,
+		"session": active,
+		"streak":  streak,
+	}
+	if active != nil && active.Warning() != "" {
+		response["warning"] = active.Warning()
+	}
+
+	return helper.JsonResponse(c, http.StatusOK, response)
+}
+
+func (h *FastingHandlers) StartFast(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	req, ok := validator.GetValidatedRequest(c).(*validator.FastingStartRequest)
+	if !ok {
+		Logger.Error().Msg("[StartFast] Failed to cast validated request to FastingStartRequest")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	startedAt := time.Now()
+	if req.StartedAt != "" {
+		startedAt, _ = time.Parse(time.RFC3339, req.StartedAt)
+		if startedAt.After(time.Now()) {
+			return helper.ErrorResponse(c, http.StatusBadRequest, "started_at must not be in the future", nil)
+		}
+	}
+
+	preset := models.FastingPreset(req.Preset)
+	hours, ok := models.FastingPresetHours(preset)
+	if !ok {
+		hours = *req.TargetHours
+	}
+
+	session := &models.FastingSession{
+		UserId:       userId,
+		Preset:       preset,
+		StartedAt:    startedAt,
+		PlannedEndAt: startedAt.Add(time.Duration(hours * float64(time.Hour))),
+	}
+	err := h.repo.Start(session)
+	if errors.Is(err, models.ErrFastingAlreadyActive) {
+		return helper.ErrorResponse(c, http.StatusConflict, "A fast is already active, stop it first", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[StartFast] Failed to start fast")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to start fast", nil)
+	}
+
+	Logger.Info().Msgf("[StartFast] Started %s fast %d for user %d", preset, session.SessionId, userId)
+	return helper.JsonResponse(c, http.StatusCreated, session)
+}
+
+func (h *FastingHandlers) StopFast(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	req, ok := validator.GetValidatedRequest(c).(*validator.FastingStopRequest)
+	if !ok {
+		Logger.Error().Msg("[StopFast] Failed to cast validated request to FastingStopRequest")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	endedAt := time.Now()
+	if req.EndedAt != "" {
+		endedAt, _ = time.Parse(time.RFC3339, req.EndedAt)
+		if endedAt.After(time.Now()) {
+			return helper.ErrorResponse(c, http.StatusBadRequest, "ended_at must not be in the future", nil)
+		}
+	}
+
+	session, err := h.repo.Stop(userId, endedAt)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "No active fast", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[StopFast] Failed to stop fast")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to stop fast", nil)
+	}
+
+	Logger.Info().Msgf("[StopFast] Stopped fast %d for user %d", session.SessionId, userId)
+	return helper.JsonResponse(c, http.StatusOK, session)
+}
+
+func (h *FastingHandlers) GetFastingHistory(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	req := validator.GetValidatedQuery(c).(*validator.FastingHistoryQuery)
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	limit := config.Get().PaginationDefaultPageSize
+	sessions, err := h.repo.GetByUserId(userId, limit, page)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetFastingHistory] Failed to get fasting history")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get fasting history", nil)
+	}
+
+	hasNext := false
+	if len(sessions) > limit {
+		hasNext = true
+		sessions = sessions[:limit]
+	}
+
+	response := map[string]interface{}{
+		"sessions": sessions,
+		"nextPage": hasNext,
+	}
+
+	return helper.JsonResponse(c, http.StatusOK, response)
+}
+
+func (h *FastingHandlers) DeleteFast(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	sessionId, err := strconv.Atoi(c.Param("session_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[DeleteFast] Invalid session ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid session ID", nil)
+	}
+
+	if err = h.repo.Delete(userId, sessionId); err != nil {
+		Logger.Error().Err(err).Msg("[DeleteFast] Failed to delete fasting session")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete fasting session", nil)
+	}
+
+	return helper.JsonResponse(c, http.StatusOK, map[string]string{"message": "Fasting session deleted successfully"})
+}
+
+// GetWeeklyFastingStats returns fasting hours for the Monday-based week containing :date
+func (h *FastingHandlers) GetWeeklyFastingStats(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	loc := models.LoadUserLocation(userId)
+	day, err := parseLocalDateParam(c, "date", loc)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetWeeklyFastingStats] Invalid date parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid date format, use YYYY-MM-DD", nil)
+	}
+	start := startOfWeek(day)
+
+	sessions, err := h.repo.FindOverlapping(userId, start, start.AddDate(0, 0, 7))
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetWeeklyFastingStats] Failed to get fasting sessions")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get fasting stats", nil)
+	}
+
+	streak, err := h.fastingStreak(userId)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetWeeklyFastingStats] Failed to get fasting streak")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get fasting stats", nil)
+	}
+
+	response := map[string]interface{}{
+		"week":   models.BuildFastingWeeklyStats(start, sessions, time.Now()),
+		"streak": streak,
+	}
+
+	return helper.JsonResponse(c, http.StatusOK, response)
+}
+
+// fastingStreak computes the user's streaks as of today in their timezone
+func (h *FastingHandlers) fastingStreak(userId int) (models.FastingStreak, error) {
+	dates, err := h.repo.FindCompletedDates(userId)
+	if err != nil && err != sql.ErrNoRows {
+		return models.FastingStreak{}, err
+	}
+	return models.BuildFastingStreak(dates, time.Now().In(models.LoadUserLocation(userId))), nil
+}
+
+// activeFast returns the running fast of a user for warning about food logged
+// during it, nil when there is none. Food is already saved when this runs, so
+// a failing lookup only drops the warning.
+func activeFast(repo models.FastingRepository, userId int, handlerName string) *models.FastingSession {
+	active, err := repo.FindActive(userId)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			Logger.Warn().Err(err).Msgf("[%s] Failed to get active fast", handlerName)
+		}
+		return nil
+	}
+	return active
+}
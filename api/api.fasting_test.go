@@ -0,0 +1,112 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/WahyuSiddarta/be_saham_go/models"
+	"github.com/WahyuSiddarta/be_saham_go/validator"
+	"github.com/rs/zerolog"
+)
+
+// fakeFastingRepo only knows the active fast, none when active is nil
+type fakeFastingRepo struct {
+	models.FastingRepository
+	active *models.FastingSession
+}
+
+func (r fakeFastingRepo) FindActive(int) (*models.FastingSession, error) {
+	if r.active == nil {
+		return nil, sql.ErrNoRows
+	}
+	return r.active, nil
+}
+
+// fakeBulkNutritionRepo saves every bulk operation
+type fakeBulkNutritionRepo struct {
+	models.NutritionRepository
+}
+
+func (fakeBulkNutritionRepo) Bulk(userID int, mode models.BulkMode, ops []models.BulkOperation[models.NutritionTracker]) ([]models.BulkOutcome, error) {
+	outcomes := make([]models.BulkOutcome, len(ops))
+	for i, op := range ops {
+		outcomes[i] = models.BulkOutcome{Index: op.Index, Action: op.Action, Id: 100 + i}
+	}
+	return outcomes, nil
+}
+
+func TestFoodLoggedDuringFastWarning(t *testing.T) {
+	nop := zerolog.Nop()
+	Logger = &nop
+	fast := func(startedAt time.Time) *models.FastingSession {
+		return &models.FastingSession{Preset: models.FastingPreset16x8, StartedAt: startedAt, PlannedEndAt: startedAt.Add(16 * time.Hour)}
+	}
+	before := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	after := time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC)
+	wantWarning := "logged during the active 16:8 fast started at 2026-03-01T12:00:00Z"
+
+	tests := []struct {
+		name        string
+		active      *models.FastingSession
+		wantWarning string
+	}{
+		{"no active fast", nil, ""},
+		{"fast running since before the meal", fast(before), wantWarning},
+		{"fast started after the meal", fast(after), ""},
+	}
+	for _, tt := range tests {
+		t.Run("apply template: "+tt.name, func(t *testing.T) {
+			repo := &fakeMealTemplateRepo{created: &models.MealTemplate{
+				Category: models.NutritionCategoryLunch,
+				Items:    []models.MealTemplateItem{{Name: "Rice", Carbohydrate: 40, Caloric: 160}},
+			}}
+			h := NewMealTemplateHandlers(repo, fakeFastingRepo{active: tt.active})
+
+			rec := serveUnits(models.MetricUnits(), http.MethodPost, "/templates/:template_id/apply", "/templates/1/apply", `{"date":"2026-03-02"}`,
+				h.ApplyMealTemplate, validator.ValidateRequest(&validator.ApplyMealTemplateRequest{}))
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("POST /templates/1/apply = %d %s", rec.Code, rec.Body)
+			}
+			var response struct {
+				Data []struct {
+					FastingWarning string `json:"fasting_warning"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode %s: %v", rec.Body, err)
+			}
+			if got := response.Data[0].FastingWarning; got != tt.wantWarning {
+				t.Errorf("fasting_warning = %q, want %q", got, tt.wantWarning)
+			}
+		})
+
+		t.Run("bulk create: "+tt.name, func(t *testing.T) {
+			h := NewNutritionHandlers(fakeBulkNutritionRepo{}, nil, fakeFastingRepo{active: tt.active})
+			body := `{"mode":"partial","create":[{"name":"Rice","category":"lunch","fat":0,"protein":0,"carbohydrate":40,"caloric":160,"logged_at":"2026-03-02T12:00:00Z"}],"delete":[7]}`
+
+			rec := serveUnits(models.MetricUnits(), http.MethodPost, "/bulk", "/bulk", body,
+				h.BulkNutritionIntake, validator.ValidateRequest(&validator.BulkRequest{}))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("POST /bulk = %d %s", rec.Code, rec.Body)
+			}
+			var response struct {
+				Data BulkResponse `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode %s: %v", rec.Body, err)
+			}
+			for _, result := range response.Data.Results {
+				want := ""
+				if result.Action == models.BulkCreate {
+					want = tt.wantWarning
+				}
+				if result.Warning != want {
+					t.Errorf("%s %d warning = %q, want %q", result.Action, result.Index, result.Warning, want)
+				}
+			}
+		})
+	}
+}
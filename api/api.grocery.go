@@ -0,0 +1,184 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/WahyuSiddarta/be_saham_go/helper"
+	"github.com/WahyuSiddarta/be_saham_go/models"
+	"github.com/WahyuSiddarta/be_saham_go/validator"
+	"github.com/labstack/echo/v4"
+)
+
+// GroceryListHandlers contains all grocery list handlers
+type GroceryListHandlers struct {
+	repo         models.GroceryListRepository
+	mealPlanRepo models.MealPlanRepository
+}
+
+// NewGroceryListHandlers creates a new instance of grocery list handlers
+func NewGroceryListHandlers(repo models.GroceryListRepository, mealPlanRepo models.MealPlanRepository) *GroceryListHandlers {
+	return &GroceryListHandlers{repo: repo, mealPlanRepo: mealPlanRepo}
+}
+
+func (h *GroceryListHandlers) GetGroceryLists(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	lists, err := h.repo.GetByUserId(userId)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetGroceryLists] Failed to get grocery lists")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get grocery lists", nil)
+	}
+	if lists == nil {
+		lists = []models.GroceryList{}
+	}
+
+	return helper.JsonResponse(c, http.StatusOK, lists)
+}
+
+func (h *GroceryListHandlers) GetGroceryList(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	listId, err := strconv.Atoi(c.Param("list_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetGroceryList] Invalid list ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid list ID", nil)
+	}
+
+	list, err := h.repo.FindById(userId, listId)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Grocery list not found", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetGroceryList] Failed to get grocery list")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get grocery list", nil)
+	}
+
+	return helper.JsonResponse(c, http.StatusOK, list)
+}
+
+// GenerateGroceryList builds a grocery list from the planned meals that are not checked off yet
+func (h *GroceryListHandlers) GenerateGroceryList(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	req, ok := validator.GetValidatedRequest(c).(*validator.GroceryListRequest)
+	if !ok {
+		Logger.Error().Msg("[GenerateGroceryList] Failed to cast validated request to GroceryListRequest")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	from, to, err := resolveMealPlanRange(req.From, req.To, models.LoadUserLocation(userId))
+	if err != nil {
+		return helper.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+	}
+
+	planned, err := h.mealPlanRepo.FindByDateRange(userId, from.Format(models.SQLDateFormat), to.Format(models.SQLDateFormat))
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GenerateGroceryList] Failed to get meal plan")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate grocery list", nil)
+	}
+
+	list := &models.GroceryList{
+		UserId:   userId,
+		FromDate: from,
+		ToDate:   to,
+		Items:    models.BuildGroceryListItems(planned),
+	}
+	if len(list.Items) == 0 {
+		return helper.ErrorResponse(c, http.StatusBadRequest, "No planned meals left to shop for in that range", nil)
+	}
+
+	if err = h.repo.Create(list); err != nil {
+		Logger.Error().Err(err).Msg("[GenerateGroceryList] Failed to save grocery list")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate grocery list", nil)
+	}
+
+	Logger.Info().Msgf("[GenerateGroceryList] Generated grocery list %d with %d items for user %d", list.ListId, len(list.Items), userId)
+	return helper.JsonResponse(c, http.StatusCreated, list)
+}
+
+func (h *GroceryListHandlers) CheckGroceryItem(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	listId, err := strconv.Atoi(c.Param("list_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[CheckGroceryItem] Invalid list ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid list ID", nil)
+	}
+	itemId, err := strconv.Atoi(c.Param("item_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[CheckGroceryItem] Invalid item ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid item ID", nil)
+	}
+
+	req, ok := validator.GetValidatedRequest(c).(*validator.GroceryItemCheckRequest)
+	if !ok {
+		Logger.Error().Msg("[CheckGroceryItem] Failed to cast validated request to GroceryItemCheckRequest")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	err = h.repo.SetItemChecked(userId, listId, itemId, *req.Checked)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Grocery list item not found", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[CheckGroceryItem] Failed to update grocery list item")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to update grocery list item", nil)
+	}
+
+	return helper.JsonResponse(c, http.StatusOK, map[string]string{"message": "Grocery list item updated successfully"})
+}
+
+func (h *GroceryListHandlers) DeleteGroceryList(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	listId, err := strconv.Atoi(c.Param("list_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[DeleteGroceryList] Invalid list ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid list ID", nil)
+	}
+
+	if err = h.repo.Delete(userId, listId); err != nil {
+		Logger.Error().Err(err).Msg("[DeleteGroceryList] Failed to delete grocery list")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete grocery list", nil)
+	}
+
+	return helper.JsonResponse(c, http.StatusOK, map[string]string{"message": "Grocery list deleted successfully"})
+}
+
+// ExportGroceryList downloads a grocery list as plain text (default) or CSV
+func (h *GroceryListHandlers) ExportGroceryList(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+	req := validator.GetValidatedQuery(c).(*validator.GroceryExportQuery)
+
+	listId, err := strconv.Atoi(c.Param("list_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[ExportGroceryList] Invalid list ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid list ID", nil)
+	}
+
+	list, err := h.repo.FindById(userId, listId)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Grocery list not found", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[ExportGroceryList] Failed to get grocery list")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to export grocery list", nil)
+	}
+
+	filename := fmt.Sprintf("grocery-list-%s", list.FromDate.Format(models.SQLDateFormat))
+	if req.Format == "csv" {
+		data, err := list.CSV()
+		if err != nil {
+			Logger.Error().Err(err).Msg("[ExportGroceryList] Failed to render CSV")
+			return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to export grocery list", nil)
+		}
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.csv"`, filename))
+		return c.Blob(http.StatusOK, "text/csv; charset=utf-8", data)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.txt"`, filename))
+	return c.Blob(http.StatusOK, echo.MIMETextPlainCharsetUTF8, []byte(list.PlainText()))
+}
@@ -0,0 +1,218 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/WahyuSiddarta/be_saham_go/config"
+	"github.com/WahyuSiddarta/be_saham_go/helper"
+	"github.com/WahyuSiddarta/be_saham_go/models"
+	"github.com/WahyuSiddarta/be_saham_go/validator"
+	"github.com/labstack/echo/v4"
+)
+
+// HydrationHandlers contains all hydration-related handlers
+type HydrationHandlers struct {
+	repo     models.HydrationRepository
+	userRepo models.UserRepository
+}
+
+// NewHydrationHandlers creates a new instance of hydration handlers
+func NewHydrationHandlers(repo models.HydrationRepository, userRepo models.UserRepository) *HydrationHandlers {
+	return &HydrationHandlers{repo: repo, userRepo: userRepo}
+}
+
+// Get user hydration logs
+func (h *HydrationHandlers) GetHydrationLogs(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	// Get validated request from middleware
+	req := validator.GetValidatedQuery(c).(*validator.HydrationRequest)
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	limit := config.Get().PaginationDefaultPageSize
+	hydrationLogs, err := h.repo.GetByUserId(userId, limit, page)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetHydrationLogs] Failed to get hydration logs")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get hydration logs", nil)
+	}
+
+	hasNext := false
+	if len(hydrationLogs) > limit {
+		hasNext = true
+		hydrationLogs = hydrationLogs[:limit]
+	}
+	models.AttachHydrationUnits(hydrationLogs, validator.GetRequestUnits(c))
+
+	response := map[string]interface{}{
+		"measurements": hydrationLogs,
+		"nextPage":     hasNext,
+	}
+
+	return helper.JsonResponse(c, http.StatusOK, response)
+}
+
+func (h *HydrationHandlers) GetDailyHydration(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	day, err := parseLocalDateParam(c, "date", models.LoadUserLocation(userId))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetDailyHydration] Invalid date parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid date format, use YYYY-MM-DD", nil)
+	}
+	date := day.Format(models.SQLDateFormat)
+
+	hydrationLogs, err := h.repo.FindByDate(userId, date)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetDailyHydration] Failed to get hydration logs")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get daily hydration", nil)
+	}
+
+	targets, err := h.userRepo.FindTargetTimeline(userId, date, date)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetDailyHydration] Failed to get personal target")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get daily hydration", nil)
+	}
+
+	summary := models.BuildHydrationDailySummary(date, hydrationLogs, targets.On(day))
+	summary.AttachUnits(validator.GetRequestUnits(c))
+	return helper.JsonResponse(c, http.StatusOK, summary)
+}
+
+func (h *HydrationHandlers) GetHydrationChartData(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+	req := validator.GetValidatedQuery(c).(*validator.HydrationChartQuery)
+
+	level, err := h.userRepo.FindUserLevel(userId)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetHydrationChartData] Failed to get user level")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get hydration chart data", nil)
+	}
+
+	window, err := resolveChartRange(req.From, req.To, models.LoadUserLocation(userId), chartRangeLimitDays(level))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetHydrationChartData] Invalid chart range")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid chart range, from must not be after to", nil)
+	}
+
+	granularity, metrics := chartGranularityAndMetrics(req.Granularity, req.Metrics, models.HydrationChartMetrics)
+
+	from := window.From.Format(models.SQLDateFormat)
+	warmupFrom := window.From.AddDate(0, 0, -models.ChartMovingAverageWarmupDays).Format(models.SQLDateFormat)
+	dailyData, err := h.repo.GetHydrationChartData(userId, warmupFrom, window.To.Format(models.SQLDateFormat))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetHydrationChartData] Failed to get hydration chart data")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get hydration chart data", nil)
+	}
+
+	chart := models.Chart{
+		From:         from,
+		To:           window.To.Format(models.SQLDateFormat),
+		Granularity:  granularity,
+		Metrics:      metrics,
+		MaxRangeDays: window.MaxDays,
+		RangeCapped:  window.Capped,
+		Points:       models.BuildHydrationChartPoints(dailyData, from, granularity, metrics),
+	}
+
+	return helper.JsonResponse(c, http.StatusOK, chart)
+}
+
+func (h *HydrationHandlers) AddHydration(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	// Get validated request from middleware
+	validatedRequest := validator.GetValidatedRequest(c)
+	if validatedRequest == nil {
+		Logger.Error().Msg("[AddHydration] No validated request found in context")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	req, ok := validatedRequest.(*validator.HydrationMutationRequest)
+	if !ok {
+		Logger.Error().Msg("[AddHydration] Failed to cast validated request to HydrationMutationRequest")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	created, err := h.repo.Create(userId, hydrationLogFromRequest(req))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[AddHydration] Failed to add hydration log")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to add hydration log", nil)
+	}
+
+	units := validator.GetRequestUnits(c)
+	created.Units = &units
+
+	Logger.Info().Msgf("[AddHydration] Added new hydration log for user %d", userId)
+	return helper.JsonResponse(c, http.StatusCreated, created)
+}
+
+func (h *HydrationHandlers) UpdateHydration(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	hydrationId, err := strconv.Atoi(c.Param("hydration_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[UpdateHydration] Invalid hydration ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid hydration ID", nil)
+	}
+
+	// Get validated request from middleware
+	validatedRequest := validator.GetValidatedRequest(c)
+	if validatedRequest == nil {
+		Logger.Error().Msg("[UpdateHydration] No validated request found in context")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	req, ok := validatedRequest.(*validator.HydrationMutationRequest)
+	if !ok {
+		Logger.Error().Msg("[UpdateHydration] Failed to cast validated request to HydrationMutationRequest")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	updated := hydrationLogFromRequest(req)
+	err = h.repo.Update(userId, hydrationId, &updated)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[UpdateHydration] Failed to update hydration log")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to update hydration log", nil)
+	}
+
+	Logger.Info().Msgf("[UpdateHydration] Updated hydration log %d for user %d", hydrationId, userId)
+	return helper.JsonResponse(c, http.StatusOK, map[string]string{"message": "Hydration log updated successfully"})
+}
+
+func (h *HydrationHandlers) DeleteHydration(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	hydrationId, err := strconv.Atoi(c.Param("hydration_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[DeleteHydration] Invalid hydration ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid hydration ID", nil)
+	}
+
+	err = h.repo.Delete(userId, hydrationId)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[DeleteHydration] Failed to delete hydration log")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete hydration log", nil)
+	}
+
+	Logger.Info().Msgf("[DeleteHydration] Deleted hydration log %d for user %d", hydrationId, userId)
+	return helper.JsonResponse(c, http.StatusOK, map[string]string{"message": "Hydration log deleted successfully"})
+}
+
+// hydrationLogFromRequest maps a validated request, estimating caffeine when it was not supplied
+func hydrationLogFromRequest(req *validator.HydrationMutationRequest) models.HydrationLog {
+	beverageType := models.BeverageType(req.BeverageType)
+	caffeineMg := req.CaffeineMg
+	if caffeineMg == nil {
+		caffeineMg = models.EstimateCaffeineMg(beverageType, req.AmountMl)
+	}
+
+	return models.HydrationLog{
+		AmountMl:     req.AmountMl,
+		BeverageType: beverageType,
+		CaffeineMg:   caffeineMg,
+	}
+}
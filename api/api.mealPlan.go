@@ -0,0 +1,264 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/WahyuSiddarta/be_saham_go/helper"
+	"github.com/WahyuSiddarta/be_saham_go/models"
+	"github.com/WahyuSiddarta/be_saham_go/validator"
+	"github.com/labstack/echo/v4"
+)
+
+// maxMealPlanRangeDays caps how many days one meal plan listing may span
+const maxMealPlanRangeDays = 31
+
+// MealPlanHandlers contains all meal plan handlers
+type MealPlanHandlers struct {
+	repo          models.MealPlanRepository
+	nutritionRepo models.NutritionRepository
+	userRepo      models.UserRepository
+}
+
+// NewMealPlanHandlers creates a new instance of meal plan handlers
+func NewMealPlanHandlers(repo models.MealPlanRepository, nutritionRepo models.NutritionRepository, userRepo models.UserRepository) *MealPlanHandlers {
+	return &MealPlanHandlers{repo: repo, nutritionRepo: nutritionRepo, userRepo: userRepo}
+}
+
+// GetMealPlan lists planned items per day with projected macros against the personal target
+func (h *MealPlanHandlers) GetMealPlan(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+	req := validator.GetValidatedQuery(c).(*validator.MealPlanQuery)
+
+	from, to, err := resolveMealPlanRange(req.From, req.To, models.LoadUserLocation(userId))
+	if err != nil {
+		return helper.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+	}
+
+	fromDate, toDate := from.Format(models.SQLDateFormat), to.Format(models.SQLDateFormat)
+	items, err := h.repo.FindByDateRange(userId, fromDate, toDate)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetMealPlan] Failed to get meal plan")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get meal plan", nil)
+	}
+
+	targets, err := h.userRepo.FindTargetTimeline(userId, fromDate, toDate)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetMealPlan] Failed to get personal target")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get meal plan", nil)
+	}
+
+	return helper.JsonResponse(c, http.StatusOK, models.BuildMealPlanDays(from, to, items, targets))
+}
+
+func (h *MealPlanHandlers) AddMealPlanItem(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	req, ok := validator.GetValidatedRequest(c).(*validator.MealPlanItemRequest)
+	if !ok {
+		Logger.Error().Msg("[AddMealPlanItem] Failed to cast validated request to MealPlanItemRequest")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	item, err := mealPlanItemFromRequest(userId, req)
+	if err != nil {
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid date format, use YYYY-MM-DD", nil)
+	}
+
+	if err = h.repo.Create(item); err != nil {
+		Logger.Error().Err(err).Msg("[AddMealPlanItem] Failed to add meal plan item")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to add meal plan item", nil)
+	}
+
+	return helper.JsonResponse(c, http.StatusCreated, item)
+}
+
+func (h *MealPlanHandlers) AddMealPlanTemplate(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	templateId, err := strconv.Atoi(c.Param("template_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[AddMealPlanTemplate] Invalid template ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid template ID", nil)
+	}
+
+	req, ok := validator.GetValidatedRequest(c).(*validator.MealPlanTemplateRequest)
+	if !ok {
+		Logger.Error().Msg("[AddMealPlanTemplate] Failed to cast validated request to MealPlanTemplateRequest")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	planDate, err := parseLocalDate(req.Date, models.LoadUserLocation(userId))
+	if err != nil {
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid date format, use YYYY-MM-DD", nil)
+	}
+
+	items, err := h.repo.CreateFromTemplate(userId, templateId, planDate, models.NutritionCategory(req.Category))
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Meal template not found", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[AddMealPlanTemplate] Failed to plan meal template")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to plan meal template", nil)
+	}
+
+	return helper.JsonResponse(c, http.StatusCreated, items)
+}
+
+func (h *MealPlanHandlers) UpdateMealPlanItem(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	planItemId, err := strconv.Atoi(c.Param("plan_item_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[UpdateMealPlanItem] Invalid plan item ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid plan item ID", nil)
+	}
+
+	req, ok := validator.GetValidatedRequest(c).(*validator.MealPlanItemRequest)
+	if !ok {
+		Logger.Error().Msg("[UpdateMealPlanItem] Failed to cast validated request to MealPlanItemRequest")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	item, err := mealPlanItemFromRequest(userId, req)
+	if err != nil {
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid date format, use YYYY-MM-DD", nil)
+	}
+	item.PlanItemId = planItemId
+
+	err = h.repo.Update(item)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Meal plan item not found or already checked off", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[UpdateMealPlanItem] Failed to update meal plan item")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to update meal plan item", nil)
+	}
+
+	return helper.JsonResponse(c, http.StatusOK, item)
+}
+
+func (h *MealPlanHandlers) DeleteMealPlanItem(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	planItemId, err := strconv.Atoi(c.Param("plan_item_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[DeleteMealPlanItem] Invalid plan item ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid plan item ID", nil)
+	}
+
+	if err = h.repo.Delete(userId, planItemId); err != nil {
+		Logger.Error().Err(err).Msg("[DeleteMealPlanItem] Failed to delete meal plan item")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete meal plan item", nil)
+	}
+
+	return helper.JsonResponse(c, http.StatusOK, map[string]string{"message": "Meal plan item deleted successfully"})
+}
+
+// CheckOffMealPlanItem logs a planned item as eaten
+func (h *MealPlanHandlers) CheckOffMealPlanItem(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	planItemId, err := strconv.Atoi(c.Param("plan_item_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[CheckOffMealPlanItem] Invalid plan item ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid plan item ID", nil)
+	}
+
+	intake, err := h.repo.CheckOff(userId, planItemId, models.LoadUserLocation(userId))
+	switch {
+	case err == sql.ErrNoRows:
+		return helper.ErrorResponse(c, http.StatusNotFound, "Meal plan item not found", nil)
+	case errors.Is(err, models.ErrMealPlanItemChecked):
+		return helper.ErrorResponse(c, http.StatusConflict, "Meal plan item is already checked off", nil)
+	case errors.Is(err, models.ErrMealPlanItemInFuture):
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Meal plan item is planned for a future date", nil)
+	case err != nil:
+		Logger.Error().Err(err).Msg("[CheckOffMealPlanItem] Failed to check off meal plan item")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to check off meal plan item", nil)
+	}
+
+	Logger.Info().Msgf("[CheckOffMealPlanItem] Logged meal plan item %d as food %d for user %d", planItemId, intake.FoodId, userId)
+	return helper.JsonResponse(c, http.StatusCreated, intake)
+}
+
+// GetWeeklyMealPlanAdherence compares the plan with logged intake for the Monday-based week containing :date
+func (h *MealPlanHandlers) GetWeeklyMealPlanAdherence(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	loc := models.LoadUserLocation(userId)
+	day, err := parseLocalDateParam(c, "date", loc)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetWeeklyMealPlanAdherence] Invalid date parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid date format, use YYYY-MM-DD", nil)
+	}
+	start := startOfWeek(day)
+	from := start.Format(models.SQLDateFormat)
+	to := start.AddDate(0, 0, 6).Format(models.SQLDateFormat)
+
+	items, err := h.repo.FindByDateRange(userId, from, to)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetWeeklyMealPlanAdherence] Failed to get meal plan")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get meal plan adherence", nil)
+	}
+
+	intakes, err := h.nutritionRepo.FindUserIntakeByDateRange(userId, from, to)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetWeeklyMealPlanAdherence] Failed to get nutrition intake")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get meal plan adherence", nil)
+	}
+
+	return helper.JsonResponse(c, http.StatusOK, models.BuildMealPlanAdherenceReport(start, items, intakes))
+}
+
+// mealPlanItemFromRequest maps a validated meal plan request
+func mealPlanItemFromRequest(userId int, req *validator.MealPlanItemRequest) (*models.MealPlanItem, error) {
+	planDate, err := parseLocalDate(req.Date, models.LoadUserLocation(userId))
+	if err != nil {
+		return nil, err
+	}
+
+	food := nutritionTrackerFromRequest(userId, &req.NutritionRequest)
+	return &models.MealPlanItem{
+		UserId:           userId,
+		PlanDate:         planDate,
+		Category:         food.Category,
+		Name:             food.Name,
+		Fat:              food.Fat,
+		Protein:          food.Protein,
+		Carbohydrate:     food.Carbohydrate,
+		Caloric:          food.Caloric,
+		Fiber:            food.Fiber,
+		Sugar:            food.Sugar,
+		SaturatedFat:     food.SaturatedFat,
+		Sodium:           food.Sodium,
+		Cholesterol:      food.Cholesterol,
+		Potassium:        food.Potassium,
+		Micronutrients:   food.Micronutrients,
+		IngredientAmount: ingredientAmountFromRequest(req.IngredientAmountRequest),
+	}, nil
+}
+
+// resolveMealPlanRange parses an optional from/to pair of local dates. from
+// defaults to today and to to six days after from; the range may span at most
+// maxMealPlanRangeDays days. Errors are safe to show to the user.
+func resolveMealPlanRange(fromStr, toStr string, loc *time.Location) (time.Time, time.Time, error) {
+	from, err := parseLocalDate(fromStr, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("Invalid date format, use YYYY-MM-DD")
+	}
+	to := from.AddDate(0, 0, 6)
+	if toStr != "" {
+		if to, err = parseLocalDate(toStr, loc); err != nil {
+			return time.Time{}, time.Time{}, errors.New("Invalid date format, use YYYY-MM-DD")
+		}
+	}
+	if to.Before(from) || to.After(from.AddDate(0, 0, maxMealPlanRangeDays-1)) {
+		return time.Time{}, time.Time{}, fmt.Errorf("Invalid range, to must be within %d days after from", maxMealPlanRangeDays)
+	}
+	return from, to, nil
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/WahyuSiddarta/be_saham_go/helper"
+	"github.com/WahyuSiddarta/be_saham_go/models"
+	"github.com/WahyuSiddarta/be_saham_go/validator"
+	"github.com/labstack/echo/v4"
+)
+
+// MealTemplateHandlers contains all meal template handlers
+type MealTemplateHandlers struct {
+	repo        models.MealTemplateRepository
+	fastingRepo models.FastingRepository
+}
+
+// NewMealTemplateHandlers creates a new instance of meal template handlers
+func NewMealTemplateHandlers(repo models.MealTemplateRepository, fastingRepo models.FastingRepository) *MealTemplateHandlers {
+	return &MealTemplateHandlers{repo: repo, fastingRepo: fastingRepo}
+}
+
+func (h *MealTemplateHandlers) GetMealTemplates(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	templates, err := h.repo.GetByUserId(userId)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetMealTemplates] Failed to get meal templates")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get meal templates", nil)
+	}
+	if templates == nil {
+		templates = []models.MealTemplate{}
+	}
+
+	models.AttachMealTemplateUnits(templates, validator.GetRequestUnits(c))
+	return helper.JsonResponse(c, http.StatusOK, templates)
+}
+
+func (h *MealTemplateHandlers) AddMealTemplate(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	// Get validated request from middleware
+	validatedRequest := validator.GetValidatedRequest(c)
+	if validatedRequest == nil {
+		Logger.Error().Msg("[AddMealTemplate] No validated request found in context")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	req, ok := validatedRequest.(*validator.MealTemplateRequest)
+	if !ok {
+		Logger.Error().Msg("[AddMealTemplate] Failed to cast validated request to MealTemplateRequest")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	template := mealTemplateFromRequest(userId, req)
+	err := h.repo.Create(template)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[AddMealTemplate] Failed to add meal template")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to add meal template", nil)
+	}
+
+	Logger.Info().Msgf("[AddMealTemplate] Added meal template %d for user %d", template.TemplateId, userId)
+	units := validator.GetRequestUnits(c)
+	template.Units = &units
+	return helper.JsonResponse(c, http.StatusCreated, template)
+}
+
+func (h *MealTemplateHandlers) UpdateMealTemplate(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	templateId, err := strconv.Atoi(c.Param("template_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[UpdateMealTemplate] Invalid template ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid template ID", nil)
+	}
+
+	// Get validated request from middleware
+	validatedRequest := validator.GetValidatedRequest(c)
+	if validatedRequest == nil {
+		Logger.Error().Msg("[UpdateMealTemplate] No validated request found in context")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	req, ok := validatedRequest.(*validator.MealTemplateRequest)
+	if !ok {
+		Logger.Error().Msg("[UpdateMealTemplate] Failed to cast validated request to MealTemplateRequest")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	template := mealTemplateFromRequest(userId, req)
+	template.TemplateId = templateId
+	err = h.repo.Update(template)
+	if errors.Is(err, sql.ErrNoRows) {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Meal template not found", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[UpdateMealTemplate] Failed to update meal template")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to update meal template", nil)
+	}
+
+	units := validator.GetRequestUnits(c)
+	template.Units = &units
+	return helper.JsonResponse(c, http.StatusOK, template)
+}
+
+func (h *MealTemplateHandlers) DeleteMealTemplate(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	templateId, err := strconv.Atoi(c.Param("template_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[DeleteMealTemplate] Invalid template ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid template ID", nil)
+	}
+
+	err = h.repo.Delete(userId, templateId)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[DeleteMealTemplate] Failed to delete meal template")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete meal template", nil)
+	}
+
+	return helper.JsonResponse(c, http.StatusOK, map[string]string{"message": "Meal template deleted successfully"})
+}
+
+func (h *MealTemplateHandlers) ApplyMealTemplate(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	templateId, err := strconv.Atoi(c.Param("template_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[ApplyMealTemplate] Invalid template ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid template ID", nil)
+	}
+
+	req, ok := validator.GetValidatedRequest(c).(*validator.ApplyMealTemplateRequest)
+	if !ok {
+		Logger.Error().Msg("[ApplyMealTemplate] Failed to cast validated request to ApplyMealTemplateRequest")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	day, err := parseLocalDate(req.Date, models.LoadUserLocation(userId))
+	if err != nil {
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid date format, use YYYY-MM-DD", nil)
+	}
+
+	intakes, err := h.repo.Apply(userId, templateId, day)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Meal template not found", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[ApplyMealTemplate] Failed to apply meal template")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to apply meal template", nil)
+	}
+
+	Logger.Info().Msgf("[ApplyMealTemplate] Logged %d items of meal template %d for user %d", len(intakes), templateId, userId)
+	models.AttachIntakeUnits(intakes, validator.GetRequestUnits(c))
+	models.AttachFastingWarnings(intakes, activeFast(h.fastingRepo, userId, "ApplyMealTemplate"))
+	return helper.JsonResponse(c, http.StatusCreated, intakes)
+}
+
+// mealTemplateFromRequest maps a validated meal template request
+func mealTemplateFromRequest(userId int, req *validator.MealTemplateRequest) *models.MealTemplate {
+	template := &models.MealTemplate{
+		UserId:   userId,
+		Name:     req.Name,
+		Category: models.NutritionCategory(req.Category),
+		Items:    make([]models.MealTemplateItem, 0, len(req.Items)),
+	}
+	for _, item := range req.Items {
+		template.Items = append(template.Items, models.MealTemplateItem{
+			Name:             item.Name,
+			Fat:              item.Fat,
+			Protein:          item.Protein,
+			Carbohydrate:     item.Carbohydrate,
+			Caloric:          item.Caloric,
+			Fiber:            item.Fiber,
+			Sugar:            item.Sugar,
+			SaturatedFat:     item.SaturatedFat,
+			Sodium:           item.Sodium,
+			Cholesterol:      item.Cholesterol,
+			Potassium:        item.Potassium,
+			Micronutrients:   item.Micronutrients,
+			IngredientAmount: ingredientAmountFromRequest(item.IngredientAmountRequest),
+		})
+	}
+	return template
+}
+
+// ingredientAmountFromRequest maps the optional grocery fields of a food
+func ingredientAmountFromRequest(req validator.IngredientAmountRequest) models.IngredientAmount {
+	amount := models.IngredientAmount{Quantity: req.Quantity}
+	if req.Unit != nil {
+		unit := models.GroceryUnit(*req.Unit)
+		amount.Unit = &unit
+	}
+	if req.FoodGroup != nil {
+		group := models.FoodGroup(*req.FoodGroup)
+		amount.FoodGroup = &group
+	}
+	return amount
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WahyuSiddarta/be_saham_go/models"
+	"github.com/WahyuSiddarta/be_saham_go/validator"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+)
+
+// unitsUserRepo is a user repository that only knows the unit preference
+type unitsUserRepo struct {
+	models.UserRepository
+	units models.UnitPreference
+}
+
+func (r unitsUserRepo) FindUnitPreference(int) (models.UnitPreference, error) {
+	return r.units, nil
+}
+
+// fakeMealTemplateRepo keeps the last created template and logs every item of it on apply
+type fakeMealTemplateRepo struct {
+	models.MealTemplateRepository
+	created *models.MealTemplate
+}
+
+func (r *fakeMealTemplateRepo) Create(template *models.MealTemplate) error {
+	template.TemplateId = 1
+	r.created = template
+	return nil
+}
+
+func (r *fakeMealTemplateRepo) Apply(userId, templateId int, date time.Time) ([]models.NutritionTracker, error) {
+	intakes := make([]models.NutritionTracker, 0, len(r.created.Items))
+	for _, item := range r.created.Items {
+		intakes = append(intakes, item.ToIntake(userId, r.created.Category, date))
+	}
+	return intakes, nil
+}
+
+// serveUnits sends one request to a handler routed at route, behind
+// ResolveUnits for a user reading and writing values in units
+func serveUnits(units models.UnitPreference, method, route, target, body string, handler echo.HandlerFunc, middleware ...echo.MiddlewareFunc) *httptest.ResponseRecorder {
+	e := echo.New()
+	middleware = append([]echo.MiddlewareFunc{validator.ResolveUnits(unitsUserRepo{units: units})}, middleware...)
+	e.Add(method, route, handler, middleware...)
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMealTemplateUnits(t *testing.T) {
+	nop := zerolog.Nop()
+	Logger = &nop
+	kilojoules := models.MetricUnits()
+	kilojoules.Energy = models.UnitKj
+
+	// 10 g fat, 20 g protein and 30 g carbohydrate are 290 kcal, 1213.36 kJ
+	template := func(caloric string) string {
+		return `{"name":"Oats","category":"breakfast","items":[{"name":"Oats","fat":10,"protein":20,"carbohydrate":30,"caloric":` + caloric + `}]}`
+	}
+
+	tests := []struct {
+		name        string
+		units       models.UnitPreference
+		body        string
+		wantStatus  int
+		wantStored  float64
+		wantCaloric float64
+	}{
+		{"kilojoules", kilojoules, template("1213.36"), http.StatusCreated, 290, 1213.36},
+		{"metric", models.MetricUnits(), template("290"), http.StatusCreated, 290, 290},
+		{"kilocalories sent as kilojoules", kilojoules, template("290"), http.StatusBadRequest, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeMealTemplateRepo{}
+			h := NewMealTemplateHandlers(repo, fakeFastingRepo{})
+
+			rec := serveUnits(tt.units, http.MethodPost, "/templates", "/templates", tt.body, h.AddMealTemplate,
+				validator.ValidateRequest(&validator.MealTemplateRequest{}))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("POST /templates = %d %s, want %d", rec.Code, rec.Body, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+			if got := repo.created.Items[0].Caloric; got != tt.wantStored {
+				t.Errorf("stored caloric = %v, want %v", got, tt.wantStored)
+			}
+			var created struct {
+				Data struct {
+					Items []struct {
+						Caloric float64 `json:"caloric"`
+					} `json:"items"`
+					Units models.UnitPreference `json:"units"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+				t.Fatalf("decode %s: %v", rec.Body, err)
+			}
+			if got := created.Data.Items[0].Caloric; got != tt.wantCaloric {
+				t.Errorf("created caloric = %v, want %v", got, tt.wantCaloric)
+			}
+			if created.Data.Units != tt.units {
+				t.Errorf("created units = %+v, want %+v", created.Data.Units, tt.units)
+			}
+
+			rec = serveUnits(tt.units, http.MethodPost, "/templates/:template_id/apply", "/templates/1/apply", `{"date":"2026-03-02"}`, h.ApplyMealTemplate,
+				validator.ValidateRequest(&validator.ApplyMealTemplateRequest{}))
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("POST /templates/1/apply = %d %s", rec.Code, rec.Body)
+			}
+			var applied struct {
+				Data []struct {
+					Caloric float64               `json:"caloric"`
+					Units   models.UnitPreference `json:"units"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &applied); err != nil {
+				t.Fatalf("decode %s: %v", rec.Body, err)
+			}
+			if got := applied.Data[0].Caloric; got != tt.wantCaloric {
+				t.Errorf("applied caloric = %v, want %v", got, tt.wantCaloric)
+			}
+			if applied.Data[0].Units != tt.units {
+				t.Errorf("applied units = %+v, want %+v", applied.Data[0].Units, tt.units)
+			}
+		})
+	}
+}
@@ -2,8 +2,13 @@ package api
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/WahyuSiddarta/be_saham_go/config"
 	"github.com/WahyuSiddarta/be_saham_go/helper"
@@ -14,12 +19,13 @@ import (
 
 // AuthHandlers contains all authentication-related handlers
 type BodyMeasurementHandlers struct {
-	repo models.BodyMeasurementRepository
+	repo     models.BodyMeasurementRepository
+	userRepo models.UserRepository
 }
 
 // NewBodyMeasurementHandlers creates a new instance of body measurement handlers
-func NewBodyMeasurementHandlers(repo models.BodyMeasurementRepository) *BodyMeasurementHandlers {
-	return &BodyMeasurementHandlers{repo: repo}
+func NewBodyMeasurementHandlers(repo models.BodyMeasurementRepository, userRepo models.UserRepository) *BodyMeasurementHandlers {
+	return &BodyMeasurementHandlers{repo: repo, userRepo: userRepo}
 }
 
 // / Daily Nutrition Intake Handlers
@@ -44,6 +50,14 @@ func (h *BodyMeasurementHandlers) GetBodyMeasurements(c echo.Context) error {
 		hasNext = true
 		userMeasurements = userMeasurements[:limit]
 	}
+	models.AttachMeasurementUnits(userMeasurements, validator.GetRequestUnits(c))
+
+	profile, err := h.userRepo.FindUserProfile(userId)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetBodyMeasurements] Failed to get user profile")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get body measurements", nil)
+	}
+	models.AttachDerivedMetrics(userMeasurements, profile)
 
 	response := map[string]interface{}{
 		"measurements": userMeasurements,
@@ -66,36 +80,33 @@ func (h *BodyMeasurementHandlers) AddBodyMeasurement(c echo.Context) error {
 
 	measurementRequest := validatedRequest.(*validator.BodyMeasurementCreateRequest)
 
-	var viceralFat, fatPercentage, nickCm, waistCm *float64
-	if measurementRequest.ViceralFat != 0 {
-		viceralFat = &measurementRequest.ViceralFat
-	}
-	if measurementRequest.FatPercentage != 0 {
-		fatPercentage = &measurementRequest.FatPercentage
-	}
-	if measurementRequest.NickCm != 0 {
-		nickCm = &measurementRequest.NickCm
-	}
-	if measurementRequest.WaistCm != 0 {
-		waistCm = &measurementRequest.WaistCm
-	}
+	newMeasurement := bodyMeasurementFromRequest(measurementRequest)
+	newMeasurement.MeasuredAt = time.Now()
 
-	newMeasurement := models.BodyMeasurement{
-		Bodyweight:    measurementRequest.Bodyweight,
-		ViceralFat:    viceralFat,
-		FatPercentage: fatPercentage,
-		NickCm:        nickCm,
-		WaistCm:       waistCm,
+	warnings, err := h.checkMeasurement(userId, 0, *newMeasurement)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[AddBodyMeasurement] Failed to get recent body measurements")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to add body measurement", nil)
 	}
+	units := validator.GetRequestUnits(c)
+	if len(warnings) > 0 && !measurementConfirmed(c, measurementRequest) {
+		return implausibleMeasurementResponse(c, warnings, units)
+	}
+	newMeasurement.Flagged, newMeasurement.FlagReasons = len(warnings) > 0, warnings
 
-	err := h.repo.Create(userId, newMeasurement)
+	err = h.repo.Create(userId, *newMeasurement)
 	if err != nil {
 		Logger.Error().Err(err).Msg("[AddBodyMeasurement] Failed to add body measurement")
 		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to add body measurement", nil)
 	}
 
 	Logger.Info().Msgf("[AddBodyMeasurement] Added new body measurement for user %d", userId)
-	return helper.JsonResponse(c, http.StatusCreated, map[string]string{"message": "Body measurement added successfully"})
+	return helper.JsonResponse(c, http.StatusCreated, map[string]interface{}{
+		"message":  "Body measurement added successfully",
+		"derived":  h.deriveMetrics(userId, *newMeasurement).InUnits(units),
+		"flagged":  newMeasurement.Flagged,
+		"warnings": warnings.InUnits(units),
+	})
 }
 
 func (h *BodyMeasurementHandlers) UpdateBodyMeasurement(c echo.Context) error {
@@ -118,27 +129,28 @@ func (h *BodyMeasurementHandlers) UpdateBodyMeasurement(c echo.Context) error {
 
 	measurementRequest := validatedRequest.(*validator.BodyMeasurementCreateRequest)
 
-	var viceralFat, fatPercentage, nickCm, waistCm *float64
-	if measurementRequest.ViceralFat != 0 {
-		viceralFat = &measurementRequest.ViceralFat
-	}
-	if measurementRequest.FatPercentage != 0 {
-		fatPercentage = &measurementRequest.FatPercentage
+	existing, err := h.repo.FindById(userId, measurementId)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Body measurement not found", nil)
 	}
-	if measurementRequest.NickCm != 0 {
-		nickCm = &measurementRequest.NickCm
-	}
-	if measurementRequest.WaistCm != 0 {
-		waistCm = &measurementRequest.WaistCm
+	if err != nil {
+		Logger.Error().Err(err).Msg("[UpdateBodyMeasurement] Failed to get body measurement")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to update body measurement", nil)
 	}
 
-	updatedMeasurement := &models.BodyMeasurement{
-		Bodyweight:    measurementRequest.Bodyweight,
-		ViceralFat:    viceralFat,
-		FatPercentage: fatPercentage,
-		NickCm:        nickCm,
-		WaistCm:       waistCm,
+	updatedMeasurement := bodyMeasurementFromRequest(measurementRequest)
+	updatedMeasurement.MeasuredAt = existing.MeasuredAt
+
+	warnings, err := h.checkMeasurement(userId, measurementId, *updatedMeasurement)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[UpdateBodyMeasurement] Failed to get recent body measurements")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to update body measurement", nil)
 	}
+	units := validator.GetRequestUnits(c)
+	if len(warnings) > 0 && !measurementConfirmed(c, measurementRequest) {
+		return implausibleMeasurementResponse(c, warnings, units)
+	}
+	updatedMeasurement.Flagged, updatedMeasurement.FlagReasons = len(warnings) > 0, warnings
 
 	err = h.repo.Update(userId, measurementId, updatedMeasurement)
 	if err != nil {
@@ -147,7 +159,12 @@ func (h *BodyMeasurementHandlers) UpdateBodyMeasurement(c echo.Context) error {
 	}
 
 	Logger.Info().Msgf("[UpdateBodyMeasurement] Updated body measurement %d for user %d", measurementId, userId)
-	return helper.JsonResponse(c, http.StatusOK, map[string]string{"message": "Body measurement updated successfully"})
+	return helper.JsonResponse(c, http.StatusOK, map[string]interface{}{
+		"message":  "Body measurement updated successfully",
+		"derived":  h.deriveMetrics(userId, *updatedMeasurement).InUnits(units),
+		"flagged":  updatedMeasurement.Flagged,
+		"warnings": warnings.InUnits(units),
+	})
 }
 
 func (h *BodyMeasurementHandlers) DeleteBodyMeasurement(c echo.Context) error {
@@ -170,3 +187,320 @@ func (h *BodyMeasurementHandlers) DeleteBodyMeasurement(c echo.Context) error {
 	Logger.Info().Msgf("[DeleteBodyMeasurement] Deleted body measurement %d for user %d", measurementId, userId)
 	return helper.JsonResponse(c, http.StatusOK, map[string]string{"message": "Body measurement deleted successfully"})
 }
+
+// deriveMetrics computes the derived metrics of a just saved measurement. The
+// measurement is already stored, so a failing profile lookup only drops the
+// profile-based metrics instead of failing the request.
+func (h *BodyMeasurementHandlers) deriveMetrics(userId int, measurement models.BodyMeasurement) models.DerivedBodyMetrics {
+	profile, err := h.userRepo.FindUserProfile(userId)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Warn().Err(err).Msg("[deriveMetrics] Failed to get user profile")
+		profile = nil
+	}
+	return models.DeriveBodyMetrics(measurement, profile)
+}
+
+// checkMeasurement runs the plausibility checks of a measurement against the
+// user's measurements taken before it, leaving out excludeId
+func (h *BodyMeasurementHandlers) checkMeasurement(userId, excludeId int, measurement models.BodyMeasurement) (models.MeasurementWarnings, error) {
+	history, err := h.repo.FindRecent(userId, excludeId, measurement.MeasuredAt, models.MeasurementHistoryLimit)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return models.CheckMeasurement(measurement, history), nil
+}
+
+// measurementConfirmed reports whether the client confirmed an implausible
+// measurement, in the body or with ?confirm=true
+func measurementConfirmed(c echo.Context, req *validator.BodyMeasurementCreateRequest) bool {
+	confirmed, _ := strconv.ParseBool(c.QueryParam("confirm"))
+	return req.Confirm || confirmed
+}
+
+// implausibleMeasurementResponse refuses a measurement that failed the
+// plausibility checks until it is sent again with confirm
+func implausibleMeasurementResponse(c echo.Context, warnings models.MeasurementWarnings, units models.UnitPreference) error {
+	return helper.ErrorResponse(c, http.StatusUnprocessableEntity, "Measurement looks implausible, send it again with confirm=true to save it anyway", map[string]interface{}{
+		"confirm_required": true,
+		"warnings":         warnings.InUnits(units),
+	})
+}
+
+// bodyMeasurementFromRequest maps a validated body measurement request; zero optional values are stored as NULL
+func bodyMeasurementFromRequest(measurementRequest *validator.BodyMeasurementCreateRequest) *models.BodyMeasurement {
+	var viceralFat, fatPercentage, nickCm, waistCm *float64
+	if measurementRequest.ViceralFat != 0 {
+		viceralFat = &measurementRequest.ViceralFat
+	}
+	if measurementRequest.FatPercentage != 0 {
+		fatPercentage = &measurementRequest.FatPercentage
+	}
+	if measurementRequest.NickCm != 0 {
+		nickCm = &measurementRequest.NickCm
+	}
+	if measurementRequest.WaistCm != 0 {
+		waistCm = &measurementRequest.WaistCm
+	}
+
+	var metabolicAge *int
+	if measurementRequest.MetabolicAge != 0 {
+		metabolicAge = &measurementRequest.MetabolicAge
+	}
+
+	return &models.BodyMeasurement{
+		Bodyweight:          measurementRequest.Bodyweight,
+		ViceralFat:          viceralFat,
+		FatPercentage:       fatPercentage,
+		NickCm:              nickCm,
+		WaistCm:             waistCm,
+		HipCm:               optionalMeasurement(measurementRequest.HipCm),
+		ChestCm:             optionalMeasurement(measurementRequest.ChestCm),
+		ArmLeftCm:           optionalMeasurement(measurementRequest.ArmLeftCm),
+		ArmRightCm:          optionalMeasurement(measurementRequest.ArmRightCm),
+		ThighLeftCm:         optionalMeasurement(measurementRequest.ThighLeftCm),
+		ThighRightCm:        optionalMeasurement(measurementRequest.ThighRightCm),
+		CalfCm:              optionalMeasurement(measurementRequest.CalfCm),
+		MuscleMassKg:        optionalMeasurement(measurementRequest.MuscleMassKg),
+		BoneMassKg:          optionalMeasurement(measurementRequest.BoneMassKg),
+		BodyWaterPercentage: optionalMeasurement(measurementRequest.BodyWaterPercentage),
+		ScaleBmr:            optionalMeasurement(measurementRequest.ScaleBmr),
+		MetabolicAge:        metabolicAge,
+	}
+}
+
+// optionalMeasurement maps an omitted (zero) measurement to nil
+func optionalMeasurement(value float64) *float64 {
+	if value == 0 {
+		return nil
+	}
+	return &value
+}
+
+// BulkBodyMeasurements creates, updates and deletes many body measurements at once
+func (h *BodyMeasurementHandlers) BulkBodyMeasurements(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	units := validator.GetRequestUnits(c)
+	decode := func(raw json.RawMessage, loggedAt time.Time) (models.BodyMeasurement, []validator.ValidationError) {
+		var req validator.BodyMeasurementCreateRequest
+		if errs := validator.ValidateBulkItemInUnits(raw, units, &req); len(errs) > 0 {
+			return models.BodyMeasurement{}, errs
+		}
+		measurement := bodyMeasurementFromRequest(&req)
+		measurement.MeasuredAt = loggedAt
+
+		// A batch is checked against the physiological bounds only, its items
+		// may be in any order and have no single previous measurement
+		warnings := models.CheckMeasurement(*measurement, nil)
+		if len(warnings) > 0 && !req.Confirm {
+			errs := make([]validator.ValidationError, len(warnings))
+			for i, warning := range warnings.InUnits(units) {
+				errs[i] = validator.ValidationError{Field: warning.Field, Message: warning.Message + ", set confirm to save it anyway", Tag: warning.Reason}
+			}
+			return models.BodyMeasurement{}, errs
+		}
+		measurement.Flagged, measurement.FlagReasons = len(warnings) > 0, warnings
+		return *measurement, nil
+	}
+	apply := func(mode models.BulkMode, ops []models.BulkOperation[models.BodyMeasurement]) ([]models.BulkOutcome, error) {
+		return h.repo.Bulk(userId, mode, ops)
+	}
+
+	return handleBulk(c, "BulkBodyMeasurements", decode, apply, nil)
+}
+
+// defaultWeightTrendDays is the weight trend window when from is not given
+const defaultWeightTrendDays = 90
+
+// GetWeightTrend returns the smoothed bodyweight with its weekly rate, the
+// projected date to reach the bodyweight target and flat stretches of the trend
+func (h *BodyMeasurementHandlers) GetWeightTrend(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+	req := validator.GetValidatedQuery(c).(*validator.WeightTrendQuery)
+
+	level, err := h.userRepo.FindUserLevel(userId)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetWeightTrend] Failed to get user level")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get weight trend", nil)
+	}
+
+	window, err := resolveChartRange(req.From, req.To, models.LoadUserLocation(userId), chartRangeLimitDays(level))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetWeightTrend] Invalid range")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid range, from must not be after to", nil)
+	}
+	if req.From == "" {
+		if defaultFrom := window.To.AddDate(0, 0, -(defaultWeightTrendDays - 1)); defaultFrom.After(window.From) {
+			window.From = defaultFrom
+		}
+	}
+	plateauWeeks := req.PlateauWeeks
+	if plateauWeeks == 0 {
+		plateauWeeks = models.DefaultPlateauWeeks
+	}
+
+	// Warm the trend up before from so the first reported day is already smoothed
+	start := window.From.AddDate(0, 0, -models.ChartMovingAverageWarmupDays)
+	weights, err := h.repo.GetDailyBodyweight(userId, start.Format(models.SQLDateFormat), window.To.Format(models.SQLDateFormat))
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetWeightTrend] Failed to get daily bodyweight")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get weight trend", nil)
+	}
+
+	target, err := h.userRepo.FindPersonalTarget(userId)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetWeightTrend] Failed to get personal target")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get weight trend", nil)
+	}
+	var targetBodyweight float64
+	if target != nil {
+		targetBodyweight = target.BodyWeight
+	}
+
+	report := models.BuildWeightTrendReport(start, window.From, window.To, weights, targetBodyweight, plateauWeeks)
+	report.AttachUnits(validator.GetRequestUnits(c))
+	return helper.JsonResponse(c, http.StatusOK, report)
+}
+
+// measurementImportMaxBytes caps the size of an uploaded scale export
+const measurementImportMaxBytes = 5 << 20
+
+// ImportBodyMeasurements reads a CSV export of a scale app into body
+// measurements. Rows measured in the same minute as a stored measurement or an
+// earlier row are skipped; with dry_run nothing is stored and the summary
+// shows what would be imported.
+func (h *BodyMeasurementHandlers) ImportBodyMeasurements(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	req, ok := validator.GetValidatedRequest(c).(*validator.BodyMeasurementImportRequest)
+	if !ok {
+		Logger.Error().Msg("[ImportBodyMeasurements] Failed to cast validated request to BodyMeasurementImportRequest")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return helper.ErrorResponse(c, http.StatusBadRequest, "A CSV file is required", nil)
+	}
+	if fileHeader.Size > measurementImportMaxBytes {
+		return helper.ErrorResponse(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("File must be at most %d bytes", measurementImportMaxBytes), nil)
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		Logger.Error().Err(err).Msg("[ImportBodyMeasurements] Failed to open uploaded file")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Failed to read file", nil)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, measurementImportMaxBytes+1))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[ImportBodyMeasurements] Failed to read uploaded file")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Failed to read file", nil)
+	}
+	if len(data) > measurementImportMaxBytes {
+		return helper.ErrorResponse(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("File must be at most %d bytes", measurementImportMaxBytes), nil)
+	}
+
+	units := validator.GetRequestUnits(c)
+	opts := models.MeasurementImportOptions{
+		DateOrder:    req.DateOrder,
+		DateFormat:   req.DateFormat,
+		WeightUnit:   req.WeightUnit,
+		DecimalComma: req.DecimalComma,
+		Location:     models.LoadUserLocation(userId),
+	}
+	if opts.WeightUnit == "" {
+		opts.WeightUnit = units.Weight
+	}
+	switch req.Profile {
+	case "":
+	case models.ImportProfileCustom:
+		var mapping map[string]string
+		if err = json.Unmarshal([]byte(req.Mapping), &mapping); err != nil {
+			return helper.ErrorResponse(c, http.StatusBadRequest, "mapping must be a JSON object of field to column header", nil)
+		}
+		profile, err := models.NewCustomImportProfile(mapping)
+		if err != nil {
+			return helper.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		}
+		opts.Profile = &profile
+	default:
+		profile := models.MeasurementImportProfiles[req.Profile]
+		opts.Profile = &profile
+	}
+
+	imp, err := models.ParseMeasurementCSV(data, opts)
+	if errors.Is(err, models.ErrInvalidImport) {
+		return helper.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[ImportBodyMeasurements] Failed to parse import file")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to import body measurements", nil)
+	}
+
+	if err = h.repo.Import(userId, imp, req.DryRun); err != nil {
+		Logger.Error().Err(err).Msg("[ImportBodyMeasurements] Failed to import body measurements")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to import body measurements", nil)
+	}
+
+	summary := imp.Summarize(req.DryRun)
+	summary.AttachUnits(units)
+	if req.DryRun {
+		return helper.JsonResponse(c, http.StatusOK, summary)
+	}
+	Logger.Info().Msgf("[ImportBodyMeasurements] Imported %d body measurements for user %d", summary.Imported, userId)
+	return helper.JsonResponse(c, http.StatusCreated, summary)
+}
+
+// GetFlaggedBodyMeasurements lists the measurements saved despite failing the
+// plausibility checks that have not been reviewed yet
+func (h *BodyMeasurementHandlers) GetFlaggedBodyMeasurements(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	req := validator.GetValidatedQuery(c).(*validator.BodyMeasurementRequest)
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	limit := config.Get().PaginationDefaultPageSize
+	measurements, err := h.repo.GetFlagged(userId, limit, page)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetFlaggedBodyMeasurements] Failed to get flagged body measurements")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get flagged body measurements", nil)
+	}
+
+	hasNext := false
+	if len(measurements) > limit {
+		hasNext = true
+		measurements = measurements[:limit]
+	}
+	models.AttachMeasurementUnits(measurements, validator.GetRequestUnits(c))
+
+	return helper.JsonResponse(c, http.StatusOK, map[string]interface{}{
+		"measurements": measurements,
+		"nextPage":     hasNext,
+	})
+}
+
+// ReviewBodyMeasurement clears the flag of a measurement the user has checked;
+// to correct it instead, update or delete the measurement
+func (h *BodyMeasurementHandlers) ReviewBodyMeasurement(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	measurementId, err := strconv.Atoi(c.Param("measurement_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[ReviewBodyMeasurement] Invalid measurement ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid measurement ID", nil)
+	}
+
+	err = h.repo.MarkReviewed(userId, measurementId)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Flagged body measurement not found", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[ReviewBodyMeasurement] Failed to mark body measurement as reviewed")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to review body measurement", nil)
+	}
+
+	Logger.Info().Msgf("[ReviewBodyMeasurement] Reviewed body measurement %d for user %d", measurementId, userId)
+	return helper.JsonResponse(c, http.StatusOK, map[string]string{"message": "Body measurement marked as reviewed"})
+}
@@ -2,8 +2,10 @@ package api
 
 import (
 	"database/sql"
+	"encoding/json"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/WahyuSiddarta/be_saham_go/config"
 	"github.com/WahyuSiddarta/be_saham_go/helper"
@@ -14,12 +16,14 @@ import (
 
 // AuthHandlers contains all authentication-related handlers
 type NutritionHandlers struct {
-	repo models.NutritionRepository
+	repo        models.NutritionRepository
+	userRepo    models.UserRepository
+	fastingRepo models.FastingRepository
 }
 
 // NewNutritionHandlers creates a new instance of nutrition handlers
-func NewNutritionHandlers(repo models.NutritionRepository) *NutritionHandlers {
-	return &NutritionHandlers{repo: repo}
+func NewNutritionHandlers(repo models.NutritionRepository, userRepo models.UserRepository, fastingRepo models.FastingRepository) *NutritionHandlers {
+	return &NutritionHandlers{repo: repo, userRepo: userRepo, fastingRepo: fastingRepo}
 }
 
 func (h *NutritionHandlers) GetNutritionAllTime(c echo.Context) error {
@@ -42,6 +46,7 @@ func (h *NutritionHandlers) GetNutritionAllTime(c echo.Context) error {
 		hasNext = true
 		userMeasurements = userMeasurements[:limit]
 	}
+	models.AttachIntakeUnits(userMeasurements, validator.GetRequestUnits(c))
 
 	response := map[string]interface{}{
 		"measurements": userMeasurements,
@@ -54,15 +59,98 @@ func (h *NutritionHandlers) GetNutritionAllTime(c echo.Context) error {
 // / Overview Nutrition Handlers
 func (h *NutritionHandlers) GetNutritionChartData(c echo.Context) error {
 	var userId int = 1 // Replace with actual user ID retrieval logic
+	req := validator.GetValidatedQuery(c).(*validator.NutritionChartQuery)
 
-	chartData, err := h.repo.GetNutritionChartData(userId)
+	level, err := h.userRepo.FindUserLevel(userId)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetNutritionChartData] Failed to get user level")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get nutrition chart data", nil)
+	}
+
+	window, err := resolveChartRange(req.From, req.To, models.LoadUserLocation(userId), chartRangeLimitDays(level))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetNutritionChartData] Invalid chart range")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid chart range, from must not be after to", nil)
+	}
+
+	granularity, metrics := chartGranularityAndMetrics(req.Granularity, req.Metrics, models.DefaultNutritionChartMetrics)
+
+	from := window.From.Format(models.SQLDateFormat)
+	warmupFrom := window.From.AddDate(0, 0, -models.ChartMovingAverageWarmupDays).Format(models.SQLDateFormat)
+	dailyData, err := h.repo.GetNutritionChartData(userId, warmupFrom, window.To.Format(models.SQLDateFormat))
 	if err != nil {
 		Logger.Error().Err(err).Msg("[GetNutritionChartData] Failed to get nutrition chart data")
 		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get nutrition chart data", nil)
 	}
 
+	chart := models.Chart{
+		From:         from,
+		To:           window.To.Format(models.SQLDateFormat),
+		Granularity:  granularity,
+		Metrics:      metrics,
+		MaxRangeDays: window.MaxDays,
+		RangeCapped:  window.Capped,
+		Points:       models.BuildNutritionChartPoints(dailyData, from, granularity, metrics),
+	}
+
 	Logger.Info().Msgf("[GetNutritionChartData] Retrieved nutrition chart data for user %d", userId)
-	return helper.JsonResponse(c, http.StatusOK, chartData)
+	return helper.JsonResponse(c, http.StatusOK, chart)
+}
+
+// / Nutrition Summary Handlers
+func (h *NutritionHandlers) GetDailyNutritionSummary(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	loc := models.LoadUserLocation(userId)
+	day, err := parseLocalDateParam(c, "date", loc)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetDailyNutritionSummary] Invalid date parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid date format, use YYYY-MM-DD", nil)
+	}
+	date := day.Format(models.SQLDateFormat)
+
+	intakes, err := h.repo.FindUserIntakeByDateRange(userId, date, date)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetDailyNutritionSummary] Failed to get nutrition intake")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get nutrition summary", nil)
+	}
+
+	targets, err := h.userRepo.FindTargetTimeline(userId, date, date)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetDailyNutritionSummary] Failed to get personal target")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get nutrition summary", nil)
+	}
+
+	return helper.JsonResponse(c, http.StatusOK, models.BuildDailyNutritionSummary(date, intakes, targets.On(day)))
+}
+
+func (h *NutritionHandlers) GetWeeklyNutritionSummary(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	loc := models.LoadUserLocation(userId)
+	day, err := parseLocalDateParam(c, "date", loc)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetWeeklyNutritionSummary] Invalid date parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid date format, use YYYY-MM-DD", nil)
+	}
+	start := startOfWeek(day)
+	from := start.Format(models.SQLDateFormat)
+	to := start.AddDate(0, 0, 6).Format(models.SQLDateFormat)
+
+	intakes, err := h.repo.FindUserIntakeByDateRange(userId, from, to)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetWeeklyNutritionSummary] Failed to get nutrition intake")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get nutrition summary", nil)
+	}
+
+	targets, err := h.userRepo.FindTargetTimeline(userId, from, to)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetWeeklyNutritionSummary] Failed to get personal target")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get nutrition summary", nil)
+	}
+
+	today, _ := parseLocalDate("", loc)
+	return helper.JsonResponse(c, http.StatusOK, models.BuildWeeklyNutritionSummary(start, today, intakes, targets))
 }
 
 // / Daily Nutrition Intake Handlers
@@ -76,6 +164,7 @@ func (h *NutritionHandlers) GetTodaysNutritionIntake(c echo.Context) error {
 	}
 
 	Logger.Info().Msgf("[GetTodaysNutritionIntake] Retrieved %d nutrition intake records for user %d", len(userIntakes), userId)
+	models.AttachIntakeUnits(userIntakes, validator.GetRequestUnits(c))
 	return helper.JsonResponse(c, http.StatusOK, userIntakes)
 }
 
@@ -94,15 +183,7 @@ func (h *NutritionHandlers) AddNutritionIntake(c echo.Context) error {
 		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
 	}
 
-	nutritionTracker := &models.NutritionTracker{
-		UserId:       userId,
-		Category:     models.NutritionCategory(req.Category),
-		Fat:          req.Fat,
-		Protein:      req.Protein,
-		Carbohydrate: req.Carbohydrate,
-		Caloric:      req.Caloric,
-		Name:         req.Name,
-	}
+	nutritionTracker := nutritionTrackerFromRequest(userId, req)
 
 	err := h.repo.AddTodayIntake(nutritionTracker)
 	if err != nil {
@@ -110,6 +191,9 @@ func (h *NutritionHandlers) AddNutritionIntake(c echo.Context) error {
 		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to add nutrition intake", nil)
 	}
 
+	units := validator.GetRequestUnits(c)
+	nutritionTracker.Units = &units
+	nutritionTracker.FastingWarning = activeFast(h.fastingRepo, userId, "AddNutritionIntake").IntakeWarning(nutritionTracker.CreatedAt)
 	return helper.JsonResponse(c, http.StatusCreated, nutritionTracker)
 }
 
@@ -140,16 +224,8 @@ func (h *NutritionHandlers) UpdateNutritionIntake(c echo.Context) error {
 		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid food_id format", nil)
 	}
 
-	nutritionTracker := &models.NutritionTracker{
-		UserId:       userId,
-		FoodId:       foodIdInt,
-		Category:     models.NutritionCategory(req.Category),
-		Fat:          req.Fat,
-		Protein:      req.Protein,
-		Carbohydrate: req.Carbohydrate,
-		Caloric:      req.Caloric,
-		Name:         req.Name,
-	}
+	nutritionTracker := nutritionTrackerFromRequest(userId, req)
+	nutritionTracker.FoodId = foodIdInt
 
 	err = h.repo.UpdateTodayIntake(nutritionTracker)
 	if err != nil {
@@ -157,6 +233,8 @@ func (h *NutritionHandlers) UpdateNutritionIntake(c echo.Context) error {
 		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to update nutrition intake", nil)
 	}
 
+	units := validator.GetRequestUnits(c)
+	nutritionTracker.Units = &units
 	return helper.JsonResponse(c, http.StatusOK, nutritionTracker)
 }
 
@@ -182,3 +260,115 @@ func (h *NutritionHandlers) DeleteNutritionIntake(c echo.Context) error {
 
 	return helper.JsonResponse(c, http.StatusOK, map[string]string{"message": "Nutrition intake deleted successfully"})
 }
+
+func (h *NutritionHandlers) CopyMeal(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	req, ok := validator.GetValidatedRequest(c).(*validator.CopyMealRequest)
+	if !ok {
+		Logger.Error().Msg("[CopyMeal] Failed to cast validated request to CopyMealRequest")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	toDay, err := parseLocalDate(req.ToDate, models.LoadUserLocation(userId))
+	if err != nil {
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid date format, use YYYY-MM-DD", nil)
+	}
+	toCategory := req.ToCategory
+	if toCategory == "" {
+		toCategory = req.Category
+	}
+
+	copied, err := h.repo.CopyMeal(userId, req.FromDate, models.NutritionCategory(req.Category), toDay.Format(models.SQLDateFormat), models.NutritionCategory(toCategory))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[CopyMeal] Failed to copy meal")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to copy meal", nil)
+	}
+	if len(copied) == 0 {
+		return helper.ErrorResponse(c, http.StatusNotFound, "No food intake found for that meal", nil)
+	}
+
+	Logger.Info().Msgf("[CopyMeal] Copied %d items for user %d", len(copied), userId)
+	models.AttachIntakeUnits(copied, validator.GetRequestUnits(c))
+	models.AttachFastingWarnings(copied, activeFast(h.fastingRepo, userId, "CopyMeal"))
+	return helper.JsonResponse(c, http.StatusCreated, copied)
+}
+
+func (h *NutritionHandlers) GetFrequentFoods(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	req, ok := validator.GetValidatedQuery(c).(*validator.FrequentFoodsQuery)
+	if !ok {
+		Logger.Error().Msg("[GetFrequentFoods] Failed to cast validated query to FrequentFoodsQuery")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid query parameters", nil)
+	}
+
+	days, limit, sortBy := req.Days, req.Limit, req.Sort
+	if days == 0 {
+		days = 90
+	}
+	if limit == 0 {
+		limit = 20
+	}
+	if sortBy == "" {
+		sortBy = "frequent"
+	}
+
+	foods, err := h.repo.FindFrequentFoods(userId, days, limit, sortBy)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetFrequentFoods] Failed to get frequent foods")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get frequent foods", nil)
+	}
+	if foods == nil {
+		foods = []models.FrequentFood{}
+	}
+	units := validator.GetRequestUnits(c)
+	for i := range foods {
+		foods[i].Units = &units
+	}
+
+	return helper.JsonResponse(c, http.StatusOK, foods)
+}
+
+// nutritionTrackerFromRequest maps a validated nutrition request
+func nutritionTrackerFromRequest(userId int, req *validator.NutritionRequest) *models.NutritionTracker {
+	return &models.NutritionTracker{
+		UserId:         userId,
+		Category:       models.NutritionCategory(req.Category),
+		Fat:            req.Fat,
+		Protein:        req.Protein,
+		Carbohydrate:   req.Carbohydrate,
+		Caloric:        req.Caloric,
+		Name:           req.Name,
+		Fiber:          req.Fiber,
+		Sugar:          req.Sugar,
+		SaturatedFat:   req.SaturatedFat,
+		Sodium:         req.Sodium,
+		Cholesterol:    req.Cholesterol,
+		Potassium:      req.Potassium,
+		Micronutrients: req.Micronutrients,
+	}
+}
+
+// BulkNutritionIntake creates, updates and deletes many food intake entries at once
+func (h *NutritionHandlers) BulkNutritionIntake(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	units := validator.GetRequestUnits(c)
+	decode := func(raw json.RawMessage, loggedAt time.Time) (models.NutritionTracker, []validator.ValidationError) {
+		var req validator.NutritionRequest
+		if errs := validator.ValidateBulkItemInUnits(raw, units, &req); len(errs) > 0 {
+			return models.NutritionTracker{}, errs
+		}
+		nutritionTracker := nutritionTrackerFromRequest(userId, &req)
+		nutritionTracker.CreatedAt = loggedAt
+		return *nutritionTracker, nil
+	}
+	apply := func(mode models.BulkMode, ops []models.BulkOperation[models.NutritionTracker]) ([]models.BulkOutcome, error) {
+		return h.repo.Bulk(userId, mode, ops)
+	}
+
+	active := activeFast(h.fastingRepo, userId, "BulkNutritionIntake")
+
+	return handleBulk(c, "BulkNutritionIntake", decode, apply, active.IntakeWarning)
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/WahyuSiddarta/be_saham_go/helper"
+	"github.com/WahyuSiddarta/be_saham_go/models"
+	"github.com/WahyuSiddarta/be_saham_go/validator"
+	"github.com/labstack/echo/v4"
+)
+
+// PersonalRecordHandlers contains all personal record handlers
+type PersonalRecordHandlers struct {
+	repo        models.PersonalRecordRepository
+	catalogRepo models.ExerciseCatalogRepository
+}
+
+// NewPersonalRecordHandlers creates a new instance of personal record handlers
+func NewPersonalRecordHandlers(repo models.PersonalRecordRepository, catalogRepo models.ExerciseCatalogRepository) *PersonalRecordHandlers {
+	return &PersonalRecordHandlers{repo: repo, catalogRepo: catalogRepo}
+}
+
+// GetPersonalRecords returns the standing record of every exercise
+func (h *PersonalRecordHandlers) GetPersonalRecords(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	records, err := h.repo.GetCurrent(userId)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetPersonalRecords] Failed to get personal records")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get personal records", nil)
+	}
+	if records == nil {
+		records = []models.PersonalRecord{}
+	}
+	models.AttachPersonalRecordUnits(records, validator.GetRequestUnits(c))
+
+	return helper.JsonResponse(c, http.StatusOK, map[string]interface{}{
+		"personal_records": records,
+	})
+}
+
+// GetPersonalRecordTimeline returns every record an exercise was improved to, oldest first
+func (h *PersonalRecordHandlers) GetPersonalRecordTimeline(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	req := validator.GetValidatedQuery(c).(*validator.PersonalRecordTimelineQuery)
+	exercise := models.PersonalRecordExercise{Name: req.Name}
+	if req.CatalogId != 0 {
+		entry, err := h.catalogRepo.FindById(req.CatalogId)
+		if err == sql.ErrNoRows {
+			return helper.ErrorResponse(c, http.StatusNotFound, "Catalog exercise not found", nil)
+		}
+		if err != nil {
+			Logger.Error().Err(err).Msg("[GetPersonalRecordTimeline] Failed to get catalog exercise")
+			return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get personal record timeline", nil)
+		}
+		exercise = models.PersonalRecordExercise{CatalogId: &entry.CatalogId, Name: entry.Name}
+	}
+
+	records, err := h.repo.GetTimeline(userId, exercise)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetPersonalRecordTimeline] Failed to get personal record timeline")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get personal record timeline", nil)
+	}
+	if records == nil {
+		records = []models.PersonalRecord{}
+	}
+	models.AttachPersonalRecordUnits(records, validator.GetRequestUnits(c))
+
+	return helper.JsonResponse(c, http.StatusOK, map[string]interface{}{
+		"exercise": exercise,
+		"timeline": records,
+	})
+}
+
+// RecomputePersonalRecords rebuilds every personal record of the user from
+// their workouts and exercise records
+func (h *PersonalRecordHandlers) RecomputePersonalRecords(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	count, err := h.repo.RecomputeAll(userId)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[RecomputePersonalRecords] Failed to recompute personal records")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to recompute personal records", nil)
+	}
+
+	Logger.Info().Msgf("[RecomputePersonalRecords] Recomputed %d personal records for user %d", count, userId)
+	return helper.JsonResponse(c, http.StatusOK, map[string]interface{}{
+		"message": "Personal records recomputed successfully",
+		"count":   count,
+	})
+}
+
+// refreshPersonalRecords recomputes the records of exercises after one of
+// their workouts or exercise records changed. The change is already saved, so
+// a failure is only logged; the recompute endpoint repairs the records later.
+func refreshPersonalRecords(repo models.PersonalRecordRepository, handlerName string, userId int, exercises []models.PersonalRecordExercise) []models.PersonalRecord {
+	if len(exercises) == 0 {
+		return []models.PersonalRecord{}
+	}
+	records, err := repo.Recompute(userId, exercises)
+	if err != nil {
+		Logger.Warn().Err(err).Msgf("[%s] Failed to recompute personal records for user %d", handlerName, userId)
+		return nil
+	}
+	return records
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/WahyuSiddarta/be_saham_go/helper"
+	"github.com/WahyuSiddarta/be_saham_go/models"
+	"github.com/WahyuSiddarta/be_saham_go/validator"
+	"github.com/labstack/echo/v4"
+)
+
+// ProfileHandlers contains the user profile and target recommendation handlers
+type ProfileHandlers struct {
+	userRepo        models.UserRepository
+	measurementRepo models.BodyMeasurementRepository
+}
+
+// NewProfileHandlers creates a new instance of profile handlers
+func NewProfileHandlers(userRepo models.UserRepository, measurementRepo models.BodyMeasurementRepository) *ProfileHandlers {
+	return &ProfileHandlers{userRepo: userRepo, measurementRepo: measurementRepo}
+}
+
+// GetUserProfile returns the profile, with a maintain goal when none has been saved yet
+func (h *ProfileHandlers) GetUserProfile(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	profile, err := h.userRepo.FindUserProfile(userId)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetUserProfile] Failed to get user profile")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get user profile", nil)
+	}
+	units := validator.GetRequestUnits(c)
+	profile.Units = &units
+
+	return helper.JsonResponse(c, http.StatusOK, profile)
+}
+
+func (h *ProfileHandlers) UpdateUserProfile(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	req, ok := validator.GetValidatedRequest(c).(*validator.UserProfileRequest)
+	if !ok {
+		Logger.Error().Msg("[UpdateUserProfile] Failed to cast validated request to UserProfileRequest")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	profile := &models.UserProfile{
+		UserId:     userId,
+		HeightCm:   req.HeightCm,
+		Goal:       models.WeightGoal(req.Goal),
+		GoalRateKg: req.GoalRateKg,
+	}
+	if profile.Goal == models.WeightGoalMaintain {
+		profile.GoalRateKg = 0
+	}
+	if req.Sex != nil {
+		sex := models.Sex(*req.Sex)
+		profile.Sex = &sex
+	}
+	if req.ActivityLevel != nil {
+		level := models.ActivityLevel(*req.ActivityLevel)
+		profile.ActivityLevel = &level
+	}
+	if req.BirthDate != nil {
+		birthDate, err := time.Parse(models.SQLDateFormat, *req.BirthDate)
+		if err != nil || !birthDate.Before(time.Now()) {
+			return helper.ErrorResponse(c, http.StatusBadRequest, "Birth date must be a past date in YYYY-MM-DD format", nil)
+		}
+		profile.BirthDate = &birthDate
+	}
+
+	if err := h.userRepo.UpsertUserProfile(profile); err != nil {
+		Logger.Error().Err(err).Msg("[UpdateUserProfile] Failed to update user profile")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to update user profile", nil)
+	}
+	units := validator.GetRequestUnits(c)
+	profile.Units = &units
+
+	return helper.JsonResponse(c, http.StatusOK, profile)
+}
+
+// GetTargetRecommendation suggests nutrition and weekly exercise targets from the
+// profile and the latest body measurement without changing anything
+func (h *ProfileHandlers) GetTargetRecommendation(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	recommendation, err := h.targetRecommendation(userId)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetTargetRecommendation] Failed to build target recommendation")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get target recommendation", nil)
+	}
+	recommendation.AttachUnits(validator.GetRequestUnits(c))
+
+	return helper.JsonResponse(c, http.StatusOK, recommendation)
+}
+
+// ApplyTargetRecommendation recalculates the recommendation and writes it to the
+// nutrition and weekly exercise targets in one transaction
+func (h *ProfileHandlers) ApplyTargetRecommendation(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	recommendation, err := h.targetRecommendation(userId)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[ApplyTargetRecommendation] Failed to build target recommendation")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to apply target recommendation", nil)
+	}
+	if recommendation.Target == nil {
+		return helper.ErrorResponse(c, http.StatusUnprocessableEntity, "Profile is incomplete, fill in the missing fields first", recommendation.Missing)
+	}
+
+	if err = h.userRepo.ApplyRecommendedTarget(recommendation.Target); err != nil {
+		Logger.Error().Err(err).Msg("[ApplyTargetRecommendation] Failed to apply target recommendation")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to apply target recommendation", nil)
+	}
+
+	userTarget, err := h.userRepo.FindPersonalTarget(userId)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[ApplyTargetRecommendation] Failed to get personal target")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get personal target", nil)
+	}
+	units := validator.GetRequestUnits(c)
+	userTarget.Units = &units
+
+	return helper.JsonResponse(c, http.StatusOK, userTarget)
+}
+
+// targetRecommendation loads the inputs of a recommendation for the user's local today
+func (h *ProfileHandlers) targetRecommendation(userId int) (models.TargetRecommendation, error) {
+	profile, err := h.userRepo.FindUserProfile(userId)
+	if err != nil && err != sql.ErrNoRows {
+		return models.TargetRecommendation{}, err
+	}
+
+	latest, err := h.measurementRepo.FindLatest(userId)
+	if err == sql.ErrNoRows {
+		latest = nil
+	} else if err != nil {
+		return models.TargetRecommendation{}, err
+	}
+
+	today := time.Now().In(models.LoadUserLocation(userId))
+	return models.BuildTargetRecommendation(*profile, latest, today), nil
+}
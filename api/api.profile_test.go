@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/WahyuSiddarta/be_saham_go/models"
+	"github.com/WahyuSiddarta/be_saham_go/validator"
+	"github.com/rs/zerolog"
+)
+
+// fakeProfileUserRepo keeps the last profile written
+type fakeProfileUserRepo struct {
+	models.UserRepository
+	saved *models.UserProfile
+}
+
+func (r *fakeProfileUserRepo) UpsertUserProfile(profile *models.UserProfile) error {
+	r.saved = profile
+	return nil
+}
+
+func TestUserProfileUnits(t *testing.T) {
+	nop := zerolog.Nop()
+	Logger = &nop
+
+	profile := func(height, rate string) string {
+		return `{"height_cm":` + height + `,"goal":"lose","goal_rate_kg":` + rate + `}`
+	}
+
+	tests := []struct {
+		name       string
+		units      models.UnitPreference
+		body       string
+		wantStatus int
+		wantHeight float64
+		wantRate   float64
+		wantJSON   [2]float64
+	}{
+		{"imperial", models.ImperialUnits(), profile("70.87", "1"), http.StatusOK, 180.01, 0.45, [2]float64{70.87, 0.99}},
+		{"metric", models.MetricUnits(), profile("180", "0.5"), http.StatusOK, 180, 0.5, [2]float64{180, 0.5}},
+		{"centimetres sent as inches", models.ImperialUnits(), profile("180", "1"), http.StatusBadRequest, 0, 0, [2]float64{}},
+		{"pounds over the kg rate limit", models.ImperialUnits(), profile("70", "2.5"), http.StatusBadRequest, 0, 0, [2]float64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeProfileUserRepo{}
+			h := NewProfileHandlers(repo, nil)
+
+			rec := serveUnits(tt.units, http.MethodPut, "/profile", "/profile", tt.body, h.UpdateUserProfile,
+				validator.ValidateRequest(&validator.UserProfileRequest{}))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("PUT /profile = %d %s, want %d", rec.Code, rec.Body, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if *repo.saved.HeightCm != tt.wantHeight || repo.saved.GoalRateKg != tt.wantRate {
+				t.Errorf("stored height %v rate %v, want %v and %v", *repo.saved.HeightCm, repo.saved.GoalRateKg, tt.wantHeight, tt.wantRate)
+			}
+			var response struct {
+				Data struct {
+					HeightCm   float64 `json:"height_cm"`
+					GoalRateKg float64 `json:"goal_rate_kg"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode %s: %v", rec.Body, err)
+			}
+			if got := [2]float64{response.Data.HeightCm, response.Data.GoalRateKg}; got != tt.wantJSON {
+				t.Errorf("height and rate = %v, want %v", got, tt.wantJSON)
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/WahyuSiddarta/be_saham_go/blobstore"
+	"github.com/WahyuSiddarta/be_saham_go/config"
+	"github.com/WahyuSiddarta/be_saham_go/helper"
+	"github.com/WahyuSiddarta/be_saham_go/models"
+	"github.com/WahyuSiddarta/be_saham_go/validator"
+	"github.com/labstack/echo/v4"
+)
+
+// ProgressPhotoHandlers contains all progress photo handlers
+type ProgressPhotoHandlers struct {
+	repo  models.ProgressPhotoRepository
+	store blobstore.BlobStore
+}
+
+// NewProgressPhotoHandlers creates a new instance of progress photo handlers
+func NewProgressPhotoHandlers(repo models.ProgressPhotoRepository, store blobstore.BlobStore) *ProgressPhotoHandlers {
+	return &ProgressPhotoHandlers{repo: repo, store: store}
+}
+
+func (h *ProgressPhotoHandlers) GetProgressPhotos(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	req := validator.GetValidatedQuery(c).(*validator.ProgressPhotoRequest)
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	limit := config.Get().PaginationDefaultPageSize
+	photos, err := h.repo.GetByUserId(userId, models.PhotoPose(req.Pose), limit, page)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetProgressPhotos] Failed to get progress photos")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get progress photos", nil)
+	}
+
+	hasNext := false
+	if len(photos) > limit {
+		hasNext = true
+		photos = photos[:limit]
+	}
+	for i := range photos {
+		signProgressPhoto(&photos[i])
+	}
+
+	response := map[string]interface{}{
+		"photos":   photos,
+		"nextPage": hasNext,
+	}
+	return helper.JsonResponse(c, http.StatusOK, response)
+}
+
+func (h *ProgressPhotoHandlers) GetProgressPhoto(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	photoId, err := strconv.Atoi(c.Param("photo_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetProgressPhoto] Invalid photo ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid photo ID", nil)
+	}
+
+	photo, err := h.repo.FindById(userId, photoId)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Progress photo not found", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetProgressPhoto] Failed to get progress photo")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get progress photo", nil)
+	}
+
+	signProgressPhoto(photo)
+	return helper.JsonResponse(c, http.StatusOK, photo)
+}
+
+func (h *ProgressPhotoHandlers) UploadProgressPhoto(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	validatedRequest := validator.GetValidatedRequest(c)
+	if validatedRequest == nil {
+		Logger.Error().Msg("[UploadProgressPhoto] No validated request found in context")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+	photoRequest := validatedRequest.(*validator.ProgressPhotoUploadRequest)
+
+	takenAt := time.Now()
+	if photoRequest.TakenAt != "" {
+		takenAt, _ = time.Parse(time.RFC3339, photoRequest.TakenAt)
+	}
+
+	fileHeader, err := c.FormFile("photo")
+	if err != nil {
+		return helper.ErrorResponse(c, http.StatusBadRequest, "A photo file is required", nil)
+	}
+	maxBytes := int64(config.Get().Storage.MaxUploadBytes)
+	if fileHeader.Size > maxBytes {
+		return helper.ErrorResponse(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("Photo must be at most %d bytes", maxBytes), nil)
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		Logger.Error().Err(err).Msg("[UploadProgressPhoto] Failed to open uploaded file")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Failed to read photo", nil)
+	}
+	defer file.Close()
+	// The declared size comes from the client, so the read is capped as well
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[UploadProgressPhoto] Failed to read uploaded file")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Failed to read photo", nil)
+	}
+	if int64(len(data)) > maxBytes {
+		return helper.ErrorResponse(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("Photo must be at most %d bytes", maxBytes), nil)
+	}
+
+	processed, err := helper.ProcessUploadedImage(data)
+	if errors.Is(err, helper.ErrUnsupportedImage) {
+		return helper.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+	}
+	if errors.Is(err, helper.ErrImageTooLarge) {
+		return helper.ErrorResponse(c, http.StatusRequestEntityTooLarge, err.Error(), nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[UploadProgressPhoto] Failed to process image")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to process photo", nil)
+	}
+
+	baseKey, err := newProgressPhotoKey(userId)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[UploadProgressPhoto] Failed to generate blob key")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to store photo", nil)
+	}
+	extension := ".jpg"
+	if processed.ContentType == "image/png" {
+		extension = ".png"
+	}
+	photo := &models.ProgressPhoto{
+		UserId:       userId,
+		Pose:         models.PhotoPose(photoRequest.Pose),
+		ContentType:  processed.ContentType,
+		Width:        processed.Width,
+		Height:       processed.Height,
+		SizeBytes:    len(processed.Data),
+		BlobKey:      baseKey + extension,
+		ThumbnailKey: baseKey + "-thumb.jpg",
+		TakenAt:      takenAt,
+	}
+	if photoRequest.MeasurementId != 0 {
+		photo.MeasurementId = &photoRequest.MeasurementId
+	}
+
+	ctx := c.Request().Context()
+	if err = h.store.Put(ctx, photo.BlobKey, processed.Data, processed.ContentType); err != nil {
+		Logger.Error().Err(err).Msg("[UploadProgressPhoto] Failed to store photo")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to store photo", nil)
+	}
+	if err = h.store.Put(ctx, photo.ThumbnailKey, processed.Thumbnail, processed.ThumbnailType); err != nil {
+		Logger.Error().Err(err).Msg("[UploadProgressPhoto] Failed to store thumbnail")
+		h.deleteBlobs(photo)
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to store photo", nil)
+	}
+
+	if err = h.repo.Create(photo); err != nil {
+		h.deleteBlobs(photo)
+		if errors.Is(err, models.ErrMeasurementNotFound) {
+			return helper.ErrorResponse(c, http.StatusNotFound, "Body measurement not found", nil)
+		}
+		Logger.Error().Err(err).Msg("[UploadProgressPhoto] Failed to save progress photo")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to save progress photo", nil)
+	}
+
+	Logger.Info().Msgf("[UploadProgressPhoto] Added progress photo %d for user %d", photo.PhotoId, userId)
+	signProgressPhoto(photo)
+	return helper.JsonResponse(c, http.StatusCreated, photo)
+}
+
+func (h *ProgressPhotoHandlers) DeleteProgressPhoto(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	photoId, err := strconv.Atoi(c.Param("photo_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[DeleteProgressPhoto] Invalid photo ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid photo ID", nil)
+	}
+
+	photo, err := h.repo.Delete(userId, photoId)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Progress photo not found", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[DeleteProgressPhoto] Failed to delete progress photo")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete progress photo", nil)
+	}
+	h.deleteBlobs(photo)
+
+	Logger.Info().Msgf("[DeleteProgressPhoto] Deleted progress photo %d for user %d", photoId, userId)
+	return helper.JsonResponse(c, http.StatusOK, map[string]string{"message": "Progress photo deleted successfully"})
+}
+
+// ServeProgressPhoto streams a photo or its thumbnail for a signed URL. It is a
+// public route because image tags can't send an Authorization header; the
+// signature binds the photo, owner, variant and expiry instead.
+func (h *ProgressPhotoHandlers) ServeProgressPhoto(c echo.Context) error {
+	photoId, err := strconv.Atoi(c.Param("photo_id"))
+	variant := c.Param("variant")
+	if err != nil || (variant != models.PhotoVariantFull && variant != models.PhotoVariantThumbnail) {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Progress photo not found", nil)
+	}
+
+	req := validator.GetValidatedQuery(c).(*validator.ProgressPhotoFileQuery)
+	resource := progressPhotoResource(photoId, req.Uid, variant)
+	if !helper.VerifyResourceSignature(config.Get().Storage.SignedURLSecret, resource, time.Unix(req.Expires, 0), req.Sig) {
+		return helper.ErrorResponse(c, http.StatusForbidden, "Invalid or expired photo URL", nil)
+	}
+
+	// The photo is looked up by the signed owner, so the URL only works while uid still owns it
+	photo, err := h.repo.FindById(req.Uid, photoId)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Progress photo not found", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[ServeProgressPhoto] Failed to get progress photo")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get progress photo", nil)
+	}
+
+	blob, err := h.store.Get(c.Request().Context(), photo.BlobKeyFor(variant))
+	if errors.Is(err, blobstore.ErrNotFound) {
+		Logger.Warn().Msgf("[ServeProgressPhoto] Blob missing for progress photo %d", photoId)
+		return helper.ErrorResponse(c, http.StatusNotFound, "Progress photo not found", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[ServeProgressPhoto] Failed to read progress photo")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get progress photo", nil)
+	}
+	defer blob.Close()
+
+	header := c.Response().Header()
+	header.Set("Cache-Control", "private, max-age="+strconv.Itoa(config.Get().Storage.SignedURLTTLSecs))
+	header.Set("X-Content-Type-Options", "nosniff")
+	return c.Stream(http.StatusOK, photo.ContentTypeFor(variant), blob)
+}
+
+// deleteBlobs removes a photo's stored files; failures only leave orphans behind so they are logged
+func (h *ProgressPhotoHandlers) deleteBlobs(photo *models.ProgressPhoto) {
+	ctx := context.Background()
+	for _, key := range []string{photo.BlobKey, photo.ThumbnailKey} {
+		if err := h.store.Delete(ctx, key); err != nil {
+			Logger.Warn().Err(err).Msgf("[deleteBlobs] Failed to delete blob %s", key)
+		}
+	}
+}
+
+// signProgressPhoto fills in short-lived signed URLs for the photo and its thumbnail
+func signProgressPhoto(photo *models.ProgressPhoto) {
+	storage := config.Get().Storage
+	expires := time.Now().Add(time.Duration(storage.SignedURLTTLSecs) * time.Second).Truncate(time.Second)
+	signedURL := func(variant string) string {
+		signature := helper.SignResource(storage.SignedURLSecret, progressPhotoResource(photo.PhotoId, photo.UserId, variant), expires)
+		query := url.Values{}
+		query.Set("uid", strconv.Itoa(photo.UserId))
+		query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+		query.Set("sig", signature)
+		return fmt.Sprintf("/api/public/progress-photos/%d/%s?%s", photo.PhotoId, variant, query.Encode())
+	}
+	photo.Url = signedURL(models.PhotoVariantFull)
+	photo.ThumbnailUrl = signedURL(models.PhotoVariantThumbnail)
+	photo.UrlExpiresAt = &expires
+}
+
+// progressPhotoResource is the string a progress photo URL signature covers
+func progressPhotoResource(photoId, userId int, variant string) string {
+	return fmt.Sprintf("progress-photo:%d:%d:%s", photoId, userId, variant)
+}
+
+// newProgressPhotoKey returns a random blob key prefix under the user's folder
+func newProgressPhotoKey(userId int) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("progress-photos/%d/%s", userId, hex.EncodeToString(random)), nil
+}
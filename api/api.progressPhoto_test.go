@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/WahyuSiddarta/be_saham_go/config"
+	"github.com/WahyuSiddarta/be_saham_go/helper"
+	"github.com/WahyuSiddarta/be_saham_go/models"
+)
+
+func TestSignProgressPhotoQuery(t *testing.T) {
+	t.Setenv("STORAGE_SIGNED_URL_SECRET", "signed-url-secret")
+	t.Setenv("STORAGE_SIGNED_URL_TTL_SECONDS", "300")
+	if _, err := config.Load(); err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+
+	photo := &models.ProgressPhoto{PhotoId: 42, UserId: 7}
+	before := time.Now()
+	signProgressPhoto(photo)
+
+	tests := []struct {
+		name     string
+		rawURL   string
+		wantPath string
+		variant  string
+	}{
+		{"full", photo.Url, "/api/public/progress-photos/42/full", models.PhotoVariantFull},
+		{"thumbnail", photo.ThumbnailUrl, "/api/public/progress-photos/42/thumbnail", models.PhotoVariantThumbnail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed, err := url.Parse(tt.rawURL)
+			if err != nil {
+				t.Fatalf("url.Parse(%q): %v", tt.rawURL, err)
+			}
+			if signed.Path != tt.wantPath {
+				t.Errorf("path = %s, want %s", signed.Path, tt.wantPath)
+			}
+
+			query := signed.Query()
+			if query.Get("uid") != "7" {
+				t.Errorf("uid = %q, want 7", query.Get("uid"))
+			}
+			unix, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+			if err != nil {
+				t.Fatalf("expires = %q: %v", query.Get("expires"), err)
+			}
+			expires := time.Unix(unix, 0)
+			if !expires.Equal(*photo.UrlExpiresAt) {
+				t.Errorf("expires = %v, url_expires_at = %v", expires, *photo.UrlExpiresAt)
+			}
+			if ttl := expires.Sub(before); ttl < 299*time.Second || ttl > 300*time.Second {
+				t.Errorf("URL is valid for %v, want the 300s TTL", ttl)
+			}
+
+			sig := query.Get("sig")
+			if !helper.VerifyResourceSignature("signed-url-secret", progressPhotoResource(42, 7, tt.variant), expires, sig) {
+				t.Errorf("sig %q does not verify", sig)
+			}
+			if helper.VerifyResourceSignature("signed-url-secret", progressPhotoResource(42, 8, tt.variant), expires, sig) {
+				t.Error("sig verifies for another owner")
+			}
+			if helper.VerifyResourceSignature("signed-url-secret", progressPhotoResource(42, 7, tt.variant), expires.Add(time.Hour), sig) {
+				t.Error("sig verifies for a later expiry")
+			}
+			if helper.VerifyResourceSignature("jwt-secret", progressPhotoResource(42, 7, tt.variant), expires, sig) {
+				t.Error("sig verifies with another secret")
+			}
+		})
+	}
+	if photo.Url == photo.ThumbnailUrl {
+		t.Error("full and thumbnail URLs are the same")
+	}
+}
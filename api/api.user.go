@@ -1,8 +1,11 @@
 package api
 
 import (
+	"database/sql"
+	"math"
 	"net/http"
 
+	"github.com/WahyuSiddarta/be_saham_go/config"
 	"github.com/WahyuSiddarta/be_saham_go/helper"
 	"github.com/WahyuSiddarta/be_saham_go/models"
 	"github.com/WahyuSiddarta/be_saham_go/validator"
@@ -26,9 +29,45 @@ func (h *UsersHandlers) GetPersonalTarget(c echo.Context) error {
 		Logger.Error().Err(err).Msg("[GetPersonalTarget - FindPersonalTarget] Failed to get personal target")
 		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get personal target", nil)
 	}
+	units := validator.GetRequestUnits(c)
+	userTarget.Units = &units
 	return helper.JsonResponse(c, http.StatusOK, userTarget)
 }
 
+// GetPersonalTargetHistory lists every version of the personal target, newest first
+func (h *UsersHandlers) GetPersonalTargetHistory(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+	req := validator.GetValidatedQuery(c).(*validator.PersonalTargetHistoryQuery)
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	limit := config.Get().PaginationDefaultPageSize
+	versions, err := h.repo.FindTargetHistory(userId, limit, page)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetPersonalTargetHistory] Failed to get personal target history")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get personal target history", nil)
+	}
+
+	hasNext := false
+	if len(versions) > limit {
+		hasNext = true
+		versions = versions[:limit]
+	}
+	if versions == nil {
+		versions = []models.UserTargetVersion{}
+	}
+	models.AttachTargetVersionUnits(versions, validator.GetRequestUnits(c))
+
+	response := map[string]interface{}{
+		"versions": versions,
+		"nextPage": hasNext,
+	}
+
+	return helper.JsonResponse(c, http.StatusOK, response)
+}
+
 func (h *UsersHandlers) UpdatePersonalBodyMeasurementTarget(c echo.Context) error {
 	var userId int = 1 // Replace with actual user ID retrieval logic
 
@@ -44,11 +83,25 @@ func (h *UsersHandlers) UpdatePersonalBodyMeasurementTarget(c echo.Context) erro
 		Logger.Error().Msg("[UpdatePersonalBodyMeasurementTarget] Failed to cast validated request to PersonalBodyMeasurementTargetRequest")
 		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
 	}
+	units := validator.GetRequestUnits(c)
 	userTarget := &models.UserTarget{
-		UserId:        userId,
-		BodyWeight:    req.BodyWeight,
-		ViceralFat:    req.ViceralFat,
-		FatPercentage: req.FatPercentage,
+		UserId:              userId,
+		Units:               &units,
+		BodyWeight:          req.BodyWeight,
+		ViceralFat:          req.ViceralFat,
+		FatPercentage:       req.FatPercentage,
+		HipCm:               req.HipCm,
+		ChestCm:             req.ChestCm,
+		ArmLeftCm:           req.ArmLeftCm,
+		ArmRightCm:          req.ArmRightCm,
+		ThighLeftCm:         req.ThighLeftCm,
+		ThighRightCm:        req.ThighRightCm,
+		CalfCm:              req.CalfCm,
+		MuscleMassKg:        req.MuscleMassKg,
+		BoneMassKg:          req.BoneMassKg,
+		BodyWaterPercentage: req.BodyWaterPercentage,
+		ScaleBmr:            req.ScaleBmr,
+		MetabolicAge:        req.MetabolicAge,
 	}
 
 	err := h.repo.UpdatePersonalBodyMeasurementTarget(userTarget)
@@ -74,12 +127,20 @@ func (h *UsersHandlers) UpdatePersonalNutritionTarget(c echo.Context) error {
 		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
 	}
 
+	units := validator.GetRequestUnits(c)
 	userTarget := &models.UserTarget{
-		UserId:           userId,
-		NutritionCaloric: req.NutritionCaloric,
-		NutritionProtein: req.NutritionProtein,
-		NutritionCarbs:   req.NutritionCarbs,
-		NutritionFat:     req.NutritionFat,
+		UserId:                userId,
+		Units:                 &units,
+		NutritionCaloric:      req.NutritionCaloric,
+		NutritionProtein:      req.NutritionProtein,
+		NutritionCarbs:        req.NutritionCarbs,
+		NutritionFat:          req.NutritionFat,
+		NutritionFiber:        req.NutritionFiber,
+		NutritionSugar:        req.NutritionSugar,
+		NutritionSaturatedFat: req.NutritionSaturatedFat,
+		NutritionSodium:       req.NutritionSodium,
+		NutritionCholesterol:  req.NutritionCholesterol,
+		NutritionPotassium:    req.NutritionPotassium,
 	}
 
 	err := h.repo.UpdatePersonalNutritionTarget(userTarget)
@@ -107,8 +168,10 @@ func (h *UsersHandlers) UpdatePersonalExerciseTarget(c echo.Context) error {
 		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
 	}
 
+	units := validator.GetRequestUnits(c)
 	userTarget := &models.UserTarget{
 		UserId:                      userId,
+		Units:                       &units,
 		WeeklyExerciseMinutes:       req.WeeklyExerciseMinutes,
 		WeeklyExcerciseSessions:     req.WeeklyExcerciseSessions,
 		WeeklyExcerciseCaloric:      req.WeeklyExcerciseCaloric,
@@ -124,3 +187,104 @@ func (h *UsersHandlers) UpdatePersonalExerciseTarget(c echo.Context) error {
 
 	return helper.JsonResponse(c, http.StatusOK, userTarget)
 }
+
+func (h *UsersHandlers) UpdatePersonalHydrationTarget(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	// Get validated request from middleware
+	validatedRequest := validator.GetValidatedRequest(c)
+	if validatedRequest == nil {
+		Logger.Error().Msg("[UpdatePersonalHydrationTarget] No validated request found in context")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	req, ok := validatedRequest.(*validator.PersonalHydrationTargetRequest)
+	if !ok {
+		Logger.Error().Msg("[UpdatePersonalHydrationTarget] Failed to cast validated request to PersonalHydrationTargetRequest")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	units := validator.GetRequestUnits(c)
+	userTarget := &models.UserTarget{
+		UserId:      userId,
+		HydrationMl: int(math.Round(req.HydrationMl)),
+		Units:       &units,
+	}
+
+	err := h.repo.UpdatePersonalHydrationTarget(userTarget)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[UpdatePersonalHydrationTarget] Failed to update personal hydration target")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to update personal hydration target", nil)
+	}
+
+	return helper.JsonResponse(c, http.StatusOK, userTarget)
+}
+
+func (h *UsersHandlers) GetUserTimezone(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	timezone, err := h.repo.FindUserTimezone(userId)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetUserTimezone] Failed to get user timezone")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get user timezone", nil)
+	}
+
+	return helper.JsonResponse(c, http.StatusOK, models.UserTimezone{UserId: userId, Timezone: timezone})
+}
+
+func (h *UsersHandlers) UpdateUserTimezone(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	// Get validated request from middleware
+	validatedRequest := validator.GetValidatedRequest(c)
+	if validatedRequest == nil {
+		Logger.Error().Msg("[UpdateUserTimezone] No validated request found in context")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	req, ok := validatedRequest.(*validator.UserTimezoneRequest)
+	if !ok {
+		Logger.Error().Msg("[UpdateUserTimezone] Failed to cast validated request to UserTimezoneRequest")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	err := h.repo.UpdateUserTimezone(userId, req.Timezone)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[UpdateUserTimezone] Failed to update user timezone")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to update user timezone", nil)
+	}
+
+	Logger.Info().Msgf("[UpdateUserTimezone] Updated timezone for user %d to %s", userId, req.Timezone)
+	return helper.JsonResponse(c, http.StatusOK, models.UserTimezone{UserId: userId, Timezone: req.Timezone})
+}
+
+func (h *UsersHandlers) GetUnitPreference(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	units, err := h.repo.FindUnitPreference(userId)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetUnitPreference] Failed to get unit preference")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get unit preference", nil)
+	}
+
+	return helper.JsonResponse(c, http.StatusOK, units)
+}
+
+func (h *UsersHandlers) UpdateUnitPreference(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	req, ok := validator.GetValidatedRequest(c).(*validator.UnitPreferenceRequest)
+	if !ok {
+		Logger.Error().Msg("[UpdateUnitPreference] Failed to cast validated request to UnitPreferenceRequest")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	units := models.UnitPreference{Weight: req.Weight, Length: req.Length, Energy: req.Energy, Volume: req.Volume}
+	if err := h.repo.UpdateUnitPreference(userId, units); err != nil {
+		Logger.Error().Err(err).Msg("[UpdateUnitPreference] Failed to update unit preference")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to update unit preference", nil)
+	}
+
+	Logger.Info().Msgf("[UpdateUnitPreference] Updated unit preference for user %d", userId)
+	return helper.JsonResponse(c, http.StatusOK, units)
+}
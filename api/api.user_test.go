@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/WahyuSiddarta/be_saham_go/models"
+	"github.com/WahyuSiddarta/be_saham_go/validator"
+	"github.com/rs/zerolog"
+)
+
+// fakeTargetUserRepo keeps the last target written
+type fakeTargetUserRepo struct {
+	models.UserRepository
+	saved *models.UserTarget
+}
+
+func (r *fakeTargetUserRepo) UpdatePersonalNutritionTarget(target *models.UserTarget) error {
+	r.saved = target
+	return nil
+}
+
+func (r *fakeTargetUserRepo) UpdatePersonalHydrationTarget(target *models.UserTarget) error {
+	r.saved = target
+	return nil
+}
+
+func TestPersonalTargetUnits(t *testing.T) {
+	nop := zerolog.Nop()
+	Logger = &nop
+	kilojoules := models.MetricUnits()
+	kilojoules.Energy = models.UnitKj
+	imperial := models.ImperialUnits()
+
+	nutrition := func(caloric string) string {
+		return `{"nutrition_caloric":` + caloric + `,"nutrition_protein":150,"nutrition_carbohydrate":200,"nutrition_fat":70}`
+	}
+
+	tests := []struct {
+		name       string
+		units      models.UnitPreference
+		hydration  bool
+		body       string
+		wantStatus int
+		wantStored float64
+		wantValue  float64
+	}{
+		{"kilojoule energy target", kilojoules, false, nutrition("8368"), http.StatusOK, 2000, 8368},
+		{"metric energy target", models.MetricUnits(), false, nutrition("2000"), http.StatusOK, 2000, 2000},
+		// decimal2 checks the converted value, which ToMetric has already rounded to two decimals
+		{"kilojoules with more than two decimals", kilojoules, false, nutrition("8368.1234"), http.StatusOK, 2000.03, 8368.13},
+		{"kilocalories with more than two decimals", models.MetricUnits(), false, nutrition("2000.123"), http.StatusBadRequest, 0, 0},
+		{"fluid ounce hydration target", imperial, true, `{"hydration_ml":67.63}`, http.StatusOK, 2000, 67.63},
+		{"metric hydration target", models.MetricUnits(), true, `{"hydration_ml":2500}`, http.StatusOK, 2500, 2500},
+		{"fluid ounces over the ml limit", imperial, true, `{"hydration_ml":400}`, http.StatusBadRequest, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeTargetUserRepo{}
+			h := NewUserHandlers(repo)
+
+			route, field := "/personal-target/nutrition", "nutrition_caloric"
+			handler, request := h.UpdatePersonalNutritionTarget, interface{}(&validator.PersonalNutritionTargetRequest{})
+			if tt.hydration {
+				route, field = "/personal-target/hydration", "hydration_ml"
+				handler, request = h.UpdatePersonalHydrationTarget, &validator.PersonalHydrationTargetRequest{}
+			}
+			rec := serveUnits(tt.units, http.MethodPut, route, route, tt.body, handler, validator.ValidateRequest(request))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("PUT %s = %d %s, want %d", route, rec.Code, rec.Body, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			stored := repo.saved.NutritionCaloric
+			if tt.hydration {
+				stored = float64(repo.saved.HydrationMl)
+			}
+			if stored != tt.wantStored {
+				t.Errorf("stored = %v, want %v", stored, tt.wantStored)
+			}
+			var response struct {
+				Data map[string]interface{} `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode %s: %v", rec.Body, err)
+			}
+			if got := response.Data[field]; got != tt.wantValue {
+				t.Errorf("%s = %v, want %v", field, got, tt.wantValue)
+			}
+		})
+	}
+}
@@ -0,0 +1,331 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/WahyuSiddarta/be_saham_go/config"
+	"github.com/WahyuSiddarta/be_saham_go/helper"
+	"github.com/WahyuSiddarta/be_saham_go/models"
+	"github.com/WahyuSiddarta/be_saham_go/validator"
+	"github.com/labstack/echo/v4"
+)
+
+// WorkoutHandlers contains all strength workout handlers
+type WorkoutHandlers struct {
+	repo          models.WorkoutRepository
+	userRepo      models.UserRepository
+	recordRepo    models.PersonalRecordRepository
+	excerciseRepo models.ExcerciseRecordRepository
+	catalogRepo   models.ExerciseCatalogRepository
+}
+
+// NewWorkoutHandlers creates a new instance of workout handlers
+func NewWorkoutHandlers(repo models.WorkoutRepository, userRepo models.UserRepository, recordRepo models.PersonalRecordRepository, excerciseRepo models.ExcerciseRecordRepository, catalogRepo models.ExerciseCatalogRepository) *WorkoutHandlers {
+	return &WorkoutHandlers{repo: repo, userRepo: userRepo, recordRepo: recordRepo, excerciseRepo: excerciseRepo, catalogRepo: catalogRepo}
+}
+
+// GetWorkouts lists the user's workouts with their exercises and sets, newest first
+func (h *WorkoutHandlers) GetWorkouts(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	req := validator.GetValidatedQuery(c).(*validator.WorkoutListRequest)
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	limit := config.Get().PaginationDefaultPageSize
+	workouts, err := h.repo.GetByUserId(userId, limit, page)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetWorkouts] Failed to get workouts")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get workouts", nil)
+	}
+
+	hasNext := false
+	if len(workouts) > limit {
+		hasNext = true
+		workouts = workouts[:limit]
+	}
+	if workouts == nil {
+		workouts = []models.Workout{}
+	}
+	models.AttachWorkoutUnits(workouts, validator.GetRequestUnits(c))
+
+	return helper.JsonResponse(c, http.StatusOK, map[string]interface{}{
+		"workouts": workouts,
+		"nextPage": hasNext,
+	})
+}
+
+// GetWorkout returns one workout with its exercises and sets
+func (h *WorkoutHandlers) GetWorkout(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	workoutId, err := strconv.Atoi(c.Param("workout_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetWorkout] Invalid workout ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid workout ID", nil)
+	}
+
+	workout, err := h.repo.FindById(userId, workoutId)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Workout not found", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetWorkout] Failed to get workout")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get workout", nil)
+	}
+
+	units := validator.GetRequestUnits(c)
+	workout.Units = &units
+	return helper.JsonResponse(c, http.StatusOK, workout)
+}
+
+// AddWorkout logs a strength workout with its exercises and sets
+func (h *WorkoutHandlers) AddWorkout(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	req, ok := validator.GetValidatedRequest(c).(*validator.WorkoutRequest)
+	if !ok {
+		Logger.Error().Msg("[AddWorkout] Failed to cast validated request to WorkoutRequest")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	catalog, err := h.catalogRepo.FindByIds(workoutRequestCatalogIds(req))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[AddWorkout] Failed to get catalog exercises")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to add workout", nil)
+	}
+	workout, errs := workoutFromRequest(userId, req, catalog)
+	if len(errs) > 0 {
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Validation errors", errs)
+	}
+
+	if err := h.repo.Create(workout); err != nil {
+		Logger.Error().Err(err).Msg("[AddWorkout] Failed to add workout")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to add workout", nil)
+	}
+
+	Logger.Info().Msgf("[AddWorkout] Added workout %d for user %d", workout.WorkoutId, userId)
+	units := validator.GetRequestUnits(c)
+	workout.Units = &units
+	records := refreshPersonalRecords(h.recordRepo, "AddWorkout", userId, models.WorkoutPersonalRecordExercises(workout))
+	workout.NewPersonalRecords = models.PersonalRecordsFrom(records, models.PersonalRecordSourceWorkoutSet, models.WorkoutSetIds(workout))
+	models.AttachPersonalRecordUnits(workout.NewPersonalRecords, units)
+	return helper.JsonResponse(c, http.StatusCreated, workout)
+}
+
+// UpdateWorkout replaces a workout with its exercises and sets
+func (h *WorkoutHandlers) UpdateWorkout(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	workoutId, err := strconv.Atoi(c.Param("workout_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[UpdateWorkout] Invalid workout ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid workout ID", nil)
+	}
+
+	req, ok := validator.GetValidatedRequest(c).(*validator.WorkoutRequest)
+	if !ok {
+		Logger.Error().Msg("[UpdateWorkout] Failed to cast validated request to WorkoutRequest")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	catalog, err := h.catalogRepo.FindByIds(workoutRequestCatalogIds(req))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[UpdateWorkout] Failed to get catalog exercises")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to update workout", nil)
+	}
+	workout, errs := workoutFromRequest(userId, req, catalog)
+	if len(errs) > 0 {
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Validation errors", errs)
+	}
+	workout.WorkoutId = workoutId
+
+	existing, err := h.repo.FindById(userId, workoutId)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Workout not found", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[UpdateWorkout] Failed to get workout")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to update workout", nil)
+	}
+
+	err = h.repo.Update(workout)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Workout not found", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[UpdateWorkout] Failed to update workout")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to update workout", nil)
+	}
+
+	Logger.Info().Msgf("[UpdateWorkout] Updated workout %d for user %d", workoutId, userId)
+	refreshPersonalRecords(h.recordRepo, "UpdateWorkout", userId, models.WorkoutPersonalRecordExercises(existing, workout))
+	units := validator.GetRequestUnits(c)
+	workout.Units = &units
+	return helper.JsonResponse(c, http.StatusOK, workout)
+}
+
+// DeleteWorkout removes a workout with its exercises and sets
+func (h *WorkoutHandlers) DeleteWorkout(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	workoutId, err := strconv.Atoi(c.Param("workout_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[DeleteWorkout] Invalid workout ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid workout ID", nil)
+	}
+
+	existing, err := h.repo.FindById(userId, workoutId)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Workout not found", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[DeleteWorkout] Failed to get workout")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete workout", nil)
+	}
+
+	err = h.repo.Delete(userId, workoutId)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Workout not found", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[DeleteWorkout] Failed to delete workout")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete workout", nil)
+	}
+
+	Logger.Info().Msgf("[DeleteWorkout] Deleted workout %d for user %d", workoutId, userId)
+	refreshPersonalRecords(h.recordRepo, "DeleteWorkout", userId, models.WorkoutPersonalRecordExercises(existing))
+	return helper.JsonResponse(c, http.StatusOK, map[string]string{"message": "Workout deleted successfully"})
+}
+
+// GetWeeklyStrengthStats returns the sessions against the weekly weight lifting
+// target, volume per muscle group and best estimated one-rep max per exercise
+// for the Monday-based week containing :date
+func (h *WorkoutHandlers) GetWeeklyStrengthStats(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+	req := validator.GetValidatedQuery(c).(*validator.StrengthWeeklyStatsQuery)
+
+	loc := models.LoadUserLocation(userId)
+	day, err := parseLocalDateParam(c, "date", loc)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetWeeklyStrengthStats] Invalid date parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid date format, use YYYY-MM-DD", nil)
+	}
+	start := startOfWeek(day)
+
+	end := start.AddDate(0, 0, 7)
+	workouts, err := h.repo.FindBetween(userId, start, end)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetWeeklyStrengthStats] Failed to get workouts")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get strength stats", nil)
+	}
+
+	records, err := h.excerciseRepo.FindBetween(userId, string(models.ExerciseCategoryWeightLifting), start, end)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetWeeklyStrengthStats] Failed to get exercise records")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get strength stats", nil)
+	}
+
+	date := start.Format(models.SQLDateFormat)
+	targets, err := h.userRepo.FindTargetTimeline(userId, date, date)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetWeeklyStrengthStats] Failed to get personal target")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get strength stats", nil)
+	}
+
+	catalog, err := h.catalogRepo.FindByIds(models.WorkoutCatalogIds(workouts))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetWeeklyStrengthStats] Failed to get catalog exercises")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get strength stats", nil)
+	}
+
+	stats := models.BuildStrengthWeeklyStats(start, workouts, records, catalog, targets.On(start), req.Formula)
+	stats.AttachUnits(validator.GetRequestUnits(c))
+	return helper.JsonResponse(c, http.StatusOK, stats)
+}
+
+// workoutRequestCatalogIds returns the catalog ids a workout request logs
+func workoutRequestCatalogIds(req *validator.WorkoutRequest) []int {
+	var ids []int
+	for _, exerciseReq := range req.Exercises {
+		if exerciseReq.CatalogId != 0 {
+			ids = append(ids, exerciseReq.CatalogId)
+		}
+	}
+	return ids
+}
+
+// workoutFromRequest maps a validated workout request. Catalog exercises get
+// their catalog name when none is given; catalog ids missing from catalog and
+// an end before the start are reported as validation errors.
+func workoutFromRequest(userId int, req *validator.WorkoutRequest, catalog map[int]models.ExerciseCatalogEntry) (*models.Workout, []validator.ValidationError) {
+	var errs []validator.ValidationError
+	workout := &models.Workout{
+		UserId:    userId,
+		Name:      req.Name,
+		StartedAt: time.Now(),
+		Notes:     optionalText(req.Notes),
+		Exercises: make([]models.WorkoutExercise, 0, len(req.Exercises)),
+	}
+	if req.StartedAt != "" {
+		workout.StartedAt, _ = time.Parse(time.RFC3339, req.StartedAt)
+	}
+	if req.EndedAt != "" {
+		endedAt, _ := time.Parse(time.RFC3339, req.EndedAt)
+		if !endedAt.After(workout.StartedAt) {
+			errs = append(errs, validator.ValidationError{Field: "ended_at", Message: "ended_at must be after started_at", Tag: "gtfield"})
+		}
+		workout.EndedAt = &endedAt
+	}
+
+	for i, exerciseReq := range req.Exercises {
+		exercise := models.WorkoutExercise{
+			Name:  exerciseReq.Name,
+			Notes: optionalText(exerciseReq.Notes),
+			Sets:  make([]models.WorkoutSet, 0, len(exerciseReq.Sets)),
+		}
+		if exerciseReq.CatalogId != 0 {
+			entry, ok := catalog[exerciseReq.CatalogId]
+			if !ok {
+				errs = append(errs, validator.ValidationError{Field: "exercises[" + strconv.Itoa(i) + "].catalog_id", Message: "Unknown catalog exercise", Tag: "catalog"})
+				continue
+			}
+			exercise.CatalogId = &entry.CatalogId
+			if exercise.Name == "" {
+				exercise.Name = entry.Name
+			}
+		}
+		if exerciseReq.SupersetGroup != 0 {
+			group := exerciseReq.SupersetGroup
+			exercise.SupersetGroup = &group
+		}
+
+		for _, setReq := range exerciseReq.Sets {
+			set := models.WorkoutSet{
+				Reps:     setReq.Reps,
+				WeightKg: setReq.Weight,
+				Rpe:      optionalMeasurement(setReq.Rpe),
+				Warmup:   setReq.Warmup,
+			}
+			if setReq.RestSeconds != 0 {
+				rest := setReq.RestSeconds
+				set.RestSeconds = &rest
+			}
+			exercise.Sets = append(exercise.Sets, set)
+		}
+		workout.Exercises = append(workout.Exercises, exercise)
+	}
+	return workout, errs
+}
+
+// optionalText maps an omitted (empty) text to nil
+func optionalText(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,436 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/WahyuSiddarta/be_saham_go/helper"
+	"github.com/WahyuSiddarta/be_saham_go/models"
+	"github.com/WahyuSiddarta/be_saham_go/validator"
+	"github.com/labstack/echo/v4"
+)
+
+// WorkoutProgramHandlers contains all workout program and enrolment handlers
+type WorkoutProgramHandlers struct {
+	repo        models.WorkoutProgramRepository
+	recordRepo  models.PersonalRecordRepository
+	catalogRepo models.ExerciseCatalogRepository
+}
+
+// NewWorkoutProgramHandlers creates a new instance of workout program handlers
+func NewWorkoutProgramHandlers(repo models.WorkoutProgramRepository, recordRepo models.PersonalRecordRepository, catalogRepo models.ExerciseCatalogRepository) *WorkoutProgramHandlers {
+	return &WorkoutProgramHandlers{repo: repo, recordRepo: recordRepo, catalogRepo: catalogRepo}
+}
+
+// GetWorkoutPrograms lists the user's program templates
+func (h *WorkoutProgramHandlers) GetWorkoutPrograms(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	programs, err := h.repo.GetByUserId(userId)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetWorkoutPrograms] Failed to get workout programs")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get workout programs", nil)
+	}
+	if programs == nil {
+		programs = []models.WorkoutProgram{}
+	}
+	models.AttachWorkoutProgramUnits(programs, validator.GetRequestUnits(c))
+
+	return helper.JsonResponse(c, http.StatusOK, map[string]interface{}{
+		"programs": programs,
+	})
+}
+
+// GetWorkoutProgram returns one program template
+func (h *WorkoutProgramHandlers) GetWorkoutProgram(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	programId, err := strconv.Atoi(c.Param("program_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetWorkoutProgram] Invalid program ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid program ID", nil)
+	}
+
+	program, err := h.repo.FindById(userId, programId)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Workout program not found", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetWorkoutProgram] Failed to get workout program")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get workout program", nil)
+	}
+
+	units := validator.GetRequestUnits(c)
+	program.Units = &units
+	return helper.JsonResponse(c, http.StatusOK, program)
+}
+
+// AddWorkoutProgram creates a program template
+func (h *WorkoutProgramHandlers) AddWorkoutProgram(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	req, ok := validator.GetValidatedRequest(c).(*validator.WorkoutProgramRequest)
+	if !ok {
+		Logger.Error().Msg("[AddWorkoutProgram] Failed to cast validated request to WorkoutProgramRequest")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	catalog, err := h.catalogRepo.FindByIds(programRequestCatalogIds(req))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[AddWorkoutProgram] Failed to get catalog exercises")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to add workout program", nil)
+	}
+	program, errs := workoutProgramFromRequest(userId, req, catalog)
+	if len(errs) > 0 {
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Validation errors", errs)
+	}
+
+	if err := h.repo.Create(program); err != nil {
+		Logger.Error().Err(err).Msg("[AddWorkoutProgram] Failed to add workout program")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to add workout program", nil)
+	}
+
+	Logger.Info().Msgf("[AddWorkoutProgram] Added workout program %d for user %d", program.ProgramId, userId)
+	units := validator.GetRequestUnits(c)
+	program.Units = &units
+	return helper.JsonResponse(c, http.StatusCreated, program)
+}
+
+// UpdateWorkoutProgram replaces a program template; enrolments follow the new schedule
+func (h *WorkoutProgramHandlers) UpdateWorkoutProgram(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	programId, err := strconv.Atoi(c.Param("program_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[UpdateWorkoutProgram] Invalid program ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid program ID", nil)
+	}
+
+	req, ok := validator.GetValidatedRequest(c).(*validator.WorkoutProgramRequest)
+	if !ok {
+		Logger.Error().Msg("[UpdateWorkoutProgram] Failed to cast validated request to WorkoutProgramRequest")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	catalog, err := h.catalogRepo.FindByIds(programRequestCatalogIds(req))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[UpdateWorkoutProgram] Failed to get catalog exercises")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to update workout program", nil)
+	}
+	program, errs := workoutProgramFromRequest(userId, req, catalog)
+	if len(errs) > 0 {
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Validation errors", errs)
+	}
+	program.ProgramId = programId
+
+	err = h.repo.Update(program)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Workout program not found", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[UpdateWorkoutProgram] Failed to update workout program")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to update workout program", nil)
+	}
+
+	Logger.Info().Msgf("[UpdateWorkoutProgram] Updated workout program %d for user %d", programId, userId)
+	units := validator.GetRequestUnits(c)
+	program.Units = &units
+	return helper.JsonResponse(c, http.StatusOK, program)
+}
+
+// DeleteWorkoutProgram removes a program template with its enrolments
+func (h *WorkoutProgramHandlers) DeleteWorkoutProgram(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	programId, err := strconv.Atoi(c.Param("program_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[DeleteWorkoutProgram] Invalid program ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid program ID", nil)
+	}
+
+	err = h.repo.Delete(userId, programId)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Workout program not found", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[DeleteWorkoutProgram] Failed to delete workout program")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete workout program", nil)
+	}
+
+	Logger.Info().Msgf("[DeleteWorkoutProgram] Deleted workout program %d for user %d", programId, userId)
+	return helper.JsonResponse(c, http.StatusOK, map[string]string{"message": "Workout program deleted successfully"})
+}
+
+// EnrolWorkoutProgram starts following a program from start_date
+func (h *WorkoutProgramHandlers) EnrolWorkoutProgram(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	programId, err := strconv.Atoi(c.Param("program_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[EnrolWorkoutProgram] Invalid program ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid program ID", nil)
+	}
+
+	req, ok := validator.GetValidatedRequest(c).(*validator.ProgramEnrolRequest)
+	if !ok {
+		Logger.Error().Msg("[EnrolWorkoutProgram] Failed to cast validated request to ProgramEnrolRequest")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+	startDate, err := parseLocalDate(req.StartDate, models.LoadUserLocation(userId))
+	if err != nil {
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid date format, use YYYY-MM-DD", nil)
+	}
+
+	enrolment, err := h.repo.Enrol(userId, programId, startDate)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Workout program not found", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[EnrolWorkoutProgram] Failed to enrol in workout program")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to enrol in workout program", nil)
+	}
+
+	Logger.Info().Msgf("[EnrolWorkoutProgram] Enrolled user %d in workout program %d", userId, programId)
+	return helper.JsonResponse(c, http.StatusCreated, enrolment)
+}
+
+// GetProgramEnrolments lists the user's enrolments with their completion
+func (h *WorkoutProgramHandlers) GetProgramEnrolments(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	enrolments, err := h.repo.GetEnrolments(userId)
+	if err != nil && err != sql.ErrNoRows {
+		Logger.Error().Err(err).Msg("[GetProgramEnrolments] Failed to get program enrolments")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get program enrolments", nil)
+	}
+	if enrolments == nil {
+		enrolments = []models.ProgramEnrolment{}
+	}
+
+	return helper.JsonResponse(c, http.StatusOK, map[string]interface{}{
+		"enrolments": enrolments,
+	})
+}
+
+// DeleteProgramEnrolment stops following a program; its workouts are kept
+func (h *WorkoutProgramHandlers) DeleteProgramEnrolment(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	enrolmentId, err := strconv.Atoi(c.Param("enrolment_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[DeleteProgramEnrolment] Invalid enrolment ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid enrolment ID", nil)
+	}
+
+	err = h.repo.DeleteEnrolment(userId, enrolmentId)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Program enrolment not found", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[DeleteProgramEnrolment] Failed to delete program enrolment")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete program enrolment", nil)
+	}
+
+	Logger.Info().Msgf("[DeleteProgramEnrolment] Deleted program enrolment %d for user %d", enrolmentId, userId)
+	return helper.JsonResponse(c, http.StatusOK, map[string]string{"message": "Program enrolment deleted successfully"})
+}
+
+// GetProgramSession returns the session an enrolment prescribes on ?date
+// (default today), or null with the date of the next one on a rest day
+func (h *WorkoutProgramHandlers) GetProgramSession(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	enrolmentId, err := strconv.Atoi(c.Param("enrolment_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetProgramSession] Invalid enrolment ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid enrolment ID", nil)
+	}
+	req := validator.GetValidatedQuery(c).(*validator.ProgramSessionQuery)
+	date, err := parseLocalDate(req.Date, models.LoadUserLocation(userId))
+	if err != nil {
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid date format, use YYYY-MM-DD", nil)
+	}
+
+	enrolment, err := h.repo.FindEnrolment(userId, enrolmentId)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Program enrolment not found", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[GetProgramSession] Failed to get program enrolment")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get program session", nil)
+	}
+
+	response := map[string]interface{}{
+		"date":      date.Format(models.SQLDateFormat),
+		"enrolment": enrolment,
+		"session":   nil,
+		"next_date": nil,
+	}
+	if week, day, ok := enrolment.ProgramDayOn(date); ok {
+		if programDay, found := enrolment.Weeks.Find(week, day); found {
+			session, err := h.resolveSession(userId, enrolment, week, programDay)
+			if err != nil {
+				Logger.Error().Err(err).Msg("[GetProgramSession] Failed to resolve program session")
+				return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to get program session", nil)
+			}
+			session.AttachUnits(validator.GetRequestUnits(c))
+			response["session"] = session
+		}
+	}
+	if week, day, ok := enrolment.NextSessionFrom(date.AddDate(0, 0, 1)); ok {
+		response["next_date"] = enrolment.DateOf(week, day).Format(models.SQLDateFormat)
+	}
+	return helper.JsonResponse(c, http.StatusOK, response)
+}
+
+// StartProgramSession logs a workout pre-filled from a prescribed session:
+// the given week and day, or the one falling on today
+func (h *WorkoutProgramHandlers) StartProgramSession(c echo.Context) error {
+	var userId int = 1 // Replace with actual user ID retrieval logic
+
+	enrolmentId, err := strconv.Atoi(c.Param("enrolment_id"))
+	if err != nil {
+		Logger.Error().Err(err).Msg("[StartProgramSession] Invalid enrolment ID parameter")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid enrolment ID", nil)
+	}
+	req, ok := validator.GetValidatedRequest(c).(*validator.ProgramSessionStartRequest)
+	if !ok {
+		Logger.Error().Msg("[StartProgramSession] Failed to cast validated request to ProgramSessionStartRequest")
+		return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
+	}
+
+	enrolment, err := h.repo.FindEnrolment(userId, enrolmentId)
+	if err == sql.ErrNoRows {
+		return helper.ErrorResponse(c, http.StatusNotFound, "Program enrolment not found", nil)
+	}
+	if err != nil {
+		Logger.Error().Err(err).Msg("[StartProgramSession] Failed to get program enrolment")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to start program session", nil)
+	}
+
+	week, day := req.Week, req.Day
+	if week == 0 {
+		today, _ := parseLocalDate("", models.LoadUserLocation(userId))
+		week, day, _ = enrolment.ProgramDayOn(today)
+	}
+	programDay, found := enrolment.Weeks.Find(week, day)
+	if !found {
+		return helper.ErrorResponse(c, http.StatusUnprocessableEntity, models.ErrProgramSessionNotScheduled.Error(), nil)
+	}
+
+	session, err := h.resolveSession(userId, enrolment, week, programDay)
+	if err != nil {
+		Logger.Error().Err(err).Msg("[StartProgramSession] Failed to resolve program session")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to start program session", nil)
+	}
+	workout := session.ToWorkout(userId, time.Now())
+	if err = h.repo.StartSession(enrolmentId, week, day, workout); err != nil {
+		Logger.Error().Err(err).Msg("[StartProgramSession] Failed to start program session")
+		return helper.ErrorResponse(c, http.StatusInternalServerError, "Failed to start program session", nil)
+	}
+
+	Logger.Info().Msgf("[StartProgramSession] Started week %d day %d of enrolment %d as workout %d for user %d", week, day, enrolmentId, workout.WorkoutId, userId)
+	refreshPersonalRecords(h.recordRepo, "StartProgramSession", userId, models.WorkoutPersonalRecordExercises(workout))
+	units := validator.GetRequestUnits(c)
+	workout.Units = &units
+	return helper.JsonResponse(c, http.StatusCreated, workout)
+}
+
+// resolveSession prescribes a program day off the user's current best
+// estimated one-rep maxes, with the workout it was started as and whether that
+// workout has ended
+func (h *WorkoutProgramHandlers) resolveSession(userId int, enrolment *models.ProgramEnrolment, week int, programDay *models.ProgramDay) (models.ProgramSession, error) {
+	records, err := h.recordRepo.GetCurrent(userId)
+	if err != nil && err != sql.ErrNoRows {
+		return models.ProgramSession{}, err
+	}
+	oneRepMaxes := map[string]float64{}
+	for _, record := range records {
+		if record.Kind == models.PersonalRecordOneRepMax {
+			oneRepMaxes[record.ExerciseKey] = record.Value
+		}
+	}
+
+	session := models.ResolveProgramSession(*enrolment, week, *programDay, oneRepMaxes)
+	workoutId, completed, err := h.repo.FindSessionWorkout(enrolment.EnrolmentId, week, programDay.Day)
+	if err != nil {
+		return models.ProgramSession{}, err
+	}
+	session.WorkoutId, session.Completed = workoutId, completed
+	return session, nil
+}
+
+// programRequestCatalogIds returns the catalog ids a program request prescribes
+func programRequestCatalogIds(req *validator.WorkoutProgramRequest) []int {
+	var ids []int
+	for _, weekReq := range req.Weeks {
+		for _, dayReq := range weekReq.Days {
+			for _, exerciseReq := range dayReq.Exercises {
+				if exerciseReq.CatalogId != 0 {
+					ids = append(ids, exerciseReq.CatalogId)
+				}
+			}
+		}
+	}
+	return ids
+}
+
+// workoutProgramFromRequest maps a validated program request. Catalog
+// exercises get their catalog name when none is given; catalog ids missing
+// from catalog and a day prescribed twice in a week are reported as validation errors.
+func workoutProgramFromRequest(userId int, req *validator.WorkoutProgramRequest, catalog map[int]models.ExerciseCatalogEntry) (*models.WorkoutProgram, []validator.ValidationError) {
+	var errs []validator.ValidationError
+	program := &models.WorkoutProgram{
+		UserId:      userId,
+		Name:        req.Name,
+		Description: optionalText(req.Description),
+		Weeks:       make(models.ProgramWeeks, 0, len(req.Weeks)),
+	}
+
+	for i, weekReq := range req.Weeks {
+		week := models.ProgramWeek{Days: make([]models.ProgramDay, 0, len(weekReq.Days))}
+		seen := map[int]bool{}
+		for j, dayReq := range weekReq.Days {
+			field := "weeks[" + strconv.Itoa(i) + "].days[" + strconv.Itoa(j) + "]"
+			if seen[dayReq.Day] {
+				errs = append(errs, validator.ValidationError{Field: field + ".day", Message: "day is already prescribed in this week", Tag: "unique"})
+				continue
+			}
+			seen[dayReq.Day] = true
+
+			day := models.ProgramDay{Day: dayReq.Day, Name: dayReq.Name, Exercises: make([]models.ProgramExercise, 0, len(dayReq.Exercises))}
+			for k, exerciseReq := range dayReq.Exercises {
+				exercise := models.ProgramExercise{
+					Name:       exerciseReq.Name,
+					Sets:       exerciseReq.Sets,
+					Reps:       exerciseReq.Reps,
+					Percentage: optionalMeasurement(exerciseReq.Percentage),
+					WeightKg:   optionalMeasurement(exerciseReq.Weight),
+					Amrap:      exerciseReq.Amrap,
+					Notes:      optionalText(exerciseReq.Notes),
+				}
+				if exerciseReq.Minutes != 0 {
+					minutes := exerciseReq.Minutes
+					exercise.Minutes = &minutes
+				}
+				if exerciseReq.CatalogId != 0 {
+					entry, ok := catalog[exerciseReq.CatalogId]
+					if !ok {
+						errs = append(errs, validator.ValidationError{Field: field + ".exercises[" + strconv.Itoa(k) + "].catalog_id", Message: "Unknown catalog exercise", Tag: "catalog"})
+						continue
+					}
+					exercise.CatalogId = &entry.CatalogId
+					if exercise.Name == "" {
+						exercise.Name = entry.Name
+					}
+				}
+				day.Exercises = append(day.Exercises, exercise)
+			}
+			week.Days = append(week.Days, day)
+		}
+		program.Weeks = append(program.Weeks, week)
+	}
+	return program, errs
+}
@@ -0,0 +1,179 @@
+package blobstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// s3Request is what the fake S3 server saw of one request
+type s3Request struct {
+	method        string
+	host          string
+	path          string
+	contentType   string
+	contentSha256 string
+	amzDate       string
+	authorization string
+	body          []byte
+}
+
+// newFakeS3 starts a server that records every request and answers with
+// status and body; the returned store sends all requests to it, whatever host
+// the bucket addressing produces
+func newFakeS3(t *testing.T, pathStyle bool, status int, body string) (*S3Store, *[]s3Request) {
+	t.Helper()
+	var requests []s3Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		requests = append(requests, s3Request{
+			method:        r.Method,
+			host:          r.Host,
+			path:          r.URL.EscapedPath(),
+			contentType:   r.Header.Get("Content-Type"),
+			contentSha256: r.Header.Get("X-Amz-Content-Sha256"),
+			amzDate:       r.Header.Get("X-Amz-Date"),
+			authorization: r.Header.Get("Authorization"),
+			body:          data,
+		})
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+		},
+	}
+	store, err := NewS3Store(S3Options{
+		Endpoint:  "http://s3.test",
+		Region:    "eu-west-1",
+		Bucket:    "photos",
+		AccessKey: "AKIDEXAMPLE",
+		SecretKey: "secret",
+		PathStyle: pathStyle,
+		Client:    &http.Client{Transport: transport},
+	})
+	if err != nil {
+		t.Fatalf("NewS3Store: %v", err)
+	}
+	return store, &requests
+}
+
+var s3Authorization = regexp.MustCompile(`^AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/(\d{8})/eu-west-1/s3/aws4_request, SignedHeaders=([a-z0-9;-]+), Signature=[0-9a-f]{64}$`)
+
+// checkSigned asserts the Signature V4 headers of a request
+func checkSigned(t *testing.T, req s3Request, signedHeaders string) {
+	t.Helper()
+	sum := sha256.Sum256(req.body)
+	if req.contentSha256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("X-Amz-Content-Sha256 = %q, want the sha256 of the body", req.contentSha256)
+	}
+	match := s3Authorization.FindStringSubmatch(req.authorization)
+	if match == nil {
+		t.Fatalf("Authorization = %q, not a Signature V4 header", req.authorization)
+	}
+	if !strings.HasPrefix(req.amzDate, match[1]+"T") {
+		t.Errorf("credential scope date %s does not match X-Amz-Date %s", match[1], req.amzDate)
+	}
+	if match[2] != signedHeaders {
+		t.Errorf("SignedHeaders = %s, want %s", match[2], signedHeaders)
+	}
+}
+
+func TestS3StoreRequests(t *testing.T) {
+	tests := []struct {
+		name      string
+		pathStyle bool
+		key       string
+		wantHost  string
+		wantPath  string
+	}{
+		{"path style", true, "users/1/photo.jpg", "s3.test", "/photos/users/1/photo.jpg"},
+		{"virtual hosted", false, "users/1/photo.jpg", "photos.s3.test", "/users/1/photo.jpg"},
+		{"escaped key", true, "users/1/my photo+1.jpg", "s3.test", "/photos/users/1/my%20photo%2B1.jpg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, requests := newFakeS3(t, tt.pathStyle, http.StatusOK, "stored bytes")
+			ctx := context.Background()
+
+			if err := store.Put(ctx, tt.key, []byte("jpeg bytes"), "image/jpeg"); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			reader, err := store.Get(ctx, tt.key)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			data, _ := io.ReadAll(reader)
+			reader.Close()
+			if string(data) != "stored bytes" {
+				t.Errorf("Get returned %q", data)
+			}
+			if err := store.Delete(ctx, tt.key); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+
+			if len(*requests) != 3 {
+				t.Fatalf("got %d requests, want 3", len(*requests))
+			}
+			for i, want := range []string{http.MethodPut, http.MethodGet, http.MethodDelete} {
+				req := (*requests)[i]
+				if req.method != want {
+					t.Errorf("request %d method = %s, want %s", i, req.method, want)
+				}
+				if req.host != tt.wantHost {
+					t.Errorf("%s host = %s, want %s", req.method, req.host, tt.wantHost)
+				}
+				if req.path != tt.wantPath {
+					t.Errorf("%s path = %s, want %s", req.method, req.path, tt.wantPath)
+				}
+			}
+
+			put := (*requests)[0]
+			if string(put.body) != "jpeg bytes" || put.contentType != "image/jpeg" {
+				t.Errorf("PUT sent %q as %q", put.body, put.contentType)
+			}
+			checkSigned(t, put, "content-type;host;x-amz-content-sha256;x-amz-date")
+			checkSigned(t, (*requests)[1], "host;x-amz-content-sha256;x-amz-date")
+			checkSigned(t, (*requests)[2], "host;x-amz-content-sha256;x-amz-date")
+		})
+	}
+}
+
+func TestS3StoreErrors(t *testing.T) {
+	ctx := context.Background()
+
+	store, _ := newFakeS3(t, true, http.StatusNotFound, "<Error><Code>NoSuchKey</Code></Error>")
+	if _, err := store.Get(ctx, "missing.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of a missing object = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, "missing.jpg"); err != nil {
+		t.Errorf("Delete of a missing object = %v, want nil", err)
+	}
+
+	store, _ = newFakeS3(t, true, http.StatusForbidden, "<Error><Code>AccessDenied</Code></Error>")
+	err := store.Put(ctx, "photo.jpg", []byte("x"), "image/jpeg")
+	if err == nil || !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("Put on a denied bucket = %v, want the status and message", err)
+	}
+
+	store, requests := newFakeS3(t, true, http.StatusOK, "")
+	for _, key := range []string{"", "/abs.jpg", "a/../b.jpg", "a//b.jpg"} {
+		if err := store.Put(ctx, key, nil, ""); err == nil {
+			t.Errorf("Put(%q) succeeded, want an invalid key error", key)
+		}
+	}
+	if len(*requests) != 0 {
+		t.Errorf("invalid keys sent %d requests", len(*requests))
+	}
+}
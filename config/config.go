@@ -1,6 +1,9 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
 	"log"
 	"os"
 	"strconv"
@@ -37,6 +40,35 @@ type Config struct {
 
 	// System Variables
 	PaginationDefaultPageSize int
+
+	// Chart Range Limits per subscription tier
+	ChartRange ChartRangeConfig
+
+	// Blob Storage Configuration
+	Storage StorageConfig
+}
+
+// StorageConfig holds blob storage configuration. Driver is "local" or "s3";
+// the S3 settings work with any S3-compatible service such as MinIO.
+type StorageConfig struct {
+	Driver           string
+	LocalDir         string
+	S3Endpoint       string
+	S3Region         string
+	S3Bucket         string
+	S3AccessKey      string
+	S3SecretKey      string
+	S3PathStyle      bool
+	SignedURLSecret  string
+	SignedURLTTLSecs int
+	MaxUploadBytes   int
+}
+
+// ChartRangeConfig holds the maximum chart range in days for each subscription tier
+type ChartRangeConfig struct {
+	FreeDays        int
+	PremiumDays     int
+	PremiumPlusDays int
 }
 
 // JWTConfig holds JWT configuration
@@ -133,11 +165,63 @@ func Load() (*Config, error) {
 			},
 		},
 		PaginationDefaultPageSize: getEnvAsInt("PAGINATION_DEFAULT_PAGE_SIZE", 20),
+		ChartRange: ChartRangeConfig{
+			FreeDays:        getEnvAsInt("CHART_RANGE_FREE_DAYS", 90),
+			PremiumDays:     getEnvAsInt("CHART_RANGE_PREMIUM_DAYS", 366),
+			PremiumPlusDays: getEnvAsInt("CHART_RANGE_PREMIUM_PLUS_DAYS", 1830),
+		},
+		Storage: StorageConfig{
+			Driver:           getEnv("STORAGE_DRIVER", "local"),
+			LocalDir:         getEnv("STORAGE_LOCAL_DIR", "./uploads"),
+			S3Endpoint:       getEnv("STORAGE_S3_ENDPOINT", ""),
+			S3Region:         getEnv("STORAGE_S3_REGION", "us-east-1"),
+			S3Bucket:         getEnv("STORAGE_S3_BUCKET", ""),
+			S3AccessKey:      getEnv("STORAGE_S3_ACCESS_KEY", ""),
+			S3SecretKey:      getEnv("STORAGE_S3_SECRET_KEY", ""),
+			S3PathStyle:      getEnv("STORAGE_S3_PATH_STYLE", "true") == "true",
+			SignedURLSecret:  getEnv("STORAGE_SIGNED_URL_SECRET", ""),
+			SignedURLTTLSecs: getEnvAsInt("STORAGE_SIGNED_URL_TTL_SECONDS", 300),
+			MaxUploadBytes:   getEnvAsInt("STORAGE_MAX_UPLOAD_BYTES", 10<<20),
+		},
+	}
+
+	if err := resolveSignedURLSecret(config); err != nil {
+		return nil, err
 	}
 
 	return config, nil
 }
 
+// resolveSignedURLSecret makes sure signed URLs use their own secret. In
+// production STORAGE_SIGNED_URL_SECRET must be set and differ from the JWT
+// secret; elsewhere a missing secret is replaced by a random one, so signed
+// URLs stop working after a restart.
+func resolveSignedURLSecret(config *Config) error {
+	secret := config.Storage.SignedURLSecret
+	if config.Env == "production" {
+		if secret == "" {
+			return fmt.Errorf("STORAGE_SIGNED_URL_SECRET must be set in production")
+		}
+		if secret == config.JWT.Secret {
+			return fmt.Errorf("STORAGE_SIGNED_URL_SECRET must differ from JWT_SECRET")
+		}
+		return nil
+	}
+	if secret != "" {
+		return nil
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return fmt.Errorf("error generating signed URL secret: %w", err)
+	}
+	config.Storage.SignedURLSecret = hex.EncodeToString(random)
+	if Logger != nil {
+		Logger.Warn().Msg("STORAGE_SIGNED_URL_SECRET not set, using a random secret until restart")
+	}
+	return nil
+}
+
 // Get returns the loaded configuration
 func Get() *Config {
 	if config == nil {
@@ -0,0 +1,38 @@
+package config
+
+import "testing"
+
+func TestResolveSignedURLSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		secret  string
+		wantErr bool
+		random  bool
+	}{
+		{"production without secret", "production", "", true, false},
+		{"production reusing the JWT secret", "production", "jwt-secret", true, false},
+		{"production with its own secret", "production", "url-secret", false, false},
+		{"development with its own secret", "development", "url-secret", false, false},
+		{"development without secret", "development", "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Env: tt.env, JWT: JWTConfig{Secret: "jwt-secret"}, Storage: StorageConfig{SignedURLSecret: tt.secret}}
+			err := resolveSignedURLSecret(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveSignedURLSecret() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got := cfg.Storage.SignedURLSecret
+			switch {
+			case tt.random && (len(got) != 64 || got == cfg.JWT.Secret):
+				t.Errorf("secret = %q, want 32 random bytes in hex", got)
+			case !tt.random && got != tt.secret:
+				t.Errorf("secret = %q, want %q", got, tt.secret)
+			}
+		})
+	}
+}
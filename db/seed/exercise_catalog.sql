@@ -0,0 +1,112 @@
+-- exercise_catalog holds the exercises users can log by catalog id. Its rows
+-- come from the Compendium of Physical Activities (2011): compendium_code is
+-- the compendium activity an exercise is based on, and met_low, met_medium
+-- and met_high are the light, moderate and vigorous effort of the activity.
+-- Resistance exercises share the compendium's resistance training values.
+--
+-- Ids are stored on exercise records, workouts and personal records: they
+-- must never be reused or renumbered. Running this file again updates the
+-- seeded rows in place.
+
+CREATE TABLE IF NOT EXISTS exercise_catalog (
+    catalog_id      INT PRIMARY KEY,
+    name            TEXT NOT NULL,
+    category        TEXT NOT NULL,
+    compendium_code TEXT,
+    met_low         NUMERIC(4, 1) NOT NULL,
+    met_medium      NUMERIC(4, 1) NOT NULL,
+    met_high        NUMERIC(4, 1) NOT NULL,
+    muscle_groups   JSONB NOT NULL DEFAULT '[]'
+);
+
+INSERT INTO exercise_catalog
+    (catalog_id, name, category, compendium_code, met_low, met_medium, met_high, muscle_groups)
+VALUES
+    (1, 'Walking', 'Cardio', '17200', 2.8, 3.5, 5.0, '["quadriceps", "hamstrings", "calves"]'),
+    (2, 'Hiking', 'Cardio', '17080', 5.3, 6.0, 7.8, '["quadriceps", "glutes", "calves"]'),
+    (3, 'Jogging', 'Cardio', '12020', 6.0, 7.0, 8.3, '["quadriceps", "hamstrings", "calves"]'),
+    (4, 'Running', 'Cardio', '12050', 8.3, 9.8, 11.8, '["quadriceps", "hamstrings", "glutes", "calves"]'),
+    (5, 'Cycling', 'Cardio', '01040', 5.8, 8.0, 10.0, '["quadriceps", "glutes", "calves"]'),
+    (6, 'Stationary cycling', 'Cardio', '02014', 3.5, 6.8, 8.8, '["quadriceps", "glutes", "calves"]'),
+    (7, 'Elliptical trainer', 'Cardio', '02048', 4.0, 5.0, 7.0, '["quadriceps", "glutes", "full_body"]'),
+    (8, 'Rowing machine', 'Cardio', '02072', 4.8, 7.0, 8.5, '["back", "quadriceps", "full_body"]'),
+    (9, 'Swimming freestyle', 'Cardio', '18240', 5.8, 8.3, 9.8, '["shoulders", "back", "full_body"]'),
+    (10, 'Swimming breaststroke', 'Cardio', '18250', 5.3, 7.0, 10.3, '["chest", "quadriceps", "full_body"]'),
+    (11, 'Stair climbing', 'Cardio', '17133', 4.0, 6.8, 9.0, '["quadriceps", "glutes", "calves"]'),
+    (12, 'Jump rope', 'Cardio', '15552', 8.8, 11.8, 12.3, '["calves", "shoulders", "full_body"]'),
+    (13, 'Aerobics', 'Cardio', '03015', 5.0, 7.3, 8.5, '["full_body"]'),
+    (14, 'Dancing', 'Cardio', '03025', 3.0, 4.5, 7.8, '["full_body"]'),
+    (15, 'Cross-country skiing', 'Cardio', '19090', 6.8, 9.0, 12.5, '["full_body"]'),
+    (16, 'Inline skating', 'Cardio', '19190', 7.5, 9.8, 12.3, '["quadriceps", "glutes"]'),
+
+    (17, 'Circuit training', 'HIT', '02040', 4.3, 6.0, 8.0, '["full_body"]'),
+    (18, 'Calisthenics', 'HIT', '02022', 2.8, 3.8, 8.0, '["full_body"]'),
+    (19, 'Kettlebell training', 'HIT', '02040', 6.0, 8.0, 9.8, '["glutes", "hamstrings", "shoulders", "core"]'),
+    (20, 'Boxing', 'HIT', '15120', 5.5, 7.8, 12.8, '["shoulders", "core", "full_body"]'),
+    (21, 'Burpees', 'HIT', '02022', 2.8, 3.8, 8.0, '["full_body"]'),
+
+    (22, 'Resistance training', 'WeightLifting', '02052', 3.5, 5.0, 6.0, '["full_body"]'),
+    (23, 'Bench press', 'WeightLifting', '02052', 3.5, 5.0, 6.0, '["chest", "triceps", "shoulders"]'),
+    (24, 'Incline bench press', 'WeightLifting', '02052', 3.5, 5.0, 6.0, '["chest", "shoulders", "triceps"]'),
+    (25, 'Squat', 'WeightLifting', '02052', 3.5, 5.0, 6.0, '["quadriceps", "glutes", "hamstrings"]'),
+    (26, 'Front squat', 'WeightLifting', '02052', 3.5, 5.0, 6.0, '["quadriceps", "glutes", "core"]'),
+    (27, 'Deadlift', 'WeightLifting', '02052', 3.5, 5.0, 6.0, '["hamstrings", "glutes", "back"]'),
+    (28, 'Romanian deadlift', 'WeightLifting', '02052', 3.5, 5.0, 6.0, '["hamstrings", "glutes"]'),
+    (29, 'Overhead press', 'WeightLifting', '02052', 3.5, 5.0, 6.0, '["shoulders", "triceps"]'),
+    (30, 'Barbell row', 'WeightLifting', '02052', 3.5, 5.0, 6.0, '["back", "biceps"]'),
+    (31, 'Pull-up', 'WeightLifting', '02022', 2.8, 3.8, 8.0, '["back", "biceps"]'),
+    (32, 'Chin-up', 'WeightLifting', '02022', 2.8, 3.8, 8.0, '["back", "biceps"]'),
+    (33, 'Lat pulldown', 'WeightLifting', '02052', 3.5, 5.0, 6.0, '["back", "biceps"]'),
+    (34, 'Push-up', 'WeightLifting', '02022', 2.8, 3.8, 8.0, '["chest", "triceps", "shoulders"]'),
+    (35, 'Dip', 'WeightLifting', '02022', 2.8, 3.8, 8.0, '["triceps", "chest"]'),
+    (36, 'Lunge', 'WeightLifting', '02052', 3.5, 5.0, 6.0, '["quadriceps", "glutes"]'),
+    (37, 'Leg press', 'WeightLifting', '02052', 3.5, 5.0, 6.0, '["quadriceps", "glutes"]'),
+    (38, 'Leg curl', 'WeightLifting', '02052', 3.5, 5.0, 6.0, '["hamstrings"]'),
+    (39, 'Leg extension', 'WeightLifting', '02052', 3.5, 5.0, 6.0, '["quadriceps"]'),
+    (40, 'Hip thrust', 'WeightLifting', '02052', 3.5, 5.0, 6.0, '["glutes", "hamstrings"]'),
+    (41, 'Calf raise', 'WeightLifting', '02052', 3.5, 5.0, 6.0, '["calves"]'),
+    (42, 'Biceps curl', 'WeightLifting', '02052', 3.5, 5.0, 6.0, '["biceps", "forearms"]'),
+    (43, 'Triceps extension', 'WeightLifting', '02052', 3.5, 5.0, 6.0, '["triceps"]'),
+    (44, 'Lateral raise', 'WeightLifting', '02052', 3.5, 5.0, 6.0, '["shoulders"]'),
+    (45, 'Chest fly', 'WeightLifting', '02052', 3.5, 5.0, 6.0, '["chest"]'),
+    (46, 'Plank', 'WeightLifting', '02022', 2.8, 3.8, 8.0, '["core"]'),
+    (47, 'Crunch', 'WeightLifting', '02022', 2.8, 3.8, 8.0, '["core"]'),
+
+    (48, 'Basketball', 'Sports', '15055', 4.5, 6.5, 8.0, '["full_body"]'),
+    (49, 'Soccer', 'Sports', '15610', 5.0, 7.0, 10.0, '["quadriceps", "hamstrings", "calves"]'),
+    (50, 'Tennis', 'Sports', '15675', 5.0, 7.3, 8.0, '["shoulders", "full_body"]'),
+    (51, 'Badminton', 'Sports', '15030', 4.5, 5.5, 7.0, '["shoulders", "full_body"]'),
+    (52, 'Volleyball', 'Sports', '15710', 3.0, 4.0, 8.0, '["shoulders", "quadriceps"]'),
+    (53, 'Table tennis', 'Sports', '15660', 3.0, 4.0, 5.0, '["shoulders", "forearms"]'),
+    (54, 'Martial arts', 'Sports', '15430', 5.3, 7.5, 10.3, '["full_body"]'),
+    (55, 'Rock climbing', 'Sports', '15535', 5.8, 7.5, 8.0, '["back", "forearms", "biceps"]'),
+    (56, 'Golf', 'Sports', '15265', 3.5, 4.3, 5.3, '["core", "shoulders"]'),
+
+    (57, 'Yoga', 'Flexibility', '02150', 2.5, 3.3, 4.0, '["core", "full_body"]'),
+    (58, 'Pilates', 'Flexibility', '02105', 2.8, 3.0, 3.8, '["core"]'),
+    (59, 'Stretching', 'Flexibility', '02101', 2.3, 2.5, 2.8, '["full_body"]'),
+    (60, 'Tai chi', 'Flexibility', '15670', 2.0, 3.0, 4.0, '["full_body"]'),
+
+    (61, 'Gardening', 'Other', '08245', 2.3, 3.8, 5.0, '["full_body"]'),
+    (62, 'House cleaning', 'Other', '05040', 2.3, 3.3, 3.8, '["full_body"]')
+ON CONFLICT (catalog_id) DO UPDATE SET
+    name = EXCLUDED.name,
+    category = EXCLUDED.category,
+    compendium_code = EXCLUDED.compendium_code,
+    met_low = EXCLUDED.met_low,
+    met_medium = EXCLUDED.met_medium,
+    met_high = EXCLUDED.met_high,
+    muscle_groups = EXCLUDED.muscle_groups;
+
+-- Every catalog_id stored elsewhere references a catalog exercise
+ALTER TABLE excercise_record DROP CONSTRAINT IF EXISTS excercise_record_catalog_id_fkey;
+ALTER TABLE excercise_record ADD CONSTRAINT excercise_record_catalog_id_fkey
+    FOREIGN KEY (catalog_id) REFERENCES exercise_catalog (catalog_id);
+
+ALTER TABLE workout_exercise DROP CONSTRAINT IF EXISTS workout_exercise_catalog_id_fkey;
+ALTER TABLE workout_exercise ADD CONSTRAINT workout_exercise_catalog_id_fkey
+    FOREIGN KEY (catalog_id) REFERENCES exercise_catalog (catalog_id);
+
+ALTER TABLE personal_record DROP CONSTRAINT IF EXISTS personal_record_catalog_id_fkey;
+ALTER TABLE personal_record ADD CONSTRAINT personal_record_catalog_id_fkey
+    FOREIGN KEY (catalog_id) REFERENCES exercise_catalog (catalog_id);
@@ -0,0 +1,233 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+// ErrUnsupportedImage is returned for uploads that are not a decodable JPEG or PNG
+var ErrUnsupportedImage = errors.New("unsupported image, upload a JPEG or PNG")
+
+// ErrImageTooLarge is returned when the pixel dimensions exceed MaxImagePixels
+var ErrImageTooLarge = errors.New("image dimensions are too large")
+
+const (
+	// MaxImagePixels caps width × height so a small file can't decode into gigabytes
+	MaxImagePixels = 50_000_000
+	// ThumbnailMaxSide is the longest side of a generated thumbnail
+	ThumbnailMaxSide = 320
+
+	jpegQuality      = 90
+	thumbnailQuality = 80
+)
+
+// ProcessedImage is an upload re-encoded without metadata, plus its thumbnail
+type ProcessedImage struct {
+	Data          []byte
+	ContentType   string
+	Width         int
+	Height        int
+	Thumbnail     []byte
+	ThumbnailType string
+}
+
+// ProcessUploadedImage sniffs the content type from the bytes themselves,
+// applies the EXIF orientation, and re-encodes the pixels. Re-encoding drops
+// every metadata segment (EXIF, GPS, XMP, comments) because Go's encoders
+// write none. The thumbnail is always a JPEG.
+func ProcessUploadedImage(data []byte) (*ProcessedImage, error) {
+	contentType := http.DetectContentType(data)
+	if contentType != "image/jpeg" && contentType != "image/png" {
+		return nil, ErrUnsupportedImage
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if cfg.Width*cfg.Height > MaxImagePixels {
+		return nil, ErrImageTooLarge
+	}
+
+	var img image.Image
+	if contentType == "image/jpeg" {
+		if img, err = jpeg.Decode(bytes.NewReader(data)); err != nil {
+			return nil, ErrUnsupportedImage
+		}
+		img = applyOrientation(img, jpegOrientation(data))
+	} else if img, err = png.Decode(bytes.NewReader(data)); err != nil {
+		return nil, ErrUnsupportedImage
+	}
+
+	var full bytes.Buffer
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&full, img, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(&full, img)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var thumb bytes.Buffer
+	if err = jpeg.Encode(&thumb, Thumbnail(img, ThumbnailMaxSide), &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	return &ProcessedImage{
+		Data:          full.Bytes(),
+		ContentType:   contentType,
+		Width:         bounds.Dx(),
+		Height:        bounds.Dy(),
+		Thumbnail:     thumb.Bytes(),
+		ThumbnailType: "image/jpeg",
+	}, nil
+}
+
+// Thumbnail scales img down so its longest side is maxSide, averaging every
+// source pixel that falls into a destination pixel. Smaller images are copied.
+// Transparent areas are flattened onto white since thumbnails are JPEGs.
+func Thumbnail(img image.Image, maxSide int) *image.RGBA {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	dstW, dstH := srcW, srcH
+	if srcW >= srcH && srcW > maxSide {
+		dstW, dstH = maxSide, max(1, srcH*maxSide/srcW)
+	} else if srcH > srcW && srcH > maxSide {
+		dstW, dstH = max(1, srcW*maxSide/srcH), maxSide
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0, y1 := bounds.Min.Y+y*srcH/dstH, bounds.Min.Y+(y+1)*srcH/dstH
+		y1 = max(y1, y0+1)
+		for x := 0; x < dstW; x++ {
+			x0, x1 := bounds.Min.X+x*srcW/dstW, bounds.Min.X+(x+1)*srcW/dstW
+			x1 = max(x1, x0+1)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa), n+1
+				}
+			}
+			// Premultiplied channels over white: c + (1 - alpha)
+			white := 0xffff*n - a
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8((r + white) / n >> 8),
+				G: uint8((g + white) / n >> 8),
+				B: uint8((b + white) / n >> 8),
+				A: 0xff,
+			})
+		}
+	}
+	return dst
+}
+
+// jpegOrientation returns the EXIF orientation (1-8) of a JPEG, 1 when absent
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xff {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xda || marker == 0xd9 {
+			// Start of scan or end of image: no more metadata segments
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xe1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+// exifOrientation reads tag 0x0112 from the first IFD of a TIFF structure
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < entries; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			value := int(order.Uint16(tiff[entry+8:]))
+			if value >= 1 && value <= 8 {
+				return value
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// applyOrientation rotates and flips img so it displays upright without EXIF
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				dx, dy = w-1-x, y
+			case 3: // rotated 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // mirrored along the top-left diagonal
+				dx, dy = y, x
+			case 6: // needs a 90° clockwise rotation
+				dx, dy = h-1-y, x
+			case 7: // mirrored along the top-right diagonal
+				dx, dy = h-1-y, w-1-x
+			case 8: // needs a 90° counter-clockwise rotation
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, img.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+	return dst
+}
@@ -1,12 +1,22 @@
 package helper
 
-import "time"
+import (
+	"time"
 
-// TimeIn :
-func TimeInWIB(t time.Time) (time.Time, error) {
-	loc, err := time.LoadLocation("Asia/Jakarta")
+	// Embedded zone database so per-user IANA zones resolve on minimal hosts
+	_ "time/tzdata"
+)
+
+// TimeIn : converts t into the named IANA zone
+func TimeIn(t time.Time, name string) (time.Time, error) {
+	loc, err := time.LoadLocation(name)
 	if err == nil {
 		t = t.In(loc)
 	}
 	return t, err
 }
+
+// TimeIn :
+func TimeInWIB(t time.Time) (time.Time, error) {
+	return TimeIn(t, "Asia/Jakarta")
+}
@@ -0,0 +1,451 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// Activity file formats
+const (
+	ActivityFormatGPX = "gpx"
+	ActivityFormatTCX = "tcx"
+	ActivityFormatFIT = "fit"
+)
+
+// Sports detected from activity files, mapped onto the exercise catalog
+const (
+	ActivitySportRunning = "running"
+	ActivitySportCycling = "cycling"
+	ActivitySportWalking = "walking"
+	ActivitySportHiking  = "hiking"
+)
+
+// ActivitySportCatalogIds is the catalog exercise logged for each sport
+var ActivitySportCatalogIds = map[string]int{
+	ActivitySportWalking: 1,
+	ActivitySportHiking:  2,
+	ActivitySportRunning: 4,
+	ActivitySportCycling: 5,
+}
+
+const (
+	// earthRadiusKm is the mean earth radius used by the haversine distance
+	earthRadiusKm = 6371.0088
+	// activityMovingSpeedMps is the speed below which time counts as stopped
+	activityMovingSpeedMps = 0.5
+	// activityElevationNoiseM is the climb needed before it counts as gain,
+	// filtering out GPS and barometer jitter
+	activityElevationNoiseM = 3.0
+	// MaxActivityTrackPoints caps the points of a parsed file
+	MaxActivityTrackPoints = 200000
+	// activityRouteMaxPoints caps the points of a stored route; longer tracks
+	// are thinned evenly, keeping the first and last point
+	activityRouteMaxPoints = 2000
+)
+
+// TrackPoint is one recorded point of an activity. Points without a GPS fix,
+// e.g. indoors, have no position.
+type TrackPoint struct {
+	Time      time.Time `json:"time"`
+	Lat       *float64  `json:"lat,omitempty"`
+	Lon       *float64  `json:"lon,omitempty"`
+	Elevation *float64  `json:"ele,omitempty"`
+	HeartRate *int      `json:"hr,omitempty"`
+}
+
+// hasPosition reports whether the point has a GPS fix
+func (p TrackPoint) hasPosition() bool {
+	return p.Lat != nil && p.Lon != nil
+}
+
+// ActivityTrack is a parsed activity file
+type ActivityTrack struct {
+	Format string
+	Name   string
+	Sport  string
+	Points []TrackPoint
+}
+
+// ActivitySplit is one kilometre of an activity; the last split may be shorter
+type ActivitySplit struct {
+	Km            int      `json:"km"`
+	DistanceKm    float64  `json:"distance_km"`
+	Seconds       float64  `json:"seconds"`
+	PaceSecPerKm  float64  `json:"pace_sec_per_km"`
+	SpeedKmh      float64  `json:"speed_kmh"`
+	ElevationGain float64  `json:"elevation_gain_m"`
+	AvgHeartRate  *float64 `json:"avg_hr,omitempty"`
+}
+
+// ActivityMetrics are the metrics of a GPS activity, stored as JSONB on its
+// exercise record. Pace and speed are over the moving time.
+type ActivityMetrics struct {
+	Format         string          `json:"format"`
+	Sport          string          `json:"sport,omitempty"`
+	DistanceKm     float64         `json:"distance_km"`
+	ElapsedSeconds int             `json:"elapsed_seconds"`
+	MovingSeconds  int             `json:"moving_seconds"`
+	ElevationGain  float64         `json:"elevation_gain_m"`
+	AvgPace        *float64        `json:"avg_pace_sec_per_km,omitempty"`
+	AvgSpeedKmh    *float64        `json:"avg_speed_kmh,omitempty"`
+	MaxSpeedKmh    *float64        `json:"max_speed_kmh,omitempty"`
+	AvgHeartRate   *float64        `json:"avg_hr,omitempty"`
+	MaxHeartRate   *int            `json:"max_hr,omitempty"`
+	Splits         []ActivitySplit `json:"splits"`
+	Points         int             `json:"points"`
+}
+
+// Scan implements the sql.Scanner interface
+func (m *ActivityMetrics) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into ActivityMetrics", value)
+	}
+	return sonic.Unmarshal(data, m)
+}
+
+// Value implements the driver.Valuer interface
+func (m ActivityMetrics) Value() (driver.Value, error) {
+	data, err := sonic.Marshal(m)
+	return string(data), err
+}
+
+// ActivityRoute is the thinned track of an activity kept for display, stored as JSONB
+type ActivityRoute []TrackPoint
+
+// Scan implements the sql.Scanner interface
+func (r *ActivityRoute) Scan(value interface{}) error {
+	if value == nil {
+		*r = nil
+		return nil
+	}
+
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into ActivityRoute", value)
+	}
+	return sonic.Unmarshal(data, r)
+}
+
+// Value implements the driver.Valuer interface
+func (r ActivityRoute) Value() (driver.Value, error) {
+	data, err := sonic.Marshal([]TrackPoint(r))
+	return string(data), err
+}
+
+// DetectActivityFormat picks the format of an activity file from its name,
+// falling back to sniffing its content
+func DetectActivityFormat(filename string, data []byte) string {
+	switch {
+	case strings.HasSuffix(strings.ToLower(filename), ".gpx"):
+		return ActivityFormatGPX
+	case strings.HasSuffix(strings.ToLower(filename), ".tcx"):
+		return ActivityFormatTCX
+	case strings.HasSuffix(strings.ToLower(filename), ".fit"):
+		return ActivityFormatFIT
+	case len(data) >= 12 && string(data[8:12]) == ".FIT":
+		return ActivityFormatFIT
+	case bytes.Contains(data[:min(len(data), 1024)], []byte("<gpx")):
+		return ActivityFormatGPX
+	case bytes.Contains(data[:min(len(data), 1024)], []byte("<TrainingCenterDatabase")):
+		return ActivityFormatTCX
+	}
+	return ""
+}
+
+// ParseActivityFile reads the track points of a GPX, TCX or FIT file, sorted
+// by time. Problems with the file are returned as ErrInvalidImport.
+func ParseActivityFile(data []byte, format string) (*ActivityTrack, error) {
+	var track *ActivityTrack
+	var err error
+	switch format {
+	case ActivityFormatGPX:
+		track, err = parseGPX(data)
+	case ActivityFormatTCX:
+		track, err = parseTCX(data)
+	case ActivityFormatFIT:
+		track, err = parseFIT(data)
+	default:
+		return nil, fmt.Errorf("%w: unsupported activity file, upload a GPX, TCX or FIT file", ErrInvalidImport)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+
+	if len(track.Points) > MaxActivityTrackPoints {
+		return nil, fmt.Errorf("%w: the file has more than %d track points", ErrInvalidImport, MaxActivityTrackPoints)
+	}
+	points := track.Points[:0]
+	for _, point := range track.Points {
+		if !point.Time.IsZero() {
+			points = append(points, point)
+		}
+	}
+	if len(points) < 2 {
+		return nil, fmt.Errorf("%w: the file has fewer than 2 timed track points", ErrInvalidImport)
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+	track.Points = points
+	track.Format = format
+	return track, nil
+}
+
+type gpxFile struct {
+	Tracks []struct {
+		Name     string `xml:"name"`
+		Type     string `xml:"type"`
+		Segments []struct {
+			Points []struct {
+				Lat       float64  `xml:"lat,attr"`
+				Lon       float64  `xml:"lon,attr"`
+				Elevation *float64 `xml:"ele"`
+				Time      string   `xml:"time"`
+				HeartRate *int     `xml:"extensions>TrackPointExtension>hr"`
+			} `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+}
+
+func parseGPX(data []byte) (*ActivityTrack, error) {
+	var file gpxFile
+	if err := xml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("not a valid GPX file: %v", err)
+	}
+
+	track := &ActivityTrack{}
+	for _, trk := range file.Tracks {
+		if track.Name == "" {
+			track.Name = strings.TrimSpace(trk.Name)
+		}
+		if track.Sport == "" {
+			track.Sport = activitySport(trk.Type)
+		}
+		for _, segment := range trk.Segments {
+			for _, pt := range segment.Points {
+				point := TrackPoint{Elevation: pt.Elevation, HeartRate: pt.HeartRate}
+				lat, lon := pt.Lat, pt.Lon
+				point.Lat, point.Lon = &lat, &lon
+				point.Time, _ = time.Parse(time.RFC3339, strings.TrimSpace(pt.Time))
+				track.Points = append(track.Points, point)
+			}
+		}
+	}
+	return track, nil
+}
+
+type tcxFile struct {
+	Activities []struct {
+		Sport string `xml:"Sport,attr"`
+		Laps  []struct {
+			Points []struct {
+				Time      string   `xml:"Time"`
+				Lat       *float64 `xml:"Position>LatitudeDegrees"`
+				Lon       *float64 `xml:"Position>LongitudeDegrees"`
+				Elevation *float64 `xml:"AltitudeMeters"`
+				HeartRate *int     `xml:"HeartRateBpm>Value"`
+			} `xml:"Track>Trackpoint"`
+		} `xml:"Lap"`
+	} `xml:"Activities>Activity"`
+}
+
+func parseTCX(data []byte) (*ActivityTrack, error) {
+	var file tcxFile
+	if err := xml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("not a valid TCX file: %v", err)
+	}
+
+	track := &ActivityTrack{}
+	for _, activity := range file.Activities {
+		if track.Sport == "" {
+			track.Sport = activitySport(activity.Sport)
+		}
+		for _, lap := range activity.Laps {
+			for _, pt := range lap.Points {
+				point := TrackPoint{Lat: pt.Lat, Lon: pt.Lon, Elevation: pt.Elevation, HeartRate: pt.HeartRate}
+				point.Time, _ = time.Parse(time.RFC3339, strings.TrimSpace(pt.Time))
+				track.Points = append(track.Points, point)
+			}
+		}
+	}
+	return track, nil
+}
+
+// activitySport maps the sport names of GPX types and TCX activities
+func activitySport(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.Contains(name, "run"):
+		return ActivitySportRunning
+	case strings.Contains(name, "bik"), strings.Contains(name, "cycl"), strings.Contains(name, "ride"):
+		return ActivitySportCycling
+	case strings.Contains(name, "hik"):
+		return ActivitySportHiking
+	case strings.Contains(name, "walk"):
+		return ActivitySportWalking
+	}
+	return ""
+}
+
+// haversineKm is the great-circle distance between two points
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := math.Pi / 180
+	dLat := (lat2 - lat1) * toRad
+	dLon := (lon2 - lon1) * toRad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*toRad)*math.Cos(lat2*toRad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// BuildActivityMetrics computes the distance, elapsed and moving time,
+// elevation gain, per-km splits and heart rate of a track. Distance only
+// accrues between points with a GPS fix; heart rate averages the samples.
+func BuildActivityMetrics(track *ActivityTrack) ActivityMetrics {
+	points := track.Points
+	metrics := ActivityMetrics{
+		Format:         track.Format,
+		Sport:          track.Sport,
+		ElapsedSeconds: int(points[len(points)-1].Time.Sub(points[0].Time).Seconds()),
+		Splits:         []ActivitySplit{},
+		Points:         len(points),
+	}
+
+	var distanceKm, movingSeconds, maxSpeed, gain float64
+	var heartRateSum, heartRateCount int
+	var reference *float64
+	split := ActivitySplit{Km: 1}
+	var splitHeartRateSum, splitHeartRateCount int
+	splitStart := points[0].Time
+	closeSplit := func(end time.Time, km float64) {
+		split.DistanceKm = round2(km)
+		split.Seconds = round2(end.Sub(splitStart).Seconds())
+		if km > 0 && split.Seconds > 0 {
+			split.PaceSecPerKm = round2(split.Seconds / km)
+			split.SpeedKmh = round2(km / split.Seconds * 3600)
+		}
+		split.ElevationGain = round2(split.ElevationGain)
+		if splitHeartRateCount > 0 {
+			avg := round2(float64(splitHeartRateSum) / float64(splitHeartRateCount))
+			split.AvgHeartRate = &avg
+		}
+		metrics.Splits = append(metrics.Splits, split)
+		split = ActivitySplit{Km: split.Km + 1}
+		splitHeartRateSum, splitHeartRateCount = 0, 0
+		splitStart = end
+	}
+
+	var previous *TrackPoint
+	for i := range points {
+		point := points[i]
+		if point.HeartRate != nil && *point.HeartRate > 0 {
+			heartRateSum += *point.HeartRate
+			heartRateCount++
+			splitHeartRateSum += *point.HeartRate
+			splitHeartRateCount++
+			if metrics.MaxHeartRate == nil || *point.HeartRate > *metrics.MaxHeartRate {
+				hr := *point.HeartRate
+				metrics.MaxHeartRate = &hr
+			}
+		}
+		if point.Elevation != nil {
+			switch {
+			case reference == nil || *point.Elevation < *reference:
+				elevation := *point.Elevation
+				reference = &elevation
+			case *point.Elevation-*reference >= activityElevationNoiseM:
+				climb := *point.Elevation - *reference
+				gain += climb
+				split.ElevationGain += climb
+				elevation := *point.Elevation
+				reference = &elevation
+			}
+		}
+		if !point.hasPosition() {
+			continue
+		}
+		if previous != nil {
+			stepKm := haversineKm(*previous.Lat, *previous.Lon, *point.Lat, *point.Lon)
+			seconds := point.Time.Sub(previous.Time).Seconds()
+			if seconds > 0 {
+				speed := stepKm * 1000 / seconds
+				if speed >= activityMovingSpeedMps {
+					movingSeconds += seconds
+					maxSpeed = math.Max(maxSpeed, speed)
+				}
+			}
+
+			// close every kilometre crossed in this step at its interpolated time
+			for stepKm > 0 && distanceKm+stepKm >= float64(split.Km) {
+				fraction := (float64(split.Km) - distanceKm) / stepKm
+				crossedAt := previous.Time.Add(time.Duration(fraction * float64(point.Time.Sub(previous.Time))))
+				covered := float64(split.Km) - distanceKm
+				distanceKm += covered
+				stepKm -= covered
+				previous = &TrackPoint{Time: crossedAt, Lat: previous.Lat, Lon: previous.Lon}
+				closeSplit(crossedAt, 1)
+			}
+			distanceKm += stepKm
+		}
+		previous = &points[i]
+	}
+	if rest := distanceKm - float64(split.Km-1); rest >= 0.01 {
+		closeSplit(points[len(points)-1].Time, rest)
+	}
+
+	metrics.DistanceKm = round2(distanceKm)
+	metrics.MovingSeconds = int(math.Round(movingSeconds))
+	metrics.ElevationGain = round2(gain)
+	if distanceKm > 0 && movingSeconds > 0 {
+		pace := round2(movingSeconds / distanceKm)
+		speed := round2(distanceKm / movingSeconds * 3600)
+		maxSpeedKmh := round2(maxSpeed * 3.6)
+		metrics.AvgPace, metrics.AvgSpeedKmh, metrics.MaxSpeedKmh = &pace, &speed, &maxSpeedKmh
+	}
+	if heartRateCount > 0 {
+		avg := round2(float64(heartRateSum) / float64(heartRateCount))
+		metrics.AvgHeartRate = &avg
+	}
+	return metrics
+}
+
+// BuildActivityRoute thins the track to at most activityRouteMaxPoints
+// positioned points, evenly spaced and keeping the first and last
+func BuildActivityRoute(track *ActivityTrack) ActivityRoute {
+	route := ActivityRoute{}
+	for _, point := range track.Points {
+		if point.hasPosition() {
+			route = append(route, point)
+		}
+	}
+	if len(route) <= activityRouteMaxPoints {
+		return route
+	}
+
+	thinned := make(ActivityRoute, 0, activityRouteMaxPoints)
+	step := float64(len(route)-1) / float64(activityRouteMaxPoints-1)
+	for i := 0; i < activityRouteMaxPoints; i++ {
+		thinned = append(thinned, route[int(math.Round(float64(i)*step))])
+	}
+	return thinned
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+func TestBuildActivityMetrics(t *testing.T) {
+	start := time.Date(2026, 5, 3, 6, 30, 0, 0, time.UTC)
+	// degreesPerKm is the latitude step of one kilometre along a meridian
+	degreesPerKm := 180 / (math.Pi * earthRadiusKm)
+	point := func(seconds int, km *float64, elevation *float64, heartRate int) TrackPoint {
+		p := TrackPoint{Time: start.Add(time.Duration(seconds) * time.Second), Elevation: elevation}
+		if km != nil {
+			lat, lon := *km*degreesPerKm, 4.9
+			p.Lat, p.Lon = &lat, &lon
+		}
+		if heartRate > 0 {
+			p.HeartRate = &heartRate
+		}
+		return p
+	}
+
+	tests := []struct {
+		name  string
+		track *ActivityTrack
+		want  ActivityMetrics
+	}{
+		{
+			name: "two kilometres then a stop",
+			track: &ActivityTrack{Format: ActivityFormatGPX, Sport: ActivitySportRunning, Points: []TrackPoint{
+				point(0, floatPtr(0), floatPtr(100), 120),
+				point(150, floatPtr(0.5), floatPtr(102), 130),
+				point(300, floatPtr(1), floatPtr(101), 140),
+				point(450, floatPtr(1.5), floatPtr(105), 150),
+				point(600, floatPtr(2), floatPtr(104), 160),
+				point(660, floatPtr(2), floatPtr(110), 170),
+			}},
+			want: ActivityMetrics{
+				Format:         ActivityFormatGPX,
+				Sport:          ActivitySportRunning,
+				DistanceKm:     2,
+				ElapsedSeconds: 660,
+				MovingSeconds:  600,
+				ElevationGain:  11,
+				AvgPace:        floatPtr(300),
+				AvgSpeedKmh:    floatPtr(12),
+				MaxSpeedKmh:    floatPtr(12),
+				AvgHeartRate:   floatPtr(145),
+				MaxHeartRate:   intPtr(170),
+				Splits: []ActivitySplit{
+					{Km: 1, DistanceKm: 1, Seconds: 300, PaceSecPerKm: 300, SpeedKmh: 12, AvgHeartRate: floatPtr(130)},
+					{Km: 2, DistanceKm: 1, Seconds: 300, PaceSecPerKm: 300, SpeedKmh: 12, ElevationGain: 5, AvgHeartRate: floatPtr(155)},
+				},
+				Points: 6,
+			},
+		},
+		{
+			name: "partial last split",
+			track: &ActivityTrack{Format: ActivityFormatFIT, Points: []TrackPoint{
+				point(0, floatPtr(0), nil, 0),
+				point(360, floatPtr(1), nil, 0),
+				point(540, floatPtr(1.5), nil, 0),
+			}},
+			want: ActivityMetrics{
+				Format:         ActivityFormatFIT,
+				DistanceKm:     1.5,
+				ElapsedSeconds: 540,
+				MovingSeconds:  540,
+				AvgPace:        floatPtr(360),
+				AvgSpeedKmh:    floatPtr(10),
+				MaxSpeedKmh:    floatPtr(10),
+				Splits: []ActivitySplit{
+					{Km: 1, DistanceKm: 1, Seconds: 360, PaceSecPerKm: 360, SpeedKmh: 10},
+					{Km: 2, DistanceKm: 0.5, Seconds: 180, PaceSecPerKm: 360, SpeedKmh: 10},
+				},
+				Points: 3,
+			},
+		},
+		{
+			name: "indoors without a GPS fix",
+			track: &ActivityTrack{Format: ActivityFormatTCX, Points: []TrackPoint{
+				point(0, nil, nil, 100),
+				point(1200, nil, nil, 110),
+			}},
+			want: ActivityMetrics{
+				Format:         ActivityFormatTCX,
+				ElapsedSeconds: 1200,
+				AvgHeartRate:   floatPtr(105),
+				MaxHeartRate:   intPtr(110),
+				Splits:         []ActivitySplit{},
+				Points:         2,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BuildActivityMetrics(tt.track)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BuildActivityMetrics() =\n%s\nwant\n%s", mustMarshal(t, got), mustMarshal(t, tt.want))
+			}
+		})
+	}
+}
+
+func intPtr(value int) *int {
+	return &value
+}
+
+func mustMarshal(t *testing.T, value interface{}) string {
+	t.Helper()
+	data, err := sonic.Marshal(value)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return string(data)
+}
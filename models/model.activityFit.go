@@ -0,0 +1,231 @@
+package models
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// FIT global message numbers and record fields read by parseFIT
+const (
+	fitMessageSport   = 12
+	fitMessageSession = 18
+	fitMessageRecord  = 20
+
+	fitFieldTimestamp        = 253
+	fitFieldPositionLat      = 0
+	fitFieldPositionLong     = 1
+	fitFieldAltitude         = 2
+	fitFieldHeartRate        = 3
+	fitFieldEnhancedAltitude = 78
+	fitFieldSport            = 0
+	fitFieldSessionSport     = 5
+)
+
+// fitEpoch is the zero of FIT timestamps, 1989-12-31T00:00:00Z
+var fitEpoch = time.Date(1989, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// fitSports names the FIT sport enum values activities are mapped from
+var fitSports = map[uint64]string{
+	1:  ActivitySportRunning,
+	2:  ActivitySportCycling,
+	11: ActivitySportWalking,
+	17: ActivitySportHiking,
+}
+
+var errFitTruncated = errors.New("the FIT file is truncated")
+
+// fitField is one field of a FIT definition message
+type fitField struct {
+	num  byte
+	size int
+}
+
+// fitDefinition describes the layout of the data messages of a local message type
+type fitDefinition struct {
+	global    uint16
+	bigEndian bool
+	fields    []fitField
+	devSize   int
+}
+
+// parseFIT decodes the record messages of a binary FIT activity file: the
+// timestamp, position in semicircles, altitude and heart rate of each point.
+// Compressed timestamp headers are resolved against the last full timestamp.
+func parseFIT(data []byte) (*ActivityTrack, error) {
+	if len(data) < 12 || string(data[8:12]) != ".FIT" {
+		return nil, fmt.Errorf("not a valid FIT file")
+	}
+	headerSize := int(data[0])
+	if headerSize < 12 || headerSize > len(data) {
+		return nil, fmt.Errorf("not a valid FIT file")
+	}
+	end := headerSize + int(binary.LittleEndian.Uint32(data[4:8]))
+	if end > len(data) {
+		return nil, errFitTruncated
+	}
+
+	track := &ActivityTrack{}
+	definitions := map[byte]*fitDefinition{}
+	var lastTimestamp uint32
+	for pos := headerSize; pos < end; {
+		header := data[pos]
+		pos++
+
+		if header&0x80 != 0 {
+			// compressed timestamp header: a data message with a 5 bit time offset
+			definition, ok := definitions[(header>>5)&0x03]
+			if !ok {
+				return nil, fmt.Errorf("the FIT file uses an undefined message type")
+			}
+			offset := uint32(header & 0x1F)
+			timestamp := lastTimestamp&^0x1F + offset
+			if offset < lastTimestamp&0x1F {
+				timestamp += 0x20
+			}
+			lastTimestamp = timestamp
+			values, next, err := readFitMessage(data, pos, end, definition)
+			if err != nil {
+				return nil, err
+			}
+			pos = next
+			values[fitFieldTimestamp] = uint64(timestamp)
+			applyFitMessage(track, definition.global, values)
+			continue
+		}
+
+		local := header & 0x0F
+		if header&0x40 != 0 {
+			definition, next, err := readFitDefinition(data, pos, end, header&0x20 != 0)
+			if err != nil {
+				return nil, err
+			}
+			definitions[local] = definition
+			pos = next
+			continue
+		}
+
+		definition, ok := definitions[local]
+		if !ok {
+			return nil, fmt.Errorf("the FIT file uses an undefined message type")
+		}
+		values, next, err := readFitMessage(data, pos, end, definition)
+		if err != nil {
+			return nil, err
+		}
+		pos = next
+		if timestamp, ok := values[fitFieldTimestamp]; ok {
+			lastTimestamp = uint32(timestamp)
+		}
+		applyFitMessage(track, definition.global, values)
+	}
+	return track, nil
+}
+
+// readFitDefinition reads a definition message starting after its header
+func readFitDefinition(data []byte, pos, end int, developer bool) (*fitDefinition, int, error) {
+	if pos+5 > end {
+		return nil, 0, errFitTruncated
+	}
+	definition := &fitDefinition{bigEndian: data[pos+1] == 1}
+	if definition.bigEndian {
+		definition.global = binary.BigEndian.Uint16(data[pos+2 : pos+4])
+	} else {
+		definition.global = binary.LittleEndian.Uint16(data[pos+2 : pos+4])
+	}
+	count := int(data[pos+4])
+	pos += 5
+	if pos+count*3 > end {
+		return nil, 0, errFitTruncated
+	}
+	for i := 0; i < count; i++ {
+		definition.fields = append(definition.fields, fitField{num: data[pos], size: int(data[pos+1])})
+		pos += 3
+	}
+
+	if developer {
+		if pos+1 > end {
+			return nil, 0, errFitTruncated
+		}
+		count = int(data[pos])
+		pos++
+		if pos+count*3 > end {
+			return nil, 0, errFitTruncated
+		}
+		for i := 0; i < count; i++ {
+			definition.devSize += int(data[pos+1])
+			pos += 3
+		}
+	}
+	return definition, pos, nil
+}
+
+// readFitMessage reads the 1, 2 and 4 byte fields of a data message as
+// unsigned integers; wider fields and arrays are skipped
+func readFitMessage(data []byte, pos, end int, definition *fitDefinition) (map[byte]uint64, int, error) {
+	values := map[byte]uint64{}
+	var order binary.ByteOrder = binary.LittleEndian
+	if definition.bigEndian {
+		order = binary.BigEndian
+	}
+	for _, field := range definition.fields {
+		if pos+field.size > end {
+			return nil, 0, errFitTruncated
+		}
+		raw := data[pos : pos+field.size]
+		switch field.size {
+		case 1:
+			values[field.num] = uint64(raw[0])
+		case 2:
+			values[field.num] = uint64(order.Uint16(raw))
+		case 4:
+			values[field.num] = uint64(order.Uint32(raw))
+		}
+		pos += field.size
+	}
+	if pos+definition.devSize > end {
+		return nil, 0, errFitTruncated
+	}
+	return values, pos + definition.devSize, nil
+}
+
+// applyFitMessage adds a record message as a track point and picks up the sport
+func applyFitMessage(track *ActivityTrack, global uint16, values map[byte]uint64) {
+	switch global {
+	case fitMessageSport:
+		if sport, ok := values[fitFieldSport]; ok && track.Sport == "" {
+			track.Sport = fitSports[sport]
+		}
+	case fitMessageSession:
+		if sport, ok := values[fitFieldSessionSport]; ok && track.Sport == "" {
+			track.Sport = fitSports[sport]
+		}
+	case fitMessageRecord:
+		timestamp, ok := values[fitFieldTimestamp]
+		if !ok || timestamp == 0xFFFFFFFF {
+			return
+		}
+		point := TrackPoint{Time: fitEpoch.Add(time.Duration(timestamp) * time.Second)}
+
+		lat, latOk := values[fitFieldPositionLat]
+		lon, lonOk := values[fitFieldPositionLong]
+		if latOk && lonOk && lat != 0x7FFFFFFF && lon != 0x7FFFFFFF {
+			latDeg := float64(int32(uint32(lat))) * 180 / (1 << 31)
+			lonDeg := float64(int32(uint32(lon))) * 180 / (1 << 31)
+			point.Lat, point.Lon = &latDeg, &lonDeg
+		}
+		if altitude, ok := values[fitFieldEnhancedAltitude]; ok && altitude != 0xFFFFFFFF {
+			meters := float64(altitude)/5 - 500
+			point.Elevation = &meters
+		} else if altitude, ok := values[fitFieldAltitude]; ok && altitude != 0xFFFF {
+			meters := float64(altitude)/5 - 500
+			point.Elevation = &meters
+		}
+		if heartRate, ok := values[fitFieldHeartRate]; ok && heartRate != 0xFF {
+			hr := int(heartRate)
+			point.HeartRate = &hr
+		}
+		track.Points = append(track.Points, point)
+	}
+}
@@ -0,0 +1,163 @@
+package models
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// fitFile wraps messages in a 14 byte FIT header; size overrides the data size
+// written in the header when non-zero
+func fitFile(size int, messages ...[]byte) []byte {
+	var body []byte
+	for _, message := range messages {
+		body = append(body, message...)
+	}
+	if size == 0 {
+		size = len(body)
+	}
+	header := []byte{14, 0x10, 0, 0, 0, 0, 0, 0, '.', 'F', 'I', 'T', 0, 0}
+	binary.LittleEndian.PutUint32(header[4:8], uint32(size))
+	return append(header, body...)
+}
+
+// fitDefinitionMessage defines a local message type as the global message
+// with fields given as number and size pairs
+func fitDefinitionMessage(local byte, bigEndian bool, global uint16, fields ...[2]byte) []byte {
+	message := []byte{0x40 | local, 0, 0, 0, 0, byte(len(fields))}
+	order := binary.ByteOrder(binary.LittleEndian)
+	if bigEndian {
+		message[2] = 1
+		order = binary.BigEndian
+	}
+	order.PutUint16(message[3:5], global)
+	for _, field := range fields {
+		message = append(message, field[0], field[1], 0)
+	}
+	return message
+}
+
+// fitSemicircles converts degrees to the FIT position encoding
+func fitSemicircles(degrees float64) uint32 {
+	return uint32(int32(math.Round(degrees * (1 << 31) / 180)))
+}
+
+func TestParseFIT(t *testing.T) {
+	const timestamp = 1000000030 // the low 5 bits are 30, so offset 3 rolls over
+	start := fitEpoch.Add(timestamp * time.Second)
+
+	recordDefinition := fitDefinitionMessage(1, false, fitMessageRecord,
+		[2]byte{fitFieldTimestamp, 4}, [2]byte{fitFieldPositionLat, 4}, [2]byte{fitFieldPositionLong, 4},
+		[2]byte{fitFieldAltitude, 2}, [2]byte{fitFieldHeartRate, 1})
+	record := []byte{0x01}
+	record = binary.LittleEndian.AppendUint32(record, timestamp)
+	record = binary.LittleEndian.AppendUint32(record, fitSemicircles(52.5))
+	record = binary.LittleEndian.AppendUint32(record, fitSemicircles(-1.25))
+	record = binary.LittleEndian.AppendUint16(record, (100+500)*5)
+	record = append(record, 140)
+
+	// a big endian record without timestamp field, sent with compressed headers
+	compressed := func(offset byte, lat, lon uint32, heartRate byte) []byte {
+		message := []byte{0x80 | 2<<5 | offset}
+		message = binary.BigEndian.AppendUint32(message, lat)
+		message = binary.BigEndian.AppendUint32(message, lon)
+		return append(message, heartRate)
+	}
+
+	valid := fitFile(0,
+		fitDefinitionMessage(0, false, fitMessageSport, [2]byte{fitFieldSport, 1}),
+		[]byte{0x00, 1},
+		recordDefinition,
+		record,
+		fitDefinitionMessage(2, true, fitMessageRecord,
+			[2]byte{fitFieldPositionLat, 4}, [2]byte{fitFieldPositionLong, 4}, [2]byte{fitFieldHeartRate, 1}),
+		compressed(3, fitSemicircles(52.501), fitSemicircles(-1.251), 0xFF),
+		compressed(10, 0x7FFFFFFF, 0x7FFFFFFF, 150),
+	)
+
+	type wantPoint struct {
+		time      time.Time
+		lat, lon  float64
+		elevation float64
+		heartRate int
+	}
+	tests := []struct {
+		name      string
+		data      []byte
+		wantSport string
+		want      []wantPoint
+		wantErr   error
+	}{
+		{
+			name:      "records with compressed timestamps",
+			data:      valid,
+			wantSport: ActivitySportRunning,
+			want: []wantPoint{
+				{time: start, lat: 52.5, lon: -1.25, elevation: 100, heartRate: 140},
+				{time: start.Add(5 * time.Second), lat: 52.501, lon: -1.251},
+				{time: start.Add(12 * time.Second), heartRate: 150},
+			},
+		},
+		{
+			name:    "shorter than its header says",
+			data:    fitFile(len(valid), valid[14:]),
+			wantErr: errFitTruncated,
+		},
+		{
+			name:    "message cut short",
+			data:    fitFile(0, recordDefinition, record[:7]),
+			wantErr: errFitTruncated,
+		},
+		{
+			name:    "not a FIT file",
+			data:    []byte("<?xml version=\"1.0\"?><gpx></gpx>"),
+			wantErr: errors.New("not a valid FIT file"),
+		},
+		{
+			name:    "undefined message type",
+			data:    fitFile(0, []byte{0x03, 0}),
+			wantErr: errors.New("the FIT file uses an undefined message type"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			track, err := parseFIT(tt.data)
+			if tt.wantErr != nil {
+				if err == nil || err.Error() != tt.wantErr.Error() {
+					t.Fatalf("parseFIT() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseFIT() error = %v", err)
+			}
+			if track.Sport != tt.wantSport {
+				t.Errorf("Sport = %q, want %q", track.Sport, tt.wantSport)
+			}
+			if len(track.Points) != len(tt.want) {
+				t.Fatalf("got %d points, want %d", len(track.Points), len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := track.Points[i]
+				if !got.Time.Equal(want.time) {
+					t.Errorf("point %d: Time = %v, want %v", i, got.Time, want.time)
+				}
+				if want.lat == 0 {
+					if got.hasPosition() {
+						t.Errorf("point %d: has a position, want none", i)
+					}
+				} else if !got.hasPosition() || math.Abs(*got.Lat-want.lat) > 1e-6 || math.Abs(*got.Lon-want.lon) > 1e-6 {
+					t.Errorf("point %d: position = %v, %v, want %v, %v", i, floatPtrString(got.Lat), floatPtrString(got.Lon), want.lat, want.lon)
+				}
+				if (got.Elevation == nil) != (want.elevation == 0) || (got.Elevation != nil && *got.Elevation != want.elevation) {
+					t.Errorf("point %d: Elevation = %v, want %v", i, floatPtrString(got.Elevation), want.elevation)
+				}
+				if (got.HeartRate == nil) != (want.heartRate == 0) || (got.HeartRate != nil && *got.HeartRate != want.heartRate) {
+					t.Errorf("point %d: HeartRate = %v, want %d", i, got.HeartRate, want.heartRate)
+				}
+			}
+		})
+	}
+}
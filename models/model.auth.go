@@ -76,6 +76,16 @@ func (ul UserLevel) Value() (driver.Value, error) {
 	return string(ul), nil
 }
 
+// EffectiveLevel returns the user level, treating a lapsed premium
+// subscription that has not been downgraded yet as free
+func (u *User) EffectiveLevel() UserLevel {
+	if (u.UserLevel == UserLevelPremium || u.UserLevel == UserLevelPremiumPlus) &&
+		u.PremiumExpiresAt != nil && u.PremiumExpiresAt.Before(time.Now()) {
+		return UserLevelFree
+	}
+	return u.UserLevel
+}
+
 // PaymentRecord represents a payment record for premium subscriptions
 type PaymentRecord struct {
 	ID                 int       `json:"id" db:"id"`
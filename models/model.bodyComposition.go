@@ -0,0 +1,169 @@
+package models
+
+import "math"
+
+// BMI categories as defined by the WHO for adults
+const (
+	BmiUnderweight = "underweight"
+	BmiNormal      = "normal"
+	BmiOverweight  = "overweight"
+	BmiObeseClass1 = "obese_class_1"
+	BmiObeseClass2 = "obese_class_2"
+	BmiObeseClass3 = "obese_class_3"
+)
+
+// Lean mass sources: the fat percentage stored on the measurement, or the US Navy estimate
+const (
+	LeanMassFromMeasured = "measured"
+	LeanMassFromNavy     = "navy"
+)
+
+// DerivedBodyMetrics are computed from one measurement and the profile, never
+// stored. A metric that can't be computed is nil and Missing lists, per metric,
+// the inputs it still needs.
+type DerivedBodyMetrics struct {
+	Bmi                   *float64            `json:"bmi"`
+	BmiCategory           *string             `json:"bmi_category"`
+	NavyBodyFatPercentage *float64            `json:"navy_body_fat_percentage"`
+	WaistToHeight         *float64            `json:"waist_to_height"`
+	LeanMassKg            *float64            `json:"lean_mass_kg"`
+	LeanMassSource        string              `json:"lean_mass_source,omitempty"`
+	Ffmi                  *float64            `json:"ffmi"`
+	NormalizedFfmi        *float64            `json:"normalized_ffmi"`
+	Missing               map[string][]string `json:"missing"`
+}
+
+// InUnits returns the metrics with the lean mass converted into units
+func (d DerivedBodyMetrics) InUnits(units UnitPreference) DerivedBodyMetrics {
+	d.LeanMassKg = ConvertOptional(d.LeanMassKg, units.WeightFromKg)
+	return d
+}
+
+// BmiCategoryOf maps a BMI onto the WHO adult categories
+func BmiCategoryOf(bmi float64) string {
+	switch {
+	case bmi < 18.5:
+		return BmiUnderweight
+	case bmi < 25:
+		return BmiNormal
+	case bmi < 30:
+		return BmiOverweight
+	case bmi < 35:
+		return BmiObeseClass1
+	case bmi < 40:
+		return BmiObeseClass2
+	default:
+		return BmiObeseClass3
+	}
+}
+
+// DeriveBodyMetrics computes BMI, the US Navy body fat estimate, waist-to-height
+// ratio, lean mass and FFMI. Lean mass prefers the measured fat percentage over
+// the Navy estimate. profile may be nil when none has been saved.
+func DeriveBodyMetrics(m BodyMeasurement, profile *UserProfile) DerivedBodyMetrics {
+	derived := DerivedBodyMetrics{Missing: map[string][]string{}}
+	var height *float64
+	var sex *Sex
+	if profile != nil {
+		height, sex = profile.HeightCm, profile.Sex
+	}
+
+	if height == nil {
+		derived.Missing["bmi"] = []string{"height_cm"}
+	} else {
+		meters := *height / 100
+		bmi := round2(m.Bodyweight / (meters * meters))
+		category := BmiCategoryOf(bmi)
+		derived.Bmi, derived.BmiCategory = &bmi, &category
+	}
+
+	if missing := missingInputs(map[string]bool{
+		"height_cm": height == nil,
+		"waist_cm":  m.WaistCm == nil,
+	}); len(missing) > 0 {
+		derived.Missing["waist_to_height"] = missing
+	} else {
+		ratio := round2(*m.WaistCm / *height)
+		derived.WaistToHeight = &ratio
+	}
+
+	if missing := missingInputs(map[string]bool{
+		"sex":       sex == nil,
+		"height_cm": height == nil,
+		"nick_cm":   m.NickCm == nil,
+		"waist_cm":  m.WaistCm == nil,
+		"hip_cm":    m.HipCm == nil && (sex == nil || *sex == SexFemale),
+	}); len(missing) > 0 {
+		derived.Missing["navy_body_fat"] = missing
+	} else {
+		derived.NavyBodyFatPercentage = navyBodyFat(*sex, *height, *m.NickCm, *m.WaistCm, m.HipCm)
+	}
+
+	switch {
+	case m.FatPercentage != nil:
+		lean := round2(m.Bodyweight * (1 - *m.FatPercentage/100))
+		derived.LeanMassKg, derived.LeanMassSource = &lean, LeanMassFromMeasured
+	case derived.NavyBodyFatPercentage != nil:
+		lean := round2(m.Bodyweight * (1 - *derived.NavyBodyFatPercentage/100))
+		derived.LeanMassKg, derived.LeanMassSource = &lean, LeanMassFromNavy
+	default:
+		derived.Missing["lean_mass"] = []string{"fat_percentage"}
+	}
+
+	if missing := missingInputs(map[string]bool{
+		"height_cm":      height == nil,
+		"fat_percentage": derived.LeanMassKg == nil,
+	}); len(missing) > 0 {
+		derived.Missing["ffmi"] = missing
+	} else {
+		meters := *height / 100
+		ffmi := round2(*derived.LeanMassKg / (meters * meters))
+		// Normalized to a height of 1.8 m so tall and short people compare
+		normalized := round2(ffmi + 6.1*(1.8-meters))
+		derived.Ffmi, derived.NormalizedFfmi = &ffmi, &normalized
+	}
+
+	return derived
+}
+
+// AttachDerivedMetrics sets Derived on every measurement
+func AttachDerivedMetrics(measurements []BodyMeasurement, profile *UserProfile) {
+	for i := range measurements {
+		derived := DeriveBodyMetrics(measurements[i], profile)
+		measurements[i].Derived = &derived
+	}
+}
+
+// navyBodyFat is the US Navy circumference formula in centimetres; nil when
+// the circumferences are inconsistent (e.g. neck larger than waist)
+func navyBodyFat(sex Sex, height, neck, waist float64, hip *float64) *float64 {
+	var density float64
+	if sex == SexMale {
+		if waist-neck <= 0 {
+			return nil
+		}
+		density = 1.0324 - 0.19077*math.Log10(waist-neck) + 0.15456*math.Log10(height)
+	} else {
+		if waist+*hip-neck <= 0 {
+			return nil
+		}
+		density = 1.29579 - 0.35004*math.Log10(waist+*hip-neck) + 0.22100*math.Log10(height)
+	}
+	fat := round2(495/density - 450)
+	if fat <= 0 || fat >= 100 {
+		return nil
+	}
+	return &fat
+}
+
+// missingInputs lists the inputs flagged as missing in a stable order
+func missingInputs(flags map[string]bool) []string {
+	order := []string{"sex", "height_cm", "nick_cm", "waist_cm", "hip_cm", "fat_percentage"}
+	var missing []string
+	for _, input := range order {
+		if flags[input] {
+			missing = append(missing, input)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,39 @@
+package models
+
+import "testing"
+
+func TestNavyBodyFat(t *testing.T) {
+	value := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name   string
+		sex    Sex
+		height float64
+		neck   float64
+		waist  float64
+		hip    *float64
+		want   *float64
+	}{
+		{"male", SexMale, 180, 40, 90, nil, value(18.37)},
+		{"lean male", SexMale, 175, 38, 80, nil, value(12.87)},
+		{"male ignores hip", SexMale, 180, 40, 90, value(120), value(18.37)},
+		{"female", SexFemale, 165, 33, 75, value(100), value(29.43)},
+		{"lean female", SexFemale, 160, 32, 70, value(95), value(26.21)},
+		{"male neck as wide as waist", SexMale, 180, 40, 40, nil, nil},
+		{"male neck wider than waist", SexMale, 180, 45, 40, nil, nil},
+		{"female neck wider than waist and hip", SexFemale, 165, 200, 50, value(100), nil},
+		{"male result below 0%", SexMale, 180, 40, 41, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := navyBodyFat(tt.sex, tt.height, tt.neck, tt.waist, tt.hip)
+			switch {
+			case got == nil && tt.want == nil:
+			case got == nil || tt.want == nil:
+				t.Errorf("navyBodyFat() = %v, want %v", got, tt.want)
+			case *got != *tt.want:
+				t.Errorf("navyBodyFat() = %v, want %v", *got, *tt.want)
+			}
+		})
+	}
+}
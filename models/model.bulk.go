@@ -0,0 +1,135 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// BulkMode decides what happens to a batch when one of its items fails
+type BulkMode string
+
+const (
+	// BulkTransactional applies every item or none of them
+	BulkTransactional BulkMode = "transactional"
+	// BulkPartial applies every item that succeeds and reports the rest
+	BulkPartial BulkMode = "partial"
+)
+
+// BulkAction is the write performed by one item of a batch
+type BulkAction string
+
+const (
+	BulkCreate BulkAction = "create"
+	BulkUpdate BulkAction = "update"
+	BulkDelete BulkAction = "delete"
+)
+
+// ErrBulkRolledBack marks items undone, or never attempted, because another
+// item of a transactional batch failed
+var ErrBulkRolledBack = errors.New("rolled back because another item failed")
+
+// BulkOperation is one already-validated item of a batch. Id is the row to
+// update or delete; Data is unused for deletes.
+type BulkOperation[T any] struct {
+	Index  int
+	Action BulkAction
+	Id     int
+	Data   T
+}
+
+// BulkOutcome reports what happened to one BulkOperation. Id is the affected
+// row, and Err is sql.ErrNoRows when the row does not belong to the user.
+type BulkOutcome struct {
+	Index  int
+	Action BulkAction
+	Id     int
+	Err    error
+}
+
+// bulkApplyFunc writes one operation inside the batch transaction and returns the affected row id
+type bulkApplyFunc[T any] func(tx *sqlx.Tx, op BulkOperation[T]) (int, error)
+
+// runBulk applies ops in order inside one transaction, see applyBulkOps.
+// The returned error is only set when the batch itself could not run.
+func runBulk[T any](mode BulkMode, ops []BulkOperation[T], apply bulkApplyFunc[T]) ([]BulkOutcome, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	outcomes, commit, err := applyBulkOps(tx, mode, ops, func(op BulkOperation[T]) (int, error) {
+		return apply(tx, op)
+	})
+	if err != nil || !commit {
+		return outcomes, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return outcomes, nil
+}
+
+// applyBulkOps applies ops in order with the savepoints of mode run on e, and
+// reports whether the batch may be committed. In partial mode every item runs
+// under its own savepoint so a failed item is undone on its own; in
+// transactional mode the first failure stops the batch, and every other item
+// is marked ErrBulkRolledBack for the caller to roll back.
+func applyBulkOps[T any](e sqlx.Execer, mode BulkMode, ops []BulkOperation[T], apply func(op BulkOperation[T]) (int, error)) ([]BulkOutcome, bool, error) {
+	outcomes := make([]BulkOutcome, 0, len(ops))
+	for i, op := range ops {
+		if mode == BulkPartial {
+			if _, err := e.Exec(`SAVEPOINT bulk_item`); err != nil {
+				return nil, false, fmt.Errorf("error creating savepoint: %w", err)
+			}
+		}
+
+		id, err := apply(op)
+		outcomes = append(outcomes, BulkOutcome{Index: op.Index, Action: op.Action, Id: id, Err: err})
+
+		if mode == BulkPartial {
+			release := `RELEASE SAVEPOINT bulk_item`
+			if err != nil {
+				release = `ROLLBACK TO SAVEPOINT bulk_item`
+			}
+			if _, err := e.Exec(release); err != nil {
+				return nil, false, fmt.Errorf("error closing savepoint: %w", err)
+			}
+			continue
+		}
+
+		if err != nil {
+			for j := range outcomes[:i] {
+				outcomes[j].Err = ErrBulkRolledBack
+			}
+			for _, rest := range ops[i+1:] {
+				outcomes = append(outcomes, BulkOutcome{Index: rest.Index, Action: rest.Action, Id: rest.Id, Err: ErrBulkRolledBack})
+			}
+			return outcomes, false, nil
+		}
+	}
+	return outcomes, true, nil
+}
+
+// requireOneRow turns an update or delete that matched nothing into sql.ErrNoRows
+func requireOneRow(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// recordingExecer records the statements run on it and fails the one at failAt (1-based)
+type recordingExecer struct {
+	statements []string
+	failAt     int
+}
+
+func (e *recordingExecer) Exec(query string, args ...interface{}) (sql.Result, error) {
+	e.statements = append(e.statements, query)
+	if len(e.statements) == e.failAt {
+		return nil, errors.New("connection lost")
+	}
+	return nil, nil
+}
+
+func TestApplyBulkOps(t *testing.T) {
+	errSave := errors.New("duplicate key")
+	ops := []BulkOperation[string]{
+		{Index: 0, Action: BulkCreate, Data: "a"},
+		{Index: 0, Action: BulkUpdate, Id: 7, Data: "fail"},
+		{Index: 0, Action: BulkDelete, Id: 8},
+	}
+
+	tests := []struct {
+		name           string
+		mode           BulkMode
+		failAt         int
+		wantErrs       []error
+		wantApplied    []BulkAction
+		wantStatements []string
+		wantCommit     bool
+		wantErr        bool
+	}{
+		{
+			name:        "transactional stops at the first failure and rolls back the rest",
+			mode:        BulkTransactional,
+			wantErrs:    []error{ErrBulkRolledBack, errSave, ErrBulkRolledBack},
+			wantApplied: []BulkAction{BulkCreate, BulkUpdate},
+		},
+		{
+			name:        "partial rolls back only the failed item to its savepoint",
+			mode:        BulkPartial,
+			wantErrs:    []error{nil, errSave, nil},
+			wantApplied: []BulkAction{BulkCreate, BulkUpdate, BulkDelete},
+			wantStatements: []string{
+				"SAVEPOINT bulk_item", "RELEASE SAVEPOINT bulk_item",
+				"SAVEPOINT bulk_item", "ROLLBACK TO SAVEPOINT bulk_item",
+				"SAVEPOINT bulk_item", "RELEASE SAVEPOINT bulk_item",
+			},
+			wantCommit: true,
+		},
+		{
+			name:           "a savepoint that cannot be created fails the batch",
+			mode:           BulkPartial,
+			failAt:         3,
+			wantApplied:    []BulkAction{BulkCreate},
+			wantStatements: []string{"SAVEPOINT bulk_item", "RELEASE SAVEPOINT bulk_item", "SAVEPOINT bulk_item"},
+			wantErr:        true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			execer := &recordingExecer{failAt: tt.failAt}
+			var applied []BulkAction
+			outcomes, commit, err := applyBulkOps(execer, tt.mode, ops, func(op BulkOperation[string]) (int, error) {
+				applied = append(applied, op.Action)
+				if op.Data == "fail" {
+					return op.Id, errSave
+				}
+				return 100, nil
+			})
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if commit != tt.wantCommit {
+				t.Errorf("commit = %v, want %v", commit, tt.wantCommit)
+			}
+			if !reflect.DeepEqual(applied, tt.wantApplied) {
+				t.Errorf("applied = %v, want %v", applied, tt.wantApplied)
+			}
+			if !reflect.DeepEqual(execer.statements, tt.wantStatements) {
+				t.Errorf("statements = %v, want %v", execer.statements, tt.wantStatements)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(outcomes) != len(ops) {
+				t.Fatalf("got %d outcomes, want %d", len(outcomes), len(ops))
+			}
+			for i, outcome := range outcomes {
+				if outcome.Action != ops[i].Action || !errors.Is(outcome.Err, tt.wantErrs[i]) || (tt.wantErrs[i] == nil && outcome.Err != nil) {
+					t.Errorf("outcome %d = %s %v, want %s %v", i, outcome.Action, outcome.Err, ops[i].Action, tt.wantErrs[i])
+				}
+			}
+		})
+	}
+}
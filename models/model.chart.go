@@ -0,0 +1,126 @@
+package models
+
+import "time"
+
+// ChartGranularity is the bucket size of a chart series
+type ChartGranularity string
+
+const (
+	ChartGranularityDay   ChartGranularity = "day"
+	ChartGranularityWeek  ChartGranularity = "week"
+	ChartGranularityMonth ChartGranularity = "month"
+)
+
+// ChartMovingAverageWarmupDays is how many days before the requested start
+// must be loaded so the 30-day moving average is complete on the first day
+const ChartMovingAverageWarmupDays = 29
+
+// ChartMetrics lists the selectable metrics of every chart series by series name
+var ChartMetrics = map[string][]string{
+	"nutrition": NutritionChartMetrics,
+	"hydration": HydrationChartMetrics,
+}
+
+// ChartPoint is one bucket of a chart keyed by metric name.
+// Moving averages are only filled for daily granularity.
+type ChartPoint struct {
+	Period          string             `json:"time_slice_label"`
+	Values          map[string]float64 `json:"values"`
+	MovingAverage7  map[string]float64 `json:"moving_average_7d,omitempty"`
+	MovingAverage30 map[string]float64 `json:"moving_average_30d,omitempty"`
+}
+
+// Chart is a history series returned to the client
+type Chart struct {
+	From         string           `json:"from"`
+	To           string           `json:"to"`
+	Granularity  ChartGranularity `json:"granularity"`
+	Metrics      []string         `json:"metrics"`
+	MaxRangeDays int              `json:"max_range_days"`
+	RangeCapped  bool             `json:"range_capped"`
+	Points       []ChartPoint     `json:"points"`
+}
+
+// chartDay is one zero-filled daily bucket loaded for a chart
+type chartDay interface {
+	label() string
+	metric(name string) float64
+}
+
+// buildChartPoints turns ascending, zero-filled daily buckets into chart
+// points starting at from. Days before from are only used as warm-up for the
+// moving averages. Points are returned newest first.
+func buildChartPoints[T chartDay](daily []T, from string, granularity ChartGranularity, metrics []string) []ChartPoint {
+	points := make([]ChartPoint, 0, len(daily))
+	for i, day := range daily {
+		if day.label() < from {
+			continue
+		}
+
+		point := ChartPoint{Period: day.label(), Values: make(map[string]float64, len(metrics))}
+		for _, name := range metrics {
+			point.Values[name] = round2(day.metric(name))
+		}
+		if granularity == ChartGranularityDay {
+			point.MovingAverage7 = movingAverage(daily, i, 7, metrics)
+			point.MovingAverage30 = movingAverage(daily, i, 30, metrics)
+		}
+		points = append(points, point)
+	}
+
+	if granularity == ChartGranularityWeek || granularity == ChartGranularityMonth {
+		points = groupChartPoints(points, granularity)
+	}
+
+	for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+		points[i], points[j] = points[j], points[i]
+	}
+	return points
+}
+
+// movingAverage averages the window ending at index end; it is nil when the
+// series does not reach back far enough for a full window
+func movingAverage[T chartDay](daily []T, end, window int, metrics []string) map[string]float64 {
+	if end+1 < window {
+		return nil
+	}
+
+	averages := make(map[string]float64, len(metrics))
+	for _, name := range metrics {
+		var sum float64
+		for i := end - window + 1; i <= end; i++ {
+			sum += daily[i].metric(name)
+		}
+		averages[name] = round2(sum / float64(window))
+	}
+	return averages
+}
+
+// groupChartPoints sums ascending daily points into Monday-based weeks
+// (labelled by their Monday) or calendar months (labelled YYYY-MM)
+func groupChartPoints(daily []ChartPoint, granularity ChartGranularity) []ChartPoint {
+	grouped := make([]ChartPoint, 0)
+	for _, day := range daily {
+		label := chartBucketLabel(day.Period, granularity)
+		if len(grouped) == 0 || grouped[len(grouped)-1].Period != label {
+			grouped = append(grouped, ChartPoint{Period: label, Values: make(map[string]float64, len(day.Values))})
+		}
+		bucket := grouped[len(grouped)-1]
+		for name, value := range day.Values {
+			bucket.Values[name] = round2(bucket.Values[name] + value)
+		}
+	}
+	return grouped
+}
+
+func chartBucketLabel(date string, granularity ChartGranularity) string {
+	day, err := time.Parse(SQLDateFormat, date)
+	if err != nil {
+		return date
+	}
+	if granularity == ChartGranularityMonth {
+		return day.Format("2006-01")
+	}
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset).Format(SQLDateFormat)
+}
@@ -19,6 +19,9 @@ const SQLTimeFormat = "2006-01-02 15:04:05"
 // SQLDateFormat :
 const SQLDateFormat = "2006-01-02"
 
+// DefaultTimezone : IANA zone used for day bucketing when a user has not set one
+const DefaultTimezone = "Asia/Jakarta"
+
 // TimeRange :
 type TimeRange struct {
 	Valid bool
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// dailyNutritionColumns are the users_daily_nutrition totals, one per users_food_intake nutrient
+const dailyNutritionColumns = `caloric, fat, protein, carbohydrate, fiber, sugar,
+	saturated_fat, sodium, cholesterol, potassium`
+
+// dailyNutritionSums sums users_food_intake into entries and dailyNutritionColumns
+const dailyNutritionSums = `COUNT(*), COALESCE(SUM(caloric), 0), COALESCE(SUM(fat), 0),
+	COALESCE(SUM(protein), 0), COALESCE(SUM(carbohydrate), 0), COALESCE(SUM(fiber), 0),
+	COALESCE(SUM(sugar), 0), COALESCE(SUM(saturated_fat), 0), COALESCE(SUM(sodium), 0),
+	COALESCE(SUM(cholesterol), 0), COALESCE(SUM(potassium), 0)`
+
+// dailyNutritionUpsert overwrites the totals of a day that already has a row
+const dailyNutritionUpsert = `ON CONFLICT (user_id, local_date) DO UPDATE SET
+	entries = EXCLUDED.entries, caloric = EXCLUDED.caloric, fat = EXCLUDED.fat,
+	protein = EXCLUDED.protein, carbohydrate = EXCLUDED.carbohydrate, fiber = EXCLUDED.fiber,
+	sugar = EXCLUDED.sugar, saturated_fat = EXCLUDED.saturated_fat, sodium = EXCLUDED.sodium,
+	cholesterol = EXCLUDED.cholesterol, potassium = EXCLUDED.potassium`
+
+// RecomputeDailyAggregates rebuilds every derived daily aggregate of a user
+// with days taken in timezone, and records it on users.daily_aggregates_timezone.
+// It runs in the transaction that changes the user's zone.
+func RecomputeDailyAggregates(tx *sqlx.Tx, userID int, timezone string) error {
+	// Lock the user so concurrent rebuilds of the same user run one after the other
+	if _, err := tx.Exec(`SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+		return fmt.Errorf("error locking user: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM users_daily_nutrition WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("error clearing daily nutrition: %w", err)
+	}
+	query := `INSERT INTO users_daily_nutrition (user_id, local_date, entries, ` + dailyNutritionColumns + `)
+	SELECT user_id, DATE(created_at AT TIME ZONE $2), ` + dailyNutritionSums + `
+	FROM users_food_intake
+	WHERE user_id = $1
+	GROUP BY user_id, DATE(created_at AT TIME ZONE $2)
+	` + dailyNutritionUpsert
+	if _, err := tx.Exec(query, userID, timezone); err != nil {
+		return fmt.Errorf("error rebuilding daily nutrition: %w", err)
+	}
+
+	if _, err := tx.Exec(`UPDATE users SET daily_aggregates_timezone = $1 WHERE id = $2`, timezone, userID); err != nil {
+		return fmt.Errorf("error recording daily aggregate timezone: %w", err)
+	}
+	return nil
+}
+
+// ensureDailyAggregates rebuilds the daily aggregates of a user who changed
+// zone outside UpdateUserTimezone or has none yet, and reports whether it did
+func ensureDailyAggregates(userID int) (bool, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return false, fmt.Errorf("database connection is nil")
+	}
+
+	var state struct {
+		Timezone string  `db:"timezone"`
+		BuiltIn  *string `db:"daily_aggregates_timezone"`
+	}
+	query := `SELECT COALESCE(NULLIF(timezone, ''), $2) AS timezone, daily_aggregates_timezone
+	FROM users WHERE id = $1`
+	if err := db.Get(&state, query, userID, DefaultTimezone); err != nil {
+		return false, err
+	}
+	if state.BuiltIn != nil && *state.BuiltIn == state.Timezone {
+		return false, nil
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return false, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err = RecomputeDailyAggregates(tx, userID, state.Timezone); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// refreshDailyNutrition recomputes the daily nutrition of the local days
+// containing times, after food intake logged at those times changed
+func refreshDailyNutrition(e sqlx.Execer, userID int, times ...time.Time) error {
+	loc := LoadUserLocation(userID)
+	query := `INSERT INTO users_daily_nutrition (user_id, local_date, entries, ` + dailyNutritionColumns + `)
+	SELECT $1, $2::date, ` + dailyNutritionSums + `
+	FROM users_food_intake
+	WHERE user_id = $1 AND DATE(created_at AT TIME ZONE $3) = $2::date
+	` + dailyNutritionUpsert
+
+	refreshed := make(map[string]bool, len(times))
+	for _, at := range times {
+		day := at.In(loc).Format(SQLDateFormat)
+		if refreshed[day] {
+			continue
+		}
+		if _, err := e.Exec(query, userID, day, loc.String()); err != nil {
+			return fmt.Errorf("error refreshing daily nutrition: %w", err)
+		}
+		refreshed[day] = true
+	}
+	return nil
+}
+
+// foodIntakeTimes returns when a food intake entry of a user was logged, empty when it does not exist
+func foodIntakeTimes(q sqlx.Queryer, userID, foodId int) ([]time.Time, error) {
+	var times []time.Time
+	query := `SELECT created_at FROM users_food_intake WHERE user_id = $1 AND food_id = $2`
+	err := sqlx.Select(q, &times, query, userID, foodId)
+	return times, err
+}
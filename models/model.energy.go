@@ -0,0 +1,289 @@
+package models
+
+import (
+	"math"
+)
+
+const (
+	// KcalPerKgBodyweight is the energy usually assumed to be stored in one kg of body weight
+	KcalPerKgBodyweight = 7700.0
+	// WeightTrendAlpha is the smoothing factor of the exponentially weighted weight trend
+	WeightTrendAlpha = 0.1
+	// TDEEMinLoggedDays and TDEEMinWeighIns are the least data an estimate is made from
+	TDEEMinLoggedDays = 7
+	TDEEMinWeighIns   = 2
+)
+
+// DailyExerciseCaloric is the calories burned by exercise on one local date
+type DailyExerciseCaloric struct {
+	Date    string  `json:"date" db:"date"`
+	Caloric float64 `json:"caloric" db:"caloric"`
+}
+
+// DailyBodyweight is the last bodyweight measured on one local date
+type DailyBodyweight struct {
+	Date       string  `json:"date" db:"date"`
+	Bodyweight float64 `json:"bodyweight" db:"bodyweight"`
+}
+
+// EnergyBalanceDay is intake minus exercise burn for one local date.
+// Bodyweight and WeightTrend are nil until the first weigh-in.
+type EnergyBalanceDay struct {
+	Date         string   `json:"date"`
+	IntakeKcal   float64  `json:"intake_kcal"`
+	ExerciseKcal float64  `json:"exercise_kcal"`
+	NetKcal      float64  `json:"net_kcal"`
+	IntakeLogged bool     `json:"intake_logged"`
+	Bodyweight   *float64 `json:"bodyweight"`
+	WeightTrend  *float64 `json:"weight_trend"`
+}
+
+// EnergyBalance is a run of EnergyBalanceDay with totals over the logged days
+type EnergyBalance struct {
+	From              string             `json:"from"`
+	To                string             `json:"to"`
+	LoggedDays        int                `json:"logged_days"`
+	TotalIntakeKcal   float64            `json:"total_intake_kcal"`
+	TotalExerciseKcal float64            `json:"total_exercise_kcal"`
+	TotalNetKcal      float64            `json:"total_net_kcal"`
+	AverageNetKcal    float64            `json:"average_net_kcal"`
+	Days              []EnergyBalanceDay `json:"days"`
+
+	Units *UnitPreference `json:"units,omitempty"`
+}
+
+// AttachUnits converts the calories and bodyweights of the balance and its days into units
+func (b *EnergyBalance) AttachUnits(units UnitPreference) {
+	b.Units = &units
+	b.TotalIntakeKcal = units.EnergyFromKcal(b.TotalIntakeKcal)
+	b.TotalExerciseKcal = units.EnergyFromKcal(b.TotalExerciseKcal)
+	b.TotalNetKcal = units.EnergyFromKcal(b.TotalNetKcal)
+	b.AverageNetKcal = units.EnergyFromKcal(b.AverageNetKcal)
+	for i := range b.Days {
+		day := &b.Days[i]
+		day.IntakeKcal = units.EnergyFromKcal(day.IntakeKcal)
+		day.ExerciseKcal = units.EnergyFromKcal(day.ExerciseKcal)
+		day.NetKcal = units.EnergyFromKcal(day.NetKcal)
+		day.Bodyweight = ConvertOptional(day.Bodyweight, units.WeightFromKg)
+		day.WeightTrend = ConvertOptional(day.WeightTrend, units.WeightFromKg)
+	}
+}
+
+// BuildEnergyBalanceDays joins daily intake, exercise and bodyweight, one
+// entry per date of nutrition (which is expected to be zero-filled and ascending)
+func BuildEnergyBalanceDays(nutrition []NutritionChartData, exercise []DailyExerciseCaloric, weights []DailyBodyweight) []EnergyBalanceDay {
+	burned := make(map[string]float64, len(exercise))
+	for _, day := range exercise {
+		burned[day.Date] = day.Caloric
+	}
+	measured := make(map[string]float64, len(weights))
+	for _, day := range weights {
+		measured[day.Date] = day.Bodyweight
+	}
+
+	dates := make([]string, 0, len(nutrition))
+	for _, day := range nutrition {
+		dates = append(dates, day.Period)
+	}
+	trend := WeightTrend(dates, measured)
+
+	days := make([]EnergyBalanceDay, 0, len(nutrition))
+	for i, day := range nutrition {
+		balance := EnergyBalanceDay{
+			Date:         day.Period,
+			IntakeKcal:   round2(day.Caloric),
+			ExerciseKcal: round2(burned[day.Period]),
+			NetKcal:      round2(day.Caloric - burned[day.Period]),
+			IntakeLogged: day.Caloric > 0,
+			WeightTrend:  trend[i],
+		}
+		if weight, ok := measured[day.Period]; ok {
+			balance.Bodyweight = &weight
+		}
+		days = append(days, balance)
+	}
+	return days
+}
+
+// BuildEnergyBalance summarises the days that fall between from and to inclusive
+func BuildEnergyBalance(from, to string, days []EnergyBalanceDay) EnergyBalance {
+	balance := EnergyBalance{From: from, To: to, Days: []EnergyBalanceDay{}}
+	for _, day := range days {
+		if day.Date < from || day.Date > to {
+			continue
+		}
+		balance.Days = append(balance.Days, day)
+		if !day.IntakeLogged {
+			continue
+		}
+		balance.LoggedDays++
+		balance.TotalIntakeKcal += day.IntakeKcal
+		balance.TotalExerciseKcal += day.ExerciseKcal
+		balance.TotalNetKcal += day.NetKcal
+	}
+
+	balance.TotalIntakeKcal = round2(balance.TotalIntakeKcal)
+	balance.TotalExerciseKcal = round2(balance.TotalExerciseKcal)
+	balance.TotalNetKcal = round2(balance.TotalNetKcal)
+	if balance.LoggedDays > 0 {
+		balance.AverageNetKcal = round2(balance.TotalNetKcal / float64(balance.LoggedDays))
+	}
+	return balance
+}
+
+// WeightTrend smooths bodyweight over consecutive dates with an exponentially
+// weighted moving average, carrying the trend over days without a weigh-in.
+// Entries before the first weigh-in are nil.
+func WeightTrend(dates []string, weights map[string]float64) []*float64 {
+	trend := make([]*float64, len(dates))
+	var current float64
+	started := false
+	for i, date := range dates {
+		if weight, ok := weights[date]; ok {
+			if !started {
+				current, started = weight, true
+			} else {
+				current += WeightTrendAlpha * (weight - current)
+			}
+		}
+		if started {
+			value := round2(current)
+			trend[i] = &value
+		}
+	}
+	return trend
+}
+
+// TDEEConfidence rates how far an estimate can be trusted. Score is 0-1 and
+// weighs intake logging coverage most, then weigh-in frequency, then window length.
+type TDEEConfidence struct {
+	Level   string   `json:"level"`
+	Score   float64  `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+// TDEEEstimate is maintenance calories back-solved from intake and the change
+// of the smoothed weight trend over a window: TDEE = average intake - trend
+// change × 7700 / days. Estimated is nil when there is too little data.
+type TDEEEstimate struct {
+	From                string         `json:"from"`
+	To                  string         `json:"to"`
+	WindowDays          int            `json:"window_days"`
+	LoggedDays          int            `json:"logged_days"`
+	WeighIns            int            `json:"weigh_ins"`
+	AverageIntakeKcal   float64        `json:"average_intake_kcal"`
+	AverageExerciseKcal float64        `json:"average_exercise_kcal"`
+	TrendStart          *float64       `json:"trend_start"`
+	TrendEnd            *float64       `json:"trend_end"`
+	WeightChangeKg      *float64       `json:"weight_change_kg"`
+	WeeklyRateKg        *float64       `json:"weekly_rate_kg"`
+	Estimated           *float64       `json:"estimated_tdee"`
+	Confidence          TDEEConfidence `json:"confidence"`
+
+	Units *UnitPreference `json:"units,omitempty"`
+}
+
+// AttachUnits converts the calories and weight trend of the estimate into units
+func (e *TDEEEstimate) AttachUnits(units UnitPreference) {
+	e.Units = &units
+	e.AverageIntakeKcal = units.EnergyFromKcal(e.AverageIntakeKcal)
+	e.AverageExerciseKcal = units.EnergyFromKcal(e.AverageExerciseKcal)
+	e.Estimated = ConvertOptional(e.Estimated, units.EnergyFromKcal)
+	e.TrendStart = ConvertOptional(e.TrendStart, units.WeightFromKg)
+	e.TrendEnd = ConvertOptional(e.TrendEnd, units.WeightFromKg)
+	e.WeightChangeKg = ConvertOptional(e.WeightChangeKg, units.WeightFromKg)
+	e.WeeklyRateKg = ConvertOptional(e.WeeklyRateKg, units.WeightFromKg)
+}
+
+// EstimateTDEE estimates maintenance calories over the given days, which must
+// be consecutive and ascending. Days without logged intake are left out of the
+// average intake because an empty log is far more likely than a zero-calorie day.
+func EstimateTDEE(days []EnergyBalanceDay) TDEEEstimate {
+	estimate := TDEEEstimate{WindowDays: len(days)}
+	if len(days) == 0 {
+		estimate.Confidence = TDEEConfidence{Level: "insufficient", Reasons: []string{"no days in the window"}}
+		return estimate
+	}
+	estimate.From = days[0].Date
+	estimate.To = days[len(days)-1].Date
+
+	var intake, exercise float64
+	for _, day := range days {
+		if day.Bodyweight != nil {
+			estimate.WeighIns++
+		}
+		if !day.IntakeLogged {
+			continue
+		}
+		estimate.LoggedDays++
+		intake += day.IntakeKcal
+		exercise += day.ExerciseKcal
+	}
+	if estimate.LoggedDays > 0 {
+		estimate.AverageIntakeKcal = round2(intake / float64(estimate.LoggedDays))
+		estimate.AverageExerciseKcal = round2(exercise / float64(estimate.LoggedDays))
+	}
+
+	// The trend is measured from the first day that has one, so a window that
+	// starts before the first weigh-in is shortened accordingly
+	first := -1
+	for i, day := range days {
+		if day.WeightTrend != nil {
+			first = i
+			break
+		}
+	}
+	estimate.Confidence = tdeeConfidence(estimate)
+	if first < 0 || estimate.Confidence.Level == "insufficient" {
+		return estimate
+	}
+
+	estimate.TrendStart = days[first].WeightTrend
+	estimate.TrendEnd = days[len(days)-1].WeightTrend
+	span := float64(len(days) - 1 - first)
+	change := round2(*estimate.TrendEnd - *estimate.TrendStart)
+	estimate.WeightChangeKg = &change
+	if span <= 0 {
+		return estimate
+	}
+
+	weekly := round2(change / span * 7)
+	estimate.WeeklyRateKg = &weekly
+	tdee := math.Round(estimate.AverageIntakeKcal - change*KcalPerKgBodyweight/span)
+	estimate.Estimated = &tdee
+	return estimate
+}
+
+func tdeeConfidence(estimate TDEEEstimate) TDEEConfidence {
+	window := float64(estimate.WindowDays)
+	intakeCoverage := float64(estimate.LoggedDays) / window
+	weighInCoverage := math.Min(1, float64(estimate.WeighIns)/(window/2))
+	windowCoverage := math.Min(1, window/28)
+
+	confidence := TDEEConfidence{
+		Score:   round2(0.5*intakeCoverage + 0.3*weighInCoverage + 0.2*windowCoverage),
+		Reasons: []string{},
+	}
+	if intakeCoverage < 0.8 {
+		confidence.Reasons = append(confidence.Reasons, "food intake is logged on fewer than 80% of days")
+	}
+	if weighInCoverage < 1 {
+		confidence.Reasons = append(confidence.Reasons, "weigh in at least every other day")
+	}
+	if windowCoverage < 1 {
+		confidence.Reasons = append(confidence.Reasons, "windows shorter than 28 days are sensitive to water weight")
+	}
+
+	switch {
+	case estimate.LoggedDays < TDEEMinLoggedDays || estimate.WeighIns < TDEEMinWeighIns:
+		confidence.Level = "insufficient"
+		confidence.Reasons = append(confidence.Reasons, "an estimate needs at least 7 logged days and 2 weigh-ins")
+	case confidence.Score >= 0.75:
+		confidence.Level = "high"
+	case confidence.Score >= 0.5:
+		confidence.Level = "medium"
+	default:
+		confidence.Level = "low"
+	}
+	return confidence
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// tdeeDays builds n consecutive days eating intakeKcal on the logged days,
+// with the weight trend given per day (nil before the first weigh-in) and a
+// weigh-in on every day weighIn reports
+func tdeeDays(n int, intakeKcal float64, logged, weighIn func(i int) bool, trend func(i int) *float64) []EnergyBalanceDay {
+	days := make([]EnergyBalanceDay, n)
+	for i := range days {
+		days[i] = EnergyBalanceDay{Date: fmt.Sprintf("2026-01-%02d", i+1), WeightTrend: trend(i)}
+		if logged(i) {
+			days[i].IntakeKcal, days[i].NetKcal, days[i].IntakeLogged = intakeKcal, intakeKcal, true
+		}
+		if weighIn(i) && days[i].WeightTrend != nil {
+			days[i].Bodyweight = days[i].WeightTrend
+		}
+	}
+	return days
+}
+
+func TestEstimateTDEE(t *testing.T) {
+	always := func(int) bool { return true }
+	linear := func(start, perDay float64, from int) func(i int) *float64 {
+		return func(i int) *float64 {
+			if i < from {
+				return nil
+			}
+			value := round2(start + perDay*float64(i-from))
+			return &value
+		}
+	}
+
+	tests := []struct {
+		name       string
+		days       []EnergyBalanceDay
+		wantTDEE   *float64
+		wantWeekly *float64
+		wantLogged int
+		wantLevel  string
+	}{
+		{
+			name:      "no days",
+			days:      nil,
+			wantLevel: "insufficient",
+		},
+		{
+			name:       "losing weight",
+			days:       tdeeDays(28, 2000, always, always, linear(80, -0.02, 0)),
+			wantTDEE:   floatPtr(2154),
+			wantWeekly: floatPtr(-0.14),
+			wantLogged: 28,
+			wantLevel:  "high",
+		},
+		{
+			name:       "stable weight weighed every other day",
+			days:       tdeeDays(14, 2500, always, func(i int) bool { return i%2 == 0 }, linear(70, 0, 0)),
+			wantTDEE:   floatPtr(2500),
+			wantWeekly: floatPtr(0),
+			wantLogged: 14,
+			wantLevel:  "high",
+		},
+		{
+			name:       "window starting before the first weigh-in",
+			days:       tdeeDays(10, 1800, always, always, linear(60, 0.07, 3)),
+			wantTDEE:   floatPtr(1261),
+			wantWeekly: floatPtr(0.49),
+			wantLogged: 10,
+			wantLevel:  "high",
+		},
+		{
+			name:       "unlogged days left out of the average intake",
+			days:       tdeeDays(14, 2000, func(i int) bool { return i%7 < 5 }, always, linear(70, 0, 0)),
+			wantTDEE:   floatPtr(2000),
+			wantWeekly: floatPtr(0),
+			wantLogged: 10,
+			wantLevel:  "high",
+		},
+		{
+			name:       "too few logged days",
+			days:       tdeeDays(14, 2000, func(i int) bool { return i < 5 }, always, linear(70, 0, 0)),
+			wantLogged: 5,
+			wantLevel:  "insufficient",
+		},
+		{
+			name:       "a single weigh-in",
+			days:       tdeeDays(14, 2000, always, func(i int) bool { return i == 0 }, linear(70, 0, 0)),
+			wantLogged: 14,
+			wantLevel:  "insufficient",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EstimateTDEE(tt.days)
+			if !floatPtrEqual(got.Estimated, tt.wantTDEE) {
+				t.Errorf("Estimated = %v, want %v", floatPtrString(got.Estimated), floatPtrString(tt.wantTDEE))
+			}
+			if !floatPtrEqual(got.WeeklyRateKg, tt.wantWeekly) {
+				t.Errorf("WeeklyRateKg = %v, want %v", floatPtrString(got.WeeklyRateKg), floatPtrString(tt.wantWeekly))
+			}
+			if got.LoggedDays != tt.wantLogged {
+				t.Errorf("LoggedDays = %d, want %d", got.LoggedDays, tt.wantLogged)
+			}
+			if got.Confidence.Level != tt.wantLevel {
+				t.Errorf("Confidence.Level = %s (%v), want %s", got.Confidence.Level, got.Confidence.Reasons, tt.wantLevel)
+			}
+		})
+	}
+}
+
+func floatPtr(value float64) *float64 {
+	return &value
+}
+
+func floatPtrEqual(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return math.Abs(*a-*b) < 1e-9
+}
+
+func floatPtrString(value *float64) string {
+	if value == nil {
+		return "nil"
+	}
+	return fmt.Sprint(*value)
+}
@@ -1,10 +1,13 @@
 package models
 
 import (
+	"database/sql"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/bytedance/sonic"
+	"github.com/jmoiron/sqlx"
 )
 
 type ExcerciseRecord struct {
@@ -16,10 +19,24 @@ type ExcerciseRecord struct {
 	Caloric     int       `json:"caloric" db:"caloric"`
 	Intensity   string    `json:"intensity" validate:"required,oneof=Low Medium High"`
 	Type        string    `json:"type" db:"type"`
+	// CatalogId is the catalog exercise logged, if any; CaloricEstimated is
+	// set when Caloric was estimated from its MET instead of entered
+	CatalogId        *int `json:"catalog_id,omitempty" db:"catalog_id"`
+	CaloricEstimated bool `json:"caloric_estimated" db:"caloric_estimated"`
+	// DistanceKm is the distance covered, for fastest time personal records
+	DistanceKm *float64 `json:"distance_km,omitempty" db:"distance_km"`
+	// Activity holds the metrics of a session imported from a GPS activity file
+	Activity *ActivityMetrics `json:"activity,omitempty" db:"activity"`
+
+	// Units, when set, are the units MarshalJSON writes the energy in
+	Units *UnitPreference `json:"-" db:"-"`
 }
 
 // MarshalJSON : Overloads BodyMeasurement
 func (a ExcerciseRecord) MarshalJSON() ([]byte, error) {
+	if a.Units != nil {
+		a.Caloric = int(math.Round(a.Units.EnergyFromKcal(float64(a.Caloric))))
+	}
 	return sonic.Marshal(struct {
 		ExcerciseId int    `json:"excercise_id" db:"excercise_id"`
 		UserId      int    `json:"user_id" db:"user_id"`
@@ -29,6 +46,12 @@ func (a ExcerciseRecord) MarshalJSON() ([]byte, error) {
 		Caloric     int    `json:"caloric" db:"caloric"`
 		Intensity   string `json:"intensity" validate:"required,oneof=Low Medium High"`
 		Type        string `json:"type" db:"type"`
+
+		CatalogId        *int             `json:"catalog_id,omitempty"`
+		CaloricEstimated bool             `json:"caloric_estimated"`
+		DistanceKm       *float64         `json:"distance_km,omitempty"`
+		Activity         *ActivityMetrics `json:"activity,omitempty"`
+		Units            *UnitPreference  `json:"units,omitempty"`
 	}{
 		ExcerciseId: a.ExcerciseId,
 		UserId:      a.UserId,
@@ -39,9 +62,22 @@ func (a ExcerciseRecord) MarshalJSON() ([]byte, error) {
 		Caloric:   a.Caloric,
 		Intensity: a.Intensity,
 		Type:      a.Type,
+
+		CatalogId:        a.CatalogId,
+		CaloricEstimated: a.CaloricEstimated,
+		DistanceKm:       a.DistanceKm,
+		Activity:         a.Activity,
+		Units:            a.Units,
 	})
 }
 
+// AttachExcerciseUnits makes every record marshal its energy in units
+func AttachExcerciseUnits(records []ExcerciseRecord, units UnitPreference) {
+	for i := range records {
+		records[i].Units = &units
+	}
+}
+
 // excerciseRecord implements excerciseRecord interface
 type excerciseRecordRepository struct{}
 
@@ -52,10 +88,16 @@ func NewexcerciseRecordRepository() ExcerciseRecordRepository {
 
 // excerciseRecord defines the interface for user data operations
 type ExcerciseRecordRepository interface {
-	Create(userId int, data ExcerciseRecord) error
+	Create(userId int, data ExcerciseRecord) (int, error)
+	FindById(userId, excerciseId int) (*ExcerciseRecord, error)
+	Import(userId int, data *ExcerciseRecord, route ActivityRoute) (int, error)
+	FindRoute(userId, excerciseId int) (ActivityRoute, error)
 	GetByUserId(userId, limit, page int) ([]ExcerciseRecord, error)
+	FindBetween(userId int, recordType string, from, to time.Time) ([]ExcerciseRecord, error)
 	Update(userId int, excerciseId int, data *ExcerciseRecord) error
 	Delete(userId int, excerciseId int) error
+	Bulk(userId int, mode BulkMode, ops []BulkOperation[ExcerciseRecord]) ([]BulkOutcome, error)
+	GetDailyCaloric(userId int, from, to string) ([]DailyExerciseCaloric, error)
 }
 
 // Delete ExcerciseRecord for a user
@@ -65,11 +107,17 @@ func (r *excerciseRecordRepository) Delete(userID, excerciseId int) error {
 		return fmt.Errorf("database connection is nil")
 	}
 
+	return requireOneRow(deleteExcerciseRecord(db, userID, excerciseId))
+}
+
+// deleteExcerciseRecord soft deletes one exercise record owned by userID that
+// is not deleted yet
+func deleteExcerciseRecord(e sqlx.Execer, userID, excerciseId int) (sql.Result, error) {
 	query := `UPDATE excercise_record SET deleted_at = NOW() 
 	WHERE user_id = $1 
-	AND excercise_id = $2`
-	_, err := db.Exec(query, userID, excerciseId)
-	return err
+	AND excercise_id = $2
+	AND deleted_at IS NULL`
+	return e.Exec(query, userID, excerciseId)
 }
 
 // Update ExcerciseRecord for a user
@@ -79,31 +127,117 @@ func (r *excerciseRecordRepository) Update(userId int, excerciseId int, data *Ex
 		return fmt.Errorf("database connection is nil")
 	}
 
+	return requireOneRow(updateExcerciseRecord(db, userId, excerciseId, data))
+}
+
+// updateExcerciseRecord overwrites one exercise record owned by userId that is not deleted
+func updateExcerciseRecord(e sqlx.Execer, userId int, excerciseId int, data *ExcerciseRecord) (sql.Result, error) {
 	query := `UPDATE excercise_record SET
-	minute = $1, caloric = $2, type = $3, intensity = $4, name = $5 
-	WHERE user_id = $6 AND excercise_id = $7`
+	minute = $1, caloric = $2, type = $3, intensity = $4, name = $5,
+	catalog_id = $6, caloric_estimated = $7, distance_km = $8
+	WHERE user_id = $9 AND excercise_id = $10 AND deleted_at IS NULL`
 
-	_, err := db.Exec(query, data.Minute, data.Caloric, data.Type, data.Intensity, data.Name, userId, excerciseId)
-	return err
+	return e.Exec(query, data.Minute, data.Caloric, data.Type, data.Intensity, data.Name,
+		data.CatalogId, data.CaloricEstimated, data.DistanceKm, userId, excerciseId)
+}
+
+// insertExcerciseRecord inserts an exercise record done at RecordAt and returns its id
+func insertExcerciseRecord(q sqlx.Queryer, userId int, data *ExcerciseRecord) (int, error) {
+	query := `INSERT INTO excercise_record
+	(user_id, minute, caloric, type, intensity, record_at, name, catalog_id, caloric_estimated, distance_km, activity)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	RETURNING excercise_id`
+
+	var excerciseId int
+	err := q.QueryRowx(query,
+		userId,
+		data.Minute, data.Caloric,
+		data.Type, data.Intensity, data.RecordAt, data.Name,
+		data.CatalogId, data.CaloricEstimated, data.DistanceKm, data.Activity).Scan(&excerciseId)
+	return excerciseId, err
 }
 
 // AddTodayIntake adds today's food intake for a user
-func (r *excerciseRecordRepository) Create(userId int, data ExcerciseRecord) error {
+func (r *excerciseRecordRepository) Create(userId int, data ExcerciseRecord) (int, error) {
 	db := GetDB().PostgreDBManager.RW
 	if db == nil {
-		return fmt.Errorf("database connection is nil")
+		return 0, fmt.Errorf("database connection is nil")
 	}
 
 	query := `INSERT INTO excercise_record
-	(user_id, minute, caloric, type, intensity, record_at, name)
-	VALUES ($1, $2, $3, $4, $5, NOW(), $6)`
+	(user_id, minute, caloric, type, intensity, record_at, name, catalog_id, caloric_estimated, distance_km)
+	VALUES ($1, $2, $3, $4, $5, NOW(), $6, $7, $8, $9)
+	RETURNING excercise_id`
 
-	_, err := db.Exec(query,
+	var excerciseId int
+	err := db.QueryRowx(query,
 		userId,
 		data.Minute, data.Caloric,
-		data.Type, data.Intensity, data.Name)
+		data.Type, data.Intensity, data.Name,
+		data.CatalogId, data.CaloricEstimated, data.DistanceKm).Scan(&excerciseId)
+
+	return excerciseId, err
+}
+
+// FindById returns one exercise record of a user that is not deleted
+func (r *excerciseRecordRepository) FindById(userId, excerciseId int) (*ExcerciseRecord, error) {
+	db := GetDB().PostgreDBManager.RC
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var record ExcerciseRecord
+	query := `SELECT 
+	excercise_id, user_id, minute, caloric, type, intensity, record_at, name,
+	catalog_id, caloric_estimated, distance_km, activity
+ FROM excercise_record 
+ WHERE user_id = $1 AND excercise_id = $2 AND deleted_at IS NULL`
+	if err := db.Get(&record, query, userId, excerciseId); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Import stores an exercise record imported from an activity file together
+// with its route in one transaction and returns its id
+func (r *excerciseRecordRepository) Import(userId int, data *ExcerciseRecord, route ActivityRoute) (int, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	excerciseId, err := insertExcerciseRecord(tx, userId, data)
+	if err != nil {
+		return 0, fmt.Errorf("error creating exercise record: %w", err)
+	}
+	if len(route) > 0 {
+		if _, err = tx.Exec(`INSERT INTO excercise_route (excercise_id, points) VALUES ($1, $2)`, excerciseId, route); err != nil {
+			return 0, fmt.Errorf("error creating exercise route: %w", err)
+		}
+	}
+	return excerciseId, tx.Commit()
+}
 
-	return err
+// FindRoute returns the route of an exercise record that is not deleted;
+// sql.ErrNoRows when the record has none
+func (r *excerciseRecordRepository) FindRoute(userId, excerciseId int) (ActivityRoute, error) {
+	db := GetDB().PostgreDBManager.RC
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var route ActivityRoute
+	query := `SELECT r.points FROM excercise_route r
+	JOIN excercise_record e ON e.excercise_id = r.excercise_id
+	WHERE e.user_id = $1 AND e.excercise_id = $2 AND e.deleted_at IS NULL`
+	err := db.Get(&route, query, userId, excerciseId)
+	return route, err
 }
 
 func (r *excerciseRecordRepository) GetByUserId(userID, limit, page int) ([]ExcerciseRecord, error) {
@@ -117,9 +251,64 @@ func (r *excerciseRecordRepository) GetByUserId(userID, limit, page int) ([]Exce
 
 	var records []ExcerciseRecord
 	query := `SELECT 
-	excercise_id, user_id, minute, caloric, type, intensity, record_at, name  
+	excercise_id, user_id, minute, caloric, type, intensity, record_at, name,
+	catalog_id, caloric_estimated, distance_km, activity
  FROM excercise_record 
  WHERE user_id = $1 AND deleted_at IS NULL ORDER BY record_at DESC LIMIT $2 OFFSET $3`
 	err := db.Select(&records, query, userID, limit, offset)
 	return records, err
 }
+
+// FindBetween retrieves the exercise records of recordType a user logged in
+// [from, to) that are not deleted, oldest first
+func (r *excerciseRecordRepository) FindBetween(userId int, recordType string, from, to time.Time) ([]ExcerciseRecord, error) {
+	db := GetDB().PostgreDBManager.RC
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var records []ExcerciseRecord
+	query := `SELECT 
+	excercise_id, user_id, minute, caloric, type, intensity, record_at, name,
+	catalog_id, caloric_estimated, distance_km, activity
+ FROM excercise_record 
+ WHERE user_id = $1 AND type = $2 AND record_at >= $3 AND record_at < $4 AND deleted_at IS NULL
+ ORDER BY record_at ASC, excercise_id ASC`
+	err := db.Select(&records, query, userId, recordType, from, to)
+	return records, err
+}
+
+// Bulk applies a batch of exercise record writes for userId. Created records
+// keep their RecordAt.
+func (r *excerciseRecordRepository) Bulk(userId int, mode BulkMode, ops []BulkOperation[ExcerciseRecord]) ([]BulkOutcome, error) {
+	return runBulk(mode, ops, func(tx *sqlx.Tx, op BulkOperation[ExcerciseRecord]) (int, error) {
+		switch op.Action {
+		case BulkCreate:
+			return insertExcerciseRecord(tx, userId, &op.Data)
+		case BulkUpdate:
+			return op.Id, requireOneRow(updateExcerciseRecord(tx, userId, op.Id, &op.Data))
+		case BulkDelete:
+			return op.Id, requireOneRow(deleteExcerciseRecord(tx, userId, op.Id))
+		}
+		return 0, fmt.Errorf("unknown bulk action %q", op.Action)
+	})
+}
+
+// GetDailyCaloric sums the calories burned per local date (in the user's
+// timezone) between from and to inclusive, skipping deleted records and days without exercise
+func (r *excerciseRecordRepository) GetDailyCaloric(userId int, from, to string) ([]DailyExerciseCaloric, error) {
+	db := GetDB().PostgreDBManager.RC
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var days []DailyExerciseCaloric
+	query := `SELECT TO_CHAR(DATE(record_at AT TIME ZONE $2), 'YYYY-MM-DD') AS date,
+	SUM(caloric) AS caloric
+ FROM excercise_record
+ WHERE user_id = $1 AND deleted_at IS NULL
+ AND DATE(record_at AT TIME ZONE $2) BETWEEN $3::date AND $4::date
+ GROUP BY 1 ORDER BY 1 ASC`
+	err := db.Select(&days, query, userId, resolveUserTimezone(userId), from, to)
+	return days, err
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/bytedance/sonic"
+)
+
+// ExerciseCategory groups catalog exercises; it is also the type of an exercise record
+type ExerciseCategory string
+
+const (
+	ExerciseCategoryCardio        ExerciseCategory = "Cardio"
+	ExerciseCategoryHIT           ExerciseCategory = "HIT"
+	ExerciseCategoryWeightLifting ExerciseCategory = "WeightLifting"
+	ExerciseCategorySports        ExerciseCategory = "Sports"
+	ExerciseCategoryFlexibility   ExerciseCategory = "Flexibility"
+	ExerciseCategoryOther         ExerciseCategory = "Other"
+)
+
+// Muscle groups a catalog exercise trains
+const (
+	MuscleChest      = "chest"
+	MuscleBack       = "back"
+	MuscleShoulders  = "shoulders"
+	MuscleBiceps     = "biceps"
+	MuscleTriceps    = "triceps"
+	MuscleForearms   = "forearms"
+	MuscleCore       = "core"
+	MuscleGlutes     = "glutes"
+	MuscleQuadriceps = "quadriceps"
+	MuscleHamstrings = "hamstrings"
+	MuscleCalves     = "calves"
+	MuscleFullBody   = "full_body"
+)
+
+// Exercise intensities, each with its own MET value in the catalog
+const (
+	IntensityLow    = "Low"
+	IntensityMedium = "Medium"
+	IntensityHigh   = "High"
+)
+
+// ExerciseMET is the metabolic equivalent of an exercise at each intensity
+type ExerciseMET struct {
+	Low    float64 `json:"low" db:"low"`
+	Medium float64 `json:"medium" db:"medium"`
+	High   float64 `json:"high" db:"high"`
+}
+
+// MuscleGroups is the JSONB list of muscle groups a catalog exercise trains
+type MuscleGroups []string
+
+// Scan implements the sql.Scanner interface
+func (g *MuscleGroups) Scan(value interface{}) error {
+	if value == nil {
+		*g = nil
+		return nil
+	}
+
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into MuscleGroups", value)
+	}
+	return sonic.Unmarshal(data, g)
+}
+
+// Value implements the driver.Valuer interface
+func (g MuscleGroups) Value() (driver.Value, error) {
+	data, err := sonic.Marshal([]string(g))
+	return string(data), err
+}
+
+// ExerciseCatalogEntry is one exercise users can log by its id, seeded into
+// exercise_catalog from the Compendium of Physical Activities by
+// db/seed/exercise_catalog.sql. Ids are referenced by exercise records,
+// workouts and personal records and must never be reused or renumbered.
+type ExerciseCatalogEntry struct {
+	CatalogId      int              `json:"catalog_id" db:"catalog_id"`
+	Name           string           `json:"name" db:"name"`
+	Category       ExerciseCategory `json:"category" db:"category"`
+	CompendiumCode *string          `json:"compendium_code,omitempty" db:"compendium_code"`
+	Met            ExerciseMET      `json:"met" db:"met"`
+	MuscleGroups   MuscleGroups     `json:"muscle_groups" db:"muscle_groups"`
+}
+
+// MET returns the MET value of the entry at intensity, Medium when unknown
+func (e ExerciseCatalogEntry) MET(intensity string) float64 {
+	switch intensity {
+	case IntensityLow:
+		return e.Met.Low
+	case IntensityHigh:
+		return e.Met.High
+	}
+	return e.Met.Medium
+}
+
+// EstimateExerciseCaloric estimates the kcal burned as MET × bodyweight in kg × hours
+func EstimateExerciseCaloric(met, bodyweightKg float64, minutes int) int {
+	return int(math.Round(met * bodyweightKg * float64(minutes) / 60))
+}
+
+// exerciseCatalogRepository implements ExerciseCatalogRepository interface
+type exerciseCatalogRepository struct{}
+
+// NewExerciseCatalogRepository creates a new exercise catalog repository
+func NewExerciseCatalogRepository() ExerciseCatalogRepository {
+	return &exerciseCatalogRepository{}
+}
+
+// ExerciseCatalogRepository defines the interface for reading the exercise catalog
+type ExerciseCatalogRepository interface {
+	Search(query string, category ExerciseCategory, muscleGroup string) ([]ExerciseCatalogEntry, error)
+	FindById(catalogId int) (*ExerciseCatalogEntry, error)
+	FindByIds(catalogIds []int) (map[int]ExerciseCatalogEntry, error)
+}
+
+const exerciseCatalogColumns = `catalog_id, name, category, compendium_code,
+	met_low AS "met.low", met_medium AS "met.medium", met_high AS "met.high", muscle_groups`
+
+// Search returns the catalog exercises whose name contains query, optionally
+// limited to one category and one muscle group
+func (r *exerciseCatalogRepository) Search(query string, category ExerciseCategory, muscleGroup string) ([]ExerciseCatalogEntry, error) {
+	db := GetDB().PostgreDBManager.RC
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	entries := []ExerciseCatalogEntry{}
+	statement := `SELECT ` + exerciseCatalogColumns + `
+ FROM exercise_catalog
+ WHERE ($1::text = '' OR POSITION($1::text IN LOWER(name)) > 0)
+ AND ($2::text = '' OR category = $2::text)
+ AND ($3::text = '' OR muscle_groups @> jsonb_build_array($3::text))
+ ORDER BY catalog_id ASC`
+	err := db.Select(&entries, statement, strings.ToLower(strings.TrimSpace(query)), string(category), muscleGroup)
+	return entries, err
+}
+
+// FindById returns the catalog exercise with the given id, sql.ErrNoRows when there is none
+func (r *exerciseCatalogRepository) FindById(catalogId int) (*ExerciseCatalogEntry, error) {
+	db := GetDB().PostgreDBManager.RC
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var entry ExerciseCatalogEntry
+	query := `SELECT ` + exerciseCatalogColumns + ` FROM exercise_catalog WHERE catalog_id = $1`
+	if err := db.Get(&entry, query, catalogId); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// FindByIds returns the catalog exercises with the given ids by id; unknown ids are left out
+func (r *exerciseCatalogRepository) FindByIds(catalogIds []int) (map[int]ExerciseCatalogEntry, error) {
+	found := map[int]ExerciseCatalogEntry{}
+	if len(catalogIds) == 0 {
+		return found, nil
+	}
+	db := GetDB().PostgreDBManager.RC
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var entries []ExerciseCatalogEntry
+	query := `SELECT ` + exerciseCatalogColumns + ` FROM exercise_catalog WHERE catalog_id = ANY($1)`
+	if err := db.Select(&entries, query, catalogIds); err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		found[entry.CatalogId] = entry
+	}
+	return found, nil
+}
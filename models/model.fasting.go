@@ -0,0 +1,429 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrFastingAlreadyActive is returned when starting a fast while another one is running
+var ErrFastingAlreadyActive = errors.New("a fasting session is already active")
+
+// FastingPreset is a named fasting schedule
+type FastingPreset string
+
+const (
+	FastingPreset16x8   FastingPreset = "16:8"
+	FastingPreset18x6   FastingPreset = "18:6"
+	FastingPreset20x4   FastingPreset = "20:4"
+	FastingPresetOMAD   FastingPreset = "omad"
+	FastingPresetCustom FastingPreset = "custom"
+)
+
+// fastingPresetHours is the fasting window of every preset except custom
+var fastingPresetHours = map[FastingPreset]float64{
+	FastingPreset16x8: 16,
+	FastingPreset18x6: 18,
+	FastingPreset20x4: 20,
+	FastingPresetOMAD: 23,
+}
+
+// FastingPresetHours returns the fasting window of a preset, false for custom or unknown presets
+func FastingPresetHours(preset FastingPreset) (float64, bool) {
+	hours, ok := fastingPresetHours[preset]
+	return hours, ok
+}
+
+// FastingSession is one fast. EndedAt is nil while the fast is active.
+// FoodLoggedCount is the number of users_food_intake entries logged between
+// start and end (or now), which means the fast was broken early.
+type FastingSession struct {
+	SessionId       int           `json:"session_id" db:"session_id"`
+	UserId          int           `json:"user_id" db:"user_id"`
+	Preset          FastingPreset `json:"preset" db:"preset"`
+	StartedAt       time.Time     `json:"started_at" db:"started_at"`
+	PlannedEndAt    time.Time     `json:"planned_end_at" db:"planned_end_at"`
+	EndedAt         *time.Time    `json:"ended_at,omitempty" db:"ended_at"`
+	FoodLoggedCount int           `json:"food_logged_count" db:"food_logged_count"`
+}
+
+// Active reports whether the fast has not been stopped yet
+func (s FastingSession) Active() bool {
+	return s.EndedAt == nil
+}
+
+// Completed reports whether the fast was stopped at or after its planned end
+func (s FastingSession) Completed() bool {
+	return s.EndedAt != nil && !s.EndedAt.Before(s.PlannedEndAt)
+}
+
+// TargetHours is the planned length of the fast
+func (s FastingSession) TargetHours() float64 {
+	return s.PlannedEndAt.Sub(s.StartedAt).Hours()
+}
+
+// ElapsedHours is how long the fast lasted, or has lasted so far at now
+func (s FastingSession) ElapsedHours(now time.Time) float64 {
+	end := now
+	if s.EndedAt != nil {
+		end = *s.EndedAt
+	}
+	return math.Max(0, end.Sub(s.StartedAt).Hours())
+}
+
+// Warning explains why the fast needs attention, empty when it does not
+func (s FastingSession) Warning() string {
+	if s.FoodLoggedCount == 0 {
+		return ""
+	}
+	if s.Active() {
+		return fmt.Sprintf("%d food intake entries were logged during this active fast", s.FoodLoggedCount)
+	}
+	return fmt.Sprintf("%d food intake entries were logged during this fast", s.FoodLoggedCount)
+}
+
+// IntakeWarning explains that food logged at loggedAt falls inside this active
+// fast, empty without a fast or when the food was logged outside of it
+func (s *FastingSession) IntakeWarning(loggedAt time.Time) string {
+	if s == nil || !s.Active() || !loggedAt.After(s.StartedAt) {
+		return ""
+	}
+	return fmt.Sprintf("logged during the active %s fast started at %s", s.Preset, s.StartedAt.Format(time.RFC3339))
+}
+
+// MarshalJSON : Overloads FastingSession
+func (a FastingSession) MarshalJSON() ([]byte, error) {
+	var endedAt *string
+	if a.EndedAt != nil {
+		formatted := a.EndedAt.Format(time.RFC3339)
+		endedAt = &formatted
+	}
+	elapsed := a.ElapsedHours(time.Now())
+	return sonic.Marshal(struct {
+		SessionId       int           `json:"session_id"`
+		UserId          int           `json:"user_id"`
+		Preset          FastingPreset `json:"preset"`
+		StartedAt       string        `json:"started_at"`
+		PlannedEndAt    string        `json:"planned_end_at"`
+		EndedAt         *string       `json:"ended_at,omitempty"`
+		Active          bool          `json:"active"`
+		Completed       bool          `json:"completed"`
+		TargetHours     float64       `json:"target_hours"`
+		ElapsedHours    float64       `json:"elapsed_hours"`
+		RemainingHours  float64       `json:"remaining_hours"`
+		ProgressPercent float64       `json:"progress_percent"`
+		FoodLoggedCount int           `json:"food_logged_count"`
+		Warning         string        `json:"warning,omitempty"`
+	}{
+		SessionId:       a.SessionId,
+		UserId:          a.UserId,
+		Preset:          a.Preset,
+		StartedAt:       a.StartedAt.Format(time.RFC3339),
+		PlannedEndAt:    a.PlannedEndAt.Format(time.RFC3339),
+		EndedAt:         endedAt,
+		Active:          a.Active(),
+		Completed:       a.Completed(),
+		TargetHours:     round2(a.TargetHours()),
+		ElapsedHours:    round2(elapsed),
+		RemainingHours:  round2(math.Max(0, a.TargetHours()-elapsed)),
+		ProgressPercent: round2(math.Min(100, elapsed/a.TargetHours()*100)),
+		FoodLoggedCount: a.FoodLoggedCount,
+		Warning:         a.Warning(),
+	})
+}
+
+// FastingStreak counts consecutive local days on which a fast was completed.
+// The current streak still counts when today has no completed fast yet.
+type FastingStreak struct {
+	Current       int     `json:"current"`
+	Longest       int     `json:"longest"`
+	LastCompleted *string `json:"last_completed"`
+}
+
+// BuildFastingStreak computes streaks from the distinct local dates
+// (YYYY-MM-DD) of completed fasts, newest first
+func BuildFastingStreak(completedDates []string, today time.Time) FastingStreak {
+	var streak FastingStreak
+	days := make([]time.Time, 0, len(completedDates))
+	for _, date := range completedDates {
+		if day, err := time.ParseInLocation(SQLDateFormat, date, today.Location()); err == nil {
+			days = append(days, day)
+		}
+	}
+	if len(days) == 0 {
+		return streak
+	}
+	last := days[0].Format(SQLDateFormat)
+	streak.LastCompleted = &last
+
+	run, newestRun := 0, 0
+	for i, day := range days {
+		if i > 0 && days[i-1].AddDate(0, 0, -1).Equal(day) {
+			run++
+		} else {
+			run = 1
+		}
+		if run == i+1 {
+			newestRun = run
+		}
+		streak.Longest = max(streak.Longest, run)
+	}
+
+	midnight := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, today.Location())
+	if !days[0].Before(midnight.AddDate(0, 0, -1)) {
+		streak.Current = newestRun
+	}
+	return streak
+}
+
+// FastingDayStats is the fasting time that fell on one local day
+type FastingDayStats struct {
+	Date         string  `json:"date"`
+	FastingHours float64 `json:"fasting_hours"`
+}
+
+// FastingWeeklyStats summarises the fasts overlapping one Monday-based week.
+// Hours are clipped to the week, and active fasts count up to now.
+type FastingWeeklyStats struct {
+	StartDate          string            `json:"start_date"`
+	EndDate            string            `json:"end_date"`
+	Sessions           int               `json:"sessions"`
+	CompletedSessions  int               `json:"completed_sessions"`
+	TotalFastingHours  float64           `json:"total_fasting_hours"`
+	AverageFastHours   float64           `json:"average_fast_hours"`
+	LongestFastHours   float64           `json:"longest_fast_hours"`
+	BrokenFastSessions int               `json:"broken_fast_sessions"`
+	Days               []FastingDayStats `json:"days"`
+}
+
+// BuildFastingWeeklyStats spreads the sessions over the seven local days starting at start
+func BuildFastingWeeklyStats(start time.Time, sessions []FastingSession, now time.Time) FastingWeeklyStats {
+	stats := FastingWeeklyStats{
+		StartDate: start.Format(SQLDateFormat),
+		EndDate:   start.AddDate(0, 0, 6).Format(SQLDateFormat),
+		Days:      make([]FastingDayStats, 0, 7),
+	}
+
+	hoursPerDay := make([]float64, 7)
+	var sessionHours float64
+	for _, session := range sessions {
+		sessionEnd := now
+		if session.EndedAt != nil {
+			sessionEnd = *session.EndedAt
+		}
+
+		stats.Sessions++
+		if session.Completed() {
+			stats.CompletedSessions++
+		}
+		if session.FoodLoggedCount > 0 {
+			stats.BrokenFastSessions++
+		}
+		elapsed := session.ElapsedHours(now)
+		sessionHours += elapsed
+		stats.LongestFastHours = math.Max(stats.LongestFastHours, elapsed)
+
+		for i := 0; i < 7; i++ {
+			dayStart := start.AddDate(0, 0, i)
+			hoursPerDay[i] += overlapHours(session.StartedAt, sessionEnd, dayStart, dayStart.AddDate(0, 0, 1))
+		}
+	}
+
+	for i, hours := range hoursPerDay {
+		stats.Days = append(stats.Days, FastingDayStats{
+			Date:         start.AddDate(0, 0, i).Format(SQLDateFormat),
+			FastingHours: round2(hours),
+		})
+		stats.TotalFastingHours += hours
+	}
+	stats.TotalFastingHours = round2(stats.TotalFastingHours)
+	stats.LongestFastHours = round2(stats.LongestFastHours)
+	if stats.Sessions > 0 {
+		stats.AverageFastHours = round2(sessionHours / float64(stats.Sessions))
+	}
+	return stats
+}
+
+// overlapHours is the length of the overlap between [from, to) and [windowFrom, windowTo)
+func overlapHours(from, to, windowFrom, windowTo time.Time) float64 {
+	if from.Before(windowFrom) {
+		from = windowFrom
+	}
+	if to.After(windowTo) {
+		to = windowTo
+	}
+	if !to.After(from) {
+		return 0
+	}
+	return to.Sub(from).Hours()
+}
+
+// fastingRepository implements FastingRepository interface
+type fastingRepository struct{}
+
+// NewFastingRepository creates a new fasting repository
+func NewFastingRepository() FastingRepository {
+	return &fastingRepository{}
+}
+
+// FastingRepository defines the interface for fasting session operations
+type FastingRepository interface {
+	FindActive(userId int) (*FastingSession, error)
+	Start(session *FastingSession) error
+	Stop(userId int, endedAt time.Time) (*FastingSession, error)
+	GetByUserId(userId, limit, page int) ([]FastingSession, error)
+	FindOverlapping(userId int, from, to time.Time) ([]FastingSession, error)
+	FindCompletedDates(userId int) ([]string, error)
+	Delete(userId, sessionId int) error
+}
+
+// fastingSessionColumns selects a users_fasting_session row aliased S, counting
+// the food logged while it ran from users_food_intake timestamps
+const fastingSessionColumns = `S.session_id, S.user_id, S.preset, S.started_at, S.planned_end_at, S.ended_at,
+	(SELECT COUNT(*) FROM users_food_intake F
+	  WHERE F.user_id = S.user_id
+	  AND F.created_at > S.started_at
+	  AND F.created_at < COALESCE(S.ended_at, NOW())) AS food_logged_count`
+
+// FindActive retrieves the running fast of a user, sql.ErrNoRows when there is none
+func (r *fastingRepository) FindActive(userId int) (*FastingSession, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	return findActiveFastingSession(db, userId)
+}
+
+// Start begins a fast; fails with ErrFastingAlreadyActive while another one runs
+func (r *fastingRepository) Start(session *FastingSession) error {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Serialise starts per user so two requests cannot both open a fast
+	if _, err = tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('fasting'), $1)`, session.UserId); err != nil {
+		return fmt.Errorf("error locking fasting sessions: %w", err)
+	}
+	var active int
+	if err = tx.Get(&active, `SELECT COUNT(*) FROM users_fasting_session WHERE user_id = $1 AND ended_at IS NULL`, session.UserId); err != nil {
+		return err
+	}
+	if active > 0 {
+		return ErrFastingAlreadyActive
+	}
+
+	query := `INSERT INTO users_fasting_session (user_id, preset, started_at, planned_end_at)
+	VALUES ($1, $2, $3, $4) RETURNING session_id`
+	if err = tx.QueryRowx(query, session.UserId, session.Preset, session.StartedAt, session.PlannedEndAt).Scan(&session.SessionId); err != nil {
+		return fmt.Errorf("error starting fasting session: %w", err)
+	}
+	return tx.Commit()
+}
+
+// Stop ends the running fast at endedAt, sql.ErrNoRows when there is none
+func (r *fastingRepository) Stop(userId int, endedAt time.Time) (*FastingSession, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var sessionId int
+	query := `UPDATE users_fasting_session SET ended_at = GREATEST($1, started_at)
+	WHERE user_id = $2 AND ended_at IS NULL RETURNING session_id`
+	if err := db.QueryRowx(query, endedAt, userId).Scan(&sessionId); err != nil {
+		return nil, err
+	}
+
+	var session FastingSession
+	err := db.Get(&session, `SELECT `+fastingSessionColumns+`
+ FROM users_fasting_session S WHERE S.user_id = $1 AND S.session_id = $2`, userId, sessionId)
+	return &session, err
+}
+
+// GetByUserId retrieves the fasting history of a user, newest first
+func (r *fastingRepository) GetByUserId(userId, limit, page int) ([]FastingSession, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	offset := (page - 1) * limit
+	limit = limit + 1
+
+	var sessions []FastingSession
+	query := `SELECT ` + fastingSessionColumns + `
+ FROM users_fasting_session S
+ WHERE S.user_id = $1 ORDER BY S.started_at DESC LIMIT $2 OFFSET $3`
+	err := db.Select(&sessions, query, userId, limit, offset)
+	return sessions, err
+}
+
+// FindOverlapping retrieves the fasts that ran at any point in [from, to)
+func (r *fastingRepository) FindOverlapping(userId int, from, to time.Time) ([]FastingSession, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var sessions []FastingSession
+	query := `SELECT ` + fastingSessionColumns + `
+ FROM users_fasting_session S
+ WHERE S.user_id = $1
+ AND S.started_at < $3
+ AND COALESCE(S.ended_at, NOW()) > $2
+ ORDER BY S.started_at ASC`
+	err := db.Select(&sessions, query, userId, from, to)
+	return sessions, err
+}
+
+// FindCompletedDates lists the distinct local dates (in the user's timezone)
+// on which a fast reached its planned end, newest first
+func (r *fastingRepository) FindCompletedDates(userId int) ([]string, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var dates []string
+	query := `SELECT DISTINCT TO_CHAR(ended_at AT TIME ZONE $2, 'YYYY-MM-DD') AS completed_date
+ FROM users_fasting_session
+ WHERE user_id = $1 AND ended_at IS NOT NULL AND ended_at >= planned_end_at
+ ORDER BY completed_date DESC`
+	err := db.Select(&dates, query, userId, resolveUserTimezone(userId))
+	return dates, err
+}
+
+// Delete removes a fasting session
+func (r *fastingRepository) Delete(userId, sessionId int) error {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	query := `DELETE FROM users_fasting_session WHERE user_id = $1 AND session_id = $2`
+	_, err := db.Exec(query, userId, sessionId)
+	return err
+}
+
+func findActiveFastingSession(q sqlx.Queryer, userId int) (*FastingSession, error) {
+	var session FastingSession
+	query := `SELECT ` + fastingSessionColumns + `
+ FROM users_fasting_session S WHERE S.user_id = $1 AND S.ended_at IS NULL
+ ORDER BY S.started_at DESC LIMIT 1`
+	if err := sqlx.Get(q, &session, query, userId); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
@@ -0,0 +1,168 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBuildFastingStreak(t *testing.T) {
+	today := time.Date(2026, 3, 10, 21, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		dates         []string
+		wantCurrent   int
+		wantLongest   int
+		wantCompleted string
+	}{
+		{name: "no completed fasts"},
+		{name: "completed through today", dates: []string{"2026-03-10", "2026-03-09", "2026-03-08"}, wantCurrent: 3, wantLongest: 3, wantCompleted: "2026-03-10"},
+		{name: "today not completed yet still counts", dates: []string{"2026-03-09", "2026-03-08"}, wantCurrent: 2, wantLongest: 2, wantCompleted: "2026-03-09"},
+		{name: "missed yesterday breaks the streak", dates: []string{"2026-03-08", "2026-03-07"}, wantCurrent: 0, wantLongest: 2, wantCompleted: "2026-03-08"},
+		{name: "longest run is an older one", dates: []string{"2026-03-10", "2026-03-09", "2026-03-05", "2026-03-04", "2026-03-03", "2026-03-02"}, wantCurrent: 2, wantLongest: 4, wantCompleted: "2026-03-10"},
+		{name: "run across a month end", dates: []string{"2026-03-01", "2026-02-28", "2026-02-27"}, wantCurrent: 0, wantLongest: 3, wantCompleted: "2026-03-01"},
+		{name: "unparsable dates are skipped", dates: []string{"not a date", "2026-03-10"}, wantCurrent: 1, wantLongest: 1, wantCompleted: "2026-03-10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BuildFastingStreak(tt.dates, today)
+			if got.Current != tt.wantCurrent || got.Longest != tt.wantLongest {
+				t.Errorf("current %d longest %d, want %d and %d", got.Current, got.Longest, tt.wantCurrent, tt.wantLongest)
+			}
+			completed := ""
+			if got.LastCompleted != nil {
+				completed = *got.LastCompleted
+			}
+			if completed != tt.wantCompleted {
+				t.Errorf("last completed %q, want %q", completed, tt.wantCompleted)
+			}
+		})
+	}
+}
+
+func TestBuildFastingWeeklyStats(t *testing.T) {
+	monday := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2026, 3, 8, 12, 0, 0, 0, time.UTC)
+	at := func(day, hour int) time.Time {
+		return time.Date(2026, 3, day, hour, 0, 0, 0, time.UTC)
+	}
+	session := func(start time.Time, hours float64, end *time.Time, food int) FastingSession {
+		return FastingSession{StartedAt: start, PlannedEndAt: start.Add(time.Duration(hours * float64(time.Hour))), EndedAt: end, FoodLoggedCount: food}
+	}
+	timePtr := func(t time.Time) *time.Time { return &t }
+
+	tests := []struct {
+		name          string
+		sessions      []FastingSession
+		wantDays      []float64
+		wantTotal     float64
+		wantAverage   float64
+		wantLongest   float64
+		wantSessions  int
+		wantCompleted int
+		wantBroken    int
+	}{
+		{
+			name:     "no fasts",
+			wantDays: []float64{0, 0, 0, 0, 0, 0, 0},
+		},
+		{
+			name:          "fast started the Sunday before is clipped to the week",
+			sessions:      []FastingSession{session(at(1, 20), 16, timePtr(at(2, 12)), 0)},
+			wantDays:      []float64{12, 0, 0, 0, 0, 0, 0},
+			wantTotal:     12,
+			wantAverage:   16,
+			wantLongest:   16,
+			wantSessions:  1,
+			wantCompleted: 1,
+		},
+		{
+			name:         "broken fast split over midnight",
+			sessions:     []FastingSession{session(at(4, 20), 16, timePtr(at(5, 8)), 2)},
+			wantDays:     []float64{0, 0, 4, 8, 0, 0, 0},
+			wantTotal:    12,
+			wantAverage:  12,
+			wantLongest:  12,
+			wantSessions: 1,
+			wantBroken:   1,
+		},
+		{
+			name:         "active fast counts up to now",
+			sessions:     []FastingSession{session(at(8, 2), 18, nil, 0)},
+			wantDays:     []float64{0, 0, 0, 0, 0, 0, 10},
+			wantTotal:    10,
+			wantAverage:  10,
+			wantLongest:  10,
+			wantSessions: 1,
+		},
+		{
+			name: "whole week",
+			sessions: []FastingSession{
+				session(at(1, 20), 16, timePtr(at(2, 12)), 0),
+				session(at(4, 20), 16, timePtr(at(5, 8)), 2),
+				session(at(8, 2), 18, nil, 0),
+			},
+			wantDays:      []float64{12, 0, 4, 8, 0, 0, 10},
+			wantTotal:     34,
+			wantAverage:   12.67,
+			wantLongest:   16,
+			wantSessions:  3,
+			wantCompleted: 1,
+			wantBroken:    1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BuildFastingWeeklyStats(monday, tt.sessions, now)
+			if got.StartDate != "2026-03-02" || got.EndDate != "2026-03-08" {
+				t.Errorf("week %s to %s, want 2026-03-02 to 2026-03-08", got.StartDate, got.EndDate)
+			}
+			days := make([]float64, len(got.Days))
+			for i, day := range got.Days {
+				days[i] = day.FastingHours
+			}
+			if !reflect.DeepEqual(days, tt.wantDays) {
+				t.Errorf("days %v, want %v", days, tt.wantDays)
+			}
+			if got.TotalFastingHours != tt.wantTotal || got.AverageFastHours != tt.wantAverage || got.LongestFastHours != tt.wantLongest {
+				t.Errorf("total %v average %v longest %v, want %v, %v and %v",
+					got.TotalFastingHours, got.AverageFastHours, got.LongestFastHours, tt.wantTotal, tt.wantAverage, tt.wantLongest)
+			}
+			if got.Sessions != tt.wantSessions || got.CompletedSessions != tt.wantCompleted || got.BrokenFastSessions != tt.wantBroken {
+				t.Errorf("sessions %d completed %d broken %d, want %d, %d and %d",
+					got.Sessions, got.CompletedSessions, got.BrokenFastSessions, tt.wantSessions, tt.wantCompleted, tt.wantBroken)
+			}
+		})
+	}
+}
+
+func TestFastingIntakeWarning(t *testing.T) {
+	started := time.Date(2026, 3, 2, 20, 0, 0, 0, time.UTC)
+	ended := started.Add(16 * time.Hour)
+	active := &FastingSession{Preset: FastingPresetOMAD, StartedAt: started, PlannedEndAt: started.Add(23 * time.Hour)}
+	stopped := &FastingSession{Preset: FastingPreset16x8, StartedAt: started, PlannedEndAt: ended, EndedAt: &ended}
+
+	tests := []struct {
+		name     string
+		session  *FastingSession
+		loggedAt time.Time
+		want     string
+	}{
+		{name: "no fast", session: nil, loggedAt: started.Add(time.Hour)},
+		{name: "during the active fast", session: active, loggedAt: started.Add(time.Hour), want: "logged during the active omad fast started at 2026-03-02T20:00:00Z"},
+		{name: "before the active fast started", session: active, loggedAt: started.Add(-time.Hour)},
+		{name: "at the start of the fast", session: active, loggedAt: started},
+		{name: "fast already stopped", session: stopped, loggedAt: started.Add(time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.session.IntakeWarning(tt.loggedAt); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,443 @@
+package models
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/jmoiron/sqlx"
+)
+
+// GroceryUnit is the unit an ingredient quantity is given in
+type GroceryUnit string
+
+const (
+	GroceryUnitGram       GroceryUnit = "g"
+	GroceryUnitKilogram   GroceryUnit = "kg"
+	GroceryUnitMillilitre GroceryUnit = "ml"
+	GroceryUnitLitre      GroceryUnit = "l"
+	GroceryUnitPiece      GroceryUnit = "piece"
+)
+
+// FoodGroup is the shop section an ingredient is grouped under
+type FoodGroup string
+
+const (
+	FoodGroupProduce     FoodGroup = "produce"
+	FoodGroupMeatSeafood FoodGroup = "meat_seafood"
+	FoodGroupDairyEggs   FoodGroup = "dairy_eggs"
+	FoodGroupBakery      FoodGroup = "bakery"
+	FoodGroupPantry      FoodGroup = "pantry"
+	FoodGroupFrozen      FoodGroup = "frozen"
+	FoodGroupBeverages   FoodGroup = "beverages"
+	FoodGroupOther       FoodGroup = "other"
+)
+
+// FoodGroups lists every food group in shopping order
+var FoodGroups = []FoodGroup{
+	FoodGroupProduce,
+	FoodGroupMeatSeafood,
+	FoodGroupDairyEggs,
+	FoodGroupBakery,
+	FoodGroupPantry,
+	FoodGroupFrozen,
+	FoodGroupBeverages,
+	FoodGroupOther,
+}
+
+// IngredientAmount holds the optional shopping details of a planned or
+// templated food. A food without a quantity is bought as one piece.
+type IngredientAmount struct {
+	Quantity  *float64     `json:"quantity,omitempty" db:"quantity"`
+	Unit      *GroceryUnit `json:"unit,omitempty" db:"unit"`
+	FoodGroup *FoodGroup   `json:"food_group,omitempty" db:"food_group"`
+}
+
+// baseAmount converts the amount to grams, millilitres or pieces
+func (a IngredientAmount) baseAmount() (float64, GroceryUnit) {
+	if a.Quantity == nil || a.Unit == nil {
+		return 1, GroceryUnitPiece
+	}
+	switch *a.Unit {
+	case GroceryUnitKilogram:
+		return *a.Quantity * 1000, GroceryUnitGram
+	case GroceryUnitLitre:
+		return *a.Quantity * 1000, GroceryUnitMillilitre
+	}
+	return *a.Quantity, *a.Unit
+}
+
+// GroceryList is a shopping list generated from the meal plan between two local dates
+type GroceryList struct {
+	ListId    int               `json:"list_id" db:"list_id"`
+	UserId    int               `json:"user_id" db:"user_id"`
+	FromDate  time.Time         `json:"from_date" db:"from_date"`
+	ToDate    time.Time         `json:"to_date" db:"to_date"`
+	CreatedAt time.Time         `json:"created_at" db:"created_at"`
+	Items     []GroceryListItem `json:"items" db:"-"`
+}
+
+// GroceryListGroup is the part of a grocery list under one food group
+type GroceryListGroup struct {
+	FoodGroup FoodGroup         `json:"food_group"`
+	Items     []GroceryListItem `json:"items"`
+}
+
+// MarshalJSON : Overloads GroceryList
+func (a GroceryList) MarshalJSON() ([]byte, error) {
+	checked := 0
+	for _, item := range a.Items {
+		if item.Checked {
+			checked++
+		}
+	}
+	return sonic.Marshal(struct {
+		ListId       int                `json:"list_id"`
+		UserId       int                `json:"user_id"`
+		FromDate     string             `json:"from_date"`
+		ToDate       string             `json:"to_date"`
+		CreatedAt    string             `json:"created_at"`
+		TotalItems   int                `json:"total_items"`
+		CheckedItems int                `json:"checked_items"`
+		Groups       []GroceryListGroup `json:"groups"`
+	}{
+		ListId:       a.ListId,
+		UserId:       a.UserId,
+		FromDate:     a.FromDate.Format(SQLDateFormat),
+		ToDate:       a.ToDate.Format(SQLDateFormat),
+		CreatedAt:    a.CreatedAt.Format(time.RFC3339),
+		TotalItems:   len(a.Items),
+		CheckedItems: checked,
+		Groups:       a.Groups(),
+	})
+}
+
+// Groups splits the items by food group, in shopping order, skipping empty groups
+func (a GroceryList) Groups() []GroceryListGroup {
+	byGroup := make(map[FoodGroup][]GroceryListItem)
+	for _, item := range a.Items {
+		byGroup[item.FoodGroup] = append(byGroup[item.FoodGroup], item)
+	}
+
+	groups := []GroceryListGroup{}
+	for _, group := range FoodGroups {
+		if items := byGroup[group]; len(items) > 0 {
+			groups = append(groups, GroceryListGroup{FoodGroup: group, Items: items})
+		}
+	}
+	return groups
+}
+
+// PlainText renders the list as a checklist grouped by food group
+func (a GroceryList) PlainText() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "Grocery list %s to %s\n", a.FromDate.Format(SQLDateFormat), a.ToDate.Format(SQLDateFormat))
+	for _, group := range a.Groups() {
+		fmt.Fprintf(&builder, "\n%s\n", group.FoodGroup.Label())
+		for _, item := range group.Items {
+			mark := " "
+			if item.Checked {
+				mark = "x"
+			}
+			fmt.Fprintf(&builder, "[%s] %s - %s\n", mark, item.Name, item.DisplayAmount())
+		}
+	}
+	return builder.String()
+}
+
+// CSV renders the list with one row per item
+func (a GroceryList) CSV() ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	if err := writer.Write([]string{"food_group", "name", "quantity", "unit", "checked"}); err != nil {
+		return nil, err
+	}
+	for _, group := range a.Groups() {
+		for _, item := range group.Items {
+			quantity, unit := item.displayQuantity()
+			row := []string{
+				string(group.FoodGroup),
+				item.Name,
+				strconv.FormatFloat(quantity, 'f', -1, 64),
+				string(unit),
+				strconv.FormatBool(item.Checked),
+			}
+			if err := writer.Write(row); err != nil {
+				return nil, err
+			}
+		}
+	}
+	writer.Flush()
+	return buffer.Bytes(), writer.Error()
+}
+
+// Label returns the food group as a heading, e.g. "Meat seafood"
+func (g FoodGroup) Label() string {
+	label := strings.ReplaceAll(string(g), "_", " ")
+	if label == "" {
+		return ""
+	}
+	return strings.ToUpper(label[:1]) + label[1:]
+}
+
+// GroceryListItem is one aggregated ingredient. Quantity is stored in the
+// base unit (g, ml or piece) and shown in kg or l once it reaches 1000.
+type GroceryListItem struct {
+	ItemId    int         `json:"item_id" db:"item_id"`
+	ListId    int         `json:"list_id" db:"list_id"`
+	Name      string      `json:"name" db:"name"`
+	FoodGroup FoodGroup   `json:"food_group" db:"food_group"`
+	Quantity  float64     `json:"quantity" db:"quantity"`
+	Unit      GroceryUnit `json:"unit" db:"unit"`
+	Checked   bool        `json:"checked" db:"checked"`
+}
+
+// MarshalJSON : Overloads GroceryListItem
+func (a GroceryListItem) MarshalJSON() ([]byte, error) {
+	quantity, unit := a.displayQuantity()
+	return sonic.Marshal(struct {
+		ItemId    int         `json:"item_id"`
+		ListId    int         `json:"list_id"`
+		Name      string      `json:"name"`
+		FoodGroup FoodGroup   `json:"food_group"`
+		Quantity  float64     `json:"quantity"`
+		Unit      GroceryUnit `json:"unit"`
+		Display   string      `json:"display"`
+		Checked   bool        `json:"checked"`
+	}{
+		ItemId:    a.ItemId,
+		ListId:    a.ListId,
+		Name:      a.Name,
+		FoodGroup: a.FoodGroup,
+		Quantity:  quantity,
+		Unit:      unit,
+		Display:   a.DisplayAmount(),
+		Checked:   a.Checked,
+	})
+}
+
+// DisplayAmount formats the quantity for people, e.g. "1.5 kg" or "3 pieces"
+func (a GroceryListItem) DisplayAmount() string {
+	quantity, unit := a.displayQuantity()
+	formatted := strconv.FormatFloat(quantity, 'f', -1, 64)
+	if unit == GroceryUnitPiece && quantity != 1 {
+		return formatted + " pieces"
+	}
+	return formatted + " " + string(unit)
+}
+
+func (a GroceryListItem) displayQuantity() (float64, GroceryUnit) {
+	switch {
+	case a.Unit == GroceryUnitGram && a.Quantity >= 1000:
+		return round2(a.Quantity / 1000), GroceryUnitKilogram
+	case a.Unit == GroceryUnitMillilitre && a.Quantity >= 1000:
+		return round2(a.Quantity / 1000), GroceryUnitLitre
+	}
+	return round2(a.Quantity), a.Unit
+}
+
+// BuildGroceryListItems sums the planned items that are not checked off yet by
+// ingredient name (case-insensitive) and base unit. Quantities in different
+// dimensions, e.g. grams and pieces of the same food, stay separate lines.
+func BuildGroceryListItems(planned []MealPlanItem) []GroceryListItem {
+	type key struct {
+		name string
+		unit GroceryUnit
+	}
+
+	indexes := make(map[key]int)
+	var items []GroceryListItem
+	for _, plan := range planned {
+		if plan.FoodId != nil {
+			continue
+		}
+
+		quantity, unit := plan.baseAmount()
+		k := key{name: strings.ToLower(strings.TrimSpace(plan.Name)), unit: unit}
+		index, ok := indexes[k]
+		if !ok {
+			index = len(items)
+			indexes[k] = index
+			items = append(items, GroceryListItem{Name: strings.TrimSpace(plan.Name), FoodGroup: FoodGroupOther, Unit: unit})
+		}
+
+		items[index].Quantity += quantity
+		if plan.FoodGroup != nil && items[index].FoodGroup == FoodGroupOther {
+			items[index].FoodGroup = *plan.FoodGroup
+		}
+	}
+
+	rank := make(map[FoodGroup]int, len(FoodGroups))
+	for i, group := range FoodGroups {
+		rank[group] = i
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].FoodGroup != items[j].FoodGroup {
+			return rank[items[i].FoodGroup] < rank[items[j].FoodGroup]
+		}
+		return strings.ToLower(items[i].Name) < strings.ToLower(items[j].Name)
+	})
+	return items
+}
+
+// groceryListRepository implements GroceryListRepository interface
+type groceryListRepository struct{}
+
+// NewGroceryListRepository creates a new grocery list repository
+func NewGroceryListRepository() GroceryListRepository {
+	return &groceryListRepository{}
+}
+
+// GroceryListRepository defines the interface for grocery list operations
+type GroceryListRepository interface {
+	GetByUserId(userId int) ([]GroceryList, error)
+	FindById(userId, listId int) (*GroceryList, error)
+	Create(list *GroceryList) error
+	SetItemChecked(userId, listId, itemId int, checked bool) error
+	Delete(userId, listId int) error
+}
+
+const groceryListItemColumns = `item_id, list_id, name, food_group, quantity, unit, checked`
+
+// GetByUserId retrieves every grocery list of a user, newest first, including items
+func (r *groceryListRepository) GetByUserId(userId int) ([]GroceryList, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var lists []GroceryList
+	query := `SELECT list_id, user_id, from_date, to_date, created_at
+ FROM users_grocery_list WHERE user_id = $1 ORDER BY created_at DESC`
+	if err := db.Select(&lists, query, userId); err != nil {
+		return nil, err
+	}
+
+	var items []GroceryListItem
+	itemQuery := `SELECT ` + groceryListItemColumns + `
+ FROM users_grocery_list_item
+ WHERE list_id IN (SELECT list_id FROM users_grocery_list WHERE user_id = $1)
+ ORDER BY item_id ASC`
+	if err := db.Select(&items, itemQuery, userId); err != nil {
+		return nil, err
+	}
+
+	byList := make(map[int][]GroceryListItem)
+	for _, item := range items {
+		byList[item.ListId] = append(byList[item.ListId], item)
+	}
+	for i := range lists {
+		lists[i].Items = byList[lists[i].ListId]
+	}
+	return lists, nil
+}
+
+// FindById retrieves one grocery list of a user with its items
+func (r *groceryListRepository) FindById(userId, listId int) (*GroceryList, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var list GroceryList
+	query := `SELECT list_id, user_id, from_date, to_date, created_at
+ FROM users_grocery_list WHERE user_id = $1 AND list_id = $2`
+	if err := db.Get(&list, query, userId, listId); err != nil {
+		return nil, err
+	}
+
+	itemQuery := `SELECT ` + groceryListItemColumns + `
+ FROM users_grocery_list_item WHERE list_id = $1 ORDER BY item_id ASC`
+	if err := db.Select(&list.Items, itemQuery, listId); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// Create stores a grocery list and its items in one transaction
+func (r *groceryListRepository) Create(list *GroceryList) error {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO users_grocery_list (user_id, from_date, to_date, created_at)
+	VALUES ($1, $2, $3, NOW()) RETURNING list_id, created_at`
+	err = tx.QueryRowx(query, list.UserId, list.FromDate.Format(SQLDateFormat), list.ToDate.Format(SQLDateFormat)).
+		Scan(&list.ListId, &list.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating grocery list: %w", err)
+	}
+
+	if err = insertGroceryListItems(tx, list); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetItemChecked ticks or unticks one item. Returns sql.ErrNoRows when the
+// item is not on a list of the user.
+func (r *groceryListRepository) SetItemChecked(userId, listId, itemId int, checked bool) error {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	query := `UPDATE users_grocery_list_item SET checked = $1
+	WHERE item_id = $2 AND list_id = $3
+	AND list_id IN (SELECT list_id FROM users_grocery_list WHERE user_id = $4)`
+	return requireOneRow(db.Exec(query, checked, itemId, listId, userId))
+}
+
+// Delete removes a grocery list and its items
+func (r *groceryListRepository) Delete(userId, listId int) error {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `DELETE FROM users_grocery_list_item WHERE list_id IN
+	(SELECT list_id FROM users_grocery_list WHERE user_id = $1 AND list_id = $2)`
+	if _, err = tx.Exec(query, userId, listId); err != nil {
+		return fmt.Errorf("error deleting grocery list items: %w", err)
+	}
+	if _, err = tx.Exec(`DELETE FROM users_grocery_list WHERE user_id = $1 AND list_id = $2`, userId, listId); err != nil {
+		return fmt.Errorf("error deleting grocery list: %w", err)
+	}
+	return tx.Commit()
+}
+
+func insertGroceryListItems(tx *sqlx.Tx, list *GroceryList) error {
+	query := `INSERT INTO users_grocery_list_item
+	(list_id, name, food_group, quantity, unit, checked)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING item_id`
+
+	for i := range list.Items {
+		item := &list.Items[i]
+		item.ListId = list.ListId
+		err := tx.QueryRowx(query, item.ListId, item.Name, item.FoodGroup,
+			item.Quantity, item.Unit, item.Checked).Scan(&item.ItemId)
+		if err != nil {
+			return fmt.Errorf("error creating grocery list item: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,333 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// BeverageType represents the kind of drink logged, like water, coffee, tea
+type BeverageType string
+
+const (
+	BeverageTypeWater  BeverageType = "water"
+	BeverageTypeCoffee BeverageType = "coffee"
+	BeverageTypeTea    BeverageType = "tea"
+	BeverageTypeOther  BeverageType = "other"
+)
+
+// caffeinePerMl holds typical caffeine content in mg per ml for beverages
+// where an estimate is meaningful (brewed coffee ~95 mg, tea ~47 mg per 240 ml)
+var caffeinePerMl = map[BeverageType]float64{
+	BeverageTypeWater:  0,
+	BeverageTypeCoffee: 0.4,
+	BeverageTypeTea:    0.2,
+}
+
+// EstimateCaffeineMg returns the typical caffeine for an amount of beverage,
+// or nil when the beverage type has no sensible default
+func EstimateCaffeineMg(beverageType BeverageType, amountMl float64) *float64 {
+	perMl, ok := caffeinePerMl[beverageType]
+	if !ok {
+		return nil
+	}
+	caffeine := round2(perMl * amountMl)
+	return &caffeine
+}
+
+type HydrationLog struct {
+	HydrationId  int          `json:"hydration_id" db:"hydration_id"`
+	UserId       int          `json:"user_id" db:"user_id"`
+	AmountMl     float64      `json:"amount_ml" db:"amount_ml"`
+	BeverageType BeverageType `json:"beverage_type" db:"beverage_type"`
+	CaffeineMg   *float64     `json:"caffeine_mg,omitempty" db:"caffeine_mg"`
+	LoggedAt     time.Time    `json:"logged_at" db:"logged_at"`
+
+	// Units, when set, are the units MarshalJSON writes the amount in
+	Units *UnitPreference `json:"-" db:"-"`
+}
+
+// MarshalJSON : Overloads HydrationLog
+func (a HydrationLog) MarshalJSON() ([]byte, error) {
+	if a.Units != nil {
+		a.AmountMl = a.Units.VolumeFromMl(a.AmountMl)
+	}
+	return sonic.Marshal(struct {
+		HydrationId  int             `json:"hydration_id"`
+		UserId       int             `json:"user_id"`
+		AmountMl     float64         `json:"amount_ml"`
+		BeverageType BeverageType    `json:"beverage_type"`
+		CaffeineMg   *float64        `json:"caffeine_mg,omitempty"`
+		LoggedAt     string          `json:"logged_at"`
+		Units        *UnitPreference `json:"units,omitempty"`
+	}{
+		HydrationId:  a.HydrationId,
+		UserId:       a.UserId,
+		AmountMl:     a.AmountMl,
+		BeverageType: a.BeverageType,
+		CaffeineMg:   a.CaffeineMg,
+		LoggedAt:     a.LoggedAt.Format(time.RFC3339),
+		Units:        a.Units,
+	})
+}
+
+// AttachHydrationUnits makes every hydration log marshal its amount in units
+func AttachHydrationUnits(logs []HydrationLog, units UnitPreference) {
+	for i := range logs {
+		logs[i].Units = &units
+	}
+}
+
+// HydrationDailySummary is one day of beverage intake against the hydration target
+type HydrationDailySummary struct {
+	Date            string                   `json:"date"`
+	Logs            []HydrationLog           `json:"logs"`
+	TotalMl         float64                  `json:"total_ml"`
+	TotalCaffeineMg float64                  `json:"total_caffeine_mg"`
+	ByType          map[BeverageType]float64 `json:"by_type"`
+	TargetMl        int                      `json:"target_ml"`
+	RemainingMl     float64                  `json:"remaining_ml"`
+	PercentOfGoal   *float64                 `json:"percent_of_goal"`
+
+	// Units, when set, are the units MarshalJSON writes the volumes in
+	Units *UnitPreference `json:"-"`
+}
+
+// MarshalJSON : Overloads HydrationDailySummary
+func (s HydrationDailySummary) MarshalJSON() ([]byte, error) {
+	targetMl := float64(s.TargetMl)
+	if s.Units != nil {
+		units := *s.Units
+		s.TotalMl = units.VolumeFromMl(s.TotalMl)
+		s.RemainingMl = units.VolumeFromMl(s.RemainingMl)
+		targetMl = units.VolumeFromMl(targetMl)
+		byType := make(map[BeverageType]float64, len(s.ByType))
+		for beverageType, amount := range s.ByType {
+			byType[beverageType] = units.VolumeFromMl(amount)
+		}
+		s.ByType = byType
+	}
+	return sonic.Marshal(struct {
+		Date            string                   `json:"date"`
+		Logs            []HydrationLog           `json:"logs"`
+		TotalMl         float64                  `json:"total_ml"`
+		TotalCaffeineMg float64                  `json:"total_caffeine_mg"`
+		ByType          map[BeverageType]float64 `json:"by_type"`
+		TargetMl        float64                  `json:"target_ml"`
+		RemainingMl     float64                  `json:"remaining_ml"`
+		PercentOfGoal   *float64                 `json:"percent_of_goal"`
+		Units           *UnitPreference          `json:"units,omitempty"`
+	}{
+		Date:            s.Date,
+		Logs:            s.Logs,
+		TotalMl:         s.TotalMl,
+		TotalCaffeineMg: s.TotalCaffeineMg,
+		ByType:          s.ByType,
+		TargetMl:        targetMl,
+		RemainingMl:     s.RemainingMl,
+		PercentOfGoal:   s.PercentOfGoal,
+		Units:           s.Units,
+	})
+}
+
+// AttachUnits makes the summary and its logs marshal their volumes in units
+func (s *HydrationDailySummary) AttachUnits(units UnitPreference) {
+	s.Units = &units
+	AttachHydrationUnits(s.Logs, units)
+}
+
+// BuildHydrationDailySummary totals one day of hydration logs against the target
+func BuildHydrationDailySummary(date string, logs []HydrationLog, target *UserTarget) HydrationDailySummary {
+	summary := HydrationDailySummary{
+		Date:   date,
+		Logs:   logs,
+		ByType: make(map[BeverageType]float64),
+	}
+	if summary.Logs == nil {
+		summary.Logs = []HydrationLog{}
+	}
+	if target != nil {
+		summary.TargetMl = target.HydrationMl
+	}
+
+	for _, log := range logs {
+		summary.TotalMl += log.AmountMl
+		summary.ByType[log.BeverageType] += log.AmountMl
+		if log.CaffeineMg != nil {
+			summary.TotalCaffeineMg += *log.CaffeineMg
+		}
+	}
+
+	summary.TotalMl = round2(summary.TotalMl)
+	summary.TotalCaffeineMg = round2(summary.TotalCaffeineMg)
+	summary.RemainingMl = round2(float64(summary.TargetMl) - summary.TotalMl)
+	summary.PercentOfGoal = percentOf(summary.TotalMl, float64(summary.TargetMl))
+	return summary
+}
+
+// HydrationChartMetrics lists the metrics that can be selected on the hydration chart
+var HydrationChartMetrics = []string{"amount_ml", "caffeine_mg"}
+
+type HydrationChartData struct {
+	AmountMl   float64 `json:"total_amount_ml" db:"total_amount_ml"`
+	CaffeineMg float64 `json:"total_caffeine_mg" db:"total_caffeine_mg"`
+	Period     string  `json:"time_slice_label" db:"time_slice_label"`
+}
+
+func (d HydrationChartData) label() string {
+	return d.Period
+}
+
+// metric returns the total for a metric name of HydrationChartMetrics
+func (d HydrationChartData) metric(name string) float64 {
+	switch name {
+	case "amount_ml":
+		return d.AmountMl
+	case "caffeine_mg":
+		return d.CaffeineMg
+	}
+	return 0
+}
+
+// BuildHydrationChartPoints turns ascending, zero-filled daily hydration
+// buckets into chart points starting at from, newest first
+func BuildHydrationChartPoints(daily []HydrationChartData, from string, granularity ChartGranularity, metrics []string) []ChartPoint {
+	return buildChartPoints(daily, from, granularity, metrics)
+}
+
+// hydrationRepository implements HydrationRepository interface
+type hydrationRepository struct{}
+
+// NewHydrationRepository creates a new hydration repository
+func NewHydrationRepository() HydrationRepository {
+	return &hydrationRepository{}
+}
+
+// HydrationRepository defines the interface for hydration data operations
+type HydrationRepository interface {
+	Create(userId int, data HydrationLog) (*HydrationLog, error)
+	GetByUserId(userId, limit, page int) ([]HydrationLog, error)
+	FindByDate(userId int, date string) ([]HydrationLog, error)
+	Update(userId int, hydrationId int, data *HydrationLog) error
+	Delete(userId int, hydrationId int) error
+
+	GetHydrationChartData(userId int, from, to string) ([]HydrationChartData, error)
+}
+
+// Create adds a hydration log for a user
+func (r *hydrationRepository) Create(userId int, data HydrationLog) (*HydrationLog, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var created HydrationLog
+	query := `INSERT INTO users_hydration_log
+	(user_id, amount_ml, beverage_type, caffeine_mg, logged_at)
+	VALUES ($1, $2, $3, $4, NOW())
+	RETURNING hydration_id, user_id, amount_ml, beverage_type, caffeine_mg, logged_at`
+
+	err := db.Get(&created, query, userId, data.AmountMl, data.BeverageType, data.CaffeineMg)
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// Update updates a hydration log for a user
+func (r *hydrationRepository) Update(userId int, hydrationId int, data *HydrationLog) error {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	query := `UPDATE users_hydration_log SET
+	amount_ml = $1, beverage_type = $2, caffeine_mg = $3
+	WHERE user_id = $4 AND hydration_id = $5`
+
+	_, err := db.Exec(query, data.AmountMl, data.BeverageType, data.CaffeineMg, userId, hydrationId)
+	return err
+}
+
+// Delete removes a hydration log for a user
+func (r *hydrationRepository) Delete(userId int, hydrationId int) error {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	query := `DELETE FROM users_hydration_log 
+	WHERE user_id = $1 
+	AND hydration_id = $2`
+	_, err := db.Exec(query, userId, hydrationId)
+	return err
+}
+
+func (r *hydrationRepository) GetByUserId(userId, limit, page int) ([]HydrationLog, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	offset := (page - 1) * limit
+	limit = limit + 1
+
+	var logs []HydrationLog
+	query := `SELECT 
+	hydration_id, user_id, amount_ml, beverage_type, caffeine_mg, logged_at
+ FROM users_hydration_log 
+ WHERE user_id = $1 ORDER BY logged_at DESC LIMIT $2 OFFSET $3`
+
+	err := db.Select(&logs, query, userId, limit, offset)
+	return logs, err
+}
+
+// FindByDate retrieves the hydration logs of one local date (YYYY-MM-DD) in the user's timezone
+func (r *hydrationRepository) FindByDate(userId int, date string) ([]HydrationLog, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var logs []HydrationLog
+	query := `SELECT 
+	hydration_id, user_id, amount_ml, beverage_type, caffeine_mg, logged_at
+ FROM users_hydration_log 
+ WHERE user_id = $1 
+ AND DATE(logged_at AT TIME ZONE $2) = $3::date
+ ORDER BY logged_at ASC`
+
+	err := db.Select(&logs, query, userId, resolveUserTimezone(userId), date)
+	return logs, err
+}
+
+// GetHydrationChartData returns one zero-filled daily bucket per local date
+// between from and to inclusive (YYYY-MM-DD), served from the read-cache pool
+func (r *hydrationRepository) GetHydrationChartData(userId int, from, to string) ([]HydrationChartData, error) {
+	db := GetDB().PostgreDBManager.RC
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var query string = `SELECT
+    TO_CHAR(D.time_slice_date, 'YYYY-MM-DD') AS time_slice_label,
+    COALESCE(SUM(T.amount_ml), 0) AS total_amount_ml,
+    COALESCE(SUM(T.caffeine_mg), 0) AS total_caffeine_mg
+FROM generate_series($3::date, $4::date, INTERVAL '1 day') AS D(time_slice_date)
+LEFT JOIN (
+    SELECT
+        DATE(logged_at AT TIME ZONE $2) AS time_slice_date,
+        amount_ml,
+        caffeine_mg
+    FROM public.users_hydration_log
+    WHERE user_id = $1
+      AND DATE(logged_at AT TIME ZONE $2) BETWEEN $3::date AND $4::date
+) AS T ON T.time_slice_date = D.time_slice_date
+GROUP BY D.time_slice_date
+ORDER BY D.time_slice_date ASC`
+
+	var chartData []HydrationChartData
+	err := db.Select(&chartData, query, userId, resolveUserTimezone(userId), from, to)
+	return chartData, err
+}
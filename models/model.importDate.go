@@ -0,0 +1,223 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Orders of the numeric day and month in dates such as 03/04/2024
+const (
+	DateOrderDayFirst   = "dmy"
+	DateOrderMonthFirst = "mdy"
+)
+
+// importMonthNames maps month names and abbreviations of the languages scale
+// apps commonly export in onto the English abbreviation Go's parser expects
+var importMonthNames = map[string]string{}
+
+func init() {
+	months := map[string][]string{
+		"Jan": {"january", "jan", "januar", "jänner", "jän", "janvier", "janv", "enero", "ene", "januari", "gennaio", "gen", "janeiro"},
+		"Feb": {"february", "feb", "februar", "février", "fevrier", "févr", "fevr", "fév", "febrero", "febbraio", "fevereiro", "fev", "februari"},
+		"Mar": {"march", "mar", "märz", "maerz", "mär", "mars", "marzo", "março", "marco", "maart", "mrt", "maret"},
+		"Apr": {"april", "apr", "avril", "avr", "abril", "abr", "aprile"},
+		"May": {"may", "mai", "mayo", "maggio", "mag", "maio", "mei"},
+		"Jun": {"june", "jun", "juni", "juin", "junio", "giugno", "giu", "junho"},
+		"Jul": {"july", "jul", "juli", "juillet", "juil", "julio", "luglio", "lug", "julho"},
+		"Aug": {"august", "aug", "août", "aout", "agosto", "ago", "augustus", "agustus", "agu"},
+		"Sep": {"september", "sep", "sept", "septembre", "septiembre", "setiembre", "settembre", "set", "setembro"},
+		"Oct": {"october", "oct", "oktober", "okt", "octobre", "octubre", "ottobre", "ott", "outubro", "out"},
+		"Nov": {"november", "nov", "novembre", "noviembre", "novembro"},
+		"Dec": {"december", "dec", "dezember", "dez", "décembre", "decembre", "déc", "diciembre", "dic", "dicembre", "dezembro", "desember", "des"},
+	}
+	for abbreviation, names := range months {
+		for _, name := range names {
+			importMonthNames[name] = abbreviation
+		}
+	}
+}
+
+// importWeekdayNames are dropped from dates, the date itself is enough
+var importWeekdayNames = map[string]bool{
+	"monday": true, "tuesday": true, "wednesday": true, "thursday": true, "friday": true, "saturday": true, "sunday": true,
+	"mon": true, "tue": true, "tues": true, "wed": true, "thu": true, "thur": true, "thurs": true, "fri": true, "sat": true, "sun": true,
+}
+
+var (
+	importWordPattern       = regexp.MustCompile(`\p{L}+`)
+	importDayDotPattern     = regexp.MustCompile(`(\d)\.(\s)`)
+	importWordDotPattern    = regexp.MustCompile(`(\p{L})\.`)
+	importDateTimeTPattern  = regexp.MustCompile(`(\d)t(\d)`)
+	importNumericDatePrefix = regexp.MustCompile(`^(\d{1,2})[/.\-](\d{1,2})[/.\-](\d{2,4})`)
+)
+
+var (
+	importIsoDateLayouts   = []string{"2006-1-2", "2006/1/2", "2006.1.2", "Jan 2 2006", "2 Jan 2006", "2-Jan-2006", "2-Jan-06", "Jan-2-2006"}
+	importDayFirstLayouts  = []string{"2/1/2006", "2-1-2006", "2.1.2006", "2/1/06", "2-1-06", "2.1.06"}
+	importMonthFirstLayout = []string{"1/2/2006", "1-2-2006", "1.2.2006", "1/2/06", "1-2-06", "1.2.06"}
+	importTimeLayouts      = []string{"", " 15:04:05", " 15:04", " 3:04:05 PM", " 3:04 PM", " 15:04:05.999999999"}
+)
+
+// ParseImportDate parses a date or date-time exported by another app in loc.
+// Month names in several languages, weekday prefixes, 12 and 24 hour times,
+// RFC3339 and Unix timestamps are understood. order decides how ambiguous
+// numeric dates are read; layout, when set, is a format such as "DD.MM.YYYY HH:mm"
+// and is the only one tried.
+func ParseImportDate(value string, loc *time.Location, order, layout string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, fmt.Errorf("date is empty")
+	}
+	if layout != "" {
+		parsed, err := time.ParseInLocation(ImportDateLayout(layout), value, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("date %q does not match the format %q", value, layout)
+		}
+		return parsed, nil
+	}
+
+	if digits := strings.Trim(value, "0123456789"); digits == "" && (len(value) == 10 || len(value) == 13) {
+		seconds, _ := strconv.ParseInt(value, 10, 64)
+		if len(value) == 13 {
+			return time.UnixMilli(seconds).In(loc), nil
+		}
+		return time.Unix(seconds, 0).In(loc), nil
+	}
+	for _, zoned := range []string{time.RFC3339Nano, "2006-01-02 15:04:05Z07:00", "2006-01-02 15:04:05 -0700"} {
+		if parsed, err := time.Parse(zoned, value); err == nil {
+			return parsed.In(loc), nil
+		}
+	}
+
+	normalized := normalizeImportDate(value)
+	dateLayouts := importIsoDateLayouts
+	if order == DateOrderMonthFirst {
+		dateLayouts = append(append([]string{}, dateLayouts...), importMonthFirstLayout...)
+	} else {
+		dateLayouts = append(append([]string{}, dateLayouts...), importDayFirstLayouts...)
+	}
+	for _, dateLayout := range dateLayouts {
+		for _, timeLayout := range importTimeLayouts {
+			if parsed, err := time.ParseInLocation(dateLayout+timeLayout, normalized, loc); err == nil {
+				return parsed, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("date %q is not in a recognised format", value)
+}
+
+// normalizeImportDate rewrites a date into the vocabulary of the layouts above:
+// English month abbreviations, no weekdays or commas, uppercase AM/PM, single spaces
+func normalizeImportDate(value string) string {
+	value = strings.ToLower(value)
+	value = strings.NewReplacer("a.m.", "am", "p.m.", "pm", ",", " ").Replace(value)
+	value = importDateTimeTPattern.ReplaceAllString(value, "$1 $2")
+	value = importDayDotPattern.ReplaceAllString(value+" ", "$1$2")
+	value = importWordDotPattern.ReplaceAllString(value, "$1")
+	value = importWordPattern.ReplaceAllStringFunc(value, func(word string) string {
+		if abbreviation, ok := importMonthNames[word]; ok {
+			return abbreviation
+		}
+		if importWeekdayNames[word] {
+			return ""
+		}
+		if word == "am" || word == "pm" {
+			return strings.ToUpper(word)
+		}
+		return word
+	})
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// DetectDateOrder looks for a numeric date whose first or second part can only
+// be a day and returns the order it implies, fallback when none is decisive.
+// Dotted dates without evidence are read day first, as in every locale using them.
+func DetectDateOrder(values []string, fallback string) string {
+	dotted := false
+	for _, value := range values {
+		match := importNumericDatePrefix.FindStringSubmatch(strings.TrimSpace(value))
+		if match == nil {
+			continue
+		}
+		first, _ := strconv.Atoi(match[1])
+		second, _ := strconv.Atoi(match[2])
+		if first > 12 && second <= 12 {
+			return DateOrderDayFirst
+		}
+		if second > 12 && first <= 12 {
+			return DateOrderMonthFirst
+		}
+		dotted = dotted || strings.Contains(match[0], ".")
+	}
+	if dotted {
+		return DateOrderDayFirst
+	}
+	return fallback
+}
+
+// importLayoutTokens maps the tokens of a user-facing date format onto Go's
+// reference layout, longest tokens first so "YYYY" is not read as two "YY"
+var importLayoutTokens = []struct{ token, layout string }{
+	{"YYYY", "2006"}, {"YY", "06"},
+	{"MMMM", "January"}, {"MMM", "Jan"}, {"MM", "01"}, {"M", "1"},
+	{"DD", "02"}, {"D", "2"},
+	{"HH", "15"}, {"H", "15"}, {"hh", "03"}, {"h", "3"},
+	{"mm", "04"}, {"ss", "05"},
+	{"A", "PM"}, {"a", "pm"},
+}
+
+// ImportDateLayout converts a format such as "DD/MM/YYYY HH:mm" into a Go time layout
+func ImportDateLayout(format string) string {
+	var layout strings.Builder
+	for i := 0; i < len(format); {
+		matched := false
+		for _, token := range importLayoutTokens {
+			if strings.HasPrefix(format[i:], token.token) {
+				layout.WriteString(token.layout)
+				i += len(token.token)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			layout.WriteByte(format[i])
+			i++
+		}
+	}
+	return layout.String()
+}
+
+// ParseImportNumber parses a number exported by another app, returning nil for
+// empty cells. A trailing unit such as "kg", "lbs" or "%" is returned lowercased.
+// With decimalComma, "1.234,5" reads as 1234.5; otherwise a lone comma is taken
+// as the decimal separator and commas next to a dot as thousands separators.
+func ParseImportNumber(value string, decimalComma bool) (*float64, string, error) {
+	value = strings.TrimSpace(value)
+	switch strings.ToLower(value) {
+	case "", "-", "--", "n/a", "na", "null":
+		return nil, "", nil
+	}
+
+	end := len(value)
+	for end > 0 && strings.ContainsRune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ% ", rune(value[end-1])) {
+		end--
+	}
+	number, unit := strings.ReplaceAll(value[:end], " ", ""), strings.ToLower(strings.TrimSpace(value[end:]))
+
+	switch {
+	case decimalComma:
+		number = strings.ReplaceAll(strings.ReplaceAll(number, ".", ""), ",", ".")
+	case strings.Contains(number, ",") && !strings.Contains(number, "."):
+		number = strings.ReplaceAll(number, ",", ".")
+	default:
+		number = strings.ReplaceAll(number, ",", "")
+	}
+	parsed, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return nil, "", fmt.Errorf("%q is not a number", value)
+	}
+	return &parsed, unit, nil
+}
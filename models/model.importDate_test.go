@@ -0,0 +1,76 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseImportDate(t *testing.T) {
+	loc := time.FixedZone("WIB", 7*60*60)
+	at := func(year int, month time.Month, day, hour, minute, second int) time.Time {
+		return time.Date(year, month, day, hour, minute, second, 0, loc)
+	}
+
+	tests := []struct {
+		name    string
+		value   string
+		order   string
+		layout  string
+		want    time.Time
+		wantErr bool
+	}{
+		{"iso date", "2024-03-04", DateOrderDayFirst, "", at(2024, 3, 4, 0, 0, 0), false},
+		{"iso date time", "2024-03-04 07:30:15", DateOrderDayFirst, "", at(2024, 3, 4, 7, 30, 15), false},
+		{"iso with T", "2024-03-04T07:30", DateOrderDayFirst, "", at(2024, 3, 4, 7, 30, 0), false},
+		{"rfc3339 in another zone", "2024-03-04T00:30:00Z", DateOrderDayFirst, "", at(2024, 3, 4, 7, 30, 0), false},
+		{"unix seconds", "1709512200", DateOrderDayFirst, "", at(2024, 3, 4, 7, 30, 0), false},
+		{"unix milliseconds", "1709512200000", DateOrderDayFirst, "", at(2024, 3, 4, 7, 30, 0), false},
+		{"day first", "04/03/2024 07:30", DateOrderDayFirst, "", at(2024, 3, 4, 7, 30, 0), false},
+		{"month first", "03/04/2024 07:30", DateOrderMonthFirst, "", at(2024, 3, 4, 7, 30, 0), false},
+		{"dotted two digit year", "4.3.24", DateOrderDayFirst, "", at(2024, 3, 4, 0, 0, 0), false},
+		{"12 hour clock", "03/04/2024 7:30 PM", DateOrderMonthFirst, "", at(2024, 3, 4, 19, 30, 0), false},
+		{"12 hour clock with dots", "03/04/2024 7:30 p.m.", DateOrderMonthFirst, "", at(2024, 3, 4, 19, 30, 0), false},
+		{"english month name with weekday", "Monday, March 4, 2024 7:30 AM", DateOrderDayFirst, "", at(2024, 3, 4, 7, 30, 0), false},
+		{"german month name", "4. März 2024 07:30", DateOrderDayFirst, "", at(2024, 3, 4, 7, 30, 0), false},
+		{"french abbreviation", "4 févr. 2024", DateOrderDayFirst, "", at(2024, 2, 4, 0, 0, 0), false},
+		{"indonesian month name", "4 Agustus 2024", DateOrderDayFirst, "", at(2024, 8, 4, 0, 0, 0), false},
+		{"explicit layout", "2024|04|03 07.30", DateOrderDayFirst, "YYYY|DD|MM HH.mm", at(2024, 3, 4, 7, 30, 0), false},
+		{"explicit layout mismatch", "2024-03-04", DateOrderDayFirst, "DD/MM/YYYY", time.Time{}, true},
+		{"empty", "  ", DateOrderDayFirst, "", time.Time{}, true},
+		{"not a date", "yesterday", DateOrderDayFirst, "", time.Time{}, true},
+		{"impossible day", "31/02/2024", DateOrderDayFirst, "", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseImportDate(tt.value, loc, tt.order, tt.layout)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseImportDate(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseImportDate(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectDateOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []string
+		fallback string
+		want     string
+	}{
+		{"day above 12 first", []string{"03/04/2024", "25/04/2024"}, DateOrderMonthFirst, DateOrderDayFirst},
+		{"day above 12 second", []string{"03/04/2024", "04/25/2024"}, DateOrderDayFirst, DateOrderMonthFirst},
+		{"ambiguous slashes", []string{"03/04/2024"}, DateOrderMonthFirst, DateOrderMonthFirst},
+		{"ambiguous dots", []string{"03.04.2024"}, DateOrderMonthFirst, DateOrderDayFirst},
+		{"iso dates", []string{"2024-03-04"}, DateOrderMonthFirst, DateOrderMonthFirst},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectDateOrder(tt.values, tt.fallback); got != tt.want {
+				t.Errorf("DetectDateOrder(%v) = %s, want %s", tt.values, got, tt.want)
+			}
+		})
+	}
+}
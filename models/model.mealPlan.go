@@ -0,0 +1,479 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	// ErrMealPlanItemChecked is returned when a planned item was already logged
+	ErrMealPlanItemChecked = errors.New("meal plan item is already checked off")
+	// ErrMealPlanItemInFuture is returned when checking off an item planned after today
+	ErrMealPlanItemInFuture = errors.New("meal plan item is planned for a future date")
+)
+
+// MealPlanItem is one food planned for a local date and category. TemplateId
+// is set when the item came from a meal template, FoodId once the item is
+// checked off and logged as a users_food_intake row.
+type MealPlanItem struct {
+	PlanItemId     int               `json:"plan_item_id" db:"plan_item_id"`
+	UserId         int               `json:"user_id" db:"user_id"`
+	PlanDate       time.Time         `json:"plan_date" db:"plan_date"`
+	Category       NutritionCategory `json:"category" db:"category"`
+	TemplateId     *int              `json:"template_id,omitempty" db:"template_id"`
+	Name           string            `json:"name" db:"name"`
+	Fat            float64           `json:"fat" db:"fat"`
+	Protein        float64           `json:"protein" db:"protein"`
+	Carbohydrate   float64           `json:"carbohydrate" db:"carbohydrate"`
+	Caloric        float64           `json:"caloric" db:"caloric"`
+	Fiber          *float64          `json:"fiber,omitempty" db:"fiber"`
+	Sugar          *float64          `json:"sugar,omitempty" db:"sugar"`
+	SaturatedFat   *float64          `json:"saturated_fat,omitempty" db:"saturated_fat"`
+	Sodium         *float64          `json:"sodium,omitempty" db:"sodium"`
+	Cholesterol    *float64          `json:"cholesterol,omitempty" db:"cholesterol"`
+	Potassium      *float64          `json:"potassium,omitempty" db:"potassium"`
+	Micronutrients NutrientMap       `json:"micronutrients,omitempty" db:"micronutrients"`
+	IngredientAmount
+	FoodId    *int       `json:"food_id,omitempty" db:"food_id"`
+	CheckedAt *time.Time `json:"checked_at,omitempty" db:"checked_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// MarshalJSON : Overloads MealPlanItem
+func (a MealPlanItem) MarshalJSON() ([]byte, error) {
+	var checkedAt *string
+	if a.CheckedAt != nil {
+		formatted := a.CheckedAt.Format(time.RFC3339)
+		checkedAt = &formatted
+	}
+	return sonic.Marshal(struct {
+		PlanItemId     int               `json:"plan_item_id"`
+		UserId         int               `json:"user_id"`
+		PlanDate       string            `json:"plan_date"`
+		Category       NutritionCategory `json:"category"`
+		TemplateId     *int              `json:"template_id,omitempty"`
+		Name           string            `json:"name"`
+		Fat            float64           `json:"fat"`
+		Protein        float64           `json:"protein"`
+		Carbohydrate   float64           `json:"carbohydrate"`
+		Caloric        float64           `json:"caloric"`
+		Fiber          *float64          `json:"fiber,omitempty"`
+		Sugar          *float64          `json:"sugar,omitempty"`
+		SaturatedFat   *float64          `json:"saturated_fat,omitempty"`
+		Sodium         *float64          `json:"sodium,omitempty"`
+		Cholesterol    *float64          `json:"cholesterol,omitempty"`
+		Potassium      *float64          `json:"potassium,omitempty"`
+		Micronutrients NutrientMap       `json:"micronutrients,omitempty"`
+		Quantity       *float64          `json:"quantity,omitempty"`
+		Unit           *GroceryUnit      `json:"unit,omitempty"`
+		FoodGroup      *FoodGroup        `json:"food_group,omitempty"`
+		Checked        bool              `json:"checked"`
+		FoodId         *int              `json:"food_id,omitempty"`
+		CheckedAt      *string           `json:"checked_at,omitempty"`
+		CreatedAt      string            `json:"created_at"`
+	}{
+		PlanItemId:     a.PlanItemId,
+		UserId:         a.UserId,
+		PlanDate:       a.PlanDate.Format(SQLDateFormat),
+		Category:       a.Category,
+		TemplateId:     a.TemplateId,
+		Name:           a.Name,
+		Fat:            a.Fat,
+		Protein:        a.Protein,
+		Carbohydrate:   a.Carbohydrate,
+		Caloric:        a.Caloric,
+		Fiber:          a.Fiber,
+		Sugar:          a.Sugar,
+		SaturatedFat:   a.SaturatedFat,
+		Sodium:         a.Sodium,
+		Cholesterol:    a.Cholesterol,
+		Potassium:      a.Potassium,
+		Micronutrients: a.Micronutrients,
+		Quantity:       a.Quantity,
+		Unit:           a.Unit,
+		FoodGroup:      a.FoodGroup,
+		Checked:        a.FoodId != nil,
+		FoodId:         a.FoodId,
+		CheckedAt:      checkedAt,
+		CreatedAt:      a.CreatedAt.Format(time.RFC3339),
+	})
+}
+
+// ToIntake converts the planned item into a food intake entry
+func (i MealPlanItem) ToIntake(createdAt time.Time) NutritionTracker {
+	return NutritionTracker{
+		UserId:         i.UserId,
+		Category:       i.Category,
+		CreatedAt:      createdAt,
+		Fat:            i.Fat,
+		Protein:        i.Protein,
+		Carbohydrate:   i.Carbohydrate,
+		Caloric:        i.Caloric,
+		Name:           i.Name,
+		Fiber:          i.Fiber,
+		Sugar:          i.Sugar,
+		SaturatedFat:   i.SaturatedFat,
+		Sodium:         i.Sodium,
+		Cholesterol:    i.Cholesterol,
+		Potassium:      i.Potassium,
+		Micronutrients: i.Micronutrients,
+	}
+}
+
+// MealPlanDay is the plan of one local date with its projected summary
+// against the personal target
+type MealPlanDay struct {
+	Date      string                `json:"date"`
+	Items     []MealPlanItem        `json:"items"`
+	Projected DailyNutritionSummary `json:"projected"`
+}
+
+// BuildMealPlanDays returns one MealPlanDay for every date from from to to
+// inclusive, projected against the target in force on each date
+func BuildMealPlanDays(from, to time.Time, items []MealPlanItem, targets TargetTimeline) []MealPlanDay {
+	byDate := make(map[string][]MealPlanItem)
+	for _, item := range items {
+		date := item.PlanDate.Format(SQLDateFormat)
+		byDate[date] = append(byDate[date], item)
+	}
+
+	var days []MealPlanDay
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format(SQLDateFormat)
+		planned := byDate[date]
+		if planned == nil {
+			planned = []MealPlanItem{}
+		}
+		days = append(days, MealPlanDay{
+			Date:      date,
+			Items:     planned,
+			Projected: BuildDailyNutritionSummary(date, mealPlanIntakes(planned), targets.On(day)),
+		})
+	}
+	return days
+}
+
+// MealPlanAdherenceDay compares what was planned for a day with what was logged.
+// UnplannedItems counts logged entries that did not come from the plan.
+type MealPlanAdherenceDay struct {
+	Date              string          `json:"date"`
+	PlannedItems      int             `json:"planned_items"`
+	CheckedItems      int             `json:"checked_items"`
+	UnplannedItems    int             `json:"unplanned_items"`
+	Planned           NutritionTotals `json:"planned"`
+	Actual            NutritionTotals `json:"actual"`
+	Difference        NutritionTotals `json:"difference"`
+	CompletionPercent *float64        `json:"completion_percent"`
+	AdherenceScore    *float64        `json:"adherence_score"`
+}
+
+// MealPlanAdherenceReport is a week of plan vs actual. The adherence score
+// rates 0-100 how close actual intake came to the planned totals, averaged
+// over the days that had a plan.
+type MealPlanAdherenceReport struct {
+	StartDate         string                 `json:"start_date"`
+	EndDate           string                 `json:"end_date"`
+	PlannedDays       int                    `json:"planned_days"`
+	PlannedItems      int                    `json:"planned_items"`
+	CheckedItems      int                    `json:"checked_items"`
+	Days              []MealPlanAdherenceDay `json:"days"`
+	Planned           NutritionTotals        `json:"planned"`
+	Actual            NutritionTotals        `json:"actual"`
+	CompletionPercent *float64               `json:"completion_percent"`
+	AdherenceScore    *float64               `json:"adherence_score"`
+}
+
+// BuildMealPlanAdherenceReport buckets planned items and logged intakes into
+// the seven local days starting at start
+func BuildMealPlanAdherenceReport(start time.Time, items []MealPlanItem, intakes []NutritionTracker) MealPlanAdherenceReport {
+	loc := start.Location()
+	plannedByDate := make(map[string][]MealPlanItem)
+	fromPlan := make(map[int]bool)
+	for _, item := range items {
+		date := item.PlanDate.Format(SQLDateFormat)
+		plannedByDate[date] = append(plannedByDate[date], item)
+		if item.FoodId != nil {
+			fromPlan[*item.FoodId] = true
+		}
+	}
+	intakesByDate := make(map[string][]NutritionTracker)
+	for _, intake := range intakes {
+		date := intake.CreatedAt.In(loc).Format(SQLDateFormat)
+		intakesByDate[date] = append(intakesByDate[date], intake)
+	}
+
+	report := MealPlanAdherenceReport{
+		StartDate: start.Format(SQLDateFormat),
+		EndDate:   start.AddDate(0, 0, 6).Format(SQLDateFormat),
+		Days:      make([]MealPlanAdherenceDay, 0, 7),
+	}
+
+	var adherenceSum float64
+	for i := 0; i < 7; i++ {
+		date := start.AddDate(0, 0, i).Format(SQLDateFormat)
+		day := MealPlanAdherenceDay{Date: date, PlannedItems: len(plannedByDate[date])}
+
+		for _, item := range plannedByDate[date] {
+			day.Planned.add(item.ToIntake(time.Time{}))
+			if item.FoodId != nil {
+				day.CheckedItems++
+			}
+		}
+		for _, intake := range intakesByDate[date] {
+			day.Actual.add(intake)
+			if !fromPlan[intake.FoodId] {
+				day.UnplannedItems++
+			}
+		}
+
+		day.Planned = day.Planned.rounded()
+		day.Actual = day.Actual.rounded()
+		day.Difference = day.Actual.minus(day.Planned).rounded()
+		if day.PlannedItems > 0 {
+			day.CompletionPercent = percentOf(float64(day.CheckedItems), float64(day.PlannedItems))
+			day.AdherenceScore = adherenceScore(day.Actual, day.Planned)
+		}
+
+		report.PlannedItems += day.PlannedItems
+		report.CheckedItems += day.CheckedItems
+		report.Planned = report.Planned.plus(day.Planned)
+		report.Actual = report.Actual.plus(day.Actual)
+		if day.AdherenceScore != nil {
+			report.PlannedDays++
+			adherenceSum += *day.AdherenceScore
+		}
+		report.Days = append(report.Days, day)
+	}
+
+	report.Planned = report.Planned.rounded()
+	report.Actual = report.Actual.rounded()
+	report.CompletionPercent = percentOf(float64(report.CheckedItems), float64(report.PlannedItems))
+	if report.PlannedDays > 0 {
+		score := round2(adherenceSum / float64(report.PlannedDays))
+		report.AdherenceScore = &score
+	}
+	return report
+}
+
+// mealPlanIntakes converts planned items so they can be summarised like intake
+func mealPlanIntakes(items []MealPlanItem) []NutritionTracker {
+	intakes := make([]NutritionTracker, 0, len(items))
+	for _, item := range items {
+		intakes = append(intakes, item.ToIntake(time.Time{}))
+	}
+	return intakes
+}
+
+// mealPlanRepository implements MealPlanRepository interface
+type mealPlanRepository struct{}
+
+// NewMealPlanRepository creates a new meal plan repository
+func NewMealPlanRepository() MealPlanRepository {
+	return &mealPlanRepository{}
+}
+
+// MealPlanRepository defines the interface for meal plan operations
+type MealPlanRepository interface {
+	FindByDateRange(userId int, from, to string) ([]MealPlanItem, error)
+	Create(item *MealPlanItem) error
+	CreateFromTemplate(userId, templateId int, planDate time.Time, category NutritionCategory) ([]MealPlanItem, error)
+	Update(item *MealPlanItem) error
+	Delete(userId, planItemId int) error
+	CheckOff(userId, planItemId int, loc *time.Location) (*NutritionTracker, error)
+}
+
+const mealPlanItemColumns = `plan_item_id, user_id, plan_date, category, template_id, name,
+	fat, protein, carbohydrate, caloric,
+	fiber, sugar, saturated_fat, sodium, cholesterol, potassium, micronutrients,
+	quantity, unit, food_group, food_id, checked_at, created_at`
+
+// FindByDateRange retrieves planned items between from and to inclusive, both YYYY-MM-DD
+func (r *mealPlanRepository) FindByDateRange(userId int, from, to string) ([]MealPlanItem, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var items []MealPlanItem
+	query := `SELECT ` + mealPlanItemColumns + `
+ FROM users_meal_plan_item
+ WHERE user_id = $1 AND plan_date BETWEEN $2::date AND $3::date
+ ORDER BY plan_date ASC, plan_item_id ASC`
+
+	err := db.Select(&items, query, userId, from, to)
+	return items, err
+}
+
+// Create plans one item and fills its id and created_at
+func (r *mealPlanRepository) Create(item *MealPlanItem) error {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	return insertMealPlanItem(db, item)
+}
+
+// CreateFromTemplate plans every item of a meal template on planDate. An empty
+// category falls back to the template's own. Returns sql.ErrNoRows when the
+// template does not belong to the user.
+func (r *mealPlanRepository) CreateFromTemplate(userId, templateId int, planDate time.Time, category NutritionCategory) ([]MealPlanItem, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	template, err := findMealTemplate(tx, userId, templateId)
+	if err != nil {
+		return nil, err
+	}
+	if category == "" {
+		category = template.Category
+	}
+
+	items := make([]MealPlanItem, 0, len(template.Items))
+	for _, templateItem := range template.Items {
+		item := MealPlanItem{
+			UserId:           userId,
+			PlanDate:         planDate,
+			Category:         category,
+			TemplateId:       &template.TemplateId,
+			Name:             templateItem.Name,
+			Fat:              templateItem.Fat,
+			Protein:          templateItem.Protein,
+			Carbohydrate:     templateItem.Carbohydrate,
+			Caloric:          templateItem.Caloric,
+			Fiber:            templateItem.Fiber,
+			Sugar:            templateItem.Sugar,
+			SaturatedFat:     templateItem.SaturatedFat,
+			Sodium:           templateItem.Sodium,
+			Cholesterol:      templateItem.Cholesterol,
+			Potassium:        templateItem.Potassium,
+			Micronutrients:   templateItem.Micronutrients,
+			IngredientAmount: templateItem.IngredientAmount,
+		}
+		if err = insertMealPlanItem(tx, &item); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, tx.Commit()
+}
+
+// Update overwrites a planned item that has not been checked off yet.
+// Returns sql.ErrNoRows when there is no such unchecked item.
+func (r *mealPlanRepository) Update(item *MealPlanItem) error {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	query := `UPDATE users_meal_plan_item SET
+	plan_date = $1, category = $2, name = $3, fat = $4, protein = $5,
+	carbohydrate = $6, caloric = $7, fiber = $8, sugar = $9, saturated_fat = $10,
+	sodium = $11, cholesterol = $12, potassium = $13, micronutrients = $14,
+	quantity = $15, unit = $16, food_group = $17
+	WHERE user_id = $18 AND plan_item_id = $19 AND food_id IS NULL
+	RETURNING template_id, created_at`
+
+	return db.QueryRowx(query, item.PlanDate.Format(SQLDateFormat), item.Category, item.Name,
+		item.Fat, item.Protein, item.Carbohydrate, item.Caloric,
+		item.Fiber, item.Sugar, item.SaturatedFat,
+		item.Sodium, item.Cholesterol, item.Potassium, item.Micronutrients,
+		item.Quantity, item.Unit, item.FoodGroup,
+		item.UserId, item.PlanItemId).Scan(&item.TemplateId, &item.CreatedAt)
+}
+
+// Delete removes a planned item; food already logged from it is kept
+func (r *mealPlanRepository) Delete(userId, planItemId int) error {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	query := `DELETE FROM users_meal_plan_item WHERE user_id = $1 AND plan_item_id = $2`
+	_, err := db.Exec(query, userId, planItemId)
+	return err
+}
+
+// CheckOff logs a planned item as food intake and links the two. An item
+// planned for today is logged now, one planned for an earlier date at the
+// category's usual meal time on that date in loc.
+func (r *mealPlanRepository) CheckOff(userId, planItemId int, loc *time.Location) (*NutritionTracker, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var item MealPlanItem
+	query := `SELECT ` + mealPlanItemColumns + `
+ FROM users_meal_plan_item WHERE user_id = $1 AND plan_item_id = $2 FOR UPDATE`
+	if err = tx.Get(&item, query, userId, planItemId); err != nil {
+		return nil, err
+	}
+	if item.FoodId != nil {
+		return nil, ErrMealPlanItemChecked
+	}
+
+	now := time.Now().In(loc)
+	planDate := time.Date(item.PlanDate.Year(), item.PlanDate.Month(), item.PlanDate.Day(), 0, 0, 0, 0, loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	createdAt := now
+	switch {
+	case planDate.After(today):
+		return nil, ErrMealPlanItemInFuture
+	case planDate.Before(today):
+		createdAt = MealTimeOn(planDate, item.Category)
+	}
+
+	intake := item.ToIntake(createdAt)
+	if err = insertFoodIntake(tx, &intake); err != nil {
+		return nil, err
+	}
+
+	update := `UPDATE users_meal_plan_item SET food_id = $1, checked_at = NOW()
+	WHERE user_id = $2 AND plan_item_id = $3`
+	if _, err = tx.Exec(update, intake.FoodId, userId, planItemId); err != nil {
+		return nil, fmt.Errorf("error checking off meal plan item: %w", err)
+	}
+
+	return &intake, tx.Commit()
+}
+
+// insertMealPlanItem inserts a planned item and fills its id and created_at
+func insertMealPlanItem(q sqlx.Queryer, item *MealPlanItem) error {
+	query := `INSERT INTO users_meal_plan_item
+	(user_id, plan_date, category, template_id, name, fat, protein, carbohydrate, caloric,
+	fiber, sugar, saturated_fat, sodium, cholesterol, potassium, micronutrients,
+	quantity, unit, food_group, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, NOW())
+	RETURNING plan_item_id, created_at`
+
+	err := q.QueryRowx(query,
+		item.UserId, item.PlanDate.Format(SQLDateFormat), item.Category, item.TemplateId, item.Name,
+		item.Fat, item.Protein, item.Carbohydrate, item.Caloric,
+		item.Fiber, item.Sugar, item.SaturatedFat,
+		item.Sodium, item.Cholesterol, item.Potassium,
+		item.Micronutrients, item.Quantity, item.Unit, item.FoodGroup).Scan(&item.PlanItemId, &item.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error adding meal plan item: %w", err)
+	}
+	return nil
+}
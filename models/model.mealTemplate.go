@@ -0,0 +1,311 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/jmoiron/sqlx"
+)
+
+// MealTemplate is a named list of foods saved under a nutrition category
+type MealTemplate struct {
+	TemplateId int                `json:"template_id" db:"template_id"`
+	UserId     int                `json:"user_id" db:"user_id"`
+	Name       string             `json:"name" db:"name"`
+	Category   NutritionCategory  `json:"category" db:"category"`
+	CreatedAt  time.Time          `json:"created_at" db:"created_at"`
+	Items      []MealTemplateItem `json:"items" db:"-"`
+
+	// Units, when set, are the units MarshalJSON writes the item energy in
+	Units *UnitPreference `json:"-" db:"-"`
+}
+
+// MarshalJSON : Overloads MealTemplate
+func (a MealTemplate) MarshalJSON() ([]byte, error) {
+	items := make([]MealTemplateItem, len(a.Items))
+	for i, item := range a.Items {
+		if a.Units != nil {
+			item.Caloric = a.Units.EnergyFromKcal(item.Caloric)
+		}
+		items[i] = item
+	}
+	return sonic.Marshal(struct {
+		TemplateId int                `json:"template_id"`
+		UserId     int                `json:"user_id"`
+		Name       string             `json:"name"`
+		Category   NutritionCategory  `json:"category"`
+		CreatedAt  string             `json:"created_at"`
+		Items      []MealTemplateItem `json:"items"`
+		Units      *UnitPreference    `json:"units,omitempty"`
+	}{
+		TemplateId: a.TemplateId,
+		UserId:     a.UserId,
+		Name:       a.Name,
+		Category:   a.Category,
+		CreatedAt:  a.CreatedAt.Format(time.RFC3339),
+		Items:      items,
+		Units:      a.Units,
+	})
+}
+
+// AttachMealTemplateUnits makes every template marshal its item energy in units
+func AttachMealTemplateUnits(templates []MealTemplate, units UnitPreference) {
+	for i := range templates {
+		templates[i].Units = &units
+	}
+}
+
+// MealTemplateItem is one food of a meal template, with the same nutrient
+// fields as a users_food_intake row
+type MealTemplateItem struct {
+	ItemId         int         `json:"item_id" db:"item_id"`
+	TemplateId     int         `json:"template_id" db:"template_id"`
+	Name           string      `json:"name" db:"name"`
+	Fat            float64     `json:"fat" db:"fat"`
+	Protein        float64     `json:"protein" db:"protein"`
+	Carbohydrate   float64     `json:"carbohydrate" db:"carbohydrate"`
+	Caloric        float64     `json:"caloric" db:"caloric"`
+	Fiber          *float64    `json:"fiber,omitempty" db:"fiber"`
+	Sugar          *float64    `json:"sugar,omitempty" db:"sugar"`
+	SaturatedFat   *float64    `json:"saturated_fat,omitempty" db:"saturated_fat"`
+	Sodium         *float64    `json:"sodium,omitempty" db:"sodium"`
+	Cholesterol    *float64    `json:"cholesterol,omitempty" db:"cholesterol"`
+	Potassium      *float64    `json:"potassium,omitempty" db:"potassium"`
+	Micronutrients NutrientMap `json:"micronutrients,omitempty" db:"micronutrients"`
+	IngredientAmount
+}
+
+// ToIntake converts the item into a food intake entry for a user
+func (i MealTemplateItem) ToIntake(userId int, category NutritionCategory, createdAt time.Time) NutritionTracker {
+	return NutritionTracker{
+		UserId:         userId,
+		Category:       category,
+		CreatedAt:      createdAt,
+		Fat:            i.Fat,
+		Protein:        i.Protein,
+		Carbohydrate:   i.Carbohydrate,
+		Caloric:        i.Caloric,
+		Name:           i.Name,
+		Fiber:          i.Fiber,
+		Sugar:          i.Sugar,
+		SaturatedFat:   i.SaturatedFat,
+		Sodium:         i.Sodium,
+		Cholesterol:    i.Cholesterol,
+		Potassium:      i.Potassium,
+		Micronutrients: i.Micronutrients,
+	}
+}
+
+// mealTemplateRepository implements MealTemplateRepository interface
+type mealTemplateRepository struct{}
+
+// NewMealTemplateRepository creates a new meal template repository
+func NewMealTemplateRepository() MealTemplateRepository {
+	return &mealTemplateRepository{}
+}
+
+// MealTemplateRepository defines the interface for meal template operations
+type MealTemplateRepository interface {
+	GetByUserId(userId int) ([]MealTemplate, error)
+	FindById(userId, templateId int) (*MealTemplate, error)
+	Create(template *MealTemplate) error
+	Update(template *MealTemplate) error
+	Delete(userId, templateId int) error
+	Apply(userId, templateId int, date time.Time) ([]NutritionTracker, error)
+}
+
+const mealTemplateItemColumns = `item_id, template_id, name, fat, protein, carbohydrate, caloric,
+	fiber, sugar, saturated_fat, sodium, cholesterol, potassium, micronutrients,
+	quantity, unit, food_group`
+
+// GetByUserId retrieves every meal template of a user including its items
+func (r *mealTemplateRepository) GetByUserId(userId int) ([]MealTemplate, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var templates []MealTemplate
+	query := `SELECT template_id, user_id, name, category, created_at
+ FROM users_meal_template WHERE user_id = $1 ORDER BY name ASC`
+	if err := db.Select(&templates, query, userId); err != nil {
+		return nil, err
+	}
+
+	var items []MealTemplateItem
+	itemQuery := `SELECT ` + mealTemplateItemColumns + `
+ FROM users_meal_template_item
+ WHERE template_id IN (SELECT template_id FROM users_meal_template WHERE user_id = $1)
+ ORDER BY item_id ASC`
+	if err := db.Select(&items, itemQuery, userId); err != nil {
+		return nil, err
+	}
+
+	byTemplate := make(map[int][]MealTemplateItem)
+	for _, item := range items {
+		byTemplate[item.TemplateId] = append(byTemplate[item.TemplateId], item)
+	}
+	for i := range templates {
+		templates[i].Items = byTemplate[templates[i].TemplateId]
+	}
+	return templates, nil
+}
+
+// FindById retrieves one meal template of a user, nil when it does not exist
+func (r *mealTemplateRepository) FindById(userId, templateId int) (*MealTemplate, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	return findMealTemplate(db, userId, templateId)
+}
+
+// Create stores a meal template and its items in one transaction
+func (r *mealTemplateRepository) Create(template *MealTemplate) error {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO users_meal_template (user_id, name, category, created_at)
+	VALUES ($1, $2, $3, NOW()) RETURNING template_id, created_at`
+	if err = tx.QueryRowx(query, template.UserId, template.Name, template.Category).Scan(&template.TemplateId, &template.CreatedAt); err != nil {
+		return fmt.Errorf("error creating meal template: %w", err)
+	}
+
+	if err = insertMealTemplateItems(tx, template); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Update renames a meal template and replaces its items in one transaction
+func (r *mealTemplateRepository) Update(template *MealTemplate) error {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE users_meal_template SET name = $1, category = $2
+	WHERE user_id = $3 AND template_id = $4 RETURNING created_at`
+	if err = tx.QueryRowx(query, template.Name, template.Category, template.UserId, template.TemplateId).Scan(&template.CreatedAt); err != nil {
+		return fmt.Errorf("error updating meal template: %w", err)
+	}
+
+	if _, err = tx.Exec(`DELETE FROM users_meal_template_item WHERE template_id = $1`, template.TemplateId); err != nil {
+		return fmt.Errorf("error replacing meal template items: %w", err)
+	}
+	if err = insertMealTemplateItems(tx, template); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Delete removes a meal template and its items
+func (r *mealTemplateRepository) Delete(userId, templateId int) error {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `DELETE FROM users_meal_template_item WHERE template_id IN
+	(SELECT template_id FROM users_meal_template WHERE user_id = $1 AND template_id = $2)`
+	if _, err = tx.Exec(query, userId, templateId); err != nil {
+		return fmt.Errorf("error deleting meal template items: %w", err)
+	}
+	if _, err = tx.Exec(`DELETE FROM users_meal_template WHERE user_id = $1 AND template_id = $2`, userId, templateId); err != nil {
+		return fmt.Errorf("error deleting meal template: %w", err)
+	}
+	return tx.Commit()
+}
+
+// Apply logs every item of a template as food intake on the given local date,
+// at the template category's usual meal time. Returns sql.ErrNoRows when the
+// template does not belong to the user.
+func (r *mealTemplateRepository) Apply(userId, templateId int, date time.Time) ([]NutritionTracker, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	template, err := findMealTemplate(tx, userId, templateId)
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt := MealTimeOn(date, template.Category)
+	intakes := make([]NutritionTracker, 0, len(template.Items))
+	for _, item := range template.Items {
+		intake := item.ToIntake(userId, template.Category, createdAt)
+		if err = insertFoodIntake(tx, &intake); err != nil {
+			return nil, err
+		}
+		intakes = append(intakes, intake)
+	}
+
+	return intakes, tx.Commit()
+}
+
+func findMealTemplate(q sqlx.Queryer, userId, templateId int) (*MealTemplate, error) {
+	var template MealTemplate
+	query := `SELECT template_id, user_id, name, category, created_at
+ FROM users_meal_template WHERE user_id = $1 AND template_id = $2`
+	if err := sqlx.Get(q, &template, query, userId, templateId); err != nil {
+		return nil, err
+	}
+
+	itemQuery := `SELECT ` + mealTemplateItemColumns + `
+ FROM users_meal_template_item WHERE template_id = $1 ORDER BY item_id ASC`
+	if err := sqlx.Select(q, &template.Items, itemQuery, templateId); err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+func insertMealTemplateItems(tx *sqlx.Tx, template *MealTemplate) error {
+	query := `INSERT INTO users_meal_template_item
+	(template_id, name, fat, protein, carbohydrate, caloric,
+	fiber, sugar, saturated_fat, sodium, cholesterol, potassium, micronutrients,
+	quantity, unit, food_group)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+	RETURNING item_id`
+
+	for i := range template.Items {
+		item := &template.Items[i]
+		item.TemplateId = template.TemplateId
+		err := tx.QueryRowx(query, item.TemplateId, item.Name, item.Fat, item.Protein,
+			item.Carbohydrate, item.Caloric, item.Fiber, item.Sugar, item.SaturatedFat,
+			item.Sodium, item.Cholesterol, item.Potassium, item.Micronutrients,
+			item.Quantity, item.Unit, item.FoodGroup).Scan(&item.ItemId)
+		if err != nil {
+			return fmt.Errorf("error creating meal template item: %w", err)
+		}
+	}
+	return nil
+}
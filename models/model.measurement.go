@@ -1,46 +1,142 @@
 package models
 
 import (
+	"database/sql"
 	"fmt"
 	"time"
 
 	"github.com/bytedance/sonic"
+	"github.com/jmoiron/sqlx"
 )
 
+// BodyMeasurement is one body_measurement row. Everything but bodyweight is
+// optional: circumferences come from a tape measure, the composition fields
+// (muscle and bone mass, body water, BMR, metabolic age) as reported by a smart scale.
 type BodyMeasurement struct {
-	MeasurementId int       `json:"measurement_id" db:"measurement_id"`
-	UserId        int       `json:"user_id" db:"user_id"`
-	Bodyweight    float64   `json:"bodyweight" db:"bodyweight"`
-	ViceralFat    *float64  `json:"viceral_fat,omitempty" db:"viceral_fat"`
-	FatPercentage *float64  `json:"fat_percentage,omitempty" db:"fat_percentage"`
-	NickCm        *float64  `json:"nick_cm,omitempty" db:"nick_cm"`
-	WaistCm       *float64  `json:"waist_cm,omitempty" db:"waist_cm"`
-	MeasuredAt    time.Time `json:"measured_at" db:"measured_at"`
+	MeasurementId       int       `json:"measurement_id" db:"measurement_id"`
+	UserId              int       `json:"user_id" db:"user_id"`
+	Bodyweight          float64   `json:"bodyweight" db:"bodyweight"`
+	ViceralFat          *float64  `json:"viceral_fat,omitempty" db:"viceral_fat"`
+	FatPercentage       *float64  `json:"fat_percentage,omitempty" db:"fat_percentage"`
+	NickCm              *float64  `json:"nick_cm,omitempty" db:"nick_cm"`
+	WaistCm             *float64  `json:"waist_cm,omitempty" db:"waist_cm"`
+	HipCm               *float64  `json:"hip_cm,omitempty" db:"hip_cm"`
+	ChestCm             *float64  `json:"chest_cm,omitempty" db:"chest_cm"`
+	ArmLeftCm           *float64  `json:"arm_left_cm,omitempty" db:"arm_left_cm"`
+	ArmRightCm          *float64  `json:"arm_right_cm,omitempty" db:"arm_right_cm"`
+	ThighLeftCm         *float64  `json:"thigh_left_cm,omitempty" db:"thigh_left_cm"`
+	ThighRightCm        *float64  `json:"thigh_right_cm,omitempty" db:"thigh_right_cm"`
+	CalfCm              *float64  `json:"calf_cm,omitempty" db:"calf_cm"`
+	MuscleMassKg        *float64  `json:"muscle_mass_kg,omitempty" db:"muscle_mass_kg"`
+	BoneMassKg          *float64  `json:"bone_mass_kg,omitempty" db:"bone_mass_kg"`
+	BodyWaterPercentage *float64  `json:"body_water_percentage,omitempty" db:"body_water_percentage"`
+	ScaleBmr            *float64  `json:"scale_bmr,omitempty" db:"scale_bmr"`
+	MetabolicAge        *int      `json:"metabolic_age,omitempty" db:"metabolic_age"`
+	MeasuredAt          time.Time `json:"measured_at" db:"measured_at"`
+	// Flagged measurements were saved despite FlagReasons and wait for review
+	Flagged     bool                `json:"flagged" db:"flagged"`
+	FlagReasons MeasurementWarnings `json:"flag_reasons,omitempty" db:"flag_reasons"`
+
+	// Derived is filled in by AttachDerivedMetrics and is not stored
+	Derived *DerivedBodyMetrics `json:"derived,omitempty" db:"-"`
+	// Units, when set, are the units MarshalJSON writes the values in
+	Units *UnitPreference `json:"-" db:"-"`
 }
 
+// bodyMeasurementColumns are the measured columns of body_measurement and its review flag
+const bodyMeasurementColumns = `bodyweight, viceral_fat, fat_percentage, nick_cm, waist_cm,
+	hip_cm, chest_cm, arm_left_cm, arm_right_cm, thigh_left_cm, thigh_right_cm, calf_cm,
+	muscle_mass_kg, bone_mass_kg, body_water_percentage, scale_bmr, metabolic_age,
+	flagged, flag_reasons`
+
 // MarshalJSON : Overloads BodyMeasurement
 func (a BodyMeasurement) MarshalJSON() ([]byte, error) {
+	if a.Units != nil {
+		units := *a.Units
+		a.Bodyweight = units.WeightFromKg(a.Bodyweight)
+		a.MuscleMassKg = ConvertOptional(a.MuscleMassKg, units.WeightFromKg)
+		a.BoneMassKg = ConvertOptional(a.BoneMassKg, units.WeightFromKg)
+		a.NickCm = ConvertOptional(a.NickCm, units.LengthFromCm)
+		a.WaistCm = ConvertOptional(a.WaistCm, units.LengthFromCm)
+		a.HipCm = ConvertOptional(a.HipCm, units.LengthFromCm)
+		a.ChestCm = ConvertOptional(a.ChestCm, units.LengthFromCm)
+		a.ArmLeftCm = ConvertOptional(a.ArmLeftCm, units.LengthFromCm)
+		a.ArmRightCm = ConvertOptional(a.ArmRightCm, units.LengthFromCm)
+		a.ThighLeftCm = ConvertOptional(a.ThighLeftCm, units.LengthFromCm)
+		a.ThighRightCm = ConvertOptional(a.ThighRightCm, units.LengthFromCm)
+		a.CalfCm = ConvertOptional(a.CalfCm, units.LengthFromCm)
+		a.ScaleBmr = ConvertOptional(a.ScaleBmr, units.EnergyFromKcal)
+		a.FlagReasons = a.FlagReasons.InUnits(units)
+		if a.Derived != nil {
+			derived := a.Derived.InUnits(units)
+			a.Derived = &derived
+		}
+	}
 	return sonic.Marshal(struct {
-		MeasurementId int      `json:"measurement_id" db:"measurement_id"`
-		UserId        int      `json:"user_id" db:"user_id"`
-		Bodyweight    float64  `json:"bodyweight" db:"bodyweight"`
-		ViceralFat    *float64 `json:"viceral_fat,omitempty" db:"viceral_fat"`
-		FatPercentage *float64 `json:"fat_percentage,omitempty" db:"fat_percentage"`
-		NickCm        *float64 `json:"nick_cm,omitempty" db:"nick_cm"`
-		WaistCm       *float64 `json:"waist_cm,omitempty" db:"waist_cm"`
-		MeasuredAt    string   `json:"measured_at" db:"measured_at"`
+		MeasurementId       int      `json:"measurement_id" db:"measurement_id"`
+		UserId              int      `json:"user_id" db:"user_id"`
+		Bodyweight          float64  `json:"bodyweight" db:"bodyweight"`
+		ViceralFat          *float64 `json:"viceral_fat,omitempty" db:"viceral_fat"`
+		FatPercentage       *float64 `json:"fat_percentage,omitempty" db:"fat_percentage"`
+		NickCm              *float64 `json:"nick_cm,omitempty" db:"nick_cm"`
+		WaistCm             *float64 `json:"waist_cm,omitempty" db:"waist_cm"`
+		HipCm               *float64 `json:"hip_cm,omitempty" db:"hip_cm"`
+		ChestCm             *float64 `json:"chest_cm,omitempty" db:"chest_cm"`
+		ArmLeftCm           *float64 `json:"arm_left_cm,omitempty" db:"arm_left_cm"`
+		ArmRightCm          *float64 `json:"arm_right_cm,omitempty" db:"arm_right_cm"`
+		ThighLeftCm         *float64 `json:"thigh_left_cm,omitempty" db:"thigh_left_cm"`
+		ThighRightCm        *float64 `json:"thigh_right_cm,omitempty" db:"thigh_right_cm"`
+		CalfCm              *float64 `json:"calf_cm,omitempty" db:"calf_cm"`
+		MuscleMassKg        *float64 `json:"muscle_mass_kg,omitempty" db:"muscle_mass_kg"`
+		BoneMassKg          *float64 `json:"bone_mass_kg,omitempty" db:"bone_mass_kg"`
+		BodyWaterPercentage *float64 `json:"body_water_percentage,omitempty" db:"body_water_percentage"`
+		ScaleBmr            *float64 `json:"scale_bmr,omitempty" db:"scale_bmr"`
+		MetabolicAge        *int     `json:"metabolic_age,omitempty" db:"metabolic_age"`
+		MeasuredAt          string   `json:"measured_at" db:"measured_at"`
+		Flagged             bool     `json:"flagged,omitempty"`
+
+		FlagReasons MeasurementWarnings `json:"flag_reasons,omitempty"`
+
+		Derived *DerivedBodyMetrics `json:"derived,omitempty"`
+		Units   *UnitPreference     `json:"units,omitempty"`
 	}{
-		MeasurementId: a.MeasurementId,
-		UserId:        a.UserId,
-		Bodyweight:    a.Bodyweight,
-		ViceralFat:    a.ViceralFat,
-		FatPercentage: a.FatPercentage,
-		NickCm:        a.NickCm,
-		WaistCm:       a.WaistCm,
-		MeasuredAt:    a.MeasuredAt.Format(time.RFC3339),
+		MeasurementId:       a.MeasurementId,
+		UserId:              a.UserId,
+		Bodyweight:          a.Bodyweight,
+		ViceralFat:          a.ViceralFat,
+		FatPercentage:       a.FatPercentage,
+		NickCm:              a.NickCm,
+		WaistCm:             a.WaistCm,
+		HipCm:               a.HipCm,
+		ChestCm:             a.ChestCm,
+		ArmLeftCm:           a.ArmLeftCm,
+		ArmRightCm:          a.ArmRightCm,
+		ThighLeftCm:         a.ThighLeftCm,
+		ThighRightCm:        a.ThighRightCm,
+		CalfCm:              a.CalfCm,
+		MuscleMassKg:        a.MuscleMassKg,
+		BoneMassKg:          a.BoneMassKg,
+		BodyWaterPercentage: a.BodyWaterPercentage,
+		ScaleBmr:            a.ScaleBmr,
+		MetabolicAge:        a.MetabolicAge,
+		MeasuredAt:          a.MeasuredAt.Format(time.RFC3339),
+		Flagged:             a.Flagged,
+		FlagReasons:         a.FlagReasons,
+		Derived:             a.Derived,
+		Units:               a.Units,
 	})
 }
 
+// values lists the fields in bodyMeasurementColumns order
+func (a *BodyMeasurement) values() []interface{} {
+	return []interface{}{
+		a.Bodyweight, a.ViceralFat, a.FatPercentage, a.NickCm, a.WaistCm,
+		a.HipCm, a.ChestCm, a.ArmLeftCm, a.ArmRightCm, a.ThighLeftCm, a.ThighRightCm, a.CalfCm,
+		a.MuscleMassKg, a.BoneMassKg, a.BodyWaterPercentage, a.ScaleBmr, a.MetabolicAge,
+		a.Flagged, a.FlagReasons,
+	}
+}
+
 // bodyMeasurement implements bodyMeasurement interface
 type bodyMeasurementRepository struct{}
 
@@ -55,6 +151,14 @@ type BodyMeasurementRepository interface {
 	GetByUserId(userId, limit, page int) ([]BodyMeasurement, error)
 	Update(userId int, measurementId int, data *BodyMeasurement) error
 	Delete(userId int, measurementId int) error
+	Bulk(userId int, mode BulkMode, ops []BulkOperation[BodyMeasurement]) ([]BulkOutcome, error)
+	GetDailyBodyweight(userId int, from, to string) ([]DailyBodyweight, error)
+	FindLatest(userId int) (*BodyMeasurement, error)
+	Import(userId int, imp *MeasurementImport, dryRun bool) error
+	FindRecent(userId, excludeId int, before time.Time, limit int) ([]BodyMeasurement, error)
+	FindById(userId, measurementId int) (*BodyMeasurement, error)
+	GetFlagged(userId, limit, page int) ([]BodyMeasurement, error)
+	MarkReviewed(userId, measurementId int) error
 }
 
 // DeleteTodayIntake deletes today's food intake for a user
@@ -64,11 +168,16 @@ func (r *bodyMeasurementRepository) Delete(userID, measurementId int) error {
 		return fmt.Errorf("database connection is nil")
 	}
 
+	_, err := deleteBodyMeasurement(db, userID, measurementId)
+	return err
+}
+
+// deleteBodyMeasurement deletes one body measurement owned by userID
+func deleteBodyMeasurement(e sqlx.Execer, userID, measurementId int) (sql.Result, error) {
 	query := `DELETE FROM body_measurement 
 	WHERE user_id = $1 
 	AND measurement_id = $2`
-	_, err := db.Exec(query, userID, measurementId)
-	return err
+	return e.Exec(query, userID, measurementId)
 }
 
 // Update updates a body measurement for a user
@@ -78,15 +187,33 @@ func (r *bodyMeasurementRepository) Update(userId int, measurementId int, data *
 		return fmt.Errorf("database connection is nil")
 	}
 
+	_, err := updateBodyMeasurement(db, userId, measurementId, data)
+	return err
+}
+
+// updateBodyMeasurement overwrites one body measurement owned by userId
+func updateBodyMeasurement(e sqlx.Execer, userId int, measurementId int, data *BodyMeasurement) (sql.Result, error) {
 	query := `UPDATE body_measurement SET
 	bodyweight = $1, viceral_fat = $2, fat_percentage = $3,
-	nick_cm = $4, waist_cm = $5 WHERE user_id = $6 AND measurement_id = $7`
+	nick_cm = $4, waist_cm = $5, hip_cm = $6, chest_cm = $7,
+	arm_left_cm = $8, arm_right_cm = $9, thigh_left_cm = $10, thigh_right_cm = $11, calf_cm = $12,
+	muscle_mass_kg = $13, bone_mass_kg = $14, body_water_percentage = $15,
+	scale_bmr = $16, metabolic_age = $17, flagged = $18, flag_reasons = $19
+	WHERE user_id = $20 AND measurement_id = $21`
+
+	return e.Exec(query, append(data.values(), userId, measurementId)...)
+}
 
-	_, err := db.Exec(query, data.Bodyweight, data.ViceralFat,
-		data.FatPercentage, data.NickCm,
-		data.WaistCm, userId, measurementId)
+// insertBodyMeasurement inserts a body measurement taken at MeasuredAt and returns its id
+func insertBodyMeasurement(q sqlx.Queryer, userId int, data *BodyMeasurement) (int, error) {
+	query := `INSERT INTO body_measurement
+	(user_id, measured_at, ` + bodyMeasurementColumns + `)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
+	RETURNING measurement_id`
 
-	return err
+	var measurementId int
+	err := q.QueryRowx(query, append([]interface{}{userId, data.MeasuredAt}, data.values()...)...).Scan(&measurementId)
+	return measurementId, err
 }
 
 // AddTodayIntake adds today's food intake for a user
@@ -97,14 +224,10 @@ func (r *bodyMeasurementRepository) Create(userId int, data BodyMeasurement) err
 	}
 
 	query := `INSERT INTO body_measurement
-	(user_id, bodyweight, viceral_fat, fat_percentage, nick_cm, waist_cm, measured_at)
-	VALUES ($1, $2, $3, $4, $5, $6, NOW())`
-
-	_, err := db.Exec(query,
-		userId,
-		data.Bodyweight, data.ViceralFat,
-		data.FatPercentage, data.NickCm,
-		data.WaistCm)
+	(user_id, measured_at, ` + bodyMeasurementColumns + `)
+	VALUES ($1, NOW(), $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)`
+
+	_, err := db.Exec(query, append([]interface{}{userId}, data.values()...)...)
 	return err
 }
 
@@ -119,11 +242,69 @@ func (r *bodyMeasurementRepository) GetByUserId(userID, limit, page int) ([]Body
 
 	var measurements []BodyMeasurement
 	query := `SELECT 
-	measurement_id, user_id, bodyweight, viceral_fat,
-	fat_percentage, nick_cm, waist_cm, measured_at 
+	measurement_id, user_id, measured_at, ` + bodyMeasurementColumns + `
  FROM body_measurement 
  WHERE user_id = $1 ORDER BY measured_at DESC LIMIT $2 OFFSET $3`
 
 	err := db.Select(&measurements, query, userID, limit, offset)
 	return measurements, err
 }
+
+// Bulk applies a batch of body measurement writes for userId. Created
+// measurements keep their MeasuredAt.
+func (r *bodyMeasurementRepository) Bulk(userId int, mode BulkMode, ops []BulkOperation[BodyMeasurement]) ([]BulkOutcome, error) {
+	return runBulk(mode, ops, func(tx *sqlx.Tx, op BulkOperation[BodyMeasurement]) (int, error) {
+		switch op.Action {
+		case BulkCreate:
+			return insertBodyMeasurement(tx, userId, &op.Data)
+		case BulkUpdate:
+			return op.Id, requireOneRow(updateBodyMeasurement(tx, userId, op.Id, &op.Data))
+		case BulkDelete:
+			return op.Id, requireOneRow(deleteBodyMeasurement(tx, userId, op.Id))
+		}
+		return 0, fmt.Errorf("unknown bulk action %q", op.Action)
+	})
+}
+
+// GetDailyBodyweight returns the last bodyweight measured on each local date
+// (in the user's timezone) between from and to inclusive
+func (r *bodyMeasurementRepository) GetDailyBodyweight(userId int, from, to string) ([]DailyBodyweight, error) {
+	db := GetDB().PostgreDBManager.RC
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var days []DailyBodyweight
+	query := `SELECT DISTINCT ON (DATE(measured_at AT TIME ZONE $2))
+	TO_CHAR(DATE(measured_at AT TIME ZONE $2), 'YYYY-MM-DD') AS date, bodyweight
+ FROM body_measurement
+ WHERE user_id = $1
+ AND DATE(measured_at AT TIME ZONE $2) BETWEEN $3::date AND $4::date
+ ORDER BY DATE(measured_at AT TIME ZONE $2) ASC, measured_at DESC`
+	err := db.Select(&days, query, userId, resolveUserTimezone(userId), from, to)
+	return days, err
+}
+
+// FindLatest returns the most recent body measurement of a user
+func (r *bodyMeasurementRepository) FindLatest(userId int) (*BodyMeasurement, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var measurement BodyMeasurement
+	query := `SELECT 
+	measurement_id, user_id, measured_at, ` + bodyMeasurementColumns + `
+ FROM body_measurement 
+ WHERE user_id = $1 ORDER BY measured_at DESC LIMIT 1`
+
+	err := db.Get(&measurement, query, userId)
+	return &measurement, err
+}
+
+// AttachMeasurementUnits makes every measurement marshal its values in units
+func AttachMeasurementUnits(measurements []BodyMeasurement, units UnitPreference) {
+	for i := range measurements {
+		measurements[i].Units = &units
+	}
+}
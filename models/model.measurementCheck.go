@@ -0,0 +1,345 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// Reasons a measurement value is flagged as implausible
+const (
+	WarningOutOfRange   = "out_of_range"
+	WarningSuddenChange = "sudden_change"
+	WarningOutlier      = "outlier"
+)
+
+// Kinds of measured value, deciding how a warning is converted into the user's units
+const (
+	measurementKindWeight  = "weight"
+	measurementKindLength  = "length"
+	measurementKindEnergy  = "energy"
+	measurementKindPercent = "percent"
+	measurementKindLevel   = "level"
+	measurementKindYears   = "years"
+)
+
+const (
+	// MeasurementHistoryLimit is how many earlier measurements the checks compare against
+	MeasurementHistoryLimit = 30
+	// measurementHistoryDays is how far back a measurement still counts as recent history
+	measurementHistoryDays = 90
+	// measurementOutlierMinSamples is the least history needed for the z-score check
+	measurementOutlierMinSamples = 5
+	// measurementOutlierZScore is how many standard deviations from the recent mean is an outlier
+	measurementOutlierZScore = 4.0
+	// measurementOutlierMaxGapDays skips the z-score check after a break, when
+	// the sudden change check alone judges how far the values may have moved
+	measurementOutlierMaxGapDays = 7.0
+	// measurementMinSpreadPct keeps a very steady history from flagging normal noise
+	measurementMinSpreadPct = 1.0
+	// measurementMaxChangeDays caps how many days of change the sudden change check allows for
+	measurementMaxChangeDays = 30.0
+)
+
+// MeasurementWarning explains why one value of a measurement looks like a
+// data-entry mistake. Value and Reference (the previous or typical value) are
+// in Unit; Min and Max are the plausible bounds of out_of_range warnings.
+type MeasurementWarning struct {
+	Field     string   `json:"field"`
+	Reason    string   `json:"reason"`
+	Message   string   `json:"message"`
+	Value     float64  `json:"value"`
+	Reference *float64 `json:"reference,omitempty"`
+	Min       *float64 `json:"min,omitempty"`
+	Max       *float64 `json:"max,omitempty"`
+	Unit      string   `json:"unit,omitempty"`
+}
+
+// MeasurementWarnings is the JSONB list of warnings stored with a flagged measurement
+type MeasurementWarnings []MeasurementWarning
+
+// Scan implements the sql.Scanner interface
+func (w *MeasurementWarnings) Scan(value interface{}) error {
+	if value == nil {
+		*w = nil
+		return nil
+	}
+
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into MeasurementWarnings", value)
+	}
+	return sonic.Unmarshal(data, w)
+}
+
+// Value implements the driver.Valuer interface
+func (w MeasurementWarnings) Value() (driver.Value, error) {
+	if len(w) == 0 {
+		return nil, nil
+	}
+	data, err := sonic.Marshal([]MeasurementWarning(w))
+	return string(data), err
+}
+
+// measurementCheck holds the physiological bounds of one field and how fast
+// it may plausibly change: maxChangePct relative to the previous value, plus
+// maxChangePctPerDay for every day since. Fields without a change limit are
+// only checked against their bounds.
+type measurementCheck struct {
+	field              string
+	kind               string
+	min, max           float64
+	maxChangePct       float64
+	maxChangePctPerDay float64
+	value              func(m *BodyMeasurement) *float64
+}
+
+// measurementChecks are wide enough for any adult, so only typos and unit
+// mix-ups (lb typed as kg, inches as cm) fall outside them
+var measurementChecks = []measurementCheck{
+	{"bodyweight", measurementKindWeight, 25, 300, 3, 1, func(m *BodyMeasurement) *float64 { return &m.Bodyweight }},
+	{"fat_percentage", measurementKindPercent, 2, 70, 20, 2, func(m *BodyMeasurement) *float64 { return m.FatPercentage }},
+	{"viceral_fat", measurementKindLevel, 1, 59, 0, 0, func(m *BodyMeasurement) *float64 { return m.ViceralFat }},
+	{"nick_cm", measurementKindLength, 20, 70, 10, 1, func(m *BodyMeasurement) *float64 { return m.NickCm }},
+	{"waist_cm", measurementKindLength, 40, 200, 10, 1, func(m *BodyMeasurement) *float64 { return m.WaistCm }},
+	{"hip_cm", measurementKindLength, 50, 220, 10, 1, func(m *BodyMeasurement) *float64 { return m.HipCm }},
+	{"chest_cm", measurementKindLength, 50, 200, 10, 1, func(m *BodyMeasurement) *float64 { return m.ChestCm }},
+	{"arm_left_cm", measurementKindLength, 15, 70, 10, 1, func(m *BodyMeasurement) *float64 { return m.ArmLeftCm }},
+	{"arm_right_cm", measurementKindLength, 15, 70, 10, 1, func(m *BodyMeasurement) *float64 { return m.ArmRightCm }},
+	{"thigh_left_cm", measurementKindLength, 25, 110, 10, 1, func(m *BodyMeasurement) *float64 { return m.ThighLeftCm }},
+	{"thigh_right_cm", measurementKindLength, 25, 110, 10, 1, func(m *BodyMeasurement) *float64 { return m.ThighRightCm }},
+	{"calf_cm", measurementKindLength, 20, 70, 10, 1, func(m *BodyMeasurement) *float64 { return m.CalfCm }},
+	{"muscle_mass_kg", measurementKindWeight, 10, 150, 5, 1, func(m *BodyMeasurement) *float64 { return m.MuscleMassKg }},
+	{"bone_mass_kg", measurementKindWeight, 0.5, 8, 0, 0, func(m *BodyMeasurement) *float64 { return m.BoneMassKg }},
+	{"body_water_percentage", measurementKindPercent, 25, 80, 0, 0, func(m *BodyMeasurement) *float64 { return m.BodyWaterPercentage }},
+	{"scale_bmr", measurementKindEnergy, 600, 4500, 0, 0, func(m *BodyMeasurement) *float64 { return m.ScaleBmr }},
+	{"metabolic_age", measurementKindYears, 10, 100, 0, 0, func(m *BodyMeasurement) *float64 {
+		if m.MetabolicAge == nil {
+			return nil
+		}
+		age := float64(*m.MetabolicAge)
+		return &age
+	}},
+}
+
+// CheckMeasurement returns a warning for every value of m that is outside its
+// physiological bounds, changed faster than the body can since the previous
+// measurement, or is far off the user's recent measurements. history holds
+// earlier measurements newest first and may be empty, leaving only the bounds.
+// Each field gets at most one warning; all values are metric.
+func CheckMeasurement(m BodyMeasurement, history []BodyMeasurement) MeasurementWarnings {
+	recentFrom := m.MeasuredAt.AddDate(0, 0, -measurementHistoryDays)
+	var warnings MeasurementWarnings
+	for _, check := range measurementChecks {
+		value := check.value(&m)
+		if value == nil {
+			continue
+		}
+		if *value < check.min || *value > check.max {
+			warnings = append(warnings, MeasurementWarning{
+				Field:   check.field,
+				Reason:  WarningOutOfRange,
+				Message: fmt.Sprintf("%s is outside the plausible range", check.field),
+				Value:   *value,
+				Min:     &check.min,
+				Max:     &check.max,
+				Unit:    measurementKindUnit(check.kind, MetricUnits()),
+			})
+			continue
+		}
+		if check.maxChangePct == 0 {
+			continue
+		}
+
+		var previous *float64
+		var previousAt time.Time
+		var recent []float64
+		for i := range history {
+			past := check.value(&history[i])
+			if past == nil || *past <= 0 || history[i].MeasuredAt.Before(recentFrom) {
+				continue
+			}
+			if previous == nil {
+				previous, previousAt = past, history[i].MeasuredAt
+			}
+			recent = append(recent, *past)
+		}
+		if previous == nil {
+			continue
+		}
+
+		days := math.Max(m.MeasuredAt.Sub(previousAt).Hours()/24, 1)
+		changePct := math.Abs(*value-*previous) / *previous * 100
+		if changePct > check.maxChangePct+check.maxChangePctPerDay*math.Min(days, measurementMaxChangeDays) {
+			reference := *previous
+			warnings = append(warnings, MeasurementWarning{
+				Field:     check.field,
+				Reason:    WarningSuddenChange,
+				Message:   fmt.Sprintf("%s changed by %.1f%% in %.0f day(s) since the previous measurement", check.field, changePct, math.Ceil(days)),
+				Value:     *value,
+				Reference: &reference,
+				Unit:      measurementKindUnit(check.kind, MetricUnits()),
+			})
+			continue
+		}
+
+		if len(recent) < measurementOutlierMinSamples || days > measurementOutlierMaxGapDays {
+			continue
+		}
+		mean, spread := meanAndDeviation(recent)
+		spread = math.Max(spread, mean*measurementMinSpreadPct/100)
+		if math.Abs(*value-mean)/spread > measurementOutlierZScore {
+			reference := round2(mean)
+			warnings = append(warnings, MeasurementWarning{
+				Field:     check.field,
+				Reason:    WarningOutlier,
+				Message:   fmt.Sprintf("%s is far from your last %d measurements", check.field, len(recent)),
+				Value:     *value,
+				Reference: &reference,
+				Unit:      measurementKindUnit(check.kind, MetricUnits()),
+			})
+		}
+	}
+	return warnings
+}
+
+// meanAndDeviation returns the mean and population standard deviation of values
+func meanAndDeviation(values []float64) (float64, float64) {
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	mean := sum / float64(len(values))
+
+	var squares float64
+	for _, value := range values {
+		squares += (value - mean) * (value - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(values)))
+}
+
+// InUnits returns the warnings with their values converted into units
+func (w MeasurementWarnings) InUnits(units UnitPreference) MeasurementWarnings {
+	if len(w) == 0 {
+		return w
+	}
+	kinds := map[string]string{}
+	for _, check := range measurementChecks {
+		kinds[check.field] = check.kind
+	}
+
+	converted := make(MeasurementWarnings, len(w))
+	for i, warning := range w {
+		var convert func(float64) float64
+		switch kinds[warning.Field] {
+		case measurementKindWeight:
+			convert = units.WeightFromKg
+		case measurementKindLength:
+			convert = units.LengthFromCm
+		case measurementKindEnergy:
+			convert = units.EnergyFromKcal
+		}
+		if convert != nil {
+			warning.Value = convert(warning.Value)
+			warning.Reference = ConvertOptional(warning.Reference, convert)
+			warning.Min = ConvertOptional(warning.Min, convert)
+			warning.Max = ConvertOptional(warning.Max, convert)
+			warning.Unit = measurementKindUnit(kinds[warning.Field], units)
+		}
+		converted[i] = warning
+	}
+	return converted
+}
+
+// measurementKindUnit is the unit a value of kind is written in
+func measurementKindUnit(kind string, units UnitPreference) string {
+	switch kind {
+	case measurementKindWeight:
+		return units.Weight
+	case measurementKindLength:
+		return units.Length
+	case measurementKindEnergy:
+		return units.Energy
+	case measurementKindPercent:
+		return "%"
+	case measurementKindYears:
+		return "years"
+	}
+	return ""
+}
+
+// FindRecent returns up to limit measurements of a user taken before before,
+// newest first, leaving out excludeId (0 to keep all)
+func (r *bodyMeasurementRepository) FindRecent(userId, excludeId int, before time.Time, limit int) ([]BodyMeasurement, error) {
+	db := GetDB().PostgreDBManager.RC
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var measurements []BodyMeasurement
+	query := `SELECT
+	measurement_id, user_id, measured_at, ` + bodyMeasurementColumns + `
+ FROM body_measurement
+ WHERE user_id = $1 AND measurement_id <> $2 AND measured_at < $3
+ ORDER BY measured_at DESC LIMIT $4`
+
+	err := db.Select(&measurements, query, userId, excludeId, before, limit)
+	return measurements, err
+}
+
+// FindById returns one body measurement owned by userId
+func (r *bodyMeasurementRepository) FindById(userId, measurementId int) (*BodyMeasurement, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var measurement BodyMeasurement
+	query := `SELECT
+	measurement_id, user_id, measured_at, ` + bodyMeasurementColumns + `
+ FROM body_measurement
+ WHERE user_id = $1 AND measurement_id = $2`
+
+	err := db.Get(&measurement, query, userId, measurementId)
+	return &measurement, err
+}
+
+// GetFlagged returns the flagged measurements of a user waiting for review, newest first
+func (r *bodyMeasurementRepository) GetFlagged(userId, limit, page int) ([]BodyMeasurement, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	offset := (page - 1) * limit
+	limit = limit + 1
+
+	var measurements []BodyMeasurement
+	query := `SELECT
+	measurement_id, user_id, measured_at, ` + bodyMeasurementColumns + `
+ FROM body_measurement
+ WHERE user_id = $1 AND flagged ORDER BY measured_at DESC LIMIT $2 OFFSET $3`
+
+	err := db.Select(&measurements, query, userId, limit, offset)
+	return measurements, err
+}
+
+// MarkReviewed clears the flag of a measurement the user has confirmed is correct
+func (r *bodyMeasurementRepository) MarkReviewed(userId, measurementId int) error {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	query := `UPDATE body_measurement SET flagged = FALSE, flag_reasons = NULL
+	WHERE user_id = $1 AND measurement_id = $2 AND flagged`
+	return requireOneRow(db.Exec(query, userId, measurementId))
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCheckMeasurement(t *testing.T) {
+	now := time.Date(2026, 3, 20, 7, 0, 0, 0, time.UTC)
+	measured := func(daysAgo int, bodyweight float64) BodyMeasurement {
+		return BodyMeasurement{Bodyweight: bodyweight, MeasuredAt: now.AddDate(0, 0, -daysAgo)}
+	}
+	withWaist := func(m BodyMeasurement, waistCm *float64) BodyMeasurement {
+		m.WaistCm = waistCm
+		return m
+	}
+	// steady is a week of daily weigh-ins around 80 kg, newest first, starting daysAgo
+	steady := func(daysAgo int, count int) []BodyMeasurement {
+		values := []float64{80.0, 80.2, 79.8, 80.1, 79.9, 80.0}
+		history := make([]BodyMeasurement, count)
+		for i := range history {
+			history[i] = measured(daysAgo+i, values[i%len(values)])
+		}
+		return history
+	}
+
+	tests := []struct {
+		name    string
+		m       BodyMeasurement
+		history []BodyMeasurement
+		want    []string
+	}{
+		{
+			name: "empty history checks only the bounds",
+			m:    measured(0, 80),
+		},
+		{
+			name: "below and above the bounds",
+			m:    withWaist(measured(0, 20), floatPtr(250)),
+			want: []string{"bodyweight:out_of_range", "waist_cm:out_of_range"},
+		},
+		{
+			name:    "an out of range value gets no other warning",
+			m:       measured(0, 400),
+			history: []BodyMeasurement{measured(1, 80)},
+			want:    []string{"bodyweight:out_of_range"},
+		},
+		{
+			name:    "jump within a day",
+			m:       measured(0, 84),
+			history: []BodyMeasurement{measured(1, 80)},
+			want:    []string{"bodyweight:sudden_change<80"},
+		},
+		{
+			name:    "the same jump is allowed after sparse history",
+			m:       measured(0, 84),
+			history: []BodyMeasurement{measured(10, 80)},
+		},
+		{
+			name:    "the allowance stops growing after 30 days",
+			m:       measured(0, 110),
+			history: []BodyMeasurement{measured(60, 80)},
+			want:    []string{"bodyweight:sudden_change<80"},
+		},
+		{
+			name:    "history older than 90 days is ignored",
+			m:       measured(0, 80),
+			history: []BodyMeasurement{measured(100, 50)},
+		},
+		{
+			name: "the previous value skips measurements without the field",
+			m:    withWaist(measured(0, 80), floatPtr(103)),
+			history: []BodyMeasurement{
+				withWaist(measured(1, 80), nil),
+				withWaist(measured(2, 80), floatPtr(90)),
+			},
+			want: []string{"waist_cm:sudden_change<90"},
+		},
+		{
+			name:    "z-score outlier against a steady history",
+			m:       measured(0, 83.5),
+			history: steady(2, 6),
+			want:    []string{"bodyweight:outlier<80"},
+		},
+		{
+			name:    "too few measurements for the z-score",
+			m:       measured(0, 83.5),
+			history: steady(2, 4),
+		},
+		{
+			name:    "no z-score after a break",
+			m:       measured(0, 83.5),
+			history: steady(8, 6),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, warning := range CheckMeasurement(tt.m, tt.history) {
+				got = append(got, describeMeasurementWarning(warning))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckMeasurement() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// describeMeasurementWarning writes a warning as field:reason[<reference]
+func describeMeasurementWarning(warning MeasurementWarning) string {
+	out := warning.Field + ":" + warning.Reason
+	if warning.Reference != nil {
+		out += fmt.Sprintf("<%v", *warning.Reference)
+	}
+	return out
+}
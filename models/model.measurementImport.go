@@ -0,0 +1,764 @@
+package models
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrInvalidImport wraps every problem with an import file as a whole, as
+// opposed to problems with single rows which are reported per row
+var ErrInvalidImport = errors.New("invalid import")
+
+const (
+	// MaxImportRows caps the data rows of one import, a decade of twice-daily weigh-ins fits
+	MaxImportRows = 20000
+	// importPreviewRows is how many importable rows a summary shows
+	importPreviewRows = 20
+	// importReportedErrors is how many invalid rows a summary lists
+	importReportedErrors = 100
+	// importMaxBodyweightKg rejects rows that can't be a bodyweight, e.g. a BMI column mapped as weight
+	importMaxBodyweightKg = 650
+)
+
+// Fields an import column can be mapped to. Masses are in the file's weight
+// unit; fat and body water may come as a mass and are stored as percentages.
+const (
+	ImportFieldDate                = "date"
+	ImportFieldTime                = "time"
+	ImportFieldBodyweight          = "bodyweight"
+	ImportFieldFatPercentage       = "fat_percentage"
+	ImportFieldFatMass             = "fat_mass"
+	ImportFieldViceralFat          = "viceral_fat"
+	ImportFieldMuscleMass          = "muscle_mass"
+	ImportFieldBoneMass            = "bone_mass"
+	ImportFieldBodyWaterPercentage = "body_water_percentage"
+	ImportFieldBodyWaterMass       = "body_water_mass"
+	ImportFieldScaleBmr            = "scale_bmr"
+	ImportFieldMetabolicAge        = "metabolic_age"
+)
+
+// importFields lists every field in the order columns are matched
+var importFields = []string{
+	ImportFieldDate, ImportFieldTime, ImportFieldBodyweight,
+	ImportFieldFatPercentage, ImportFieldFatMass, ImportFieldViceralFat,
+	ImportFieldMuscleMass, ImportFieldBoneMass,
+	ImportFieldBodyWaterPercentage, ImportFieldBodyWaterMass,
+	ImportFieldScaleBmr, ImportFieldMetabolicAge,
+}
+
+// Statuses of an import row
+const (
+	ImportRowNew             = "new"
+	ImportRowDuplicate       = "duplicate"
+	ImportRowDuplicateInFile = "duplicate_in_file"
+	ImportRowInvalid         = "invalid"
+)
+
+// ImportProfileCustom is the profile name of a mapping supplied with the upload
+const ImportProfileCustom = "custom"
+
+// MeasurementImportProfile maps the CSV headers of one app onto import fields.
+// Headers are compared ignoring case, spaces and punctuation, so "Weight (kg)"
+// matches "weight(kg)". DateOrder is used when the dates don't give it away.
+type MeasurementImportProfile struct {
+	Name      string
+	Columns   map[string][]string
+	DateOrder string
+}
+
+// MeasurementImportProfiles are the scale apps whose exports are recognised
+var MeasurementImportProfiles = map[string]MeasurementImportProfile{
+	"withings": {
+		Name: "withings",
+		Columns: map[string][]string{
+			ImportFieldDate:          {"Date"},
+			ImportFieldBodyweight:    {"Weight (kg)", "Weight (lb)"},
+			ImportFieldFatMass:       {"Fat mass (kg)", "Fat mass (lb)"},
+			ImportFieldBoneMass:      {"Bone mass (kg)", "Bone mass (lb)"},
+			ImportFieldMuscleMass:    {"Muscle mass (kg)", "Muscle mass (lb)"},
+			ImportFieldBodyWaterMass: {"Hydration (kg)", "Hydration (lb)"},
+		},
+		DateOrder: DateOrderDayFirst,
+	},
+	"renpho": {
+		Name: "renpho",
+		Columns: map[string][]string{
+			ImportFieldDate:                {"Time of Measurement", "Date"},
+			ImportFieldTime:                {"Time"},
+			ImportFieldBodyweight:          {"Weight(kg)", "Weight(lb)", "Weight"},
+			ImportFieldFatPercentage:       {"Body Fat(%)"},
+			ImportFieldViceralFat:          {"Visceral Fat"},
+			ImportFieldBodyWaterPercentage: {"Body Water(%)"},
+			ImportFieldMuscleMass:          {"Muscle Mass(kg)", "Muscle Mass(lb)"},
+			ImportFieldBoneMass:            {"Bone Mass(kg)", "Bone Mass(lb)"},
+			ImportFieldScaleBmr:            {"BMR(kcal)", "BMR"},
+			ImportFieldMetabolicAge:        {"Metabolic Age"},
+		},
+		DateOrder: DateOrderMonthFirst,
+	},
+	"garmin": {
+		Name: "garmin",
+		Columns: map[string][]string{
+			ImportFieldDate:                {"Date"},
+			ImportFieldTime:                {"Time"},
+			ImportFieldBodyweight:          {"Weight"},
+			ImportFieldFatPercentage:       {"Body Fat"},
+			ImportFieldMuscleMass:          {"Skeletal Muscle Mass", "Muscle Mass"},
+			ImportFieldBoneMass:            {"Bone Mass"},
+			ImportFieldBodyWaterPercentage: {"Body Water"},
+		},
+		DateOrder: DateOrderMonthFirst,
+	},
+	"fitbit": {
+		Name: "fitbit",
+		Columns: map[string][]string{
+			ImportFieldDate:          {"Date"},
+			ImportFieldTime:          {"Time"},
+			ImportFieldBodyweight:    {"Weight"},
+			ImportFieldFatPercentage: {"Fat", "Body Fat"},
+		},
+		DateOrder: DateOrderMonthFirst,
+	},
+	"eufy": {
+		Name: "eufy",
+		Columns: map[string][]string{
+			ImportFieldDate:                {"Date", "Time"},
+			ImportFieldBodyweight:          {"Weight(kg)", "Weight(lb)", "Weight(lbs)"},
+			ImportFieldFatPercentage:       {"Body Fat(%)"},
+			ImportFieldMuscleMass:          {"Muscle Mass(kg)", "Muscle Mass(lb)", "Muscle Mass(lbs)"},
+			ImportFieldBoneMass:            {"Bone Mass(kg)", "Bone Mass(lb)", "Bone Mass(lbs)"},
+			ImportFieldBodyWaterPercentage: {"Water(%)", "Body Water(%)"},
+			ImportFieldViceralFat:          {"Visceral Fat"},
+			ImportFieldScaleBmr:            {"BMR(kcal)", "BMR"},
+			ImportFieldMetabolicAge:        {"Body Age", "Metabolic Age"},
+		},
+		DateOrder: DateOrderDayFirst,
+	},
+}
+
+// NewCustomImportProfile builds a profile from a mapping of field to header
+// supplied by the user; date and bodyweight must be mapped
+func NewCustomImportProfile(columns map[string]string) (MeasurementImportProfile, error) {
+	profile := MeasurementImportProfile{Name: ImportProfileCustom, Columns: map[string][]string{}, DateOrder: DateOrderDayFirst}
+	for field, header := range columns {
+		if !isImportField(field) {
+			return profile, fmt.Errorf("%w: unknown field %q in mapping", ErrInvalidImport, field)
+		}
+		if strings.TrimSpace(header) != "" {
+			profile.Columns[field] = []string{header}
+		}
+	}
+	if len(profile.Columns[ImportFieldDate]) == 0 || len(profile.Columns[ImportFieldBodyweight]) == 0 {
+		return profile, fmt.Errorf("%w: the mapping needs a date and a bodyweight column", ErrInvalidImport)
+	}
+	return profile, nil
+}
+
+// MeasurementImportOptions controls how a file is read. Profile is nil to
+// detect it from the headers. DateOrder and DateFormat are empty to detect the
+// date format; WeightUnit is used when neither header nor cell names a unit.
+type MeasurementImportOptions struct {
+	Profile      *MeasurementImportProfile
+	DateOrder    string
+	DateFormat   string
+	WeightUnit   string
+	DecimalComma bool
+	Location     *time.Location
+}
+
+// MeasurementImportRow is one data row of an import file with its outcome
+type MeasurementImportRow struct {
+	Line        int              `json:"line"`
+	Status      string           `json:"status"`
+	Measurement *BodyMeasurement `json:"measurement,omitempty"`
+	Errors      []string         `json:"errors,omitempty"`
+	// Warnings are the plausibility checks the row failed, see CheckMeasurement
+	Warnings MeasurementWarnings `json:"warnings,omitempty"`
+}
+
+// MeasurementImport is a parsed import file
+type MeasurementImport struct {
+	Profile         string
+	DateOrder       string
+	Delimiter       string
+	UnmappedColumns []string
+	Rows            []MeasurementImportRow
+}
+
+// MeasurementImportSummary reports what an import did, or would do on a dry run
+type MeasurementImportSummary struct {
+	DryRun             bool                   `json:"dry_run"`
+	Profile            string                 `json:"profile"`
+	DateOrder          string                 `json:"date_order"`
+	Delimiter          string                 `json:"delimiter"`
+	UnmappedColumns    []string               `json:"unmapped_columns"`
+	TotalRows          int                    `json:"total_rows"`
+	Imported           int                    `json:"imported"`
+	DuplicatesExisting int                    `json:"duplicates_existing"`
+	DuplicatesInFile   int                    `json:"duplicates_in_file"`
+	Invalid            int                    `json:"invalid"`
+	Flagged            int                    `json:"flagged"`
+	FirstMeasuredAt    *string                `json:"first_measured_at"`
+	LastMeasuredAt     *string                `json:"last_measured_at"`
+	Preview            []MeasurementImportRow `json:"preview"`
+	Errors             []MeasurementImportRow `json:"errors"`
+	FlaggedRows        []MeasurementImportRow `json:"flagged_rows"`
+}
+
+// ParseMeasurementCSV reads a scale app export. The delimiter (comma,
+// semicolon or tab) is taken from the header line. Problems with the file as
+// a whole are returned as ErrInvalidImport; problems with a row mark it invalid.
+func ParseMeasurementCSV(data []byte, opts MeasurementImportOptions) (*MeasurementImport, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	delimiter := detectCSVDelimiter(data)
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	var header []string
+	var records [][]string
+	var lines []int
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+		if isBlankRecord(record) {
+			continue
+		}
+		if header == nil {
+			header = record
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		records, lines = append(records, record), append(lines, line)
+		if len(records) > MaxImportRows {
+			return nil, fmt.Errorf("%w: the file has more than %d rows", ErrInvalidImport, MaxImportRows)
+		}
+	}
+	if header == nil {
+		return nil, fmt.Errorf("%w: the file is empty", ErrInvalidImport)
+	}
+
+	profile := opts.Profile
+	if profile == nil {
+		if profile = detectImportProfile(header); profile == nil {
+			return nil, fmt.Errorf("%w: the columns don't match a known scale app, map them with the custom profile", ErrInvalidImport)
+		}
+	}
+	columns, unmapped := mapImportColumns(header, *profile)
+	if _, ok := columns[ImportFieldDate]; !ok {
+		return nil, fmt.Errorf("%w: no date column found", ErrInvalidImport)
+	}
+	if _, ok := columns[ImportFieldBodyweight]; !ok {
+		return nil, fmt.Errorf("%w: no bodyweight column found", ErrInvalidImport)
+	}
+
+	dateOrder := opts.DateOrder
+	if dateOrder == "" && opts.DateFormat == "" {
+		dates := make([]string, 0, len(records))
+		for _, record := range records {
+			dates = append(dates, importCell(record, columns[ImportFieldDate].index))
+		}
+		dateOrder = DetectDateOrder(dates, profile.DateOrder)
+	}
+
+	loc := opts.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	parsed := &MeasurementImport{
+		Profile:         profile.Name,
+		DateOrder:       dateOrder,
+		Delimiter:       string(delimiter),
+		UnmappedColumns: unmapped,
+		Rows:            make([]MeasurementImportRow, 0, len(records)),
+	}
+	for i, record := range records {
+		row := parseImportRow(record, columns, opts, dateOrder, loc)
+		row.Line = lines[i]
+		parsed.Rows = append(parsed.Rows, row)
+	}
+	return parsed, nil
+}
+
+// importColumn is a mapped column and the weight unit its header names, if any
+type importColumn struct {
+	index int
+	unit  string
+}
+
+// parseImportRow turns one record into a measurement, collecting every problem
+func parseImportRow(record []string, columns map[string]importColumn, opts MeasurementImportOptions, dateOrder string, loc *time.Location) MeasurementImportRow {
+	row := MeasurementImportRow{Status: ImportRowNew}
+	fail := func(format string, args ...interface{}) {
+		row.Errors = append(row.Errors, fmt.Sprintf(format, args...))
+	}
+
+	dateValue := importCell(record, columns[ImportFieldDate].index)
+	if timeColumn, ok := columns[ImportFieldTime]; ok && timeColumn.index != columns[ImportFieldDate].index {
+		if timeValue := importCell(record, timeColumn.index); timeValue != "" {
+			dateValue += " " + timeValue
+		}
+	}
+	measuredAt, err := ParseImportDate(dateValue, loc, dateOrder, opts.DateFormat)
+	if err != nil {
+		fail("%v", err)
+	} else if measuredAt.Before(time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)) || measuredAt.After(time.Now().Add(24*time.Hour)) {
+		fail("date %s is out of range", measuredAt.Format(time.RFC3339))
+	}
+
+	// number reads a mapped column; masses are converted from the file's weight unit to kg
+	number := func(field string, mass bool) *float64 {
+		column, ok := columns[field]
+		if !ok {
+			return nil
+		}
+		value, unit, err := ParseImportNumber(importCell(record, column.index), opts.DecimalComma)
+		if err != nil {
+			fail("%s: %v", field, err)
+			return nil
+		}
+		if value == nil {
+			return nil
+		}
+		if mass {
+			if unit == "" {
+				unit = column.unit
+			}
+			if unit == "" {
+				unit = opts.WeightUnit
+			}
+			switch unit {
+			case "lb", "lbs":
+				converted := round2(*value * kgPerLb)
+				return &converted
+			case "", "kg", "kgs":
+			default:
+				fail("%s: unknown unit %q", field, unit)
+				return nil
+			}
+		}
+		rounded := round2(*value)
+		return &rounded
+	}
+
+	measurement := BodyMeasurement{MeasuredAt: measuredAt}
+	failures := len(row.Errors)
+	if bodyweight := number(ImportFieldBodyweight, true); bodyweight == nil {
+		if len(row.Errors) == failures {
+			fail("bodyweight is missing")
+		}
+	} else if *bodyweight <= 0 || *bodyweight > importMaxBodyweightKg {
+		fail("bodyweight %.2f kg is out of range", *bodyweight)
+	} else {
+		measurement.Bodyweight = *bodyweight
+	}
+
+	measurement.FatPercentage = number(ImportFieldFatPercentage, false)
+	measurement.ViceralFat = number(ImportFieldViceralFat, false)
+	measurement.MuscleMassKg = number(ImportFieldMuscleMass, true)
+	measurement.BoneMassKg = number(ImportFieldBoneMass, true)
+	measurement.BodyWaterPercentage = number(ImportFieldBodyWaterPercentage, false)
+	measurement.ScaleBmr = number(ImportFieldScaleBmr, false)
+	fatMass := number(ImportFieldFatMass, true)
+	waterMass := number(ImportFieldBodyWaterMass, true)
+	if metabolicAge := number(ImportFieldMetabolicAge, false); metabolicAge != nil {
+		age := int(*metabolicAge)
+		measurement.MetabolicAge = &age
+	}
+
+	if measurement.Bodyweight > 0 {
+		if measurement.FatPercentage == nil && fatMass != nil {
+			measurement.FatPercentage = ConvertOptional(fatMass, func(kg float64) float64 { return round2(kg / measurement.Bodyweight * 100) })
+		}
+		if measurement.BodyWaterPercentage == nil && waterMass != nil {
+			measurement.BodyWaterPercentage = ConvertOptional(waterMass, func(kg float64) float64 { return round2(kg / measurement.Bodyweight * 100) })
+		}
+		for field, mass := range map[string]*float64{ImportFieldMuscleMass: measurement.MuscleMassKg, ImportFieldBoneMass: measurement.BoneMassKg} {
+			if mass != nil && (*mass <= 0 || *mass > measurement.Bodyweight) {
+				fail("%s %.2f kg is not between 0 and the bodyweight", field, *mass)
+			}
+		}
+	}
+	for field, percentage := range map[string]*float64{ImportFieldFatPercentage: measurement.FatPercentage, ImportFieldBodyWaterPercentage: measurement.BodyWaterPercentage} {
+		if percentage != nil && (*percentage <= 0 || *percentage > 100) {
+			fail("%s %.2f%% is not between 0 and 100", field, *percentage)
+		}
+	}
+	for field, value := range map[string]*float64{ImportFieldViceralFat: measurement.ViceralFat, ImportFieldScaleBmr: measurement.ScaleBmr} {
+		if value != nil && *value <= 0 {
+			fail("%s must be greater than 0", field)
+		}
+	}
+	if measurement.MetabolicAge != nil && (*measurement.MetabolicAge <= 0 || *measurement.MetabolicAge > 120) {
+		fail("metabolic_age %d is not between 1 and 120", *measurement.MetabolicAge)
+	}
+
+	if len(row.Errors) > 0 {
+		sort.Strings(row.Errors)
+		row.Status = ImportRowInvalid
+		return row
+	}
+	row.Measurement = &measurement
+	return row
+}
+
+// MarkDuplicates flags rows measured in the same minute as an existing
+// measurement or an earlier row of the file; existing holds Unix minutes
+func (imp *MeasurementImport) MarkDuplicates(existing map[int64]bool) {
+	seen := map[int64]bool{}
+	for i := range imp.Rows {
+		row := &imp.Rows[i]
+		if row.Status != ImportRowNew {
+			continue
+		}
+		minute := ImportMinute(row.Measurement.MeasuredAt)
+		switch {
+		case existing[minute]:
+			row.Status = ImportRowDuplicate
+		case seen[minute]:
+			row.Status = ImportRowDuplicateInFile
+		default:
+			seen[minute] = true
+		}
+	}
+}
+
+// CheckPlausibility runs CheckMeasurement on the new rows in time order. Each
+// row is compared with stored, the user's measurements before the file, and
+// the unflagged rows of the file before it. A row with a value outside its
+// physiological bounds becomes invalid; a row that only changed too fast or
+// is an outlier is imported flagged for review, as when confirmed by hand.
+func (imp *MeasurementImport) CheckPlausibility(stored []BodyMeasurement) {
+	var order []int
+	for i, row := range imp.Rows {
+		if row.Status == ImportRowNew {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return imp.Rows[order[a]].Measurement.MeasuredAt.Before(imp.Rows[order[b]].Measurement.MeasuredAt)
+	})
+	sort.SliceStable(stored, func(a, b int) bool {
+		return stored[a].MeasuredAt.Before(stored[b].MeasuredAt)
+	})
+
+	// past holds the history so far oldest first; stored measurements are
+	// merged in as the rows reach their time
+	var past []BodyMeasurement
+	next := 0
+	for _, i := range order {
+		row := &imp.Rows[i]
+		measurement := row.Measurement
+		for next < len(stored) && stored[next].MeasuredAt.Before(measurement.MeasuredAt) {
+			past = append(past, stored[next])
+			next++
+		}
+
+		history := make([]BodyMeasurement, 0, MeasurementHistoryLimit)
+		for j := len(past) - 1; j >= 0 && len(history) < MeasurementHistoryLimit; j-- {
+			history = append(history, past[j])
+		}
+		warnings := CheckMeasurement(*measurement, history)
+		if len(warnings) == 0 {
+			past = append(past, *measurement)
+			continue
+		}
+
+		row.Warnings = warnings
+		for _, warning := range warnings {
+			if warning.Reason == WarningOutOfRange {
+				row.Errors = append(row.Errors, warning.Message)
+			}
+		}
+		if len(row.Errors) > 0 {
+			row.Status = ImportRowInvalid
+			row.Measurement = nil
+			continue
+		}
+		measurement.Flagged, measurement.FlagReasons = true, warnings
+	}
+}
+
+// TimeRange returns the earliest and latest timestamp of the valid rows; ok is false when there are none
+func (imp *MeasurementImport) TimeRange() (first, last time.Time, ok bool) {
+	for _, row := range imp.Rows {
+		if row.Measurement == nil {
+			continue
+		}
+		measuredAt := row.Measurement.MeasuredAt
+		if !ok || measuredAt.Before(first) {
+			first = measuredAt
+		}
+		if !ok || measuredAt.After(last) {
+			last = measuredAt
+		}
+		ok = true
+	}
+	return first, last, ok
+}
+
+// Summarize counts the rows by status. Imported counts the new rows, which
+// on a dry run is how many would be imported.
+func (imp *MeasurementImport) Summarize(dryRun bool) MeasurementImportSummary {
+	summary := MeasurementImportSummary{
+		DryRun:          dryRun,
+		Profile:         imp.Profile,
+		DateOrder:       imp.DateOrder,
+		Delimiter:       imp.Delimiter,
+		UnmappedColumns: imp.UnmappedColumns,
+		TotalRows:       len(imp.Rows),
+		Preview:         []MeasurementImportRow{},
+		Errors:          []MeasurementImportRow{},
+		FlaggedRows:     []MeasurementImportRow{},
+	}
+	if summary.UnmappedColumns == nil {
+		summary.UnmappedColumns = []string{}
+	}
+
+	var first, last *time.Time
+	for _, row := range imp.Rows {
+		switch row.Status {
+		case ImportRowNew:
+			summary.Imported++
+			if len(summary.Preview) < importPreviewRows {
+				summary.Preview = append(summary.Preview, row)
+			}
+			measuredAt := row.Measurement.MeasuredAt
+			if first == nil || measuredAt.Before(*first) {
+				first = &measuredAt
+			}
+			if last == nil || measuredAt.After(*last) {
+				last = &measuredAt
+			}
+			if row.Measurement.Flagged {
+				summary.Flagged++
+				if len(summary.FlaggedRows) < importReportedErrors {
+					summary.FlaggedRows = append(summary.FlaggedRows, row)
+				}
+			}
+		case ImportRowDuplicate:
+			summary.DuplicatesExisting++
+		case ImportRowDuplicateInFile:
+			summary.DuplicatesInFile++
+		case ImportRowInvalid:
+			summary.Invalid++
+			if len(summary.Errors) < importReportedErrors {
+				summary.Errors = append(summary.Errors, row)
+			}
+		}
+	}
+	if first != nil {
+		firstAt, lastAt := first.Format(time.RFC3339), last.Format(time.RFC3339)
+		summary.FirstMeasuredAt, summary.LastMeasuredAt = &firstAt, &lastAt
+	}
+	return summary
+}
+
+// AttachUnits makes the measurements and warnings of the reported rows marshal in units
+func (s *MeasurementImportSummary) AttachUnits(units UnitPreference) {
+	for _, rows := range [][]MeasurementImportRow{s.Preview, s.Errors, s.FlaggedRows} {
+		for i := range rows {
+			if rows[i].Measurement != nil {
+				rows[i].Measurement.Units = &units
+			}
+			rows[i].Warnings = rows[i].Warnings.InUnits(units)
+		}
+	}
+}
+
+// ImportMinute is the dedupe key of a timestamp: the Unix minute it falls in
+func ImportMinute(t time.Time) int64 {
+	return t.Unix() / 60
+}
+
+// detectCSVDelimiter picks the most frequent of comma, semicolon and tab on the first line
+func detectCSVDelimiter(data []byte) rune {
+	firstLine := data
+	if end := bytes.IndexByte(data, '\n'); end >= 0 {
+		firstLine = data[:end]
+	}
+	delimiter, best := ',', bytes.Count(firstLine, []byte(","))
+	for _, candidate := range []rune{';', '\t'} {
+		if count := bytes.Count(firstLine, []byte(string(candidate))); count > best {
+			delimiter, best = candidate, count
+		}
+	}
+	return delimiter
+}
+
+// detectImportProfile picks the profile matching the most headers; date and bodyweight must match
+func detectImportProfile(header []string) *MeasurementImportProfile {
+	names := make([]string, 0, len(MeasurementImportProfiles))
+	for name := range MeasurementImportProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var best *MeasurementImportProfile
+	bestCount := 0
+	for _, name := range names {
+		profile := MeasurementImportProfiles[name]
+		columns, _ := mapImportColumns(header, profile)
+		_, hasDate := columns[ImportFieldDate]
+		_, hasWeight := columns[ImportFieldBodyweight]
+		if hasDate && hasWeight && len(columns) > bestCount {
+			best, bestCount = &profile, len(columns)
+		}
+	}
+	return best
+}
+
+// mapImportColumns finds the column of each field of profile, returning the headers left unmapped
+func mapImportColumns(header []string, profile MeasurementImportProfile) (map[string]importColumn, []string) {
+	used := map[int]bool{}
+	columns := map[string]importColumn{}
+	for _, field := range importFields {
+		for _, alias := range profile.Columns[field] {
+			index := indexOfHeader(header, alias, used)
+			if index < 0 {
+				continue
+			}
+			columns[field] = importColumn{index: index, unit: headerWeightUnit(header[index])}
+			// A combined date-time column may be mapped as both date and time
+			if field != ImportFieldDate {
+				used[index] = true
+			}
+			break
+		}
+	}
+	if date, ok := columns[ImportFieldDate]; ok {
+		used[date.index] = true
+	}
+
+	var unmapped []string
+	for i, name := range header {
+		if !used[i] && strings.TrimSpace(name) != "" {
+			unmapped = append(unmapped, name)
+		}
+	}
+	return columns, unmapped
+}
+
+// indexOfHeader finds an unused header equal to name once normalized
+func indexOfHeader(header []string, name string, used map[int]bool) int {
+	wanted := normalizeHeader(name)
+	for i, candidate := range header {
+		if !used[i] && normalizeHeader(candidate) == wanted {
+			return i
+		}
+	}
+	return -1
+}
+
+// normalizeHeader keeps only the lowercased letters, digits and percent sign of a header
+func normalizeHeader(name string) string {
+	var normalized strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '%' {
+			normalized.WriteRune(r)
+		}
+	}
+	return normalized.String()
+}
+
+// headerWeightUnit returns the weight unit a header names, like "Weight (lb)"
+func headerWeightUnit(name string) string {
+	normalized := normalizeHeader(name)
+	switch {
+	case strings.HasSuffix(normalized, "lbs"), strings.HasSuffix(normalized, "lb"):
+		return "lb"
+	case strings.HasSuffix(normalized, "kg"):
+		return "kg"
+	}
+	return ""
+}
+
+// importCell returns a trimmed cell, empty when the row is short
+func importCell(record []string, index int) string {
+	if index < 0 || index >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[index])
+}
+
+func isBlankRecord(record []string) bool {
+	for _, cell := range record {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func isImportField(field string) bool {
+	for _, known := range importFields {
+		if field == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Import marks the rows of imp that duplicate a stored measurement, checks
+// the plausibility of the others and, unless dryRun, inserts the new ones in
+// one transaction. Rows are updated in place so imp.Summarize reports the outcome.
+func (r *bodyMeasurementRepository) Import(userId int, imp *MeasurementImport, dryRun bool) error {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// the stored measurements from the plausibility history window before the
+	// first row up to the last row, for dedupe and the plausibility checks
+	existing := map[int64]bool{}
+	var stored []BodyMeasurement
+	if first, last, ok := imp.TimeRange(); ok {
+		query := `SELECT
+		measurement_id, user_id, measured_at, ` + bodyMeasurementColumns + `
+		FROM body_measurement
+		WHERE user_id = $1 AND measured_at >= $2 AND measured_at < $3`
+		from := first.Truncate(time.Minute).AddDate(0, 0, -measurementHistoryDays)
+		err = tx.Select(&stored, query, userId, from, last.Truncate(time.Minute).Add(time.Minute))
+		if err != nil {
+			return err
+		}
+		for _, measurement := range stored {
+			existing[ImportMinute(measurement.MeasuredAt)] = true
+		}
+	}
+	imp.MarkDuplicates(existing)
+	imp.CheckPlausibility(stored)
+	if dryRun {
+		return nil
+	}
+
+	for _, row := range imp.Rows {
+		if row.Status != ImportRowNew {
+			continue
+		}
+		if _, err = insertBodyMeasurement(tx, userId, row.Measurement); err != nil {
+			return fmt.Errorf("error importing line %d: %w", row.Line, err)
+		}
+	}
+	return tx.Commit()
+}
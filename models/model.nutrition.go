@@ -1,49 +1,138 @@
 package models
 
 import (
+	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"time"
 
 	"github.com/bytedance/sonic"
+	"github.com/jmoiron/sqlx"
 )
 
+// NutritionTracker is one users_food_intake row. Extended nutrients are
+// optional: fiber, sugar and saturated fat in grams, sodium, cholesterol
+// and potassium in milligrams, and free-form vitamins/minerals in Micronutrients.
 type NutritionTracker struct {
-	UserId       int               `json:"user_id" db:"user_id"`
-	FoodId       int               `json:"food_id" db:"food_id"`
-	Category     NutritionCategory `json:"category" db:"category"`
-	CreatedAt    time.Time         `json:"created_at" db:"created_at"`
-	Fat          float64           `json:"fat" db:"fat"`
-	Protein      float64           `json:"protein" db:"protein"`
-	Carbohydrate float64           `json:"carbohydrate" db:"carbohydrate"`
-	Caloric      float64           `json:"caloric" db:"caloric"`
-	Name         string            `json:"name" db:"name"`
+	UserId         int               `json:"user_id" db:"user_id"`
+	FoodId         int               `json:"food_id" db:"food_id"`
+	Category       NutritionCategory `json:"category" db:"category"`
+	CreatedAt      time.Time         `json:"created_at" db:"created_at"`
+	Fat            float64           `json:"fat" db:"fat"`
+	Protein        float64           `json:"protein" db:"protein"`
+	Carbohydrate   float64           `json:"carbohydrate" db:"carbohydrate"`
+	Caloric        float64           `json:"caloric" db:"caloric"`
+	Name           string            `json:"name" db:"name"`
+	Fiber          *float64          `json:"fiber,omitempty" db:"fiber"`
+	Sugar          *float64          `json:"sugar,omitempty" db:"sugar"`
+	SaturatedFat   *float64          `json:"saturated_fat,omitempty" db:"saturated_fat"`
+	Sodium         *float64          `json:"sodium,omitempty" db:"sodium"`
+	Cholesterol    *float64          `json:"cholesterol,omitempty" db:"cholesterol"`
+	Potassium      *float64          `json:"potassium,omitempty" db:"potassium"`
+	Micronutrients NutrientMap       `json:"micronutrients,omitempty" db:"micronutrients"`
+
+	// FastingWarning is set by AttachFastingWarnings on entries just logged during an active fast
+	FastingWarning string `json:"fasting_warning,omitempty" db:"-"`
+	// Units, when set, are the units MarshalJSON writes the energy in
+	Units *UnitPreference `json:"-" db:"-"`
+}
+
+// nutritionTrackerColumns is the users_food_intake column list scanned into NutritionTracker
+const nutritionTrackerColumns = `user_id, food_id, category, created_at, fat,
+	protein, carbohydrate, caloric, name,
+	fiber, sugar, saturated_fat, sodium, cholesterol, potassium, micronutrients`
+
+// NutrientMap is a JSONB bag of vitamin/mineral amounts keyed by nutrient name
+type NutrientMap map[string]float64
+
+// Scan implements the sql.Scanner interface
+func (m *NutrientMap) Scan(value interface{}) error {
+	if value == nil {
+		*m = nil
+		return nil
+	}
+
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into NutrientMap", value)
+	}
+	return sonic.Unmarshal(data, m)
+}
+
+// Value implements the driver.Valuer interface
+func (m NutrientMap) Value() (driver.Value, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	data, err := sonic.Marshal(map[string]float64(m))
+	return string(data), err
 }
 
 // MarshalJSON : Overloads NutritionTracker
 func (a NutritionTracker) MarshalJSON() ([]byte, error) {
+	if a.Units != nil {
+		a.Caloric = a.Units.EnergyFromKcal(a.Caloric)
+	}
 	return sonic.Marshal(struct {
-		UserId       int               `json:"user_id"`
-		FoodId       int               `json:"food_id"`
-		Category     NutritionCategory `json:"category"`
-		CreatedAt    string            `json:"created_at"`
-		Fat          float64           `json:"fat"`
-		Protein      float64           `json:"protein"`
-		Carbohydrate float64           `json:"carbohydrate"`
-		Caloric      float64           `json:"caloric"`
-		Name         string            `json:"name"`
+		UserId         int               `json:"user_id"`
+		FoodId         int               `json:"food_id"`
+		Category       NutritionCategory `json:"category"`
+		CreatedAt      string            `json:"created_at"`
+		Fat            float64           `json:"fat"`
+		Protein        float64           `json:"protein"`
+		Carbohydrate   float64           `json:"carbohydrate"`
+		Caloric        float64           `json:"caloric"`
+		Name           string            `json:"name"`
+		Fiber          *float64          `json:"fiber,omitempty"`
+		Sugar          *float64          `json:"sugar,omitempty"`
+		SaturatedFat   *float64          `json:"saturated_fat,omitempty"`
+		Sodium         *float64          `json:"sodium,omitempty"`
+		Cholesterol    *float64          `json:"cholesterol,omitempty"`
+		Potassium      *float64          `json:"potassium,omitempty"`
+		Micronutrients NutrientMap       `json:"micronutrients,omitempty"`
+		FastingWarning string            `json:"fasting_warning,omitempty"`
+		Units          *UnitPreference   `json:"units,omitempty"`
 	}{
-		UserId:       a.UserId,
-		FoodId:       a.FoodId,
-		Category:     a.Category,
-		CreatedAt:    a.CreatedAt.Format(time.RFC3339),
-		Fat:          a.Fat,
-		Protein:      a.Protein,
-		Carbohydrate: a.Carbohydrate,
-		Caloric:      a.Caloric,
-		Name:         a.Name,
+		UserId:         a.UserId,
+		FoodId:         a.FoodId,
+		Category:       a.Category,
+		CreatedAt:      a.CreatedAt.Format(time.RFC3339),
+		Fat:            a.Fat,
+		Protein:        a.Protein,
+		Carbohydrate:   a.Carbohydrate,
+		Caloric:        a.Caloric,
+		Name:           a.Name,
+		Fiber:          a.Fiber,
+		Sugar:          a.Sugar,
+		SaturatedFat:   a.SaturatedFat,
+		Sodium:         a.Sodium,
+		Cholesterol:    a.Cholesterol,
+		Potassium:      a.Potassium,
+		Micronutrients: a.Micronutrients,
+		FastingWarning: a.FastingWarning,
+		Units:          a.Units,
 	})
 }
 
+// AttachIntakeUnits makes every intake marshal its energy in units
+func AttachIntakeUnits(intakes []NutritionTracker, units UnitPreference) {
+	for i := range intakes {
+		intakes[i].Units = &units
+	}
+}
+
+// AttachFastingWarnings warns on every intake logged during the active fast, which may be nil
+func AttachFastingWarnings(intakes []NutritionTracker, active *FastingSession) {
+	for i := range intakes {
+		intakes[i].FastingWarning = active.IntakeWarning(intakes[i].CreatedAt)
+	}
+}
+
 // NutritionCategory represents nutrition category, like breakfast, lunch, dinner, snack
 type NutritionCategory string
 
@@ -54,12 +143,85 @@ const (
 	NutritionCategorySnack     NutritionCategory = "snack"
 )
 
+// mealTimes is the local hour a category is logged at when only a date is known
+var mealTimes = map[NutritionCategory]int{
+	NutritionCategoryBreakfast: 7,
+	NutritionCategoryLunch:     12,
+	NutritionCategorySnack:     15,
+	NutritionCategoryDinner:    19,
+}
+
+// MealTimeOn returns the usual meal time of a category on the local date of day
+func MealTimeOn(day time.Time, category NutritionCategory) time.Time {
+	hour, ok := mealTimes[category]
+	if !ok {
+		hour = 12
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, 0, 0, 0, day.Location())
+}
+
+// FrequentFood is a food from the intake history with how often and how
+// recently it was logged; nutrient values are taken from the latest entry
+type FrequentFood struct {
+	NutritionTracker
+	TimesLogged  int       `json:"times_logged" db:"times_logged"`
+	LastLoggedAt time.Time `json:"last_logged_at" db:"last_logged_at"`
+}
+
+// MarshalJSON : Overloads FrequentFood
+func (a FrequentFood) MarshalJSON() ([]byte, error) {
+	if a.Units != nil {
+		a.Caloric = a.Units.EnergyFromKcal(a.Caloric)
+	}
+	return sonic.Marshal(struct {
+		Name           string            `json:"name"`
+		Category       NutritionCategory `json:"category"`
+		Fat            float64           `json:"fat"`
+		Protein        float64           `json:"protein"`
+		Carbohydrate   float64           `json:"carbohydrate"`
+		Caloric        float64           `json:"caloric"`
+		Fiber          *float64          `json:"fiber,omitempty"`
+		Sugar          *float64          `json:"sugar,omitempty"`
+		SaturatedFat   *float64          `json:"saturated_fat,omitempty"`
+		Sodium         *float64          `json:"sodium,omitempty"`
+		Cholesterol    *float64          `json:"cholesterol,omitempty"`
+		Potassium      *float64          `json:"potassium,omitempty"`
+		Micronutrients NutrientMap       `json:"micronutrients,omitempty"`
+		TimesLogged    int               `json:"times_logged"`
+		LastLoggedAt   string            `json:"last_logged_at"`
+		Units          *UnitPreference   `json:"units,omitempty"`
+	}{
+		Name:           a.Name,
+		Category:       a.Category,
+		Fat:            a.Fat,
+		Protein:        a.Protein,
+		Carbohydrate:   a.Carbohydrate,
+		Caloric:        a.Caloric,
+		Fiber:          a.Fiber,
+		Sugar:          a.Sugar,
+		SaturatedFat:   a.SaturatedFat,
+		Sodium:         a.Sodium,
+		Cholesterol:    a.Cholesterol,
+		Potassium:      a.Potassium,
+		Micronutrients: a.Micronutrients,
+		TimesLogged:    a.TimesLogged,
+		LastLoggedAt:   a.LastLoggedAt.Format(time.RFC3339),
+		Units:          a.Units,
+	})
+}
+
 type NutritionChartData struct {
 	UserId       int     `json:"user_id" db:"user_id"`
 	Fat          float64 `json:"total_fat" db:"total_fat"`
 	Protein      float64 `json:"total_protein" db:"total_protein"`
 	Carbohydrate float64 `json:"total_carbohydrate" db:"total_carbohydrate"`
 	Caloric      float64 `json:"total_caloric" db:"total_caloric"`
+	Fiber        float64 `json:"total_fiber" db:"total_fiber"`
+	Sugar        float64 `json:"total_sugar" db:"total_sugar"`
+	SaturatedFat float64 `json:"total_saturated_fat" db:"total_saturated_fat"`
+	Sodium       float64 `json:"total_sodium" db:"total_sodium"`
+	Cholesterol  float64 `json:"total_cholesterol" db:"total_cholesterol"`
+	Potassium    float64 `json:"total_potassium" db:"total_potassium"`
 	Period       string  `json:"time_slice_label" db:"time_slice_label"`
 }
 
@@ -77,8 +239,12 @@ type NutritionRepository interface {
 	UpdateTodayIntake(nutritionTracker *NutritionTracker) error
 	AddTodayIntake(nutritionTracker *NutritionTracker) error
 	FindUserTodayIntake(userID int) ([]NutritionTracker, error)
+	FindUserIntakeByDateRange(userID int, from, to string) ([]NutritionTracker, error)
+	CopyMeal(userID int, fromDate string, fromCategory NutritionCategory, toDate string, toCategory NutritionCategory) ([]NutritionTracker, error)
+	FindFrequentFoods(userID, days, limit int, sortBy string) ([]FrequentFood, error)
+	Bulk(userID int, mode BulkMode, ops []BulkOperation[NutritionTracker]) ([]BulkOutcome, error)
 
-	GetNutritionChartData(userID int) ([]NutritionChartData, error)
+	GetNutritionChartData(userID int, from, to string) ([]NutritionChartData, error)
 	GetNutritionAllTime(userID, limit, page int) ([]NutritionTracker, error)
 }
 
@@ -92,9 +258,7 @@ func (r *nutritionRepository) GetNutritionAllTime(userId, limit, page int) ([]Nu
 	limit = limit + 1
 
 	var measurements []NutritionTracker
-	query := `SELECT 
-	user_id, food_id, category, created_at, fat,
-	protein, carbohydrate, caloric, name 
+	query := `SELECT ` + nutritionTrackerColumns + `
  FROM users_food_intake 
  WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2 OFFSET $3`
 
@@ -103,34 +267,41 @@ func (r *nutritionRepository) GetNutritionAllTime(userId, limit, page int) ([]Nu
 }
 
 // / Overview Nutrition Handlers
-func (r *nutritionRepository) GetNutritionChartData(userID int) ([]NutritionChartData, error) {
-	db := GetDB().PostgreDBManager.RW
+// GetNutritionChartData returns one zero-filled daily bucket per local date
+// between from and to inclusive (YYYY-MM-DD), read from users_daily_nutrition.
+// It is served from the read-cache pool unless the aggregates were just rebuilt.
+func (r *nutritionRepository) GetNutritionChartData(userID int, from, to string) ([]NutritionChartData, error) {
+	rebuilt, err := ensureDailyAggregates(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error rebuilding daily aggregates: %w", err)
+	}
+	db := GetDB().PostgreDBManager.RC
+	if rebuilt {
+		db = GetDB().PostgreDBManager.RW
+	}
 	if db == nil {
 		return nil, fmt.Errorf("database connection is nil")
 	}
 
 	var query string = `SELECT
-    TO_CHAR(time_slice_date, 'YYYY-MM-DD') AS time_slice_label,
-    SUM(fat) AS total_fat,
-    SUM(protein) AS total_protein,
-    SUM(carbohydrate) AS total_carbohydrate,
-    SUM(caloric) AS total_caloric
-FROM (
-    SELECT
-        DATE(created_at AT TIME ZONE 'Asia/Makassar') AS time_slice_date,
-        fat,
-        protein,
-        carbohydrate,
-        caloric
-    FROM public.users_food_intake
-    WHERE user_id = $1
-      AND created_at AT TIME ZONE 'Asia/Makassar' >= (NOW() AT TIME ZONE 'Asia/Makassar') - INTERVAL '366 days'
-) AS T
-GROUP BY time_slice_date
-ORDER BY time_slice_date DESC`
+    TO_CHAR(D.time_slice_date, 'YYYY-MM-DD') AS time_slice_label,
+    COALESCE(A.fat, 0) AS total_fat,
+    COALESCE(A.protein, 0) AS total_protein,
+    COALESCE(A.carbohydrate, 0) AS total_carbohydrate,
+    COALESCE(A.caloric, 0) AS total_caloric,
+    COALESCE(A.fiber, 0) AS total_fiber,
+    COALESCE(A.sugar, 0) AS total_sugar,
+    COALESCE(A.saturated_fat, 0) AS total_saturated_fat,
+    COALESCE(A.sodium, 0) AS total_sodium,
+    COALESCE(A.cholesterol, 0) AS total_cholesterol,
+    COALESCE(A.potassium, 0) AS total_potassium
+FROM generate_series($2::date, $3::date, INTERVAL '1 day') AS D(time_slice_date)
+LEFT JOIN public.users_daily_nutrition AS A
+    ON A.user_id = $1 AND A.local_date = D.time_slice_date
+ORDER BY D.time_slice_date ASC`
 
 	var chartData []NutritionChartData
-	err := db.Select(&chartData, query, userID)
+	err = db.Select(&chartData, query, userID, from, to)
 	return chartData, err
 }
 
@@ -142,11 +313,33 @@ func (r *nutritionRepository) DeleteTodayIntake(userID, foodId int) error {
 		return fmt.Errorf("database connection is nil")
 	}
 
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err = deleteFoodIntake(tx, userID, foodId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// deleteFoodIntake deletes one food intake entry owned by userID and refreshes its day
+func deleteFoodIntake(e sqlx.Ext, userID, foodId int) (sql.Result, error) {
+	times, err := foodIntakeTimes(e, userID, foodId)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `DELETE FROM users_food_intake 
 	WHERE user_id = $1 
 	AND food_id = $2`
-	_, err := db.Exec(query, userID, foodId)
-	return err
+	result, err := e.Exec(query, userID, foodId)
+	if err != nil {
+		return nil, err
+	}
+	return result, refreshDailyNutrition(e, userID, times...)
 }
 
 // UpdateTodayIntake updates today's food intake for a user
@@ -156,36 +349,81 @@ func (r *nutritionRepository) UpdateTodayIntake(nutritionTracker *NutritionTrack
 		return fmt.Errorf("database connection is nil")
 	}
 
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err = updateFoodIntake(tx, nutritionTracker); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// updateFoodIntake overwrites the food intake entry identified by UserId and
+// FoodId and refreshes its day
+func updateFoodIntake(e sqlx.Ext, nutritionTracker *NutritionTracker) (sql.Result, error) {
 	query := `UPDATE users_food_intake SET 
 	fat = $1, protein = $2, carbohydrate = $3, category = $4,
-	caloric = $5, name = $6 WHERE user_id = $7 AND food_id = $8`
+	caloric = $5, name = $6, fiber = $7, sugar = $8, saturated_fat = $9,
+	sodium = $10, cholesterol = $11, potassium = $12, micronutrients = $13
+	WHERE user_id = $14 AND food_id = $15`
 
-	_, err := db.Exec(query, nutritionTracker.Fat, nutritionTracker.Protein,
+	result, err := e.Exec(query, nutritionTracker.Fat, nutritionTracker.Protein,
 		nutritionTracker.Carbohydrate, nutritionTracker.Category,
-		nutritionTracker.Caloric, nutritionTracker.Name, nutritionTracker.UserId, nutritionTracker.FoodId)
-	return err
+		nutritionTracker.Caloric, nutritionTracker.Name,
+		nutritionTracker.Fiber, nutritionTracker.Sugar, nutritionTracker.SaturatedFat,
+		nutritionTracker.Sodium, nutritionTracker.Cholesterol, nutritionTracker.Potassium,
+		nutritionTracker.Micronutrients, nutritionTracker.UserId, nutritionTracker.FoodId)
+	if err != nil {
+		return nil, err
+	}
+
+	times, err := foodIntakeTimes(e, nutritionTracker.UserId, nutritionTracker.FoodId)
+	if err != nil {
+		return nil, err
+	}
+	return result, refreshDailyNutrition(e, nutritionTracker.UserId, times...)
 }
 
-// AddTodayIntake adds today's food intake for a user
+// AddTodayIntake adds today's food intake for a user and fills FoodId and CreatedAt
 func (r *nutritionRepository) AddTodayIntake(nutritionTracker *NutritionTracker) error {
 	db := GetDB().PostgreDBManager.RW
 	if db == nil {
 		return fmt.Errorf("database connection is nil")
 	}
 
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	query := `INSERT INTO users_food_intake 
-	(user_id, category, created_at, fat, protein, carbohydrate, caloric, name) 
-	VALUES ($1, $2, NOW(), $3, $4, $5, $6, $7)`
+	(user_id, category, created_at, fat, protein, carbohydrate, caloric, name,
+	fiber, sugar, saturated_fat, sodium, cholesterol, potassium, micronutrients) 
+	VALUES ($1, $2, NOW(), $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+	RETURNING food_id, created_at`
 
-	_, err := db.Exec(query,
+	err = tx.QueryRowx(query,
 		nutritionTracker.UserId,
 		nutritionTracker.Category, nutritionTracker.Fat,
 		nutritionTracker.Protein, nutritionTracker.Carbohydrate,
-		nutritionTracker.Caloric, nutritionTracker.Name)
-	return err
+		nutritionTracker.Caloric, nutritionTracker.Name,
+		nutritionTracker.Fiber, nutritionTracker.Sugar, nutritionTracker.SaturatedFat,
+		nutritionTracker.Sodium, nutritionTracker.Cholesterol, nutritionTracker.Potassium,
+		nutritionTracker.Micronutrients).Scan(&nutritionTracker.FoodId, &nutritionTracker.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if err = refreshDailyNutrition(tx, nutritionTracker.UserId, nutritionTracker.CreatedAt); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-// FindUserTodayIntake retrieves the personal target for a given user ID
+// FindUserTodayIntake retrieves today's food intake, with "today" taken in the user's timezone
 func (r *nutritionRepository) FindUserTodayIntake(userID int) ([]NutritionTracker, error) {
 	db := GetDB().PostgreDBManager.RW
 	if db == nil {
@@ -193,14 +431,145 @@ func (r *nutritionRepository) FindUserTodayIntake(userID int) ([]NutritionTracke
 	}
 
 	var users []NutritionTracker
-	query := `SELECT 
-	user_id, food_id, category, created_at, fat,
-	protein, carbohydrate, caloric, name 
+	query := `SELECT ` + nutritionTrackerColumns + `
  FROM users_food_intake 
  WHERE user_id = $1 
- AND created_at AT TIME ZONE 'Asia/Makassar' >= CURRENT_DATE AT TIME ZONE 'Asia/Makassar'
- AND created_at AT TIME ZONE 'Asia/Makassar' < (CURRENT_DATE + INTERVAL '1 day') AT TIME ZONE 'Asia/Makassar'`
+ AND DATE(created_at AT TIME ZONE $2) = DATE(NOW() AT TIME ZONE $2)`
 
-	err := db.Select(&users, query, userID)
+	err := db.Select(&users, query, userID, resolveUserTimezone(userID))
 	return users, err
 }
+
+// FindUserIntakeByDateRange retrieves food intake whose local date (in the
+// user's timezone) falls between from and to inclusive, both YYYY-MM-DD
+func (r *nutritionRepository) FindUserIntakeByDateRange(userID int, from, to string) ([]NutritionTracker, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var intakes []NutritionTracker
+	query := `SELECT ` + nutritionTrackerColumns + `
+ FROM users_food_intake 
+ WHERE user_id = $1 
+ AND DATE(created_at AT TIME ZONE $2) BETWEEN $3::date AND $4::date
+ ORDER BY created_at ASC`
+
+	err := db.Select(&intakes, query, userID, resolveUserTimezone(userID), from, to)
+	return intakes, err
+}
+
+// insertFoodIntake inserts a food intake entry at its CreatedAt, fills FoodId
+// and refreshes its day
+func insertFoodIntake(q sqlx.Ext, nutritionTracker *NutritionTracker) error {
+	query := `INSERT INTO users_food_intake 
+	(user_id, category, created_at, fat, protein, carbohydrate, caloric, name,
+	fiber, sugar, saturated_fat, sodium, cholesterol, potassium, micronutrients) 
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+	RETURNING food_id`
+
+	err := q.QueryRowx(query,
+		nutritionTracker.UserId, nutritionTracker.Category, nutritionTracker.CreatedAt,
+		nutritionTracker.Fat, nutritionTracker.Protein, nutritionTracker.Carbohydrate,
+		nutritionTracker.Caloric, nutritionTracker.Name,
+		nutritionTracker.Fiber, nutritionTracker.Sugar, nutritionTracker.SaturatedFat,
+		nutritionTracker.Sodium, nutritionTracker.Cholesterol, nutritionTracker.Potassium,
+		nutritionTracker.Micronutrients).Scan(&nutritionTracker.FoodId)
+	if err != nil {
+		return fmt.Errorf("error adding food intake: %w", err)
+	}
+	return refreshDailyNutrition(q, nutritionTracker.UserId, nutritionTracker.CreatedAt)
+}
+
+// CopyMeal duplicates every entry of one category on fromDate into toCategory
+// on toDate, keeping each entry's time of day. Dates are local YYYY-MM-DD.
+func (r *nutritionRepository) CopyMeal(userID int, fromDate string, fromCategory NutritionCategory, toDate string, toCategory NutritionCategory) ([]NutritionTracker, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var copied []NutritionTracker
+	query := `INSERT INTO users_food_intake 
+	(user_id, category, created_at, fat, protein, carbohydrate, caloric, name,
+	fiber, sugar, saturated_fat, sodium, cholesterol, potassium, micronutrients)
+ SELECT user_id, $6, created_at + ($4::date - $3::date) * INTERVAL '1 day',
+	fat, protein, carbohydrate, caloric, name,
+	fiber, sugar, saturated_fat, sodium, cholesterol, potassium, micronutrients
+ FROM users_food_intake 
+ WHERE user_id = $1 
+ AND category = $5
+ AND DATE(created_at AT TIME ZONE $2) = $3::date
+ RETURNING ` + nutritionTrackerColumns
+
+	if err = tx.Select(&copied, query, userID, resolveUserTimezone(userID), fromDate, toDate, fromCategory, toCategory); err != nil {
+		return nil, err
+	}
+
+	times := make([]time.Time, len(copied))
+	for i := range copied {
+		times[i] = copied[i].CreatedAt
+	}
+	if err = refreshDailyNutrition(tx, userID, times...); err != nil {
+		return nil, err
+	}
+	return copied, tx.Commit()
+}
+
+// FindFrequentFoods lists distinct foods (by case-insensitive name) logged in
+// the last days days, ordered by sortBy: "frequent" (default) or "recent"
+func (r *nutritionRepository) FindFrequentFoods(userID, days, limit int, sortBy string) ([]FrequentFood, error) {
+	db := GetDB().PostgreDBManager.RC
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	orderBy := "times_logged DESC, last_logged_at DESC"
+	if sortBy == "recent" {
+		orderBy = "last_logged_at DESC, times_logged DESC"
+	}
+
+	var foods []FrequentFood
+	query := `SELECT ` + nutritionTrackerColumns + `, times_logged, last_logged_at
+ FROM (
+	SELECT *,
+		ROW_NUMBER() OVER (PARTITION BY LOWER(name) ORDER BY created_at DESC) AS recency_rank,
+		COUNT(*) OVER (PARTITION BY LOWER(name)) AS times_logged,
+		MAX(created_at) OVER (PARTITION BY LOWER(name)) AS last_logged_at
+	FROM users_food_intake 
+	WHERE user_id = $1 
+	AND created_at >= NOW() - make_interval(days => $2::int)
+ ) AS F
+ WHERE recency_rank = 1
+ ORDER BY ` + orderBy + `
+ LIMIT $3`
+
+	err := db.Select(&foods, query, userID, days, limit)
+	return foods, err
+}
+
+// Bulk applies a batch of food intake writes for userID. Created entries keep
+// their CreatedAt so entries logged offline land on the right day.
+func (r *nutritionRepository) Bulk(userID int, mode BulkMode, ops []BulkOperation[NutritionTracker]) ([]BulkOutcome, error) {
+	return runBulk(mode, ops, func(tx *sqlx.Tx, op BulkOperation[NutritionTracker]) (int, error) {
+		entry := op.Data
+		entry.UserId = userID
+		switch op.Action {
+		case BulkCreate:
+			err := insertFoodIntake(tx, &entry)
+			return entry.FoodId, err
+		case BulkUpdate:
+			entry.FoodId = op.Id
+			return op.Id, requireOneRow(updateFoodIntake(tx, &entry))
+		case BulkDelete:
+			return op.Id, requireOneRow(deleteFoodIntake(tx, userID, op.Id))
+		}
+		return 0, fmt.Errorf("unknown bulk action %q", op.Action)
+	})
+}
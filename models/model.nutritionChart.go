@@ -0,0 +1,47 @@
+package models
+
+// NutritionChartMetrics lists the metrics that can be selected on the nutrition chart
+var NutritionChartMetrics = []string{
+	"fat", "protein", "carbohydrate", "caloric",
+	"fiber", "sugar", "saturated_fat", "sodium", "cholesterol", "potassium",
+}
+
+// DefaultNutritionChartMetrics are charted when no metrics are requested
+var DefaultNutritionChartMetrics = []string{"fat", "protein", "carbohydrate", "caloric"}
+
+func (d NutritionChartData) label() string {
+	return d.Period
+}
+
+// metric returns the total for a metric name of NutritionChartMetrics
+func (d NutritionChartData) metric(name string) float64 {
+	switch name {
+	case "fat":
+		return d.Fat
+	case "protein":
+		return d.Protein
+	case "carbohydrate":
+		return d.Carbohydrate
+	case "caloric":
+		return d.Caloric
+	case "fiber":
+		return d.Fiber
+	case "sugar":
+		return d.Sugar
+	case "saturated_fat":
+		return d.SaturatedFat
+	case "sodium":
+		return d.Sodium
+	case "cholesterol":
+		return d.Cholesterol
+	case "potassium":
+		return d.Potassium
+	}
+	return 0
+}
+
+// BuildNutritionChartPoints turns ascending, zero-filled daily nutrition
+// buckets into chart points starting at from, newest first
+func BuildNutritionChartPoints(daily []NutritionChartData, from string, granularity ChartGranularity, metrics []string) []ChartPoint {
+	return buildChartPoints(daily, from, granularity, metrics)
+}
@@ -0,0 +1,397 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// NutritionTotals holds summed macro, energy and extended nutrient values.
+// Entries without an extended nutrient count as 0 towards it.
+type NutritionTotals struct {
+	Fat          float64 `json:"fat"`
+	Protein      float64 `json:"protein"`
+	Carbohydrate float64 `json:"carbohydrate"`
+	Caloric      float64 `json:"caloric"`
+	Fiber        float64 `json:"fiber"`
+	Sugar        float64 `json:"sugar"`
+	SaturatedFat float64 `json:"saturated_fat"`
+	Sodium       float64 `json:"sodium"`
+	Cholesterol  float64 `json:"cholesterol"`
+	Potassium    float64 `json:"potassium"`
+}
+
+// NutritionPercentOfGoal holds intake as a percentage of the personal target.
+// A field is nil when the matching target is not set.
+type NutritionPercentOfGoal struct {
+	Fat          *float64 `json:"fat"`
+	Protein      *float64 `json:"protein"`
+	Carbohydrate *float64 `json:"carbohydrate"`
+	Caloric      *float64 `json:"caloric"`
+	Fiber        *float64 `json:"fiber"`
+	Sugar        *float64 `json:"sugar"`
+	SaturatedFat *float64 `json:"saturated_fat"`
+	Sodium       *float64 `json:"sodium"`
+	Cholesterol  *float64 `json:"cholesterol"`
+	Potassium    *float64 `json:"potassium"`
+}
+
+// Statuses of an extended nutrient against its target
+const (
+	NutrientStatusUnder = "under"
+	NutrientStatusOver  = "over"
+)
+
+// NutrientTargetStatus compares the intake of one extended nutrient with its
+// target. A limit (sugar, saturated fat, sodium, cholesterol) is met while
+// intake is not over it; a goal (fiber, potassium) once intake is not under it.
+// DaysOffTarget is only set on weekly summaries: the logged days not met.
+type NutrientTargetStatus struct {
+	Nutrient      string   `json:"nutrient"`
+	Limit         bool     `json:"limit"`
+	Intake        float64  `json:"intake"`
+	Target        float64  `json:"target"`
+	PercentOfGoal *float64 `json:"percent_of_goal"`
+	Status        string   `json:"status"`
+	Met           bool     `json:"met"`
+	DaysOffTarget *int     `json:"days_off_target,omitempty"`
+}
+
+// extendedNutrient is an extended nutrient that can have a target
+type extendedNutrient struct {
+	name  string
+	limit bool
+	value func(t NutritionTotals) float64
+}
+
+// extendedNutrients lists the extended nutrients in the order they are reported
+var extendedNutrients = []extendedNutrient{
+	{"fiber", false, func(t NutritionTotals) float64 { return t.Fiber }},
+	{"sugar", true, func(t NutritionTotals) float64 { return t.Sugar }},
+	{"saturated_fat", true, func(t NutritionTotals) float64 { return t.SaturatedFat }},
+	{"sodium", true, func(t NutritionTotals) float64 { return t.Sodium }},
+	{"cholesterol", true, func(t NutritionTotals) float64 { return t.Cholesterol }},
+	{"potassium", false, func(t NutritionTotals) float64 { return t.Potassium }},
+}
+
+// MacroEnergySplit holds the share of macro energy coming from each macro,
+// using 9 kcal/g for fat and 4 kcal/g for protein and carbohydrate
+type MacroEnergySplit struct {
+	FatPercent          float64 `json:"fat_percent"`
+	ProteinPercent      float64 `json:"protein_percent"`
+	CarbohydratePercent float64 `json:"carbohydrate_percent"`
+}
+
+// DailyNutritionSummary is one day of intake compared against the personal target
+type DailyNutritionSummary struct {
+	Date             string                                `json:"date"`
+	EntryCount       int                                   `json:"entry_count"`
+	Categories       map[NutritionCategory]NutritionTotals `json:"categories"`
+	Totals           NutritionTotals                       `json:"totals"`
+	Target           NutritionTotals                       `json:"target"`
+	Remaining        NutritionTotals                       `json:"remaining"`
+	PercentOfGoal    NutritionPercentOfGoal                `json:"percent_of_goal"`
+	MacroEnergySplit MacroEnergySplit                      `json:"macro_energy_split"`
+	AdherenceScore   *float64                              `json:"adherence_score"`
+	NutrientTargets  []NutrientTargetStatus                `json:"nutrient_targets"`
+}
+
+// WeeklyNutritionSummary is seven daily summaries plus averages and adherence.
+// Averages are taken over logged days only; the adherence score counts a day
+// without any entry as 0 so skipped logging is visible. Days after today are
+// listed but not yet counted: ElapsedDays is how many of the seven have begun.
+type WeeklyNutritionSummary struct {
+	StartDate        string                  `json:"start_date"`
+	EndDate          string                  `json:"end_date"`
+	ElapsedDays      int                     `json:"elapsed_days"`
+	LoggedDays       int                     `json:"logged_days"`
+	Days             []DailyNutritionSummary `json:"days"`
+	Totals           NutritionTotals         `json:"totals"`
+	Averages         NutritionTotals         `json:"averages"`
+	Target           NutritionTotals         `json:"target"`
+	MacroEnergySplit MacroEnergySplit        `json:"macro_energy_split"`
+	AdherenceScore   *float64                `json:"adherence_score"`
+	NutrientTargets  []NutrientTargetStatus  `json:"nutrient_targets"`
+}
+
+// BuildDailyNutritionSummary sums the given intakes (all belonging to one day)
+// and compares them against the user's nutrition target
+func BuildDailyNutritionSummary(date string, intakes []NutritionTracker, target *UserTarget) DailyNutritionSummary {
+	summary := DailyNutritionSummary{
+		Date:       date,
+		EntryCount: len(intakes),
+		Categories: map[NutritionCategory]NutritionTotals{
+			NutritionCategoryBreakfast: {},
+			NutritionCategoryLunch:     {},
+			NutritionCategoryDinner:    {},
+			NutritionCategorySnack:     {},
+		},
+		Target: nutritionTargetTotals(target),
+	}
+
+	for _, intake := range intakes {
+		category := summary.Categories[intake.Category]
+		category.add(intake)
+		summary.Categories[intake.Category] = category
+		summary.Totals.add(intake)
+	}
+
+	for name, category := range summary.Categories {
+		summary.Categories[name] = category.rounded()
+	}
+	summary.Totals = summary.Totals.rounded()
+	summary.Remaining = summary.Target.minus(summary.Totals).rounded()
+	summary.PercentOfGoal = NutritionPercentOfGoal{
+		Fat:          percentOf(summary.Totals.Fat, summary.Target.Fat),
+		Protein:      percentOf(summary.Totals.Protein, summary.Target.Protein),
+		Carbohydrate: percentOf(summary.Totals.Carbohydrate, summary.Target.Carbohydrate),
+		Caloric:      percentOf(summary.Totals.Caloric, summary.Target.Caloric),
+		Fiber:        percentOf(summary.Totals.Fiber, summary.Target.Fiber),
+		Sugar:        percentOf(summary.Totals.Sugar, summary.Target.Sugar),
+		SaturatedFat: percentOf(summary.Totals.SaturatedFat, summary.Target.SaturatedFat),
+		Sodium:       percentOf(summary.Totals.Sodium, summary.Target.Sodium),
+		Cholesterol:  percentOf(summary.Totals.Cholesterol, summary.Target.Cholesterol),
+		Potassium:    percentOf(summary.Totals.Potassium, summary.Target.Potassium),
+	}
+	summary.MacroEnergySplit = macroEnergySplit(summary.Totals)
+	if len(intakes) > 0 {
+		summary.AdherenceScore = adherenceScore(summary.Totals, summary.Target)
+	}
+	summary.NutrientTargets = nutrientTargetStatuses(summary.Totals, summary.Target)
+
+	return summary
+}
+
+// BuildWeeklyNutritionSummary buckets intakes into the seven local days
+// starting at start and compares each day against the nutrition target in
+// force on it. The weekly Target is the one in force on the last day. Days
+// after today, the user's current local day, are left out of the adherence score.
+func BuildWeeklyNutritionSummary(start, today time.Time, intakes []NutritionTracker, targets TargetTimeline) WeeklyNutritionSummary {
+	loc := start.Location()
+	byDate := make(map[string][]NutritionTracker)
+	for _, intake := range intakes {
+		date := intake.CreatedAt.In(loc).Format(SQLDateFormat)
+		byDate[date] = append(byDate[date], intake)
+	}
+
+	summary := WeeklyNutritionSummary{
+		StartDate: start.Format(SQLDateFormat),
+		EndDate:   start.AddDate(0, 0, 6).Format(SQLDateFormat),
+		Days:      make([]DailyNutritionSummary, 0, 7),
+		Target:    nutritionTargetTotals(targets.On(start.AddDate(0, 0, 6))),
+	}
+
+	var adherenceSum float64
+	scoredDays := 0
+	for i := 0; i < 7; i++ {
+		current := start.AddDate(0, 0, i)
+		date := current.Format(SQLDateFormat)
+		day := BuildDailyNutritionSummary(date, byDate[date], targets.On(current))
+		summary.Days = append(summary.Days, day)
+		if current.After(today) {
+			continue
+		}
+		summary.ElapsedDays++
+
+		if day.EntryCount > 0 {
+			summary.LoggedDays++
+			summary.Totals = summary.Totals.plus(day.Totals)
+		}
+		if day.Target.hasGoal() {
+			scoredDays++
+			if day.AdherenceScore != nil {
+				adherenceSum += *day.AdherenceScore
+			}
+		}
+	}
+
+	summary.Totals = summary.Totals.rounded()
+	if summary.LoggedDays > 0 {
+		summary.Averages = summary.Totals.divided(float64(summary.LoggedDays)).rounded()
+	}
+	summary.MacroEnergySplit = macroEnergySplit(summary.Totals)
+	if scoredDays > 0 {
+		score := round2(adherenceSum / float64(scoredDays))
+		summary.AdherenceScore = &score
+	}
+
+	// the average of the logged days against the weekly target, with how many
+	// logged days missed the target in force on them
+	summary.NutrientTargets = nutrientTargetStatuses(summary.Averages, summary.Target)
+	for i := range summary.NutrientTargets {
+		status := &summary.NutrientTargets[i]
+		daysOff := 0
+		for _, day := range summary.Days {
+			if day.EntryCount == 0 {
+				continue
+			}
+			for _, dayStatus := range day.NutrientTargets {
+				if dayStatus.Nutrient == status.Nutrient && !dayStatus.Met {
+					daysOff++
+				}
+			}
+		}
+		status.DaysOffTarget = &daysOff
+	}
+
+	return summary
+}
+
+// nutritionTargetTotals maps the nutrition_* columns of users_target
+func nutritionTargetTotals(target *UserTarget) NutritionTotals {
+	if target == nil {
+		return NutritionTotals{}
+	}
+	return NutritionTotals{
+		Fat:          target.NutritionFat,
+		Protein:      target.NutritionProtein,
+		Carbohydrate: target.NutritionCarbs,
+		Caloric:      target.NutritionCaloric,
+		Fiber:        optionalValue(target.NutritionFiber),
+		Sugar:        optionalValue(target.NutritionSugar),
+		SaturatedFat: optionalValue(target.NutritionSaturatedFat),
+		Sodium:       optionalValue(target.NutritionSodium),
+		Cholesterol:  optionalValue(target.NutritionCholesterol),
+		Potassium:    optionalValue(target.NutritionPotassium),
+	}
+}
+
+// nutrientTargetStatuses compares every extended nutrient that has a target
+func nutrientTargetStatuses(totals, target NutritionTotals) []NutrientTargetStatus {
+	statuses := []NutrientTargetStatus{}
+	for _, nutrient := range extendedNutrients {
+		goal := nutrient.value(target)
+		if goal <= 0 {
+			continue
+		}
+		intake := nutrient.value(totals)
+		status := NutrientTargetStatus{
+			Nutrient:      nutrient.name,
+			Limit:         nutrient.limit,
+			Intake:        intake,
+			Target:        goal,
+			PercentOfGoal: percentOf(intake, goal),
+			Status:        NutrientStatusUnder,
+		}
+		if intake > goal {
+			status.Status = NutrientStatusOver
+		}
+		if nutrient.limit {
+			status.Met = intake <= goal
+		} else {
+			status.Met = intake >= goal
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+func (t *NutritionTotals) add(intake NutritionTracker) {
+	t.Fat += intake.Fat
+	t.Protein += intake.Protein
+	t.Carbohydrate += intake.Carbohydrate
+	t.Caloric += intake.Caloric
+	t.Fiber += optionalValue(intake.Fiber)
+	t.Sugar += optionalValue(intake.Sugar)
+	t.SaturatedFat += optionalValue(intake.SaturatedFat)
+	t.Sodium += optionalValue(intake.Sodium)
+	t.Cholesterol += optionalValue(intake.Cholesterol)
+	t.Potassium += optionalValue(intake.Potassium)
+}
+
+func (t NutritionTotals) plus(other NutritionTotals) NutritionTotals {
+	return t.combine(other, func(a, b float64) float64 { return a + b })
+}
+
+func (t NutritionTotals) minus(other NutritionTotals) NutritionTotals {
+	return t.combine(other, func(a, b float64) float64 { return a - b })
+}
+
+func (t NutritionTotals) divided(divisor float64) NutritionTotals {
+	return t.combine(NutritionTotals{}, func(a, _ float64) float64 { return a / divisor })
+}
+
+// combine applies op to every pair of matching fields
+func (t NutritionTotals) combine(other NutritionTotals, op func(a, b float64) float64) NutritionTotals {
+	return NutritionTotals{
+		Fat:          op(t.Fat, other.Fat),
+		Protein:      op(t.Protein, other.Protein),
+		Carbohydrate: op(t.Carbohydrate, other.Carbohydrate),
+		Caloric:      op(t.Caloric, other.Caloric),
+		Fiber:        op(t.Fiber, other.Fiber),
+		Sugar:        op(t.Sugar, other.Sugar),
+		SaturatedFat: op(t.SaturatedFat, other.SaturatedFat),
+		Sodium:       op(t.Sodium, other.Sodium),
+		Cholesterol:  op(t.Cholesterol, other.Cholesterol),
+		Potassium:    op(t.Potassium, other.Potassium),
+	}
+}
+
+func (t NutritionTotals) hasGoal() bool {
+	return t.Fat > 0 || t.Protein > 0 || t.Carbohydrate > 0 || t.Caloric > 0
+}
+
+func (t NutritionTotals) rounded() NutritionTotals {
+	return t.combine(NutritionTotals{}, func(a, _ float64) float64 { return round2(a) })
+}
+
+// adherenceScore rates 0-100 how close the totals are to every target that is
+// set; each target contributes max(0, 1 - |actual-target|/target)
+func adherenceScore(totals, target NutritionTotals) *float64 {
+	pairs := [][2]float64{
+		{totals.Caloric, target.Caloric},
+		{totals.Protein, target.Protein},
+		{totals.Carbohydrate, target.Carbohydrate},
+		{totals.Fat, target.Fat},
+	}
+
+	var sum float64
+	count := 0
+	for _, pair := range pairs {
+		if pair[1] <= 0 {
+			continue
+		}
+		sum += math.Max(0, 1-math.Abs(pair[0]-pair[1])/pair[1])
+		count++
+	}
+	if count == 0 {
+		return nil
+	}
+
+	score := round2(sum / float64(count) * 100)
+	return &score
+}
+
+func macroEnergySplit(totals NutritionTotals) MacroEnergySplit {
+	fat := totals.Fat * 9
+	protein := totals.Protein * 4
+	carbohydrate := totals.Carbohydrate * 4
+	energy := fat + protein + carbohydrate
+	if energy <= 0 {
+		return MacroEnergySplit{}
+	}
+	return MacroEnergySplit{
+		FatPercent:          round2(fat / energy * 100),
+		ProteinPercent:      round2(protein / energy * 100),
+		CarbohydratePercent: round2(carbohydrate / energy * 100),
+	}
+}
+
+func percentOf(value, goal float64) *float64 {
+	if goal <= 0 {
+		return nil
+	}
+	percent := round2(value / goal * 100)
+	return &percent
+}
+
+// optionalValue reads an optional amount, 0 when it is not set
+func optionalValue(value *float64) float64 {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
+
+func round2(value float64) float64 {
+	return math.Round(value*100) / 100
+}
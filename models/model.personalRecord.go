@@ -0,0 +1,484 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/jmoiron/sqlx"
+)
+
+// Kinds of personal record
+const (
+	PersonalRecordRepMax          = "rep_max"
+	PersonalRecordOneRepMax       = "e1rm"
+	PersonalRecordLongestDuration = "longest_duration"
+	PersonalRecordFastestTime     = "fastest_time"
+)
+
+// Where a personal record was set
+const (
+	PersonalRecordSourceWorkoutSet = "workout_set"
+	PersonalRecordSourceExcercise  = "excercise_record"
+)
+
+// How a fastest time was estimated
+const (
+	PersonalRecordEstimateSplitWindow = "split_window"
+	PersonalRecordEstimateAveragePace = "average_pace"
+)
+
+// personalRecordPaceTolerance is how far, as a share of a distance, a session
+// without splits may be from it for its average pace to give a fastest time
+const personalRecordPaceTolerance = 0.05
+
+// personalRecordRepCounts are the rep maxes tracked: the heaviest weight
+// lifted for at least that many reps
+var personalRecordRepCounts = []int{1, 3, 5, 8, 10, 12}
+
+// personalRecordDistance is a standard distance with a fastest time record
+type personalRecordDistance struct {
+	Name string
+	Km   float64
+}
+
+// personalRecordDistances are the standard distances of fastest time records
+var personalRecordDistances = []personalRecordDistance{
+	{"1k", 1},
+	{"1mi", 1.609344},
+	{"5k", 5},
+	{"10k", 10},
+	{"half_marathon", 21.0975},
+	{"marathon", 42.195},
+}
+
+// PersonalRecordExercise identifies an exercise across workouts and exercise
+// records: its catalog entry, or its name for exercises logged without one
+type PersonalRecordExercise struct {
+	CatalogId *int   `json:"catalog_id,omitempty"`
+	Name      string `json:"name"`
+}
+
+// Key is the exercise_key personal records of the exercise are stored under
+func (e PersonalRecordExercise) Key() string {
+	return ExerciseKey(e.CatalogId, e.Name)
+}
+
+// PersonalRecord is a best an exercise was improved to. Value is kg for rep
+// maxes and estimated one-rep maxes, minutes for the longest duration and
+// seconds for the fastest time over Distance. Estimate tells how a fastest time
+// was found: the quickest contiguous splits of an activity, or the average
+// pace of a session about as long as Distance. Previous is the record it beat.
+type PersonalRecord struct {
+	RecordId     int       `json:"record_id" db:"record_id"`
+	UserId       int       `json:"user_id" db:"user_id"`
+	ExerciseKey  string    `json:"exercise_key" db:"exercise_key"`
+	CatalogId    *int      `json:"catalog_id,omitempty" db:"catalog_id"`
+	ExerciseName string    `json:"exercise_name" db:"exercise_name"`
+	Kind         string    `json:"kind" db:"kind"`
+	Reps         *int      `json:"reps,omitempty" db:"reps"`
+	Distance     *string   `json:"distance,omitempty" db:"distance"`
+	Estimate     *string   `json:"estimate,omitempty" db:"estimate"`
+	Value        float64   `json:"value" db:"value"`
+	Previous     *float64  `json:"previous,omitempty" db:"previous_value"`
+	SourceType   string    `json:"source_type" db:"source_type"`
+	SourceId     int       `json:"source_id" db:"source_id"`
+	AchievedAt   time.Time `json:"achieved_at" db:"achieved_at"`
+
+	// Units, when set, are the units MarshalJSON writes weights in
+	Units *UnitPreference `json:"-" db:"-"`
+}
+
+// MarshalJSON : Overloads PersonalRecord
+func (a PersonalRecord) MarshalJSON() ([]byte, error) {
+	unit := "s"
+	switch a.Kind {
+	case PersonalRecordRepMax, PersonalRecordOneRepMax:
+		unit = UnitKg
+		if a.Units != nil {
+			unit = a.Units.Weight
+			a.Value = a.Units.WeightFromKg(a.Value)
+			a.Previous = ConvertOptional(a.Previous, a.Units.WeightFromKg)
+		}
+	case PersonalRecordLongestDuration:
+		unit = "min"
+	}
+	return sonic.Marshal(struct {
+		RecordId     int      `json:"record_id"`
+		CatalogId    *int     `json:"catalog_id,omitempty"`
+		ExerciseName string   `json:"exercise_name"`
+		Kind         string   `json:"kind"`
+		Reps         *int     `json:"reps,omitempty"`
+		Distance     *string  `json:"distance,omitempty"`
+		Estimate     *string  `json:"estimate,omitempty"`
+		Value        float64  `json:"value"`
+		Previous     *float64 `json:"previous,omitempty"`
+		Unit         string   `json:"unit"`
+		SourceType   string   `json:"source_type"`
+		SourceId     int      `json:"source_id"`
+		AchievedAt   string   `json:"achieved_at"`
+	}{
+		RecordId:     a.RecordId,
+		CatalogId:    a.CatalogId,
+		ExerciseName: a.ExerciseName,
+		Kind:         a.Kind,
+		Reps:         a.Reps,
+		Distance:     a.Distance,
+		Estimate:     a.Estimate,
+		Value:        a.Value,
+		Previous:     a.Previous,
+		Unit:         unit,
+		SourceType:   a.SourceType,
+		SourceId:     a.SourceId,
+		AchievedAt:   a.AchievedAt.Format(time.RFC3339),
+	})
+}
+
+// AttachPersonalRecordUnits makes every record marshal its weights in units
+func AttachPersonalRecordUnits(records []PersonalRecord, units UnitPreference) {
+	for i := range records {
+		records[i].Units = &units
+	}
+}
+
+// WorkoutPersonalRecordExercises returns the exercises of workouts, for
+// recomputing their records
+func WorkoutPersonalRecordExercises(workouts ...*Workout) []PersonalRecordExercise {
+	var exercises []PersonalRecordExercise
+	for _, workout := range workouts {
+		if workout == nil {
+			continue
+		}
+		for _, exercise := range workout.Exercises {
+			exercises = append(exercises, PersonalRecordExercise{CatalogId: exercise.CatalogId, Name: exercise.Name})
+		}
+	}
+	return exercises
+}
+
+// WorkoutSetIds returns the ids of every set of the workout
+func WorkoutSetIds(workout *Workout) map[int]bool {
+	ids := map[int]bool{}
+	for _, exercise := range workout.Exercises {
+		for _, set := range exercise.Sets {
+			ids[set.SetId] = true
+		}
+	}
+	return ids
+}
+
+// PersonalRecordsFrom returns the records set by the given sources
+func PersonalRecordsFrom(records []PersonalRecord, sourceType string, sourceIds map[int]bool) []PersonalRecord {
+	found := []PersonalRecord{}
+	for _, record := range records {
+		if record.SourceType == sourceType && sourceIds[record.SourceId] {
+			found = append(found, record)
+		}
+	}
+	return found
+}
+
+// personalRecordAttempt is one set or exercise record a personal record may
+// be set by: a strength set with Reps and WeightKg, or a session with Minutes,
+// DistanceKm and, when imported from an activity file, its Activity splits
+type personalRecordAttempt struct {
+	SourceType string           `db:"source_type"`
+	SourceId   int              `db:"source_id"`
+	AchievedAt time.Time        `db:"achieved_at"`
+	Reps       *int             `db:"reps"`
+	WeightKg   *float64         `db:"weight_kg"`
+	Warmup     bool             `db:"warmup"`
+	Minutes    *int             `db:"minute"`
+	DistanceKm *float64         `db:"distance_km"`
+	Activity   *ActivityMetrics `db:"activity"`
+}
+
+// personalRecordBest is one category a record is kept in
+type personalRecordBest struct {
+	kind     string
+	reps     *int
+	distance *string
+	estimate *string
+	value    float64
+	lower    bool
+}
+
+// buildPersonalRecords replays the attempts at an exercise in the order they
+// happened and returns every record set, oldest first. An attempt sets a record
+// when it strictly beats the best so far; ties keep the earlier record.
+func buildPersonalRecords(exercise PersonalRecordExercise, attempts []personalRecordAttempt) []PersonalRecord {
+	sort.SliceStable(attempts, func(i, j int) bool {
+		if !attempts[i].AchievedAt.Equal(attempts[j].AchievedAt) {
+			return attempts[i].AchievedAt.Before(attempts[j].AchievedAt)
+		}
+		return attempts[i].SourceId < attempts[j].SourceId
+	})
+
+	bests := map[string]*personalRecordBest{}
+	var records []PersonalRecord
+	beat := func(attempt personalRecordAttempt, candidate personalRecordBest) {
+		key := candidate.kind
+		if candidate.reps != nil {
+			key += fmt.Sprintf(":%d", *candidate.reps)
+		}
+		if candidate.distance != nil {
+			key += ":" + *candidate.distance
+		}
+		current, ok := bests[key]
+		if ok && (candidate.value == current.value || (candidate.value > current.value) == candidate.lower) {
+			return
+		}
+
+		record := PersonalRecord{
+			ExerciseKey:  exercise.Key(),
+			CatalogId:    exercise.CatalogId,
+			ExerciseName: exercise.Name,
+			Kind:         candidate.kind,
+			Reps:         candidate.reps,
+			Distance:     candidate.distance,
+			Estimate:     candidate.estimate,
+			Value:        candidate.value,
+			SourceType:   attempt.SourceType,
+			SourceId:     attempt.SourceId,
+			AchievedAt:   attempt.AchievedAt,
+		}
+		if ok {
+			previous := current.value
+			record.Previous = &previous
+		}
+		bests[key] = &candidate
+		records = append(records, record)
+	}
+
+	for _, attempt := range attempts {
+		if attempt.Reps != nil && attempt.WeightKg != nil && *attempt.WeightKg > 0 && !attempt.Warmup {
+			for _, reps := range personalRecordRepCounts {
+				if *attempt.Reps >= reps {
+					reps := reps
+					beat(attempt, personalRecordBest{kind: PersonalRecordRepMax, reps: &reps, value: *attempt.WeightKg})
+				}
+			}
+			if e1rm := EstimateOneRepMax(*attempt.WeightKg, *attempt.Reps, OneRepMaxEpley); e1rm != nil {
+				beat(attempt, personalRecordBest{kind: PersonalRecordOneRepMax, value: *e1rm})
+			}
+		}
+		if attempt.Minutes != nil && *attempt.Minutes > 0 {
+			beat(attempt, personalRecordBest{kind: PersonalRecordLongestDuration, value: float64(*attempt.Minutes)})
+		}
+		for _, distance := range personalRecordDistances {
+			if seconds, estimate, ok := fastestTime(attempt, distance.Km); ok {
+				name := distance.Name
+				beat(attempt, personalRecordBest{kind: PersonalRecordFastestTime, distance: &name, estimate: &estimate, value: round2(seconds), lower: true})
+			}
+		}
+	}
+	return records
+}
+
+// fastestTime estimates the seconds a session took over distanceKm. A session
+// with activity splits uses its quickest contiguous splits covering the
+// distance, the last of them counted pro rata. A session without splits uses
+// its average pace, but only when it is within personalRecordPaceTolerance of
+// the distance, as a longer session says little about its fastest stretch.
+func fastestTime(attempt personalRecordAttempt, distanceKm float64) (float64, string, bool) {
+	if attempt.Activity != nil && len(attempt.Activity.Splits) > 0 {
+		seconds, ok := fastestSplitWindow(attempt.Activity.Splits, distanceKm)
+		return seconds, PersonalRecordEstimateSplitWindow, ok
+	}
+	if attempt.Minutes == nil || *attempt.Minutes <= 0 || attempt.DistanceKm == nil || *attempt.DistanceKm <= 0 {
+		return 0, "", false
+	}
+	if math.Abs(*attempt.DistanceKm-distanceKm) > distanceKm*personalRecordPaceTolerance {
+		return 0, "", false
+	}
+	return float64(*attempt.Minutes) * 60 / *attempt.DistanceKm * distanceKm, PersonalRecordEstimateAveragePace, true
+}
+
+// fastestSplitWindow returns the least seconds any run of contiguous splits
+// took to cover distanceKm, and false when the splits are shorter than it
+func fastestSplitWindow(splits []ActivitySplit, distanceKm float64) (float64, bool) {
+	best, found := 0.0, false
+	for first := range splits {
+		covered, seconds := 0.0, 0.0
+		for _, split := range splits[first:] {
+			if split.DistanceKm <= 0 {
+				break
+			}
+			if covered+split.DistanceKm >= distanceKm {
+				seconds += split.Seconds * (distanceKm - covered) / split.DistanceKm
+				if !found || seconds < best {
+					best, found = seconds, true
+				}
+				break
+			}
+			covered += split.DistanceKm
+			seconds += split.Seconds
+		}
+	}
+	return best, found
+}
+
+// personalRecordRepository implements PersonalRecordRepository interface
+type personalRecordRepository struct{}
+
+// NewPersonalRecordRepository creates a new personal record repository
+func NewPersonalRecordRepository() PersonalRecordRepository {
+	return &personalRecordRepository{}
+}
+
+// PersonalRecordRepository defines the interface for personal record operations.
+// Records are derived from workouts and exercise records and are rebuilt per
+// exercise whenever one of those changes, so edits and deletes never leave a
+// record pointing at a lift that no longer holds it.
+type PersonalRecordRepository interface {
+	Recompute(userId int, exercises []PersonalRecordExercise) ([]PersonalRecord, error)
+	RecomputeAll(userId int) (int, error)
+	GetCurrent(userId int) ([]PersonalRecord, error)
+	GetTimeline(userId int, exercise PersonalRecordExercise) ([]PersonalRecord, error)
+}
+
+const personalRecordColumns = `record_id, user_id, exercise_key, catalog_id, exercise_name, kind, reps, distance,
+	estimate, value, previous_value, source_type, source_id, achieved_at`
+
+// Recompute rebuilds the records of the given exercises from scratch in one
+// transaction and returns them
+func (r *personalRecordRepository) Recompute(userId int, exercises []PersonalRecordExercise) ([]PersonalRecord, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	records, err := recomputePersonalRecords(tx, userId, exercises)
+	if err != nil {
+		return nil, err
+	}
+	return records, tx.Commit()
+}
+
+// RecomputeAll rebuilds the records of every exercise the user has logged and
+// returns how many records there are
+func (r *personalRecordRepository) RecomputeAll(userId int) (int, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exercises []struct {
+		CatalogId *int   `db:"catalog_id"`
+		Name      string `db:"name"`
+	}
+	query := `SELECT DISTINCT ON (COALESCE(catalog_id::text, LOWER(TRIM(name)))) catalog_id, name FROM (
+		SELECT e.catalog_id, e.name FROM workout_exercise e JOIN workout w ON w.workout_id = e.workout_id WHERE w.user_id = $1
+		UNION ALL
+		SELECT catalog_id, name FROM excercise_record WHERE user_id = $1 AND deleted_at IS NULL
+	) logged`
+	if err = tx.Select(&exercises, query, userId); err != nil {
+		return 0, err
+	}
+
+	if _, err = tx.Exec(`DELETE FROM personal_record WHERE user_id = $1`, userId); err != nil {
+		return 0, fmt.Errorf("error deleting personal records: %w", err)
+	}
+	keys := make([]PersonalRecordExercise, len(exercises))
+	for i, exercise := range exercises {
+		keys[i] = PersonalRecordExercise{CatalogId: exercise.CatalogId, Name: exercise.Name}
+	}
+	records, err := recomputePersonalRecords(tx, userId, keys)
+	if err != nil {
+		return 0, err
+	}
+	return len(records), tx.Commit()
+}
+
+// GetCurrent returns the standing record of every exercise, kind, rep count and distance
+func (r *personalRecordRepository) GetCurrent(userId int) ([]PersonalRecord, error) {
+	db := GetDB().PostgreDBManager.RC
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var records []PersonalRecord
+	query := `SELECT DISTINCT ON (exercise_key, kind, reps, distance) ` + personalRecordColumns + `
+ FROM personal_record WHERE user_id = $1
+ ORDER BY exercise_key, kind, reps, distance, achieved_at DESC, record_id DESC`
+	err := db.Select(&records, query, userId)
+	return records, err
+}
+
+// GetTimeline returns every record an exercise was improved to, oldest first
+func (r *personalRecordRepository) GetTimeline(userId int, exercise PersonalRecordExercise) ([]PersonalRecord, error) {
+	db := GetDB().PostgreDBManager.RC
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var records []PersonalRecord
+	query := `SELECT ` + personalRecordColumns + `
+ FROM personal_record WHERE user_id = $1 AND exercise_key = $2
+ ORDER BY achieved_at ASC, record_id ASC`
+	err := db.Select(&records, query, userId, exercise.Key())
+	return records, err
+}
+
+// recomputePersonalRecords replaces the stored records of each exercise with
+// ones rebuilt from its current sets and exercise records
+func recomputePersonalRecords(tx *sqlx.Tx, userId int, exercises []PersonalRecordExercise) ([]PersonalRecord, error) {
+	seen := map[string]bool{}
+	records := []PersonalRecord{}
+	for _, exercise := range exercises {
+		key := exercise.Key()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		var attempts []personalRecordAttempt
+		// A catalog exercise is matched by its catalog id, anything else by name
+		query := `SELECT 'workout_set' AS source_type, s.set_id AS source_id, w.started_at AS achieved_at,
+		s.reps, s.weight_kg, s.warmup, NULL::int AS minute, NULL::numeric AS distance_km,
+		NULL::jsonb AS activity
+	FROM workout_set s
+	JOIN workout_exercise e ON e.workout_exercise_id = s.workout_exercise_id
+	JOIN workout w ON w.workout_id = e.workout_id
+	WHERE w.user_id = $1 AND (e.catalog_id = $2::int OR ($2::int IS NULL AND e.catalog_id IS NULL AND LOWER(TRIM(e.name)) = $3))
+	UNION ALL
+	SELECT 'excercise_record', excercise_id, record_at, NULL, NULL, FALSE, minute, distance_km, activity
+	FROM excercise_record
+	WHERE user_id = $1 AND deleted_at IS NULL AND (catalog_id = $2::int OR ($2::int IS NULL AND catalog_id IS NULL AND LOWER(TRIM(name)) = $3))`
+		if err := tx.Select(&attempts, query, userId, exercise.CatalogId, normalizedExerciseName(exercise.Name)); err != nil {
+			return nil, fmt.Errorf("error reading personal record attempts: %w", err)
+		}
+
+		if _, err := tx.Exec(`DELETE FROM personal_record WHERE user_id = $1 AND exercise_key = $2`, userId, key); err != nil {
+			return nil, fmt.Errorf("error deleting personal records: %w", err)
+		}
+		insert := `INSERT INTO personal_record
+		(user_id, exercise_key, catalog_id, exercise_name, kind, reps, distance, estimate, value, previous_value, source_type, source_id, achieved_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING record_id`
+		for _, record := range buildPersonalRecords(exercise, attempts) {
+			record.UserId = userId
+			err := tx.QueryRowx(insert, userId, record.ExerciseKey, record.CatalogId, record.ExerciseName, record.Kind,
+				record.Reps, record.Distance, record.Estimate, record.Value, record.Previous, record.SourceType, record.SourceId,
+				record.AchievedAt).Scan(&record.RecordId)
+			if err != nil {
+				return nil, fmt.Errorf("error creating personal record: %w", err)
+			}
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
@@ -0,0 +1,149 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBuildPersonalRecords(t *testing.T) {
+	start := time.Date(2026, 3, 2, 7, 0, 0, 0, time.UTC)
+	lift := func(id, day, reps int, weightKg float64, warmup bool) personalRecordAttempt {
+		return personalRecordAttempt{SourceType: PersonalRecordSourceWorkoutSet, SourceId: id, AchievedAt: start.AddDate(0, 0, day), Reps: &reps, WeightKg: &weightKg, Warmup: warmup}
+	}
+	session := func(id, day, minutes int, distanceKm float64) personalRecordAttempt {
+		return personalRecordAttempt{SourceType: PersonalRecordSourceExcercise, SourceId: id, AchievedAt: start.AddDate(0, 0, day), Minutes: &minutes, DistanceKm: &distanceKm}
+	}
+	activity := func(id, day, minutes int, distanceKm float64, splits ...ActivitySplit) personalRecordAttempt {
+		attempt := session(id, day, minutes, distanceKm)
+		attempt.Activity = &ActivityMetrics{DistanceKm: distanceKm, Splits: splits}
+		return attempt
+	}
+	split := func(distanceKm, seconds float64) ActivitySplit {
+		return ActivitySplit{DistanceKm: distanceKm, Seconds: seconds}
+	}
+
+	tests := []struct {
+		name     string
+		attempts []personalRecordAttempt
+		want     []string
+	}{
+		{
+			name: "strict improvements in the order lifted",
+			attempts: []personalRecordAttempt{
+				lift(4, 3, 1, 90, false),
+				lift(2, 1, 3, 100, false),
+				lift(1, 0, 1, 100, false),
+			},
+			want: []string{
+				"rep_max:1=100@1",
+				"e1rm=100@1",
+				"rep_max:3=100@2",
+				"e1rm=110<100@2",
+			},
+		},
+		{
+			name: "warmups are skipped",
+			attempts: []personalRecordAttempt{
+				lift(1, 0, 1, 60, false),
+				lift(2, 1, 1, 80, true),
+			},
+			want: []string{"rep_max:1=60@1", "e1rm=60@1"},
+		},
+		{
+			name: "ties keep the earlier record",
+			attempts: []personalRecordAttempt{
+				lift(5, 0, 1, 50, false),
+				lift(4, 0, 1, 50, false),
+				lift(6, 1, 1, 50, false),
+			},
+			want: []string{"rep_max:1=50@4", "e1rm=50@4"},
+		},
+		{
+			name: "fastest times go down, durations go up",
+			attempts: []personalRecordAttempt{
+				session(1, 0, 30, 5),
+				session(2, 1, 20, 5),
+				session(3, 2, 60, 8),
+			},
+			want: []string{
+				"longest_duration=30@1",
+				"fastest_time:5k=1800~average_pace@1",
+				"fastest_time:5k=1200<1800~average_pace@2",
+				"longest_duration=60<30@3",
+			},
+		},
+		{
+			name: "average pace only for sessions about as long as the distance",
+			attempts: []personalRecordAttempt{
+				session(1, 0, 25, 4.9),
+				session(2, 1, 50, 10.6),
+			},
+			want: []string{
+				"longest_duration=25@1",
+				"fastest_time:5k=1530.61~average_pace@1",
+				"longest_duration=50<25@2",
+			},
+		},
+		{
+			name: "quickest contiguous splits, the last pro rata",
+			attempts: []personalRecordAttempt{
+				activity(1, 0, 27, 5.5, split(1, 300), split(1, 240), split(1, 260), split(1, 250), split(1, 400), split(0.5, 150)),
+			},
+			want: []string{
+				"longest_duration=27@1",
+				"fastest_time:1k=240~split_window@1",
+				"fastest_time:1mi=398.43~split_window@1",
+				"fastest_time:5k=1450~split_window@1",
+			},
+		},
+		{
+			name: "splits shorter than the distance set no record",
+			attempts: []personalRecordAttempt{
+				activity(1, 0, 5, 1.5, split(1, 200), split(0.5, 100)),
+			},
+			want: []string{
+				"longest_duration=5@1",
+				"fastest_time:1k=200~split_window@1",
+			},
+		},
+		{
+			name: "no attempts",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exercise := PersonalRecordExercise{Name: "Bench Press"}
+			var got []string
+			for _, record := range buildPersonalRecords(exercise, tt.attempts) {
+				if record.ExerciseKey != "name:bench press" {
+					t.Errorf("ExerciseKey = %s, want name:bench press", record.ExerciseKey)
+				}
+				got = append(got, describePersonalRecord(record))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildPersonalRecords() =\n%v\nwant\n%v", got, tt.want)
+			}
+		})
+	}
+}
+
+// describePersonalRecord writes a record as kind[:reps|:distance]=value[<previous][~estimate]@source
+func describePersonalRecord(record PersonalRecord) string {
+	out := record.Kind
+	if record.Reps != nil {
+		out += fmt.Sprintf(":%d", *record.Reps)
+	}
+	if record.Distance != nil {
+		out += ":" + *record.Distance
+	}
+	out += fmt.Sprintf("=%v", record.Value)
+	if record.Previous != nil {
+		out += fmt.Sprintf("<%v", *record.Previous)
+	}
+	if record.Estimate != nil {
+		out += "~" + *record.Estimate
+	}
+	return out + fmt.Sprintf("@%d", record.SourceId)
+}
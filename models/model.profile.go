@@ -0,0 +1,324 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// Sex selects the sex-specific constants of the BMR formulas
+type Sex string
+
+const (
+	SexMale   Sex = "male"
+	SexFemale Sex = "female"
+)
+
+// ActivityLevel is the everyday activity outside of logged exercise
+type ActivityLevel string
+
+const (
+	ActivitySedentary  ActivityLevel = "sedentary"
+	ActivityLight      ActivityLevel = "light"
+	ActivityModerate   ActivityLevel = "moderate"
+	ActivityActive     ActivityLevel = "active"
+	ActivityVeryActive ActivityLevel = "very_active"
+)
+
+// activityMultipliers turn BMR into TDEE for each ActivityLevel
+var activityMultipliers = map[ActivityLevel]float64{
+	ActivitySedentary:  1.2,
+	ActivityLight:      1.375,
+	ActivityModerate:   1.55,
+	ActivityActive:     1.725,
+	ActivityVeryActive: 1.9,
+}
+
+// WeightGoal is the direction the user wants their bodyweight to move
+type WeightGoal string
+
+const (
+	WeightGoalLose     WeightGoal = "lose"
+	WeightGoalMaintain WeightGoal = "maintain"
+	WeightGoalGain     WeightGoal = "gain"
+)
+
+const (
+	// MinimumCaloricMale and MinimumCaloricFemale are the lowest daily calories ever suggested
+	MinimumCaloricMale   = 1500.0
+	MinimumCaloricFemale = 1200.0
+	// moderateExerciseMET is the intensity weekly exercise calories are estimated at
+	moderateExerciseMET = 5.0
+)
+
+// UserProfile is the users_profile row the target recommendation is calculated from.
+// GoalRateKg is the weekly change in kg aimed for when Goal is lose or gain.
+type UserProfile struct {
+	UserId        int            `json:"user_id" db:"user_id"`
+	Sex           *Sex           `json:"sex" db:"sex"`
+	BirthDate     *time.Time     `json:"birth_date" db:"birth_date"`
+	HeightCm      *float64       `json:"height_cm" db:"height_cm"`
+	ActivityLevel *ActivityLevel `json:"activity_level" db:"activity_level"`
+	Goal          WeightGoal     `json:"goal" db:"goal"`
+	GoalRateKg    float64        `json:"goal_rate_kg" db:"goal_rate_kg"`
+	// Units, when set, are the units MarshalJSON writes the height and goal rate in
+	Units *UnitPreference `json:"-" db:"-"`
+}
+
+// MarshalJSON : Overloads UserProfile
+func (p UserProfile) MarshalJSON() ([]byte, error) {
+	var birthDate *string
+	if p.BirthDate != nil {
+		date := p.BirthDate.Format(SQLDateFormat)
+		birthDate = &date
+	}
+	if p.Units != nil {
+		p.HeightCm = ConvertOptional(p.HeightCm, p.Units.LengthFromCm)
+		p.GoalRateKg = p.Units.WeightFromKg(p.GoalRateKg)
+	}
+	return sonic.Marshal(struct {
+		UserId        int             `json:"user_id"`
+		Sex           *Sex            `json:"sex"`
+		BirthDate     *string         `json:"birth_date"`
+		HeightCm      *float64        `json:"height_cm"`
+		ActivityLevel *ActivityLevel  `json:"activity_level"`
+		Goal          WeightGoal      `json:"goal"`
+		GoalRateKg    float64         `json:"goal_rate_kg"`
+		Units         *UnitPreference `json:"units,omitempty"`
+	}{
+		UserId:        p.UserId,
+		Sex:           p.Sex,
+		BirthDate:     birthDate,
+		HeightCm:      p.HeightCm,
+		ActivityLevel: p.ActivityLevel,
+		Goal:          p.Goal,
+		GoalRateKg:    p.GoalRateKg,
+		Units:         p.Units,
+	})
+}
+
+// AgeOn returns the age in whole years on the given day, nil without a birth date
+func (p UserProfile) AgeOn(day time.Time) *int {
+	if p.BirthDate == nil {
+		return nil
+	}
+	age := day.Year() - p.BirthDate.Year()
+	if day.Month() < p.BirthDate.Month() || (day.Month() == p.BirthDate.Month() && day.Day() < p.BirthDate.Day()) {
+		age--
+	}
+	return &age
+}
+
+// TargetRecommendation is a suggested users_target derived from the profile and
+// latest body measurement. Missing lists the inputs that prevented a suggestion;
+// when it is not empty Target is nil.
+type TargetRecommendation struct {
+	Formula       string      `json:"formula,omitempty"`
+	Age           *int        `json:"age,omitempty"`
+	Bodyweight    *float64    `json:"bodyweight,omitempty"`
+	FatPercentage *float64    `json:"fat_percentage,omitempty"`
+	BMR           float64     `json:"bmr"`
+	TDEE          float64     `json:"tdee"`
+	DailyDelta    float64     `json:"daily_delta"`
+	Target        *UserTarget `json:"target"`
+	Missing       []string    `json:"missing"`
+	Notes         []string    `json:"notes"`
+
+	Units *UnitPreference `json:"units,omitempty"`
+}
+
+// AttachUnits converts the bodyweight and calories, and makes the target marshal in units
+func (r *TargetRecommendation) AttachUnits(units UnitPreference) {
+	r.Units = &units
+	r.Bodyweight = ConvertOptional(r.Bodyweight, units.WeightFromKg)
+	r.BMR = units.EnergyFromKcal(r.BMR)
+	r.TDEE = units.EnergyFromKcal(r.TDEE)
+	r.DailyDelta = units.EnergyFromKcal(r.DailyDelta)
+	if r.Target != nil {
+		r.Target.Units = &units
+	}
+}
+
+// BuildTargetRecommendation calculates BMR with Katch-McArdle when a body fat
+// percentage is known and Mifflin-St Jeor otherwise, multiplies it by the activity
+// level into TDEE and shifts it by the weekly goal rate. Macros follow from the
+// calories: protein per kg of bodyweight, 25% fat and the remainder carbohydrate.
+func BuildTargetRecommendation(profile UserProfile, latest *BodyMeasurement, today time.Time) TargetRecommendation {
+	recommendation := TargetRecommendation{Missing: []string{}, Notes: []string{}}
+	if profile.Sex == nil {
+		recommendation.Missing = append(recommendation.Missing, "sex")
+	}
+	if profile.BirthDate == nil {
+		recommendation.Missing = append(recommendation.Missing, "birth_date")
+	}
+	if profile.HeightCm == nil {
+		recommendation.Missing = append(recommendation.Missing, "height_cm")
+	}
+	if profile.ActivityLevel == nil {
+		recommendation.Missing = append(recommendation.Missing, "activity_level")
+	}
+	if latest == nil {
+		recommendation.Missing = append(recommendation.Missing, "bodyweight")
+	}
+	if len(recommendation.Missing) > 0 {
+		return recommendation
+	}
+
+	weight := latest.Bodyweight
+	recommendation.Bodyweight = &weight
+	recommendation.Age = profile.AgeOn(today)
+
+	var bmr float64
+	if latest.FatPercentage != nil && *latest.FatPercentage > 0 {
+		recommendation.Formula = "katch_mcardle"
+		recommendation.FatPercentage = latest.FatPercentage
+		bmr = 370 + 21.6*weight*(1-*latest.FatPercentage/100)
+	} else {
+		recommendation.Formula = "mifflin_st_jeor"
+		bmr = 10*weight + 6.25**profile.HeightCm - 5*float64(*recommendation.Age)
+		if *profile.Sex == SexMale {
+			bmr += 5
+		} else {
+			bmr -= 161
+		}
+	}
+	recommendation.BMR = math.Round(bmr)
+	recommendation.TDEE = math.Round(bmr * activityMultipliers[*profile.ActivityLevel])
+
+	switch profile.Goal {
+	case WeightGoalLose:
+		recommendation.DailyDelta = -math.Round(profile.GoalRateKg * KcalPerKgBodyweight / 7)
+	case WeightGoalGain:
+		recommendation.DailyDelta = math.Round(profile.GoalRateKg * KcalPerKgBodyweight / 7)
+	}
+
+	caloric := recommendation.TDEE + recommendation.DailyDelta
+	minimum := MinimumCaloricFemale
+	if *profile.Sex == SexMale {
+		minimum = MinimumCaloricMale
+	}
+	if caloric < minimum {
+		caloric = minimum
+		recommendation.DailyDelta = caloric - recommendation.TDEE
+		recommendation.Notes = append(recommendation.Notes,
+			fmt.Sprintf("calories raised to the %.0f kcal minimum, the goal rate will be slower than requested", minimum))
+	}
+
+	recommendation.Target = recommendedTarget(profile, weight, caloric)
+	return recommendation
+}
+
+// recommendedTarget fills the nutrition and weekly exercise fields of a users_target
+func recommendedTarget(profile UserProfile, weight, caloric float64) *UserTarget {
+	proteinPerKg := 1.6
+	switch profile.Goal {
+	case WeightGoalLose:
+		proteinPerKg = 2.0
+	case WeightGoalGain:
+		proteinPerKg = 1.8
+	}
+	protein := round2(weight * proteinPerKg)
+	fat := round2(caloric * 0.25 / 9)
+	carbs := round2(math.Max(0, caloric-protein*4-fat*9) / 4)
+
+	fiber := round2(caloric / 1000 * 14)
+	sugar := round2(caloric * 0.10 / 4)
+	saturatedFat := round2(caloric * 0.10 / 9)
+	sodium := 2300.0
+	cholesterol := 300.0
+	potassium := 2600.0
+	if *profile.Sex == SexMale {
+		potassium = 3400
+	}
+
+	// WHO guidance: 150 minutes of moderate activity a week and two strength
+	// sessions, with more cardio while losing and more lifting while gaining
+	target := &UserTarget{
+		UserId:                      profile.UserId,
+		NutritionCaloric:            caloric,
+		NutritionProtein:            protein,
+		NutritionCarbs:              carbs,
+		NutritionFat:                fat,
+		NutritionFiber:              &fiber,
+		NutritionSugar:              &sugar,
+		NutritionSaturatedFat:       &saturatedFat,
+		NutritionSodium:             &sodium,
+		NutritionCholesterol:        &cholesterol,
+		NutritionPotassium:          &potassium,
+		WeeklyExerciseMinutes:       150,
+		WeeklyExcerciseSessions:     4,
+		WeeklyWeightLiftingSessions: 2,
+		WeeklyCardioMinutes:         90,
+	}
+	switch profile.Goal {
+	case WeightGoalLose:
+		target.WeeklyExerciseMinutes = 225
+		target.WeeklyExcerciseSessions = 5
+		target.WeeklyCardioMinutes = 150
+	case WeightGoalGain:
+		target.WeeklyWeightLiftingSessions = 3
+		target.WeeklyCardioMinutes = 60
+	}
+	// kcal per minute = MET × 3.5 × kg / 200
+	target.WeeklyExcerciseCaloric = int(math.Round(float64(target.WeeklyExerciseMinutes) * moderateExerciseMET * 3.5 * weight / 200))
+	return target
+}
+
+// FindUserProfile retrieves the profile of a user, a maintain goal with every
+// other field empty when none has been saved yet
+func (r *userRepository) FindUserProfile(userID int) (*UserProfile, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	profile := UserProfile{UserId: userID, Goal: WeightGoalMaintain}
+	query := `SELECT user_id, sex, birth_date, height_cm, activity_level, goal, goal_rate_kg
+	FROM users_profile WHERE user_id = $1`
+
+	err := db.Get(&profile, query, userID)
+	return &profile, err
+}
+
+// UpsertUserProfile creates or replaces the profile of a user
+func (r *userRepository) UpsertUserProfile(profile *UserProfile) error {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	query := `INSERT INTO users_profile
+	(user_id, sex, birth_date, height_cm, activity_level, goal, goal_rate_kg, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP)
+	ON CONFLICT (user_id) DO UPDATE SET
+	sex = EXCLUDED.sex, birth_date = EXCLUDED.birth_date, height_cm = EXCLUDED.height_cm,
+	activity_level = EXCLUDED.activity_level, goal = EXCLUDED.goal,
+	goal_rate_kg = EXCLUDED.goal_rate_kg, updated_at = EXCLUDED.updated_at`
+
+	_, err := db.Exec(query, profile.UserId, profile.Sex, profile.BirthDate, profile.HeightCm,
+		profile.ActivityLevel, profile.Goal, profile.GoalRateKg)
+	return err
+}
+
+// ApplyRecommendedTarget writes the nutrition and weekly exercise fields of a
+// recommended target as one new target version, leaving the body measurement
+// and hydration targets untouched
+func (r *userRepository) ApplyRecommendedTarget(userTarget *UserTarget) error {
+	query := `UPDATE users_target SET
+	nutrition_caloric = $1, nutrition_protein = $2, nutrition_carbohydrate = $3,
+	nutrition_fat = $4, nutrition_fiber = $5, nutrition_sugar = $6,
+	nutrition_saturated_fat = $7, nutrition_sodium = $8, nutrition_cholesterol = $9,
+	nutrition_potassium = $10,
+	weekly_exercise_minutes = $11, weekly_exercise_sessions = $12, weekly_exercise_caloric = $13,
+	weekly_weight_lifting_sessions = $14, weekly_cardio_minutes = $15
+	WHERE user_id = $16`
+	return updateUserTarget(userTarget.UserId, query, userTarget.NutritionCaloric, userTarget.NutritionProtein,
+		userTarget.NutritionCarbs, userTarget.NutritionFat,
+		userTarget.NutritionFiber, userTarget.NutritionSugar, userTarget.NutritionSaturatedFat,
+		userTarget.NutritionSodium, userTarget.NutritionCholesterol, userTarget.NutritionPotassium,
+		userTarget.WeeklyExerciseMinutes, userTarget.WeeklyExcerciseSessions, userTarget.WeeklyExcerciseCaloric,
+		userTarget.WeeklyWeightLiftingSessions, userTarget.WeeklyCardioMinutes,
+		userTarget.UserId)
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBuildTargetRecommendation(t *testing.T) {
+	today := time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC)
+	birthDate := time.Date(1996, 6, 15, 0, 0, 0, 0, time.UTC)
+	profile := func(sex Sex, heightCm float64, level ActivityLevel, goal WeightGoal, rateKg float64) UserProfile {
+		return UserProfile{Sex: &sex, BirthDate: &birthDate, HeightCm: &heightCm, ActivityLevel: &level, Goal: goal, GoalRateKg: rateKg}
+	}
+	measurement := func(bodyweight float64, fatPercentage *float64) *BodyMeasurement {
+		return &BodyMeasurement{Bodyweight: bodyweight, FatPercentage: fatPercentage}
+	}
+
+	type result struct {
+		Formula    string
+		BMR        float64
+		TDEE       float64
+		DailyDelta float64
+		Caloric    float64
+		Missing    []string
+		Notes      []string
+	}
+	tests := []struct {
+		name    string
+		profile UserProfile
+		latest  *BodyMeasurement
+		want    result
+	}{
+		{
+			name:    "mifflin-st jeor for a man maintaining",
+			profile: profile(SexMale, 180, ActivityModerate, WeightGoalMaintain, 0),
+			latest:  measurement(80, nil),
+			want:    result{Formula: "mifflin_st_jeor", BMR: 1785, TDEE: 2767, Caloric: 2767, Missing: []string{}, Notes: []string{}},
+		},
+		{
+			name:    "katch-mcardle from the lean mass when the fat percentage is known",
+			profile: profile(SexMale, 180, ActivityActive, WeightGoalGain, 0.25),
+			latest:  measurement(80, floatPtr(20)),
+			want:    result{Formula: "katch_mcardle", BMR: 1752, TDEE: 3023, DailyDelta: 275, Caloric: 3298, Missing: []string{}, Notes: []string{}},
+		},
+		{
+			name:    "a zero fat percentage falls back to mifflin-st jeor",
+			profile: profile(SexMale, 180, ActivityModerate, WeightGoalMaintain, 0),
+			latest:  measurement(80, floatPtr(0)),
+			want:    result{Formula: "mifflin_st_jeor", BMR: 1785, TDEE: 2767, Caloric: 2767, Missing: []string{}, Notes: []string{}},
+		},
+		{
+			name:    "losing weight takes the goal rate off the TDEE",
+			profile: profile(SexFemale, 165, ActivitySedentary, WeightGoalLose, 0.25),
+			latest:  measurement(60, nil),
+			want:    result{Formula: "mifflin_st_jeor", BMR: 1325, TDEE: 1590, DailyDelta: -275, Caloric: 1315, Missing: []string{}, Notes: []string{}},
+		},
+		{
+			name:    "a woman is clamped to 1200 kcal",
+			profile: profile(SexFemale, 165, ActivitySedentary, WeightGoalLose, 0.5),
+			latest:  measurement(60, nil),
+			want: result{Formula: "mifflin_st_jeor", BMR: 1325, TDEE: 1590, DailyDelta: -390, Caloric: 1200, Missing: []string{},
+				Notes: []string{"calories raised to the 1200 kcal minimum, the goal rate will be slower than requested"}},
+		},
+		{
+			name:    "a man is clamped to 1500 kcal",
+			profile: profile(SexMale, 160, ActivitySedentary, WeightGoalLose, 1),
+			latest:  measurement(55, nil),
+			want: result{Formula: "mifflin_st_jeor", BMR: 1410, TDEE: 1692, DailyDelta: -192, Caloric: 1500, Missing: []string{},
+				Notes: []string{"calories raised to the 1500 kcal minimum, the goal rate will be slower than requested"}},
+		},
+		{
+			name:    "every missing input is listed",
+			profile: UserProfile{},
+			want:    result{Missing: []string{"sex", "birth_date", "height_cm", "activity_level", "bodyweight"}, Notes: []string{}},
+		},
+		{
+			name:    "a missing bodyweight alone prevents a suggestion",
+			profile: profile(SexMale, 180, ActivityModerate, WeightGoalMaintain, 0),
+			want:    result{Missing: []string{"bodyweight"}, Notes: []string{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recommendation := BuildTargetRecommendation(tt.profile, tt.latest, today)
+			got := result{
+				Formula:    recommendation.Formula,
+				BMR:        recommendation.BMR,
+				TDEE:       recommendation.TDEE,
+				DailyDelta: recommendation.DailyDelta,
+				Missing:    recommendation.Missing,
+				Notes:      recommendation.Notes,
+			}
+			if recommendation.Target != nil {
+				got.Caloric = recommendation.Target.NutritionCaloric
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BuildTargetRecommendation() = %+v, want %+v", got, tt.want)
+			}
+			if (recommendation.Target == nil) != (len(tt.want.Missing) > 0) {
+				t.Errorf("Target = %v with missing %v", recommendation.Target, tt.want.Missing)
+			}
+			if len(tt.want.Missing) == 0 && (recommendation.Age == nil || *recommendation.Age != 29) {
+				t.Errorf("Age = %v, want 29", recommendation.Age)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// ErrMeasurementNotFound is returned when a photo is linked to a body measurement the user doesn't own
+var ErrMeasurementNotFound = errors.New("body measurement not found")
+
+// PhotoPose is the angle a progress photo was taken from
+type PhotoPose string
+
+const (
+	PhotoPoseFront PhotoPose = "front"
+	PhotoPoseSide  PhotoPose = "side"
+	PhotoPoseBack  PhotoPose = "back"
+)
+
+// Progress photo variants that can be requested through a signed URL
+const (
+	PhotoVariantFull      = "full"
+	PhotoVariantThumbnail = "thumbnail"
+)
+
+// ProgressPhoto is one users_progress_photo row. The image and its thumbnail
+// live in the blob store under BlobKey and ThumbnailKey, which never leave the
+// server; clients get short-lived signed URLs in Url and ThumbnailUrl instead.
+type ProgressPhoto struct {
+	PhotoId       int       `json:"photo_id" db:"photo_id"`
+	UserId        int       `json:"user_id" db:"user_id"`
+	MeasurementId *int      `json:"measurement_id,omitempty" db:"measurement_id"`
+	Pose          PhotoPose `json:"pose" db:"pose"`
+	ContentType   string    `json:"content_type" db:"content_type"`
+	Width         int       `json:"width" db:"width"`
+	Height        int       `json:"height" db:"height"`
+	SizeBytes     int       `json:"size_bytes" db:"size_bytes"`
+	BlobKey       string    `json:"-" db:"blob_key"`
+	ThumbnailKey  string    `json:"-" db:"thumbnail_key"`
+	TakenAt       time.Time `json:"taken_at" db:"taken_at"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+
+	Url          string     `json:"url,omitempty" db:"-"`
+	ThumbnailUrl string     `json:"thumbnail_url,omitempty" db:"-"`
+	UrlExpiresAt *time.Time `json:"url_expires_at,omitempty" db:"-"`
+}
+
+// MarshalJSON : Overloads ProgressPhoto
+func (p ProgressPhoto) MarshalJSON() ([]byte, error) {
+	var expiresAt *string
+	if p.UrlExpiresAt != nil {
+		formatted := p.UrlExpiresAt.Format(time.RFC3339)
+		expiresAt = &formatted
+	}
+	return sonic.Marshal(struct {
+		PhotoId       int       `json:"photo_id"`
+		UserId        int       `json:"user_id"`
+		MeasurementId *int      `json:"measurement_id,omitempty"`
+		Pose          PhotoPose `json:"pose"`
+		ContentType   string    `json:"content_type"`
+		Width         int       `json:"width"`
+		Height        int       `json:"height"`
+		SizeBytes     int       `json:"size_bytes"`
+		TakenAt       string    `json:"taken_at"`
+		CreatedAt     string    `json:"created_at"`
+		Url           string    `json:"url,omitempty"`
+		ThumbnailUrl  string    `json:"thumbnail_url,omitempty"`
+		UrlExpiresAt  *string   `json:"url_expires_at,omitempty"`
+	}{
+		PhotoId:       p.PhotoId,
+		UserId:        p.UserId,
+		MeasurementId: p.MeasurementId,
+		Pose:          p.Pose,
+		ContentType:   p.ContentType,
+		Width:         p.Width,
+		Height:        p.Height,
+		SizeBytes:     p.SizeBytes,
+		TakenAt:       p.TakenAt.Format(time.RFC3339),
+		CreatedAt:     p.CreatedAt.Format(time.RFC3339),
+		Url:           p.Url,
+		ThumbnailUrl:  p.ThumbnailUrl,
+		UrlExpiresAt:  expiresAt,
+	})
+}
+
+// BlobKeyFor returns the blob key of the requested variant
+func (p ProgressPhoto) BlobKeyFor(variant string) string {
+	if variant == PhotoVariantThumbnail {
+		return p.ThumbnailKey
+	}
+	return p.BlobKey
+}
+
+// ContentTypeFor returns the content type of the requested variant; thumbnails are always JPEG
+func (p ProgressPhoto) ContentTypeFor(variant string) string {
+	if variant == PhotoVariantThumbnail {
+		return "image/jpeg"
+	}
+	return p.ContentType
+}
+
+// progressPhotoRepository implements ProgressPhotoRepository interface
+type progressPhotoRepository struct{}
+
+// NewProgressPhotoRepository creates a new progress photo repository
+func NewProgressPhotoRepository() ProgressPhotoRepository {
+	return &progressPhotoRepository{}
+}
+
+// ProgressPhotoRepository defines the interface for progress photo data operations
+type ProgressPhotoRepository interface {
+	Create(photo *ProgressPhoto) error
+	GetByUserId(userId int, pose PhotoPose, limit, page int) ([]ProgressPhoto, error)
+	FindById(userId, photoId int) (*ProgressPhoto, error)
+	Delete(userId, photoId int) (*ProgressPhoto, error)
+}
+
+const progressPhotoColumns = `photo_id, user_id, measurement_id, pose, content_type,
+	width, height, size_bytes, blob_key, thumbnail_key, taken_at, created_at`
+
+// Create stores the photo metadata after checking that a linked measurement belongs to the user
+func (r *progressPhotoRepository) Create(photo *ProgressPhoto) error {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if photo.MeasurementId != nil {
+		var exists bool
+		query := `SELECT EXISTS (SELECT 1 FROM body_measurement WHERE user_id = $1 AND measurement_id = $2)`
+		if err = tx.Get(&exists, query, photo.UserId, *photo.MeasurementId); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrMeasurementNotFound
+		}
+	}
+
+	query := `INSERT INTO users_progress_photo
+	(user_id, measurement_id, pose, content_type, width, height, size_bytes, blob_key, thumbnail_key, taken_at, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, CURRENT_TIMESTAMP)
+	RETURNING photo_id, created_at`
+	err = tx.QueryRowx(query, photo.UserId, photo.MeasurementId, photo.Pose, photo.ContentType,
+		photo.Width, photo.Height, photo.SizeBytes, photo.BlobKey, photo.ThumbnailKey, photo.TakenAt).
+		Scan(&photo.PhotoId, &photo.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetByUserId lists photos newest first, optionally only one pose
+func (r *progressPhotoRepository) GetByUserId(userId int, pose PhotoPose, limit, page int) ([]ProgressPhoto, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	offset := (page - 1) * limit
+	limit = limit + 1
+
+	var photos []ProgressPhoto
+	query := `SELECT ` + progressPhotoColumns + `
+	FROM users_progress_photo
+	WHERE user_id = $1 AND ($2 = '' OR pose = $2)
+	ORDER BY taken_at DESC, photo_id DESC LIMIT $3 OFFSET $4`
+
+	err := db.Select(&photos, query, userId, string(pose), limit, offset)
+	return photos, err
+}
+
+// FindById returns one photo owned by userId
+func (r *progressPhotoRepository) FindById(userId, photoId int) (*ProgressPhoto, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var photo ProgressPhoto
+	query := `SELECT ` + progressPhotoColumns + `
+	FROM users_progress_photo WHERE user_id = $1 AND photo_id = $2`
+
+	err := db.Get(&photo, query, userId, photoId)
+	return &photo, err
+}
+
+// Delete removes one photo owned by userId and returns it so its blobs can be removed
+func (r *progressPhotoRepository) Delete(userId, photoId int) (*ProgressPhoto, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var photo ProgressPhoto
+	query := `DELETE FROM users_progress_photo WHERE user_id = $1 AND photo_id = $2
+	RETURNING ` + progressPhotoColumns
+
+	err := db.Get(&photo, query, userId, photoId)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	return &photo, err
+}
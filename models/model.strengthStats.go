@@ -0,0 +1,229 @@
+package models
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// One-rep max formulas
+const (
+	OneRepMaxEpley   = "epley"
+	OneRepMaxBrzycki = "brzycki"
+)
+
+// oneRepMaxMaxReps is the most reps a one-rep max is estimated from; both
+// formulas drift badly on higher rep sets
+const oneRepMaxMaxReps = 12
+
+// muscleGroupOther collects the volume of exercises logged without a catalog entry
+const muscleGroupOther = "other"
+
+// EstimateOneRepMax estimates the heaviest single rep from a set of reps at
+// weight with the Epley (w × (1 + reps/30)) or Brzycki (w × 36 / (37 − reps))
+// formula. Returns nil for unweighted sets and sets over oneRepMaxMaxReps.
+func EstimateOneRepMax(weight float64, reps int, formula string) *float64 {
+	if weight <= 0 || reps < 1 || reps > oneRepMaxMaxReps {
+		return nil
+	}
+	estimate := weight
+	if reps > 1 {
+		if formula == OneRepMaxBrzycki {
+			estimate = weight * 36 / float64(37-reps)
+		} else {
+			estimate = weight * (1 + float64(reps)/30)
+		}
+	}
+	estimate = round2(estimate)
+	return &estimate
+}
+
+// MuscleGroupVolume is the training volume a muscle group got in a week: the
+// working sets and reps × weight of every exercise training it
+type MuscleGroupVolume struct {
+	MuscleGroup string  `json:"muscle_group"`
+	Sets        int     `json:"sets"`
+	Volume      float64 `json:"volume"`
+}
+
+// ExerciseBestLift is the set with the highest estimated one-rep max of an exercise
+type ExerciseBestLift struct {
+	CatalogId *int    `json:"catalog_id,omitempty"`
+	Name      string  `json:"name"`
+	Reps      int     `json:"reps"`
+	Weight    float64 `json:"weight"`
+	E1rm      float64 `json:"e1rm"`
+	Date      string  `json:"date"`
+}
+
+// StrengthWeeklyStats summarises the strength workouts of a Monday-based week
+// against the weekly_weight_lifting_sessions target in force on its first day
+type StrengthWeeklyStats struct {
+	WeekStart      string              `json:"week_start"`
+	Sessions       int                 `json:"sessions"`
+	TargetSessions *int                `json:"target_sessions"`
+	AdherencePct   *float64            `json:"adherence_pct"`
+	WorkingSets    int                 `json:"working_sets"`
+	Volume         float64             `json:"volume"`
+	MuscleGroups   []MuscleGroupVolume `json:"muscle_groups"`
+	BestLifts      []ExerciseBestLift  `json:"best_lifts"`
+	Formula        string              `json:"formula"`
+	Units          *UnitPreference     `json:"units,omitempty"`
+}
+
+// BuildStrengthWeeklyStats counts the workouts started in the week and sums
+// their volume per muscle group. An exercise's volume counts fully towards
+// every muscle group its entry in catalog trains. Adherence is capped at 100.
+// WeightLifting exercise records of the week count as one session per local
+// day, unless a workout was started that day already.
+func BuildStrengthWeeklyStats(start time.Time, workouts []Workout, records []ExcerciseRecord, catalog map[int]ExerciseCatalogEntry, target *UserTarget, formula string) StrengthWeeklyStats {
+	if formula != OneRepMaxBrzycki {
+		formula = OneRepMaxEpley
+	}
+	stats := StrengthWeeklyStats{
+		WeekStart:    start.Format(SQLDateFormat),
+		Sessions:     strengthSessions(start.Location(), workouts, records),
+		MuscleGroups: []MuscleGroupVolume{},
+		BestLifts:    []ExerciseBestLift{},
+		Formula:      formula,
+	}
+	if target != nil && target.WeeklyWeightLiftingSessions > 0 {
+		targetSessions := target.WeeklyWeightLiftingSessions
+		adherence := round2(math.Min(float64(stats.Sessions)/float64(targetSessions)*100, 100))
+		stats.TargetSessions, stats.AdherencePct = &targetSessions, &adherence
+	}
+
+	groups := map[string]*MuscleGroupVolume{}
+	best := map[string]*ExerciseBestLift{}
+	var order []string
+	for _, workout := range workouts {
+		for _, exercise := range workout.Exercises {
+			muscleGroups := []string{muscleGroupOther}
+			if exercise.CatalogId != nil {
+				if entry, ok := catalog[*exercise.CatalogId]; ok {
+					muscleGroups = entry.MuscleGroups
+				}
+			}
+
+			sets, volume := 0, 0.0
+			for _, set := range exercise.Sets {
+				if set.Warmup {
+					continue
+				}
+				sets++
+				volume += set.Volume()
+				e1rm := EstimateOneRepMax(set.WeightKg, set.Reps, formula)
+				if e1rm == nil {
+					continue
+				}
+				key := ExerciseKey(exercise.CatalogId, exercise.Name)
+				if current, ok := best[key]; !ok || *e1rm > current.E1rm {
+					if !ok {
+						order = append(order, key)
+					}
+					best[key] = &ExerciseBestLift{
+						CatalogId: exercise.CatalogId,
+						Name:      exercise.Name,
+						Reps:      set.Reps,
+						Weight:    set.WeightKg,
+						E1rm:      *e1rm,
+						Date:      workout.StartedAt.In(start.Location()).Format(SQLDateFormat),
+					}
+				}
+			}
+
+			stats.WorkingSets += sets
+			stats.Volume += volume
+			for _, muscleGroup := range muscleGroups {
+				group, ok := groups[muscleGroup]
+				if !ok {
+					group = &MuscleGroupVolume{MuscleGroup: muscleGroup}
+					groups[muscleGroup] = group
+				}
+				group.Sets += sets
+				group.Volume += volume
+			}
+		}
+	}
+
+	stats.Volume = round2(stats.Volume)
+	for _, group := range groups {
+		group.Volume = round2(group.Volume)
+		stats.MuscleGroups = append(stats.MuscleGroups, *group)
+	}
+	sort.Slice(stats.MuscleGroups, func(i, j int) bool {
+		if stats.MuscleGroups[i].Volume != stats.MuscleGroups[j].Volume {
+			return stats.MuscleGroups[i].Volume > stats.MuscleGroups[j].Volume
+		}
+		return stats.MuscleGroups[i].MuscleGroup < stats.MuscleGroups[j].MuscleGroup
+	})
+	for _, key := range order {
+		stats.BestLifts = append(stats.BestLifts, *best[key])
+	}
+	return stats
+}
+
+// WorkoutCatalogIds returns the catalog ids of the exercises of workouts, each once
+func WorkoutCatalogIds(workouts []Workout) []int {
+	seen := map[int]bool{}
+	var ids []int
+	for _, workout := range workouts {
+		for _, exercise := range workout.Exercises {
+			if exercise.CatalogId != nil && !seen[*exercise.CatalogId] {
+				seen[*exercise.CatalogId] = true
+				ids = append(ids, *exercise.CatalogId)
+			}
+		}
+	}
+	return ids
+}
+
+// strengthSessions counts the workouts and the local days in loc with a
+// WeightLifting exercise record but no workout
+func strengthSessions(loc *time.Location, workouts []Workout, records []ExcerciseRecord) int {
+	days := make(map[string]bool, len(workouts))
+	for _, workout := range workouts {
+		days[workout.StartedAt.In(loc).Format(SQLDateFormat)] = true
+	}
+	sessions := len(workouts)
+	for _, record := range records {
+		if record.Type != string(ExerciseCategoryWeightLifting) {
+			continue
+		}
+		day := record.RecordAt.In(loc).Format(SQLDateFormat)
+		if !days[day] {
+			days[day] = true
+			sessions++
+		}
+	}
+	return sessions
+}
+
+// ExerciseKey identifies the same exercise across workouts and exercise
+// records: its catalog entry, or its name for exercises logged without one
+func ExerciseKey(catalogId *int, name string) string {
+	if catalogId != nil {
+		return "catalog:" + strconv.Itoa(*catalogId)
+	}
+	return "name:" + normalizedExerciseName(name)
+}
+
+// normalizedExerciseName is the name exercises without a catalog entry are matched by
+func normalizedExerciseName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// AttachUnits converts the volumes and lifts into units
+func (s *StrengthWeeklyStats) AttachUnits(units UnitPreference) {
+	s.Units = &units
+	s.Volume = units.WeightFromKg(s.Volume)
+	for i := range s.MuscleGroups {
+		s.MuscleGroups[i].Volume = units.WeightFromKg(s.MuscleGroups[i].Volume)
+	}
+	for i := range s.BestLifts {
+		s.BestLifts[i].Weight = units.WeightFromKg(s.BestLifts[i].Weight)
+		s.BestLifts[i].E1rm = units.WeightFromKg(s.BestLifts[i].E1rm)
+	}
+}
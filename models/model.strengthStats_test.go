@@ -0,0 +1,123 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildStrengthWeeklyStatsSessions(t *testing.T) {
+	loc := time.FixedZone("UTC+7", 7*60*60)
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, loc)
+	workout := func(day, hour int) Workout {
+		return Workout{StartedAt: start.AddDate(0, 0, day).Add(time.Duration(hour) * time.Hour)}
+	}
+	record := func(day, hour int, recordType string) ExcerciseRecord {
+		return ExcerciseRecord{RecordAt: start.AddDate(0, 0, day).Add(time.Duration(hour) * time.Hour), Type: recordType}
+	}
+	lifting := string(ExerciseCategoryWeightLifting)
+
+	tests := []struct {
+		name          string
+		workouts      []Workout
+		records       []ExcerciseRecord
+		target        *UserTarget
+		wantSessions  int
+		wantAdherence *float64
+	}{
+		{
+			name:         "workouts only",
+			workouts:     []Workout{workout(0, 8), workout(2, 8)},
+			wantSessions: 2,
+		},
+		{
+			name:         "weight lifting records count as sessions",
+			workouts:     []Workout{workout(0, 8)},
+			records:      []ExcerciseRecord{record(1, 8, lifting), record(3, 18, lifting)},
+			wantSessions: 3,
+		},
+		{
+			name:         "a record on a workout day is the same session",
+			workouts:     []Workout{workout(0, 8)},
+			records:      []ExcerciseRecord{record(0, 20, lifting)},
+			wantSessions: 1,
+		},
+		{
+			name:         "records of one day are one session",
+			records:      []ExcerciseRecord{record(1, 8, lifting), record(1, 9, lifting)},
+			wantSessions: 1,
+		},
+		{
+			name:         "days are taken in the week's zone",
+			workouts:     []Workout{workout(1, 1)},
+			records:      []ExcerciseRecord{record(1, -2, lifting)},
+			wantSessions: 2,
+		},
+		{
+			name:         "other types are not strength sessions",
+			records:      []ExcerciseRecord{record(1, 8, string(ExerciseCategoryCardio)), record(2, 8, "")},
+			wantSessions: 0,
+		},
+		{
+			name:          "records count towards adherence",
+			workouts:      []Workout{workout(0, 8)},
+			records:       []ExcerciseRecord{record(2, 8, lifting)},
+			target:        &UserTarget{WeeklyWeightLiftingSessions: 4},
+			wantSessions:  2,
+			wantAdherence: floatPtr(50),
+		},
+		{
+			name:          "adherence is capped at 100",
+			workouts:      []Workout{workout(0, 8), workout(1, 8)},
+			records:       []ExcerciseRecord{record(2, 8, lifting)},
+			target:        &UserTarget{WeeklyWeightLiftingSessions: 2},
+			wantSessions:  3,
+			wantAdherence: floatPtr(100),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := BuildStrengthWeeklyStats(start, tt.workouts, tt.records, nil, tt.target, OneRepMaxEpley)
+			if stats.Sessions != tt.wantSessions {
+				t.Errorf("sessions = %d, want %d", stats.Sessions, tt.wantSessions)
+			}
+			if !floatPtrEqual(stats.AdherencePct, tt.wantAdherence) {
+				t.Errorf("adherence = %s, want %s", floatPtrString(stats.AdherencePct), floatPtrString(tt.wantAdherence))
+			}
+		})
+	}
+}
+
+func TestBuildStrengthWeeklyStatsMuscleGroups(t *testing.T) {
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	bench, unknown := 23, 999
+	catalog := map[int]ExerciseCatalogEntry{
+		bench: {CatalogId: bench, Name: "Bench press", MuscleGroups: MuscleGroups{MuscleChest, MuscleTriceps}},
+	}
+	workouts := []Workout{{
+		StartedAt: start.Add(8 * time.Hour),
+		Exercises: []WorkoutExercise{
+			{CatalogId: &bench, Name: "Bench press", Sets: []WorkoutSet{{Reps: 5, WeightKg: 100}, {Reps: 10, WeightKg: 40, Warmup: true}}},
+			{CatalogId: &unknown, Name: "Cable crossover", Sets: []WorkoutSet{{Reps: 10, WeightKg: 20}}},
+			{Name: "Farmer carry", Sets: []WorkoutSet{{Reps: 1, WeightKg: 60}}},
+		},
+	}}
+
+	stats := BuildStrengthWeeklyStats(start, workouts, nil, catalog, nil, OneRepMaxEpley)
+	want := []MuscleGroupVolume{
+		{MuscleGroup: MuscleChest, Sets: 1, Volume: 500},
+		{MuscleGroup: MuscleTriceps, Sets: 1, Volume: 500},
+		{MuscleGroup: muscleGroupOther, Sets: 2, Volume: 260},
+	}
+	if len(stats.MuscleGroups) != len(want) {
+		t.Fatalf("muscle groups = %+v, want %+v", stats.MuscleGroups, want)
+	}
+	for i := range want {
+		if stats.MuscleGroups[i] != want[i] {
+			t.Errorf("muscle group %d = %+v, want %+v", i, stats.MuscleGroups[i], want[i])
+		}
+	}
+	if ids := WorkoutCatalogIds(workouts); len(ids) != 2 || ids[0] != bench || ids[1] != unknown {
+		t.Errorf("catalog ids = %v, want [%d %d]", ids, bench, unknown)
+	}
+}
@@ -0,0 +1,188 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/jmoiron/sqlx"
+)
+
+// userTargetHistoryColumns are the users_target columns copied into every users_target_history version
+const userTargetHistoryColumns = `nutrition_caloric, nutrition_protein, nutrition_carbohydrate, nutrition_fat,
+	nutrition_fiber, nutrition_sugar, nutrition_saturated_fat, nutrition_sodium,
+	nutrition_cholesterol, nutrition_potassium, bodyweight, viceral_fat, fat_percentage,
+	hip_cm, chest_cm, arm_left_cm, arm_right_cm, thigh_left_cm, thigh_right_cm, calf_cm,
+	muscle_mass_kg, bone_mass_kg, body_water_percentage, scale_bmr, metabolic_age,
+	weekly_exercise_minutes, weekly_exercise_sessions, weekly_exercise_caloric,
+	weekly_weight_lifting_sessions, weekly_cardio_minutes, hydration_ml`
+
+// UserTargetVersion is one users_target_history row: the whole target as it was
+// from ValidFrom until ValidTo, which is nil for the version still in force
+type UserTargetVersion struct {
+	UserTarget
+	VersionId int        `json:"version_id" db:"version_id"`
+	ValidFrom time.Time  `json:"valid_from" db:"valid_from"`
+	ValidTo   *time.Time `json:"valid_to" db:"valid_to"`
+}
+
+// MarshalJSON : Overloads UserTargetVersion
+func (v UserTargetVersion) MarshalJSON() ([]byte, error) {
+	var validTo *string
+	if v.ValidTo != nil {
+		formatted := v.ValidTo.Format(time.RFC3339)
+		validTo = &formatted
+	}
+	return sonic.Marshal(struct {
+		userTargetJSON
+		VersionId int     `json:"version_id"`
+		ValidFrom string  `json:"valid_from"`
+		ValidTo   *string `json:"valid_to"`
+	}{
+		userTargetJSON: v.UserTarget.toJSON(),
+		VersionId:      v.VersionId,
+		ValidFrom:      v.ValidFrom.Format(time.RFC3339),
+		ValidTo:        validTo,
+	})
+}
+
+// AttachTargetVersionUnits makes every target version marshal its values in units
+func AttachTargetVersionUnits(versions []UserTargetVersion, units UnitPreference) {
+	for i := range versions {
+		versions[i].Units = &units
+	}
+}
+
+// TargetTimeline is a run of target versions ordered by ValidFrom ascending
+type TargetTimeline []UserTargetVersion
+
+// On returns the target in force on a local day: the last version that became
+// valid on or before that date, so a change made during a day applies to the
+// whole day. Days before the first version have no target.
+func (t TargetTimeline) On(day time.Time) *UserTarget {
+	date := day.Format(SQLDateFormat)
+	var target *UserTarget
+	for i := range t {
+		if t[i].ValidFrom.In(day.Location()).Format(SQLDateFormat) > date {
+			break
+		}
+		target = &t[i].UserTarget
+	}
+	return target
+}
+
+// FindTargetTimeline returns the target versions in force between the local
+// dates from and to inclusive. Users whose target has never been changed since
+// history was kept get their current target as a single version in force forever.
+func (r *userRepository) FindTargetTimeline(userID int, from, to string) (TargetTimeline, error) {
+	db := GetDB().PostgreDBManager.RC
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var timeline TargetTimeline
+	query := `SELECT version_id, user_id, valid_from, ` + userTargetHistoryColumns + `
+	FROM users_target_history
+	WHERE user_id = $1
+	AND valid_from < ($3::date + 1)::timestamp AT TIME ZONE $4
+	AND valid_from >= COALESCE((
+		SELECT MAX(valid_from) FROM users_target_history
+		WHERE user_id = $1 AND valid_from < ($2::date + 1)::timestamp AT TIME ZONE $4
+	), '-infinity'::timestamptz)
+	ORDER BY valid_from ASC`
+	if err := db.Select(&timeline, query, userID, from, to, resolveUserTimezone(userID)); err != nil {
+		return nil, err
+	}
+	if len(timeline) > 0 {
+		return timeline, nil
+	}
+
+	var versions int
+	if err := db.Get(&versions, `SELECT COUNT(*) FROM users_target_history WHERE user_id = $1`, userID); err != nil {
+		return nil, err
+	}
+	if versions > 0 {
+		// History exists but starts after to
+		return TargetTimeline{}, nil
+	}
+
+	current, err := r.FindPersonalTarget(userID)
+	if err == sql.ErrNoRows {
+		return TargetTimeline{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return TargetTimeline{{UserTarget: *current}}, nil
+}
+
+// FindTargetHistory lists the target versions of a user, newest first
+func (r *userRepository) FindTargetHistory(userID, limit, page int) ([]UserTargetVersion, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	offset := (page - 1) * limit
+	limit = limit + 1
+
+	var versions []UserTargetVersion
+	query := `SELECT version_id, user_id, valid_from,
+	LEAD(valid_from) OVER (ORDER BY valid_from ASC) AS valid_to, ` + userTargetHistoryColumns + `
+	FROM users_target_history
+	WHERE user_id = $1
+	ORDER BY valid_from DESC LIMIT $2 OFFSET $3`
+
+	err := db.Select(&versions, query, userID, limit, offset)
+	return versions, err
+}
+
+// updateUserTarget runs an UPDATE of users_target and records the resulting
+// target as a new version in the same transaction. The first time a user's
+// target changes, the previous target is recorded as in force since sign-up.
+func updateUserTarget(userID int, query string, args ...interface{}) error {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err = recordBaselineTargetVersion(tx, userID); err != nil {
+		return fmt.Errorf("error recording target baseline: %w", err)
+	}
+	if _, err = tx.Exec(query, args...); err != nil {
+		return err
+	}
+	if err = recordTargetVersion(tx, userID); err != nil {
+		return fmt.Errorf("error recording target version: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// recordBaselineTargetVersion stores the current target valid from the user's
+// sign-up when no version has been recorded for the user yet
+func recordBaselineTargetVersion(e sqlx.Execer, userID int) error {
+	query := `INSERT INTO users_target_history (user_id, valid_from, ` + userTargetHistoryColumns + `)
+	SELECT user_id, (SELECT created_at FROM users WHERE id = $1), ` + userTargetHistoryColumns + `
+	FROM users_target
+	WHERE user_id = $1
+	AND NOT EXISTS (SELECT 1 FROM users_target_history WHERE user_id = $1)`
+	_, err := e.Exec(query, userID)
+	return err
+}
+
+// recordTargetVersion stores the current target as a version valid from now
+func recordTargetVersion(e sqlx.Execer, userID int) error {
+	query := `INSERT INTO users_target_history (user_id, valid_from, ` + userTargetHistoryColumns + `)
+	SELECT user_id, CURRENT_TIMESTAMP, ` + userTargetHistoryColumns + `
+	FROM users_target WHERE user_id = $1`
+	_, err := e.Exec(query, userID)
+	return err
+}
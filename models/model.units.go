@@ -0,0 +1,197 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Units a value can be displayed in. Everything is stored in the first unit
+// of each pair (kg, cm, kcal, ml); conversion only happens at the API boundary.
+// Meal plans, grocery lists and the prose of recommendation notes stay metric.
+const (
+	UnitKg   = "kg"
+	UnitLb   = "lb"
+	UnitCm   = "cm"
+	UnitIn   = "in"
+	UnitKcal = "kcal"
+	UnitKj   = "kj"
+	UnitMl   = "ml"
+	UnitFlOz = "fl_oz"
+)
+
+// Unit systems accepted as a shorthand for a full set of units
+const (
+	UnitSystemMetric   = "metric"
+	UnitSystemImperial = "imperial"
+)
+
+const (
+	kgPerLb   = 0.45359237
+	cmPerIn   = 2.54
+	kjPerKcal = 4.184
+	mlPerFlOz = 29.5735295625
+)
+
+// UnitPreference is the unit a user reads and writes each kind of value in
+type UnitPreference struct {
+	Weight string `json:"weight" db:"weight_unit"`
+	Length string `json:"length" db:"length_unit"`
+	Energy string `json:"energy" db:"energy_unit"`
+	Volume string `json:"volume" db:"volume_unit"`
+}
+
+// MetricUnits is the canonical storage units and the default preference
+func MetricUnits() UnitPreference {
+	return UnitPreference{Weight: UnitKg, Length: UnitCm, Energy: UnitKcal, Volume: UnitMl}
+}
+
+// ImperialUnits is the US customary set; food energy stays in kcal as on US labels
+func ImperialUnits() UnitPreference {
+	return UnitPreference{Weight: UnitLb, Length: UnitIn, Energy: UnitKcal, Volume: UnitFlOz}
+}
+
+// ParseUnitOverride applies a per-request override on top of base. The value
+// is either a unit system ("metric", "imperial") or a comma-separated list of
+// units such as "lb,kj", each replacing the unit of its own kind.
+func ParseUnitOverride(value string, base UnitPreference) (UnitPreference, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case "":
+		return base, nil
+	case UnitSystemMetric:
+		return MetricUnits(), nil
+	case UnitSystemImperial:
+		return ImperialUnits(), nil
+	}
+
+	units := base
+	for _, unit := range strings.Split(value, ",") {
+		switch unit = strings.TrimSpace(unit); unit {
+		case UnitKg, UnitLb:
+			units.Weight = unit
+		case UnitCm, UnitIn:
+			units.Length = unit
+		case UnitKcal, UnitKj:
+			units.Energy = unit
+		case UnitMl, UnitFlOz:
+			units.Volume = unit
+		default:
+			return base, fmt.Errorf("unknown unit %q", unit)
+		}
+	}
+	return units, nil
+}
+
+// IsMetric reports whether every unit is the canonical storage unit
+func (u UnitPreference) IsMetric() bool {
+	return u == MetricUnits()
+}
+
+// WeightFromKg converts a stored kilogram value into the preferred weight unit
+func (u UnitPreference) WeightFromKg(kg float64) float64 {
+	if u.Weight == UnitLb {
+		return round2(kg / kgPerLb)
+	}
+	return kg
+}
+
+// WeightToKg converts a value in the preferred weight unit into kilograms
+func (u UnitPreference) WeightToKg(value float64) float64 {
+	if u.Weight == UnitLb {
+		return round2(value * kgPerLb)
+	}
+	return value
+}
+
+// LengthFromCm converts a stored centimetre value into the preferred length unit
+func (u UnitPreference) LengthFromCm(cm float64) float64 {
+	if u.Length == UnitIn {
+		return round2(cm / cmPerIn)
+	}
+	return cm
+}
+
+// LengthToCm converts a value in the preferred length unit into centimetres
+func (u UnitPreference) LengthToCm(value float64) float64 {
+	if u.Length == UnitIn {
+		return round2(value * cmPerIn)
+	}
+	return value
+}
+
+// EnergyFromKcal converts a stored kcal value into the preferred energy unit
+func (u UnitPreference) EnergyFromKcal(kcal float64) float64 {
+	if u.Energy == UnitKj {
+		return round2(kcal * kjPerKcal)
+	}
+	return kcal
+}
+
+// EnergyToKcal converts a value in the preferred energy unit into kcal
+func (u UnitPreference) EnergyToKcal(value float64) float64 {
+	if u.Energy == UnitKj {
+		return round2(value / kjPerKcal)
+	}
+	return value
+}
+
+// VolumeFromMl converts a stored millilitre value into the preferred volume unit
+func (u UnitPreference) VolumeFromMl(ml float64) float64 {
+	if u.Volume == UnitFlOz {
+		return round2(ml / mlPerFlOz)
+	}
+	return ml
+}
+
+// VolumeToMl converts a value in the preferred volume unit into millilitres
+func (u UnitPreference) VolumeToMl(value float64) float64 {
+	if u.Volume == UnitFlOz {
+		return round2(value * mlPerFlOz)
+	}
+	return value
+}
+
+// ConvertOptional applies convert to an optional value, keeping nil as nil
+func ConvertOptional(value *float64, convert func(float64) float64) *float64 {
+	if value == nil {
+		return nil
+	}
+	converted := convert(*value)
+	return &converted
+}
+
+// FindUnitPreference retrieves the units stored on the user, falling back to
+// the metric unit for every kind that has not been set
+func (r *userRepository) FindUnitPreference(userID int) (UnitPreference, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return MetricUnits(), fmt.Errorf("database connection is nil")
+	}
+
+	defaults := MetricUnits()
+	var units UnitPreference
+	query := `SELECT COALESCE(NULLIF(weight_unit, ''), $2) AS weight_unit,
+		COALESCE(NULLIF(length_unit, ''), $3) AS length_unit,
+		COALESCE(NULLIF(energy_unit, ''), $4) AS energy_unit,
+		COALESCE(NULLIF(volume_unit, ''), $5) AS volume_unit
+	FROM users WHERE id = $1`
+
+	err := db.Get(&units, query, userID, defaults.Weight, defaults.Length, defaults.Energy, defaults.Volume)
+	if err != nil {
+		return defaults, err
+	}
+	return units, nil
+}
+
+// UpdateUnitPreference stores the units a user reads and writes values in
+func (r *userRepository) UpdateUnitPreference(userID int, units UnitPreference) error {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	query := `UPDATE users SET weight_unit = $1, length_unit = $2, energy_unit = $3, volume_unit = $4,
+		updated_at = CURRENT_TIMESTAMP WHERE id = $5`
+	_, err := db.Exec(query, units.Weight, units.Length, units.Energy, units.Volume, userID)
+	return err
+}
@@ -0,0 +1,228 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestUnitConversions(t *testing.T) {
+	imperial := UnitPreference{Weight: UnitLb, Length: UnitIn, Energy: UnitKj, Volume: UnitFlOz}
+	metric := MetricUnits()
+
+	tests := []struct {
+		name    string
+		convert func(float64) float64
+		value   float64
+		want    float64
+	}{
+		{name: "kg to lb", convert: imperial.WeightFromKg, value: 80, want: 176.37},
+		{name: "lb to kg", convert: imperial.WeightToKg, value: 176.37, want: 80},
+		{name: "cm to in", convert: imperial.LengthFromCm, value: 180, want: 70.87},
+		{name: "in to cm loses the rounding of the way out", convert: imperial.LengthToCm, value: 70.87, want: 180.01},
+		{name: "kcal to kj", convert: imperial.EnergyFromKcal, value: 2000, want: 8368},
+		{name: "kj to kcal", convert: imperial.EnergyToKcal, value: 8368, want: 2000},
+		{name: "kj to kcal rounds to two decimals", convert: imperial.EnergyToKcal, value: 1000, want: 239.01},
+		{name: "ml to fl oz", convert: imperial.VolumeFromMl, value: 2000, want: 67.63},
+		{name: "fl oz to ml", convert: imperial.VolumeToMl, value: 67.63, want: 2000.06},
+		{name: "metric weight is not rounded", convert: metric.WeightToKg, value: 80.123, want: 80.123},
+		{name: "metric energy is not rounded", convert: metric.EnergyFromKcal, value: 290.456, want: 290.456},
+		{name: "metric volume is not rounded", convert: metric.VolumeToMl, value: 250.5, want: 250.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.convert(tt.value); !floatPtrEqual(&got, &tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseUnitOverride(t *testing.T) {
+	base := UnitPreference{Weight: UnitLb, Length: UnitCm, Energy: UnitKcal, Volume: UnitMl}
+
+	tests := []struct {
+		name    string
+		value   string
+		want    UnitPreference
+		wantErr bool
+	}{
+		{name: "no override keeps the preference", value: "", want: base},
+		{name: "metric system", value: "metric", want: MetricUnits()},
+		{name: "imperial system", value: " Imperial ", want: ImperialUnits()},
+		{name: "single units replace their own kind", value: "kg,kj", want: UnitPreference{Weight: UnitKg, Length: UnitCm, Energy: UnitKj, Volume: UnitMl}},
+		{name: "unknown unit", value: "lb,stone", want: base, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseUnitOverride(tt.value, base)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserTargetJSON(t *testing.T) {
+	imperial := UnitPreference{Weight: UnitLb, Length: UnitIn, Energy: UnitKj, Volume: UnitFlOz}
+	target := UserTarget{
+		NutritionCaloric:       2000,
+		BodyWeight:             80,
+		HipCm:                  floatPtr(100),
+		MuscleMassKg:           floatPtr(35),
+		WeeklyExcerciseCaloric: 2000,
+		HydrationMl:            2000,
+	}
+	withUnits := target
+	withUnits.Units = &imperial
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  map[string]interface{}
+	}{
+		{
+			name:  "metric without units",
+			value: target,
+			want: map[string]interface{}{
+				"nutrition_caloric": 2000.0, "bodyweight": 80.0, "hip_cm": 100.0, "muscle_mass_kg": 35.0,
+				"weekly_exercise_caloric": 2000.0, "hydration_ml": 2000.0, "units": nil,
+			},
+		},
+		{
+			name:  "converted into the user's units",
+			value: withUnits,
+			want: map[string]interface{}{
+				"nutrition_caloric": 8368.0, "bodyweight": 176.37, "hip_cm": 39.37, "muscle_mass_kg": 77.16,
+				"weekly_exercise_caloric": 8368.0, "hydration_ml": 67.63,
+			},
+		},
+		{
+			name:  "target version keeps its own fields",
+			value: UserTargetVersion{UserTarget: withUnits, VersionId: 3, ValidFrom: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+			want: map[string]interface{}{
+				"version_id": 3.0, "valid_from": "2026-01-01T00:00:00Z", "bodyweight": 176.37, "hydration_ml": 67.63,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]interface{}
+			if err := json.Unmarshal([]byte(mustMarshal(t, tt.value)), &got); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			for key, want := range tt.want {
+				value, ok := got[key]
+				if want == nil {
+					if ok {
+						t.Errorf("%s = %v, want it left out", key, value)
+					}
+					continue
+				}
+				if value != want {
+					t.Errorf("%s = %v, want %v", key, value, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReportAttachUnits(t *testing.T) {
+	imperial := UnitPreference{Weight: UnitLb, Length: UnitIn, Energy: UnitKj, Volume: UnitFlOz}
+
+	tests := []struct {
+		name string
+		got  func() []float64
+		want []float64
+	}{
+		{
+			name: "weight trend rate, projection and points",
+			got: func() []float64 {
+				report := WeightTrendReport{
+					CurrentTrend: floatPtr(80),
+					Rate:         &WeightRate{WeeklyRateKg: -0.5, LowKg: -0.7, HighKg: -0.3},
+					Projection:   &WeightProjection{TargetBodyweight: 75, RemainingKg: -5},
+					Plateaus:     []WeightPlateau{{ChangeKg: 0.2}},
+					Points:       []WeightTrendPoint{{Bodyweight: floatPtr(80.5), Trend: floatPtr(80)}},
+				}
+				report.AttachUnits(imperial)
+				return []float64{*report.CurrentTrend, report.Rate.WeeklyRateKg, report.Rate.LowKg, report.Rate.HighKg,
+					report.Projection.TargetBodyweight, report.Projection.RemainingKg, report.Plateaus[0].ChangeKg,
+					*report.Points[0].Bodyweight, *report.Points[0].Trend}
+			},
+			want: []float64{176.37, -1.1, -1.54, -0.66, 165.35, -11.02, 0.44, 177.47, 176.37},
+		},
+		{
+			name: "energy balance totals and days",
+			got: func() []float64 {
+				balance := EnergyBalance{
+					TotalIntakeKcal: 2000, TotalExerciseKcal: 500, TotalNetKcal: 1500, AverageNetKcal: 1500,
+					Days: []EnergyBalanceDay{{IntakeKcal: 2000, ExerciseKcal: 500, NetKcal: 1500, Bodyweight: floatPtr(80), WeightTrend: floatPtr(80)}},
+				}
+				balance.AttachUnits(imperial)
+				day := balance.Days[0]
+				return []float64{balance.TotalIntakeKcal, balance.TotalExerciseKcal, balance.TotalNetKcal, balance.AverageNetKcal,
+					day.IntakeKcal, day.ExerciseKcal, day.NetKcal, *day.Bodyweight, *day.WeightTrend}
+			},
+			want: []float64{8368, 2092, 6276, 6276, 8368, 2092, 6276, 176.37, 176.37},
+		},
+		{
+			name: "tdee calories and weight trend",
+			got: func() []float64 {
+				estimate := TDEEEstimate{
+					AverageIntakeKcal: 2000, AverageExerciseKcal: 500, Estimated: floatPtr(2500),
+					TrendStart: floatPtr(80), TrendEnd: floatPtr(79), WeightChangeKg: floatPtr(-1), WeeklyRateKg: floatPtr(-0.25),
+				}
+				estimate.AttachUnits(imperial)
+				return []float64{estimate.AverageIntakeKcal, estimate.AverageExerciseKcal, *estimate.Estimated,
+					*estimate.TrendStart, *estimate.TrendEnd, *estimate.WeightChangeKg, *estimate.WeeklyRateKg}
+			},
+			want: []float64{8368, 2092, 10460, 176.37, 174.17, -2.2, -0.55},
+		},
+		{
+			name: "target recommendation",
+			got: func() []float64 {
+				recommendation := TargetRecommendation{Bodyweight: floatPtr(80), BMR: 1700, TDEE: 2500, DailyDelta: -500, Target: &UserTarget{}}
+				recommendation.AttachUnits(imperial)
+				return []float64{*recommendation.Bodyweight, recommendation.BMR, recommendation.TDEE, recommendation.DailyDelta,
+					boolFloat(recommendation.Target.Units != nil)}
+			},
+			want: []float64{176.37, 7112.8, 10460, -2092, 1},
+		},
+		{
+			name: "derived lean mass",
+			got: func() []float64 {
+				derived := DerivedBodyMetrics{LeanMassKg: floatPtr(64), Bmi: floatPtr(24.69)}.InUnits(imperial)
+				return []float64{*derived.LeanMassKg, *derived.Bmi}
+			},
+			want: []float64{141.1, 24.69},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.got()
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !floatPtrEqual(&got[i], &tt.want[i]) {
+					t.Errorf("value %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func boolFloat(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
@@ -1,22 +1,91 @@
 package models
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+	"time"
 
+	"github.com/bytedance/sonic"
+)
+
+// UserTarget is the users_target row. Extended nutrient targets are optional;
+// sugar, saturated fat, sodium and cholesterol are daily limits while fiber
+// and potassium are daily goals. Extended body measurement targets are optional too.
 type UserTarget struct {
-	TargetId                    int     `json:"target_id" db:"target_id"`
-	UserId                      int     `json:"user_id" db:"user_id"`
-	NutritionCaloric            float64 `json:"nutrition_caloric" db:"nutrition_caloric"`
-	NutritionProtein            float64 `json:"nutrition_protein" db:"nutrition_protein"`
-	NutritionCarbs              float64 `json:"nutrition_carbohydrate" db:"nutrition_carbohydrate"`
-	NutritionFat                float64 `json:"nutrition_fat" db:"nutrition_fat"`
-	BodyWeight                  float64 `json:"bodyweight" db:"bodyweight"`
-	ViceralFat                  float64 `json:"viceral_fat" db:"viceral_fat"`
-	FatPercentage               float64 `json:"fat_percentage" db:"fat_percentage"`
-	WeeklyExerciseMinutes       int     `json:"weekly_exercise_minutes" db:"weekly_exercise_minutes"`
-	WeeklyExcerciseSessions     int     `json:"weekly_exercise_sessions" db:"weekly_exercise_sessions"`
-	WeeklyExcerciseCaloric      int     `json:"weekly_exercise_caloric" db:"weekly_exercise_caloric"`
-	WeeklyWeightLiftingSessions int     `json:"weekly_weight_lifting_sessions" db:"weekly_weight_lifting_sessions"`
-	WeeklyCardioMinutes         int     `json:"weekly_cardio_minutes" db:"weekly_cardio_minutes"`
+	TargetId                    int      `json:"target_id" db:"target_id"`
+	UserId                      int      `json:"user_id" db:"user_id"`
+	NutritionCaloric            float64  `json:"nutrition_caloric" db:"nutrition_caloric"`
+	NutritionProtein            float64  `json:"nutrition_protein" db:"nutrition_protein"`
+	NutritionCarbs              float64  `json:"nutrition_carbohydrate" db:"nutrition_carbohydrate"`
+	NutritionFat                float64  `json:"nutrition_fat" db:"nutrition_fat"`
+	NutritionFiber              *float64 `json:"nutrition_fiber,omitempty" db:"nutrition_fiber"`
+	NutritionSugar              *float64 `json:"nutrition_sugar,omitempty" db:"nutrition_sugar"`
+	NutritionSaturatedFat       *float64 `json:"nutrition_saturated_fat,omitempty" db:"nutrition_saturated_fat"`
+	NutritionSodium             *float64 `json:"nutrition_sodium,omitempty" db:"nutrition_sodium"`
+	NutritionCholesterol        *float64 `json:"nutrition_cholesterol,omitempty" db:"nutrition_cholesterol"`
+	NutritionPotassium          *float64 `json:"nutrition_potassium,omitempty" db:"nutrition_potassium"`
+	BodyWeight                  float64  `json:"bodyweight" db:"bodyweight"`
+	ViceralFat                  float64  `json:"viceral_fat" db:"viceral_fat"`
+	FatPercentage               float64  `json:"fat_percentage" db:"fat_percentage"`
+	HipCm                       *float64 `json:"hip_cm,omitempty" db:"hip_cm"`
+	ChestCm                     *float64 `json:"chest_cm,omitempty" db:"chest_cm"`
+	ArmLeftCm                   *float64 `json:"arm_left_cm,omitempty" db:"arm_left_cm"`
+	ArmRightCm                  *float64 `json:"arm_right_cm,omitempty" db:"arm_right_cm"`
+	ThighLeftCm                 *float64 `json:"thigh_left_cm,omitempty" db:"thigh_left_cm"`
+	ThighRightCm                *float64 `json:"thigh_right_cm,omitempty" db:"thigh_right_cm"`
+	CalfCm                      *float64 `json:"calf_cm,omitempty" db:"calf_cm"`
+	MuscleMassKg                *float64 `json:"muscle_mass_kg,omitempty" db:"muscle_mass_kg"`
+	BoneMassKg                  *float64 `json:"bone_mass_kg,omitempty" db:"bone_mass_kg"`
+	BodyWaterPercentage         *float64 `json:"body_water_percentage,omitempty" db:"body_water_percentage"`
+	ScaleBmr                    *float64 `json:"scale_bmr,omitempty" db:"scale_bmr"`
+	MetabolicAge                *int     `json:"metabolic_age,omitempty" db:"metabolic_age"`
+	WeeklyExerciseMinutes       int      `json:"weekly_exercise_minutes" db:"weekly_exercise_minutes"`
+	WeeklyExcerciseSessions     int      `json:"weekly_exercise_sessions" db:"weekly_exercise_sessions"`
+	WeeklyExcerciseCaloric      int      `json:"weekly_exercise_caloric" db:"weekly_exercise_caloric"`
+	WeeklyWeightLiftingSessions int      `json:"weekly_weight_lifting_sessions" db:"weekly_weight_lifting_sessions"`
+	WeeklyCardioMinutes         int      `json:"weekly_cardio_minutes" db:"weekly_cardio_minutes"`
+	HydrationMl                 int      `json:"hydration_ml" db:"hydration_ml"`
+	// Units, when set, are the units MarshalJSON writes the values in
+	Units *UnitPreference `json:"-" db:"-"`
+}
+
+// userTargetFields is UserTarget without its MarshalJSON
+type userTargetFields UserTarget
+
+// userTargetJSON is UserTarget as written in its units, where the hydration
+// target is no longer a whole number
+type userTargetJSON struct {
+	userTargetFields
+	HydrationMl float64         `json:"hydration_ml"`
+	Units       *UnitPreference `json:"units,omitempty"`
+}
+
+// MarshalJSON : Overloads UserTarget
+func (t UserTarget) MarshalJSON() ([]byte, error) {
+	return sonic.Marshal(t.toJSON())
+}
+
+// toJSON converts the energy, weight, length and volume targets into Units
+func (t UserTarget) toJSON() userTargetJSON {
+	hydration := float64(t.HydrationMl)
+	if t.Units != nil {
+		units := *t.Units
+		t.NutritionCaloric = units.EnergyFromKcal(t.NutritionCaloric)
+		t.BodyWeight = units.WeightFromKg(t.BodyWeight)
+		t.HipCm = ConvertOptional(t.HipCm, units.LengthFromCm)
+		t.ChestCm = ConvertOptional(t.ChestCm, units.LengthFromCm)
+		t.ArmLeftCm = ConvertOptional(t.ArmLeftCm, units.LengthFromCm)
+		t.ArmRightCm = ConvertOptional(t.ArmRightCm, units.LengthFromCm)
+		t.ThighLeftCm = ConvertOptional(t.ThighLeftCm, units.LengthFromCm)
+		t.ThighRightCm = ConvertOptional(t.ThighRightCm, units.LengthFromCm)
+		t.CalfCm = ConvertOptional(t.CalfCm, units.LengthFromCm)
+		t.MuscleMassKg = ConvertOptional(t.MuscleMassKg, units.WeightFromKg)
+		t.BoneMassKg = ConvertOptional(t.BoneMassKg, units.WeightFromKg)
+		t.ScaleBmr = ConvertOptional(t.ScaleBmr, units.EnergyFromKcal)
+		t.WeeklyExcerciseCaloric = int(math.Round(units.EnergyFromKcal(float64(t.WeeklyExcerciseCaloric))))
+		hydration = units.VolumeFromMl(hydration)
+	}
+	return userTargetJSON{userTargetFields: userTargetFields(t), HydrationMl: hydration, Units: t.Units}
 }
 
 // userRepository implements UserRepository interface
@@ -33,6 +102,112 @@ type UserRepository interface {
 	UpdatePersonalNutritionTarget(userTarget *UserTarget) error
 	UpdatePersonalBodyMeasurementTarget(userTarget *UserTarget) error
 	UpdatePersonalExerciseTarget(userTarget *UserTarget) error
+	UpdatePersonalHydrationTarget(userTarget *UserTarget) error
+
+	FindUserTimezone(userID int) (string, error)
+	UpdateUserTimezone(userID int, timezone string) error
+	FindUserLevel(userID int) (UserLevel, error)
+	FindUnitPreference(userID int) (UnitPreference, error)
+	UpdateUnitPreference(userID int, units UnitPreference) error
+
+	FindUserProfile(userID int) (*UserProfile, error)
+	UpsertUserProfile(profile *UserProfile) error
+	ApplyRecommendedTarget(userTarget *UserTarget) error
+	FindTargetHistory(userID, limit, page int) ([]UserTargetVersion, error)
+	FindTargetTimeline(userID int, from, to string) (TargetTimeline, error)
+}
+
+// UpdatePersonalHydrationTarget updates the daily hydration target for a user
+func (r *userRepository) UpdatePersonalHydrationTarget(userTarget *UserTarget) error {
+	query := `UPDATE users_target SET hydration_ml = $1 WHERE user_id = $2`
+	return updateUserTarget(userTarget.UserId, query, userTarget.HydrationMl, userTarget.UserId)
+}
+
+// UserTimezone holds the IANA zone used to bucket a user's records into days
+type UserTimezone struct {
+	UserId   int    `json:"user_id" db:"id"`
+	Timezone string `json:"timezone" db:"timezone"`
+}
+
+// FindUserTimezone retrieves the IANA timezone stored on the user profile,
+// falling back to DefaultTimezone when none has been set
+func (r *userRepository) FindUserTimezone(userID int) (string, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return "", fmt.Errorf("database connection is nil")
+	}
+
+	var timezone string
+	query := `SELECT COALESCE(NULLIF(timezone, ''), $2) FROM users WHERE id = $1`
+
+	err := db.Get(&timezone, query, userID, DefaultTimezone)
+	return timezone, err
+}
+
+// UpdateUserTimezone stores a new IANA timezone on the user profile and, in
+// the same transaction, rebuilds the derived daily aggregates in that zone.
+func (r *userRepository) UpdateUserTimezone(userID int, timezone string) error {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE users SET timezone = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
+	if _, err = tx.Exec(query, timezone, userID); err != nil {
+		return fmt.Errorf("error updating user timezone: %w", err)
+	}
+	if err = RecomputeDailyAggregates(tx, userID, timezone); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FindUserLevel retrieves the effective subscription level of a user
+func (r *userRepository) FindUserLevel(userID int) (UserLevel, error) {
+	db := GetDB().PostgreDBManager.RC
+	if db == nil {
+		return UserLevelFree, fmt.Errorf("database connection is nil")
+	}
+
+	var user User
+	query := `SELECT user_level, premium_expires_at FROM users WHERE id = $1`
+
+	err := db.Get(&user, query, userID)
+	if err != nil {
+		return UserLevelFree, err
+	}
+	return user.EffectiveLevel(), nil
+}
+
+// LoadUserLocation resolves the user's timezone into a *time.Location. Any
+// lookup failure falls back to DefaultTimezone so day bucketing never breaks.
+func LoadUserLocation(userID int) *time.Location {
+	timezone, err := NewUserRepository().FindUserTimezone(userID)
+	if err != nil || timezone == "" {
+		if err != nil && Logger != nil {
+			Logger.Warn().Err(err).Int("user_id", userID).Msg("[LoadUserLocation] Falling back to default timezone")
+		}
+		timezone = DefaultTimezone
+	}
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		if loc, err = time.LoadLocation(DefaultTimezone); err != nil {
+			loc = time.UTC
+		}
+	}
+	return loc
+}
+
+// resolveUserTimezone returns the user's IANA timezone name for SQL day bucketing
+func resolveUserTimezone(userID int) string {
+	return LoadUserLocation(userID).String()
 }
 
 // FindPersonalTarget retrieves the personal target for a given user ID
@@ -46,7 +221,11 @@ func (r *userRepository) FindPersonalTarget(userID int) (*UserTarget, error) {
 	query := `SELECT 
 	target_id, user_id, nutrition_caloric, nutrition_protein, nutrition_carbohydrate,
 	weekly_exercise_minutes, weekly_exercise_sessions, weekly_exercise_caloric, weekly_weight_lifting_sessions, weekly_cardio_minutes,
-	nutrition_fat, bodyweight, viceral_fat, fat_percentage FROM users_target WHERE user_id = $1`
+	nutrition_fat, bodyweight, viceral_fat, fat_percentage,
+	hip_cm, chest_cm, arm_left_cm, arm_right_cm, thigh_left_cm, thigh_right_cm, calf_cm,
+	muscle_mass_kg, bone_mass_kg, body_water_percentage, scale_bmr, metabolic_age,
+	nutrition_fiber, nutrition_sugar, nutrition_saturated_fat, nutrition_sodium,
+	nutrition_cholesterol, nutrition_potassium, hydration_ml FROM users_target WHERE user_id = $1`
 
 	err := db.Get(&user, query, userID)
 	return &user, err
@@ -54,46 +233,41 @@ func (r *userRepository) FindPersonalTarget(userID int) (*UserTarget, error) {
 
 // UpdatePersonalBodyMeasurementTarget updates the personal target for a user
 func (r *userRepository) UpdatePersonalBodyMeasurementTarget(userTarget *UserTarget) error {
-	db := GetDB().PostgreDBManager.RW
-	if db == nil {
-		return fmt.Errorf("database connection is nil")
-	}
-
 	query := `UPDATE users_target SET 
-	bodyweight = $1, viceral_fat = $2, fat_percentage = $3
-	WHERE user_id = $4`
+	bodyweight = $1, viceral_fat = $2, fat_percentage = $3,
+	hip_cm = $4, chest_cm = $5, arm_left_cm = $6, arm_right_cm = $7,
+	thigh_left_cm = $8, thigh_right_cm = $9, calf_cm = $10,
+	muscle_mass_kg = $11, bone_mass_kg = $12, body_water_percentage = $13,
+	scale_bmr = $14, metabolic_age = $15
+	WHERE user_id = $16`
 	Logger.Debug().Msgf("Executing query: %s with values %+v", query, userTarget)
-	_, err := db.Exec(query, userTarget.BodyWeight, userTarget.ViceralFat, userTarget.FatPercentage, userTarget.UserId)
-	return err
+	return updateUserTarget(userTarget.UserId, query, userTarget.BodyWeight, userTarget.ViceralFat, userTarget.FatPercentage,
+		userTarget.HipCm, userTarget.ChestCm, userTarget.ArmLeftCm, userTarget.ArmRightCm,
+		userTarget.ThighLeftCm, userTarget.ThighRightCm, userTarget.CalfCm,
+		userTarget.MuscleMassKg, userTarget.BoneMassKg, userTarget.BodyWaterPercentage,
+		userTarget.ScaleBmr, userTarget.MetabolicAge, userTarget.UserId)
 }
 
 // UpdatePersonalTarget updates the personal target for a user
 func (r *userRepository) UpdatePersonalNutritionTarget(userTarget *UserTarget) error {
-	db := GetDB().PostgreDBManager.RW
-	if db == nil {
-		return fmt.Errorf("database connection is nil")
-	}
-
 	query := `UPDATE users_target SET 
 	nutrition_caloric = $1, nutrition_protein = $2, nutrition_carbohydrate = $3,
-	nutrition_fat = $4 WHERE user_id = $5`
+	nutrition_fat = $4, nutrition_fiber = $5, nutrition_sugar = $6,
+	nutrition_saturated_fat = $7, nutrition_sodium = $8, nutrition_cholesterol = $9,
+	nutrition_potassium = $10 WHERE user_id = $11`
 
-	_, err := db.Exec(query, userTarget.NutritionCaloric, userTarget.NutritionProtein,
-		userTarget.NutritionCarbs, userTarget.NutritionFat, userTarget.UserId)
-	return err
+	return updateUserTarget(userTarget.UserId, query, userTarget.NutritionCaloric, userTarget.NutritionProtein,
+		userTarget.NutritionCarbs, userTarget.NutritionFat,
+		userTarget.NutritionFiber, userTarget.NutritionSugar, userTarget.NutritionSaturatedFat,
+		userTarget.NutritionSodium, userTarget.NutritionCholesterol, userTarget.NutritionPotassium,
+		userTarget.UserId)
 }
 
 // UpdatePersonalExerciseTarget updates the personal target for a user
 func (r *userRepository) UpdatePersonalExerciseTarget(userTarget *UserTarget) error {
-	db := GetDB().PostgreDBManager.RW
-	if db == nil {
-		return fmt.Errorf("database connection is nil")
-	}
-
 	query := `UPDATE users_target SET 
 	weekly_exercise_minutes = $1, weekly_exercise_sessions = $2, weekly_exercise_caloric = $3, weekly_weight_lifting_sessions = $4, weekly_cardio_minutes = $5
 	WHERE user_id = $6`
 	Logger.Debug().Msgf("Executing query: %s with values %+v", query, userTarget)
-	_, err := db.Exec(query, userTarget.WeeklyExerciseMinutes, userTarget.WeeklyExcerciseSessions, userTarget.WeeklyExcerciseCaloric, userTarget.WeeklyWeightLiftingSessions, userTarget.WeeklyCardioMinutes, userTarget.UserId)
-	return err
+	return updateUserTarget(userTarget.UserId, query, userTarget.WeeklyExerciseMinutes, userTarget.WeeklyExcerciseSessions, userTarget.WeeklyExcerciseCaloric, userTarget.WeeklyWeightLiftingSessions, userTarget.WeeklyCardioMinutes, userTarget.UserId)
 }
@@ -0,0 +1,268 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// WeightRateWindowDays is how far back weigh-ins are fitted for the weekly rate
+	WeightRateWindowDays = 28
+	// WeightPlateauBandKg is the widest trend swing that still counts as a plateau
+	WeightPlateauBandKg = 0.5
+	// DefaultPlateauWeeks is how long the trend must stay flat before it is flagged
+	DefaultPlateauWeeks = 3
+	// weightProjectionMaxDays caps projections, further out is reported as unreachable
+	weightProjectionMaxDays = 5 * 365
+	// weightRateMinWeighIns is the fewest weigh-ins a rate is fitted from
+	weightRateMinWeighIns = 3
+)
+
+// WeightTrendPoint is one day of the weight trend. Bodyweight is nil on days
+// without a weigh-in, Trend is nil before the first one.
+type WeightTrendPoint struct {
+	Date       string   `json:"date"`
+	Bodyweight *float64 `json:"bodyweight"`
+	Trend      *float64 `json:"trend"`
+}
+
+// WeightRate is the least-squares slope of the weigh-ins over the rate window,
+// as kg per week with its 95% confidence interval
+type WeightRate struct {
+	WeeklyRateKg float64 `json:"weekly_rate_kg"`
+	LowKg        float64 `json:"low_kg"`
+	HighKg       float64 `json:"high_kg"`
+	WeighIns     int     `json:"weigh_ins"`
+	WindowDays   int     `json:"window_days"`
+}
+
+// WeightProjection is when the target bodyweight is reached at the current rate.
+// EarliestDate and LatestDate follow from the ends of the rate's confidence
+// interval; LatestDate is nil when the slow end never reaches the target.
+type WeightProjection struct {
+	TargetBodyweight float64 `json:"target_bodyweight"`
+	RemainingKg      float64 `json:"remaining_kg"`
+	ProjectedDate    *string `json:"projected_date"`
+	EarliestDate     *string `json:"earliest_date"`
+	LatestDate       *string `json:"latest_date"`
+	Reason           string  `json:"reason,omitempty"`
+}
+
+// WeightPlateau is a run of days over which the trend stayed within WeightPlateauBandKg
+type WeightPlateau struct {
+	From     string  `json:"from"`
+	To       string  `json:"to"`
+	Weeks    float64 `json:"weeks"`
+	ChangeKg float64 `json:"change_kg"`
+}
+
+// WeightTrendReport is the smoothed weight over a range with the current rate,
+// goal projection and plateaus longer than PlateauWeeks
+type WeightTrendReport struct {
+	From          string             `json:"from"`
+	To            string             `json:"to"`
+	CurrentTrend  *float64           `json:"current_trend"`
+	Rate          *WeightRate        `json:"rate"`
+	Projection    *WeightProjection  `json:"projection"`
+	PlateauWeeks  int                `json:"plateau_weeks"`
+	Plateaus      []WeightPlateau    `json:"plateaus"`
+	PlateauActive bool               `json:"plateau_active"`
+	Points        []WeightTrendPoint `json:"points"`
+
+	Units *UnitPreference `json:"units,omitempty"`
+}
+
+// AttachUnits converts every weight of the report into units
+func (r *WeightTrendReport) AttachUnits(units UnitPreference) {
+	r.Units = &units
+	r.CurrentTrend = ConvertOptional(r.CurrentTrend, units.WeightFromKg)
+	if r.Rate != nil {
+		r.Rate.WeeklyRateKg = units.WeightFromKg(r.Rate.WeeklyRateKg)
+		r.Rate.LowKg = units.WeightFromKg(r.Rate.LowKg)
+		r.Rate.HighKg = units.WeightFromKg(r.Rate.HighKg)
+	}
+	if r.Projection != nil {
+		r.Projection.TargetBodyweight = units.WeightFromKg(r.Projection.TargetBodyweight)
+		r.Projection.RemainingKg = units.WeightFromKg(r.Projection.RemainingKg)
+	}
+	for i := range r.Plateaus {
+		r.Plateaus[i].ChangeKg = units.WeightFromKg(r.Plateaus[i].ChangeKg)
+	}
+	for i := range r.Points {
+		r.Points[i].Bodyweight = ConvertOptional(r.Points[i].Bodyweight, units.WeightFromKg)
+		r.Points[i].Trend = ConvertOptional(r.Points[i].Trend, units.WeightFromKg)
+	}
+}
+
+// BuildWeightTrendReport smooths the weigh-ins from start to end inclusive and
+// reports the days from from onwards; days before from only warm the trend up.
+// targetBodyweight of 0 means no target is set.
+func BuildWeightTrendReport(start, from, end time.Time, weights []DailyBodyweight, targetBodyweight float64, plateauWeeks int) WeightTrendReport {
+	measured := make(map[string]float64, len(weights))
+	for _, day := range weights {
+		measured[day.Date] = day.Bodyweight
+	}
+	var dates []string
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		dates = append(dates, day.Format(SQLDateFormat))
+	}
+	trend := WeightTrend(dates, measured)
+
+	report := WeightTrendReport{
+		From:         from.Format(SQLDateFormat),
+		To:           end.Format(SQLDateFormat),
+		PlateauWeeks: plateauWeeks,
+		Plateaus:     []WeightPlateau{},
+		Points:       []WeightTrendPoint{},
+	}
+	for i, date := range dates {
+		if date < report.From {
+			continue
+		}
+		point := WeightTrendPoint{Date: date, Trend: trend[i]}
+		if weight, ok := measured[date]; ok {
+			point.Bodyweight = &weight
+		}
+		report.Points = append(report.Points, point)
+	}
+	if len(trend) > 0 {
+		report.CurrentTrend = trend[len(trend)-1]
+	}
+
+	report.Rate = fitWeightRate(dates, measured)
+	if report.CurrentTrend != nil {
+		report.Projection = projectWeight(*report.CurrentTrend, report.Rate, targetBodyweight, end)
+	}
+	report.Plateaus = findWeightPlateaus(report.Points, plateauWeeks)
+	if n := len(report.Plateaus); n > 0 && report.Plateaus[n-1].To == report.To {
+		report.PlateauActive = true
+	}
+	return report
+}
+
+// fitWeightRate fits a line through the weigh-ins of the last WeightRateWindowDays
+// dates; nil with fewer than weightRateMinWeighIns weigh-ins or less than a week apart
+func fitWeightRate(dates []string, measured map[string]float64) *WeightRate {
+	first := len(dates) - WeightRateWindowDays
+	if first < 0 {
+		first = 0
+	}
+
+	var xs, ys []float64
+	for i := first; i < len(dates); i++ {
+		if weight, ok := measured[dates[i]]; ok {
+			xs = append(xs, float64(i-first))
+			ys = append(ys, weight)
+		}
+	}
+	n := float64(len(xs))
+	if len(xs) < weightRateMinWeighIns || xs[len(xs)-1]-xs[0] < 7 {
+		return nil
+	}
+
+	var meanX, meanY float64
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= n
+	meanY /= n
+	var sxx, sxy float64
+	for i := range xs {
+		sxx += (xs[i] - meanX) * (xs[i] - meanX)
+		sxy += (xs[i] - meanX) * (ys[i] - meanY)
+	}
+	slope := sxy / sxx
+	var ssr float64
+	for i := range xs {
+		residual := ys[i] - (meanY + slope*(xs[i]-meanX))
+		ssr += residual * residual
+	}
+	standardError := math.Sqrt(ssr / (n - 2) / sxx)
+
+	return &WeightRate{
+		WeeklyRateKg: round2(slope * 7),
+		LowKg:        round2((slope - 1.96*standardError) * 7),
+		HighKg:       round2((slope + 1.96*standardError) * 7),
+		WeighIns:     len(xs),
+		WindowDays:   len(dates) - first,
+	}
+}
+
+// projectWeight extrapolates the current trend towards the target at the fitted rate
+func projectWeight(current float64, rate *WeightRate, target float64, today time.Time) *WeightProjection {
+	if target <= 0 {
+		return &WeightProjection{Reason: "no bodyweight target is set"}
+	}
+	projection := &WeightProjection{TargetBodyweight: target, RemainingKg: round2(target - current)}
+	if math.Abs(projection.RemainingKg) < 0.1 {
+		date := today.Format(SQLDateFormat)
+		projection.ProjectedDate, projection.EarliestDate, projection.LatestDate = &date, &date, &date
+		projection.Reason = "target reached"
+		return projection
+	}
+	if rate == nil {
+		projection.Reason = "at least 3 weigh-ins spread over a week are needed for a rate"
+		return projection
+	}
+
+	// dateAt returns when the target is reached at weeklyKg, nil when never or too far out
+	dateAt := func(weeklyKg float64) *string {
+		days := projection.RemainingKg / (weeklyKg / 7)
+		if weeklyKg == 0 || days <= 0 || days > weightProjectionMaxDays {
+			return nil
+		}
+		date := today.AddDate(0, 0, int(math.Ceil(days))).Format(SQLDateFormat)
+		return &date
+	}
+	projection.ProjectedDate = dateAt(rate.WeeklyRateKg)
+	fast, slow := rate.LowKg, rate.HighKg
+	if projection.RemainingKg > 0 {
+		fast, slow = rate.HighKg, rate.LowKg
+	}
+	projection.EarliestDate = dateAt(fast)
+	projection.LatestDate = dateAt(slow)
+
+	if projection.ProjectedDate == nil {
+		if (projection.RemainingKg > 0) != (rate.WeeklyRateKg > 0) || rate.WeeklyRateKg == 0 {
+			projection.Reason = "the trend is not moving towards the target"
+		} else {
+			projection.Reason = "the target is more than five years away at the current rate"
+		}
+	}
+	return projection
+}
+
+// findWeightPlateaus returns the runs of more than plateauWeeks weeks over
+// which the trend stayed within WeightPlateauBandKg. The scan runs backwards
+// from the latest day so a plateau still going on is found in full.
+func findWeightPlateaus(points []WeightTrendPoint, plateauWeeks int) []WeightPlateau {
+	plateaus := []WeightPlateau{}
+	minDays := plateauWeeks * 7
+	for end := len(points) - 1; end >= 0; {
+		if points[end].Trend == nil {
+			break
+		}
+		low, high := *points[end].Trend, *points[end].Trend
+		begin := end - 1
+		for ; begin >= 0 && points[begin].Trend != nil; begin-- {
+			value := *points[begin].Trend
+			if math.Max(high, value)-math.Min(low, value) > WeightPlateauBandKg {
+				break
+			}
+			low, high = math.Min(low, value), math.Max(high, value)
+		}
+		if days := end - begin; days > minDays {
+			plateaus = append([]WeightPlateau{{
+				From:     points[begin+1].Date,
+				To:       points[end].Date,
+				Weeks:    round2(float64(days) / 7),
+				ChangeKg: round2(*points[end].Trend - *points[begin+1].Trend),
+			}}, plateaus...)
+			end = begin
+			continue
+		}
+		end--
+	}
+	return plateaus
+}
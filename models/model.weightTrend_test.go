@@ -0,0 +1,78 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFitWeightRate(t *testing.T) {
+	// dates returns n consecutive dates; weighIns maps a day index to a weight
+	dates := func(n int) []string {
+		out := make([]string, n)
+		for i := range out {
+			out[i] = fmt.Sprintf("day-%02d", i)
+		}
+		return out
+	}
+	measured := func(weighIns map[int]float64) map[string]float64 {
+		out := make(map[string]float64, len(weighIns))
+		for i, weight := range weighIns {
+			out[fmt.Sprintf("day-%02d", i)] = weight
+		}
+		return out
+	}
+
+	tests := []struct {
+		name     string
+		days     int
+		weighIns map[int]float64
+		want     *WeightRate
+	}{
+		{
+			name:     "exact line",
+			days:     15,
+			weighIns: map[int]float64{0: 80, 7: 79.5, 14: 79},
+			want:     &WeightRate{WeeklyRateKg: -0.5, LowKg: -0.5, HighKg: -0.5, WeighIns: 3, WindowDays: 15},
+		},
+		{
+			name:     "noisy weigh-ins widen the interval",
+			days:     22,
+			weighIns: map[int]float64{0: 80, 7: 79.8, 14: 79.2, 21: 79},
+			want:     &WeightRate{WeeklyRateKg: -0.36, LowKg: -0.47, HighKg: -0.25, WeighIns: 4, WindowDays: 22},
+		},
+		{
+			name:     "weigh-ins before the window are ignored",
+			days:     40,
+			weighIns: map[int]float64{0: 90, 12: 80, 19: 80, 26: 80, 33: 80},
+			want:     &WeightRate{WeighIns: 4, WindowDays: WeightRateWindowDays},
+		},
+		{
+			name:     "too few weigh-ins",
+			days:     15,
+			weighIns: map[int]float64{0: 80, 14: 79},
+		},
+		{
+			name:     "weigh-ins less than a week apart",
+			days:     15,
+			weighIns: map[int]float64{8: 80, 10: 79.8, 14: 79.6},
+		},
+		{
+			name: "no weigh-ins",
+			days: 15,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fitWeightRate(dates(tt.days), measured(tt.weighIns))
+			if got == nil || tt.want == nil {
+				if got != tt.want {
+					t.Fatalf("fitWeightRate() = %+v, want %+v", got, tt.want)
+				}
+				return
+			}
+			if *got != *tt.want {
+				t.Errorf("fitWeightRate() = %+v, want %+v", *got, *tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,423 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/jmoiron/sqlx"
+)
+
+// Workout is one strength training session made of exercises, each done for
+// one or more sets
+type Workout struct {
+	WorkoutId int               `json:"workout_id" db:"workout_id"`
+	UserId    int               `json:"user_id" db:"user_id"`
+	Name      string            `json:"name" db:"name"`
+	StartedAt time.Time         `json:"started_at" db:"started_at"`
+	EndedAt   *time.Time        `json:"ended_at,omitempty" db:"ended_at"`
+	Notes     *string           `json:"notes,omitempty" db:"notes"`
+	Exercises []WorkoutExercise `json:"exercises" db:"-"`
+	// NewPersonalRecords, when set, are the personal records the workout set
+	NewPersonalRecords []PersonalRecord `json:"new_personal_records,omitempty" db:"-"`
+
+	// Units, when set, are the units MarshalJSON writes the weights in
+	Units *UnitPreference `json:"-" db:"-"`
+}
+
+// WorkoutExercise is one exercise of a workout. Exercises sharing a
+// SupersetGroup are done back to back as a superset.
+type WorkoutExercise struct {
+	WorkoutExerciseId int          `json:"workout_exercise_id" db:"workout_exercise_id"`
+	WorkoutId         int          `json:"workout_id" db:"workout_id"`
+	Position          int          `json:"position" db:"position"`
+	CatalogId         *int         `json:"catalog_id,omitempty" db:"catalog_id"`
+	Name              string       `json:"name" db:"name"`
+	SupersetGroup     *int         `json:"superset_group,omitempty" db:"superset_group"`
+	Notes             *string      `json:"notes,omitempty" db:"notes"`
+	Sets              []WorkoutSet `json:"sets" db:"-"`
+}
+
+// WorkoutSet is one set of an exercise. Warmup sets are left out of volume
+// and one-rep max estimates.
+type WorkoutSet struct {
+	SetId             int      `json:"set_id" db:"set_id"`
+	WorkoutExerciseId int      `json:"workout_exercise_id" db:"workout_exercise_id"`
+	Position          int      `json:"position" db:"position"`
+	Reps              int      `json:"reps" db:"reps"`
+	WeightKg          float64  `json:"weight" db:"weight_kg"`
+	Rpe               *float64 `json:"rpe,omitempty" db:"rpe"`
+	RestSeconds       *int     `json:"rest_seconds,omitempty" db:"rest_seconds"`
+	Warmup            bool     `json:"warmup" db:"warmup"`
+
+	// Units, when set, are the units MarshalJSON writes the weights in
+	Units *UnitPreference `json:"-" db:"-"`
+}
+
+// Volume is the weight moved in the set, reps × weight in kg; 0 for warmups
+func (s WorkoutSet) Volume() float64 {
+	if s.Warmup {
+		return 0
+	}
+	return float64(s.Reps) * s.WeightKg
+}
+
+// MarshalJSON : Overloads Workout
+func (a Workout) MarshalJSON() ([]byte, error) {
+	exercises := make([]WorkoutExercise, len(a.Exercises))
+	for i, exercise := range a.Exercises {
+		sets := make([]WorkoutSet, len(exercise.Sets))
+		for j, set := range exercise.Sets {
+			set.Units = a.Units
+			sets[j] = set
+		}
+		exercise.Sets = sets
+		exercises[i] = exercise
+	}
+
+	var newPr *bool
+	if a.NewPersonalRecords != nil {
+		found := len(a.NewPersonalRecords) > 0
+		newPr = &found
+	}
+
+	var endedAt *string
+	if a.EndedAt != nil {
+		formatted := a.EndedAt.Format(time.RFC3339)
+		endedAt = &formatted
+	}
+	return sonic.Marshal(struct {
+		WorkoutId int               `json:"workout_id"`
+		UserId    int               `json:"user_id"`
+		Name      string            `json:"name"`
+		StartedAt string            `json:"started_at"`
+		EndedAt   *string           `json:"ended_at,omitempty"`
+		Notes     *string           `json:"notes,omitempty"`
+		Exercises []WorkoutExercise `json:"exercises"`
+		Units     *UnitPreference   `json:"units,omitempty"`
+
+		NewPr              *bool            `json:"new_pr,omitempty"`
+		NewPersonalRecords []PersonalRecord `json:"new_personal_records,omitempty"`
+	}{
+		WorkoutId: a.WorkoutId,
+		UserId:    a.UserId,
+		Name:      a.Name,
+		StartedAt: a.StartedAt.Format(time.RFC3339),
+		EndedAt:   endedAt,
+		Notes:     a.Notes,
+		Exercises: exercises,
+		Units:     a.Units,
+
+		NewPr:              newPr,
+		NewPersonalRecords: a.NewPersonalRecords,
+	})
+}
+
+// MarshalJSON : Overloads WorkoutSet, adding the one-rep max estimates of working sets
+func (a WorkoutSet) MarshalJSON() ([]byte, error) {
+	var epley, brzycki *float64
+	if !a.Warmup {
+		epley = EstimateOneRepMax(a.WeightKg, a.Reps, OneRepMaxEpley)
+		brzycki = EstimateOneRepMax(a.WeightKg, a.Reps, OneRepMaxBrzycki)
+	}
+	weight := a.WeightKg
+	if a.Units != nil {
+		weight = a.Units.WeightFromKg(weight)
+		epley = ConvertOptional(epley, a.Units.WeightFromKg)
+		brzycki = ConvertOptional(brzycki, a.Units.WeightFromKg)
+	}
+	return sonic.Marshal(struct {
+		SetId       int      `json:"set_id"`
+		Position    int      `json:"position"`
+		Reps        int      `json:"reps"`
+		Weight      float64  `json:"weight"`
+		Rpe         *float64 `json:"rpe,omitempty"`
+		RestSeconds *int     `json:"rest_seconds,omitempty"`
+		Warmup      bool     `json:"warmup"`
+		E1rmEpley   *float64 `json:"e1rm_epley,omitempty"`
+		E1rmBrzycki *float64 `json:"e1rm_brzycki,omitempty"`
+	}{
+		SetId:       a.SetId,
+		Position:    a.Position,
+		Reps:        a.Reps,
+		Weight:      weight,
+		Rpe:         a.Rpe,
+		RestSeconds: a.RestSeconds,
+		Warmup:      a.Warmup,
+		E1rmEpley:   epley,
+		E1rmBrzycki: brzycki,
+	})
+}
+
+// AttachWorkoutUnits makes every workout marshal its weights in units
+func AttachWorkoutUnits(workouts []Workout, units UnitPreference) {
+	for i := range workouts {
+		workouts[i].Units = &units
+	}
+}
+
+// workoutRepository implements WorkoutRepository interface
+type workoutRepository struct{}
+
+// NewWorkoutRepository creates a new workout repository
+func NewWorkoutRepository() WorkoutRepository {
+	return &workoutRepository{}
+}
+
+// WorkoutRepository defines the interface for strength workout operations
+type WorkoutRepository interface {
+	GetByUserId(userId, limit, page int) ([]Workout, error)
+	FindById(userId, workoutId int) (*Workout, error)
+	FindBetween(userId int, from, to time.Time) ([]Workout, error)
+	Create(workout *Workout) error
+	Update(workout *Workout) error
+	Delete(userId, workoutId int) error
+}
+
+const workoutColumns = `workout_id, user_id, name, started_at, ended_at, notes`
+
+// GetByUserId retrieves a page of a user's workouts, newest first, including their exercises and sets
+func (r *workoutRepository) GetByUserId(userId, limit, page int) ([]Workout, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	offset := (page - 1) * limit
+	limit = limit + 1
+
+	var workouts []Workout
+	query := `SELECT ` + workoutColumns + `
+ FROM workout WHERE user_id = $1
+ ORDER BY started_at DESC, workout_id DESC LIMIT $2 OFFSET $3`
+	if err := db.Select(&workouts, query, userId, limit, offset); err != nil {
+		return nil, err
+	}
+	return workouts, attachWorkoutExercises(db, userId, workouts)
+}
+
+// FindById retrieves one workout of a user including its exercises and sets
+func (r *workoutRepository) FindById(userId, workoutId int) (*Workout, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	return findWorkout(db, userId, workoutId)
+}
+
+// FindBetween retrieves the workouts a user started in [from, to), oldest first
+func (r *workoutRepository) FindBetween(userId int, from, to time.Time) ([]Workout, error) {
+	db := GetDB().PostgreDBManager.RC
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var workouts []Workout
+	query := `SELECT ` + workoutColumns + `
+ FROM workout WHERE user_id = $1 AND started_at >= $2 AND started_at < $3
+ ORDER BY started_at ASC, workout_id ASC`
+	if err := db.Select(&workouts, query, userId, from, to); err != nil {
+		return nil, err
+	}
+	return workouts, attachWorkoutExercises(db, userId, workouts)
+}
+
+// Create stores a workout with its exercises and sets in one transaction
+func (r *workoutRepository) Create(workout *Workout) error {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err = insertWorkout(tx, workout); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Update overwrites a workout and replaces its exercises and sets in one
+// transaction. Returns sql.ErrNoRows when the workout does not belong to the user.
+func (r *workoutRepository) Update(workout *Workout) error {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE workout SET name = $1, started_at = $2, ended_at = $3, notes = $4
+	WHERE user_id = $5 AND workout_id = $6`
+	if err = requireOneRow(tx.Exec(query, workout.Name, workout.StartedAt, workout.EndedAt, workout.Notes, workout.UserId, workout.WorkoutId)); err != nil {
+		return err
+	}
+
+	if err = deleteWorkoutExercises(tx, workout.WorkoutId); err != nil {
+		return err
+	}
+	if err = insertWorkoutExercises(tx, workout); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Delete removes a workout with its exercises and sets. Returns sql.ErrNoRows
+// when the workout does not belong to the user.
+func (r *workoutRepository) Delete(userId, workoutId int) error {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id int
+	if err = tx.Get(&id, `SELECT workout_id FROM workout WHERE user_id = $1 AND workout_id = $2 FOR UPDATE`, userId, workoutId); err != nil {
+		return err
+	}
+	if err = deleteWorkoutExercises(tx, workoutId); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`DELETE FROM workout WHERE workout_id = $1`, workoutId); err != nil {
+		return fmt.Errorf("error deleting workout: %w", err)
+	}
+	return tx.Commit()
+}
+
+func findWorkout(q sqlx.Queryer, userId, workoutId int) (*Workout, error) {
+	var workout Workout
+	query := `SELECT ` + workoutColumns + ` FROM workout WHERE user_id = $1 AND workout_id = $2`
+	if err := sqlx.Get(q, &workout, query, userId, workoutId); err != nil {
+		return nil, err
+	}
+
+	workouts := []Workout{workout}
+	if err := attachWorkoutExercises(q, userId, workouts); err != nil {
+		return nil, err
+	}
+	return &workouts[0], nil
+}
+
+// attachWorkoutExercises loads the exercises and sets of workouts, which must
+// all belong to userId. They are selected by the time span of the workouts,
+// so rows of other workouts in that span are read and dropped.
+func attachWorkoutExercises(q sqlx.Queryer, userId int, workouts []Workout) error {
+	if len(workouts) == 0 {
+		return nil
+	}
+	from, to := workouts[0].StartedAt, workouts[0].StartedAt
+	byId := make(map[int]*Workout, len(workouts))
+	for i := range workouts {
+		workouts[i].Exercises = []WorkoutExercise{}
+		byId[workouts[i].WorkoutId] = &workouts[i]
+		if workouts[i].StartedAt.Before(from) {
+			from = workouts[i].StartedAt
+		}
+		if workouts[i].StartedAt.After(to) {
+			to = workouts[i].StartedAt
+		}
+	}
+
+	var exercises []WorkoutExercise
+	exerciseQuery := `SELECT e.workout_exercise_id, e.workout_id, e.position, e.catalog_id, e.name, e.superset_group, e.notes
+ FROM workout_exercise e JOIN workout w ON w.workout_id = e.workout_id
+ WHERE w.user_id = $1 AND w.started_at BETWEEN $2 AND $3
+ ORDER BY e.workout_id, e.position`
+	if err := sqlx.Select(q, &exercises, exerciseQuery, userId, from, to); err != nil {
+		return err
+	}
+
+	var sets []WorkoutSet
+	setQuery := `SELECT s.set_id, s.workout_exercise_id, s.position, s.reps, s.weight_kg, s.rpe, s.rest_seconds, s.warmup
+ FROM workout_set s
+ JOIN workout_exercise e ON e.workout_exercise_id = s.workout_exercise_id
+ JOIN workout w ON w.workout_id = e.workout_id
+ WHERE w.user_id = $1 AND w.started_at BETWEEN $2 AND $3
+ ORDER BY s.workout_exercise_id, s.position`
+	if err := sqlx.Select(q, &sets, setQuery, userId, from, to); err != nil {
+		return err
+	}
+
+	setsByExercise := make(map[int][]WorkoutSet)
+	for _, set := range sets {
+		setsByExercise[set.WorkoutExerciseId] = append(setsByExercise[set.WorkoutExerciseId], set)
+	}
+	for _, exercise := range exercises {
+		workout, ok := byId[exercise.WorkoutId]
+		if !ok {
+			continue
+		}
+		exercise.Sets = setsByExercise[exercise.WorkoutExerciseId]
+		if exercise.Sets == nil {
+			exercise.Sets = []WorkoutSet{}
+		}
+		workout.Exercises = append(workout.Exercises, exercise)
+	}
+	return nil
+}
+
+// insertWorkout stores a new workout with its exercises and sets
+func insertWorkout(tx *sqlx.Tx, workout *Workout) error {
+	query := `INSERT INTO workout (user_id, name, started_at, ended_at, notes, created_at)
+	VALUES ($1, $2, $3, $4, $5, NOW()) RETURNING workout_id`
+	if err := tx.QueryRowx(query, workout.UserId, workout.Name, workout.StartedAt, workout.EndedAt, workout.Notes).Scan(&workout.WorkoutId); err != nil {
+		return fmt.Errorf("error creating workout: %w", err)
+	}
+	return insertWorkoutExercises(tx, workout)
+}
+
+func insertWorkoutExercises(tx *sqlx.Tx, workout *Workout) error {
+	exerciseQuery := `INSERT INTO workout_exercise (workout_id, position, catalog_id, name, superset_group, notes)
+	VALUES ($1, $2, $3, $4, $5, $6) RETURNING workout_exercise_id`
+	setQuery := `INSERT INTO workout_set (workout_exercise_id, position, reps, weight_kg, rpe, rest_seconds, warmup)
+	VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING set_id`
+
+	for i := range workout.Exercises {
+		exercise := &workout.Exercises[i]
+		exercise.WorkoutId = workout.WorkoutId
+		exercise.Position = i + 1
+		err := tx.QueryRowx(exerciseQuery, exercise.WorkoutId, exercise.Position, exercise.CatalogId,
+			exercise.Name, exercise.SupersetGroup, exercise.Notes).Scan(&exercise.WorkoutExerciseId)
+		if err != nil {
+			return fmt.Errorf("error creating workout exercise: %w", err)
+		}
+
+		for j := range exercise.Sets {
+			set := &exercise.Sets[j]
+			set.WorkoutExerciseId = exercise.WorkoutExerciseId
+			set.Position = j + 1
+			err = tx.QueryRowx(setQuery, set.WorkoutExerciseId, set.Position, set.Reps, set.WeightKg,
+				set.Rpe, set.RestSeconds, set.Warmup).Scan(&set.SetId)
+			if err != nil {
+				return fmt.Errorf("error creating workout set: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func deleteWorkoutExercises(tx *sqlx.Tx, workoutId int) error {
+	query := `DELETE FROM workout_set WHERE workout_exercise_id IN
+	(SELECT workout_exercise_id FROM workout_exercise WHERE workout_id = $1)`
+	if _, err := tx.Exec(query, workoutId); err != nil {
+		return fmt.Errorf("error deleting workout sets: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM workout_exercise WHERE workout_id = $1`, workoutId); err != nil {
+		return fmt.Errorf("error deleting workout exercises: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,576 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrProgramSessionNotScheduled is returned when starting a session the program
+// does not prescribe
+var ErrProgramSessionNotScheduled = errors.New("no session is prescribed for that program day")
+
+// programWeightIncrementKg is the plate increment prescribed weights are rounded to
+const programWeightIncrementKg = 2.5
+
+// ProgramExercise is one prescribed exercise of a program day: Sets × Reps at
+// a fixed Weight or at Percentage of the user's best estimated one-rep max,
+// or a timed bout of Minutes for cardio programs like couch-to-5k
+type ProgramExercise struct {
+	CatalogId  *int     `json:"catalog_id,omitempty"`
+	Name       string   `json:"name"`
+	Sets       int      `json:"sets"`
+	Reps       int      `json:"reps"`
+	Percentage *float64 `json:"percentage,omitempty"`
+	WeightKg   *float64 `json:"weight,omitempty"`
+	Minutes    *int     `json:"minutes,omitempty"`
+	Amrap      bool     `json:"amrap,omitempty"`
+	Notes      *string  `json:"notes,omitempty"`
+}
+
+// ProgramDay is one session of a program week. Day 1 is the weekday the user
+// enrolled on, day 7 the day before the next program week starts.
+type ProgramDay struct {
+	Day       int               `json:"day"`
+	Name      string            `json:"name"`
+	Exercises []ProgramExercise `json:"exercises"`
+}
+
+// ProgramWeek is one week of a program
+type ProgramWeek struct {
+	Days []ProgramDay `json:"days"`
+}
+
+// ProgramWeeks is the schedule of a program, stored as JSONB
+type ProgramWeeks []ProgramWeek
+
+// Scan implements the sql.Scanner interface
+func (w *ProgramWeeks) Scan(value interface{}) error {
+	if value == nil {
+		*w = nil
+		return nil
+	}
+
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into ProgramWeeks", value)
+	}
+	return sonic.Unmarshal(data, w)
+}
+
+// Value implements the driver.Valuer interface
+func (w ProgramWeeks) Value() (driver.Value, error) {
+	data, err := sonic.Marshal([]ProgramWeek(w))
+	return string(data), err
+}
+
+// Find returns the session prescribed for a day of a week, both 1-based
+func (w ProgramWeeks) Find(week, day int) (*ProgramDay, bool) {
+	if week < 1 || week > len(w) {
+		return nil, false
+	}
+	for i := range w[week-1].Days {
+		if w[week-1].Days[i].Day == day {
+			return &w[week-1].Days[i], true
+		}
+	}
+	return nil, false
+}
+
+// Sessions is the number of sessions the program prescribes
+func (w ProgramWeeks) Sessions() int {
+	sessions := 0
+	for _, week := range w {
+		sessions += len(week.Days)
+	}
+	return sessions
+}
+
+// WorkoutProgram is a program template: a named schedule of weeks and days,
+// e.g. 5/3/1 or couch-to-5k
+type WorkoutProgram struct {
+	ProgramId   int          `json:"program_id" db:"program_id"`
+	UserId      int          `json:"user_id" db:"user_id"`
+	Name        string       `json:"name" db:"name"`
+	Description *string      `json:"description,omitempty" db:"description"`
+	Weeks       ProgramWeeks `json:"weeks" db:"weeks"`
+	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
+
+	// Units, when set, are the units MarshalJSON writes fixed weights in
+	Units *UnitPreference `json:"-" db:"-"`
+}
+
+// MarshalJSON : Overloads WorkoutProgram
+func (a WorkoutProgram) MarshalJSON() ([]byte, error) {
+	weeks := make([]ProgramWeek, len(a.Weeks))
+	for i, week := range a.Weeks {
+		days := make([]ProgramDay, len(week.Days))
+		for j, day := range week.Days {
+			days[j] = day.inUnits(a.Units)
+		}
+		weeks[i] = ProgramWeek{Days: days}
+	}
+	return sonic.Marshal(struct {
+		ProgramId   int             `json:"program_id"`
+		UserId      int             `json:"user_id"`
+		Name        string          `json:"name"`
+		Description *string         `json:"description,omitempty"`
+		Weeks       []ProgramWeek   `json:"weeks"`
+		Sessions    int             `json:"sessions"`
+		CreatedAt   string          `json:"created_at"`
+		Units       *UnitPreference `json:"units,omitempty"`
+	}{
+		ProgramId:   a.ProgramId,
+		UserId:      a.UserId,
+		Name:        a.Name,
+		Description: a.Description,
+		Weeks:       weeks,
+		Sessions:    a.Weeks.Sessions(),
+		CreatedAt:   a.CreatedAt.Format(time.RFC3339),
+		Units:       a.Units,
+	})
+}
+
+// inUnits returns a copy of the day with its fixed weights converted to units
+func (d ProgramDay) inUnits(units *UnitPreference) ProgramDay {
+	exercises := make([]ProgramExercise, len(d.Exercises))
+	for i, exercise := range d.Exercises {
+		if units != nil {
+			exercise.WeightKg = ConvertOptional(exercise.WeightKg, units.WeightFromKg)
+		}
+		exercises[i] = exercise
+	}
+	d.Exercises = exercises
+	return d
+}
+
+// AttachWorkoutProgramUnits makes every program marshal its weights in units
+func AttachWorkoutProgramUnits(programs []WorkoutProgram, units UnitPreference) {
+	for i := range programs {
+		programs[i].Units = &units
+	}
+}
+
+// ProgramEnrolment is a user following a program from StartDate. Completed
+// counts the prescribed sessions whose workout still exists and has ended.
+type ProgramEnrolment struct {
+	EnrolmentId int       `json:"enrolment_id" db:"enrolment_id"`
+	UserId      int       `json:"user_id" db:"user_id"`
+	ProgramId   int       `json:"program_id" db:"program_id"`
+	ProgramName string    `json:"program_name" db:"program_name"`
+	StartDate   time.Time `json:"start_date" db:"start_date"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	Completed   int       `json:"completed_sessions" db:"completed_sessions"`
+
+	Weeks ProgramWeeks `json:"-" db:"weeks"`
+}
+
+// MarshalJSON : Overloads ProgramEnrolment, adding the end date and completion
+func (a ProgramEnrolment) MarshalJSON() ([]byte, error) {
+	total := a.Weeks.Sessions()
+	completion := 0.0
+	if total > 0 {
+		completion = round2(math.Min(float64(a.Completed)/float64(total)*100, 100))
+	}
+	return sonic.Marshal(struct {
+		EnrolmentId   int     `json:"enrolment_id"`
+		UserId        int     `json:"user_id"`
+		ProgramId     int     `json:"program_id"`
+		ProgramName   string  `json:"program_name"`
+		StartDate     string  `json:"start_date"`
+		EndDate       string  `json:"end_date"`
+		Completed     int     `json:"completed_sessions"`
+		TotalSessions int     `json:"total_sessions"`
+		CompletionPct float64 `json:"completion_pct"`
+		CreatedAt     string  `json:"created_at"`
+	}{
+		EnrolmentId:   a.EnrolmentId,
+		UserId:        a.UserId,
+		ProgramId:     a.ProgramId,
+		ProgramName:   a.ProgramName,
+		StartDate:     a.StartDate.Format(SQLDateFormat),
+		EndDate:       a.StartDate.AddDate(0, 0, len(a.Weeks)*7-1).Format(SQLDateFormat),
+		Completed:     a.Completed,
+		TotalSessions: total,
+		CompletionPct: completion,
+		CreatedAt:     a.CreatedAt.Format(time.RFC3339),
+	})
+}
+
+// ProgramDayOn returns the 1-based week and day of the program falling on
+// date. ok is false before the start date and after the last week.
+func (a ProgramEnrolment) ProgramDayOn(date time.Time) (week, day int, ok bool) {
+	offset := a.dayOffset(date)
+	if offset < 0 || offset >= len(a.Weeks)*7 {
+		return 0, 0, false
+	}
+	return offset/7 + 1, offset%7 + 1, true
+}
+
+// NextSessionFrom returns the first program day with a prescribed session on
+// or after date. ok is false once the program has no sessions left.
+func (a ProgramEnrolment) NextSessionFrom(date time.Time) (week, day int, ok bool) {
+	for offset := max(a.dayOffset(date), 0); offset < len(a.Weeks)*7; offset++ {
+		week, day = offset/7+1, offset%7+1
+		if _, found := a.Weeks.Find(week, day); found {
+			return week, day, true
+		}
+	}
+	return 0, 0, false
+}
+
+// dayOffset is the number of calendar days from the start date to date
+func (a ProgramEnrolment) dayOffset(date time.Time) int {
+	start := time.Date(a.StartDate.Year(), a.StartDate.Month(), a.StartDate.Day(), 0, 0, 0, 0, time.UTC)
+	on := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	return int(on.Sub(start).Hours() / 24)
+}
+
+// DateOf returns the date a week and day of the program falls on
+func (a ProgramEnrolment) DateOf(week, day int) time.Time {
+	return a.StartDate.AddDate(0, 0, (week-1)*7+day-1)
+}
+
+// ProgramSession is one prescribed session of an enrolment resolved for the
+// user: percentages become weights off their best estimated one-rep max
+type ProgramSession struct {
+	EnrolmentId int               `json:"enrolment_id"`
+	Date        string            `json:"date"`
+	Week        int               `json:"week"`
+	Day         int               `json:"day"`
+	Name        string            `json:"name"`
+	Exercises   []ProgramExercise `json:"exercises"`
+	WorkoutId   *int              `json:"workout_id,omitempty"`
+	Completed   bool              `json:"completed"`
+	Units       *UnitPreference   `json:"units,omitempty"`
+}
+
+// ResolveProgramSession prescribes a program day. Exercises with a percentage
+// get that share of oneRepMaxes (kg by ExerciseKey) rounded down to
+// programWeightIncrementKg; the percentage is kept when no one-rep max is known.
+func ResolveProgramSession(enrolment ProgramEnrolment, week int, programDay ProgramDay, oneRepMaxes map[string]float64) ProgramSession {
+	session := ProgramSession{
+		EnrolmentId: enrolment.EnrolmentId,
+		Date:        enrolment.DateOf(week, programDay.Day).Format(SQLDateFormat),
+		Week:        week,
+		Day:         programDay.Day,
+		Name:        programDay.Name,
+		Exercises:   make([]ProgramExercise, len(programDay.Exercises)),
+	}
+	for i, exercise := range programDay.Exercises {
+		if exercise.Percentage != nil && exercise.WeightKg == nil {
+			if best, ok := oneRepMaxes[ExerciseKey(exercise.CatalogId, exercise.Name)]; ok {
+				weight := math.Floor(best**exercise.Percentage/100/programWeightIncrementKg) * programWeightIncrementKg
+				exercise.WeightKg = &weight
+			}
+		}
+		session.Exercises[i] = exercise
+	}
+	return session
+}
+
+// AttachUnits converts the prescribed weights into units
+func (s *ProgramSession) AttachUnits(units UnitPreference) {
+	s.Units = &units
+	for i := range s.Exercises {
+		s.Exercises[i].WeightKg = ConvertOptional(s.Exercises[i].WeightKg, units.WeightFromKg)
+	}
+}
+
+// ToWorkout pre-fills a workout with the prescription: one set per prescribed
+// set at the resolved weight. Timed exercises get a single set with their
+// minutes in the notes.
+func (s ProgramSession) ToWorkout(userId int, startedAt time.Time) *Workout {
+	name := s.Name
+	if name == "" {
+		name = "Week " + strconv.Itoa(s.Week) + " day " + strconv.Itoa(s.Day)
+	}
+	workout := &Workout{
+		UserId:    userId,
+		Name:      name,
+		StartedAt: startedAt,
+		Exercises: make([]WorkoutExercise, 0, len(s.Exercises)),
+	}
+	for _, prescribed := range s.Exercises {
+		exercise := WorkoutExercise{
+			CatalogId: prescribed.CatalogId,
+			Name:      prescribed.Name,
+			Notes:     prescribed.Notes,
+		}
+		if prescribed.Minutes != nil {
+			notes := strconv.Itoa(*prescribed.Minutes) + " min"
+			if prescribed.Notes != nil {
+				notes += ", " + *prescribed.Notes
+			}
+			exercise.Notes = &notes
+		}
+
+		sets, reps := max(prescribed.Sets, 1), max(prescribed.Reps, 1)
+		var weight float64
+		if prescribed.WeightKg != nil {
+			weight = *prescribed.WeightKg
+		}
+		for i := 0; i < sets; i++ {
+			exercise.Sets = append(exercise.Sets, WorkoutSet{Reps: reps, WeightKg: weight})
+		}
+		workout.Exercises = append(workout.Exercises, exercise)
+	}
+	return workout
+}
+
+// workoutProgramRepository implements WorkoutProgramRepository interface
+type workoutProgramRepository struct{}
+
+// NewWorkoutProgramRepository creates a new workout program repository
+func NewWorkoutProgramRepository() WorkoutProgramRepository {
+	return &workoutProgramRepository{}
+}
+
+// WorkoutProgramRepository defines the interface for workout program and enrolment operations
+type WorkoutProgramRepository interface {
+	GetByUserId(userId int) ([]WorkoutProgram, error)
+	FindById(userId, programId int) (*WorkoutProgram, error)
+	Create(program *WorkoutProgram) error
+	Update(program *WorkoutProgram) error
+	Delete(userId, programId int) error
+
+	Enrol(userId, programId int, startDate time.Time) (*ProgramEnrolment, error)
+	GetEnrolments(userId int) ([]ProgramEnrolment, error)
+	FindEnrolment(userId, enrolmentId int) (*ProgramEnrolment, error)
+	DeleteEnrolment(userId, enrolmentId int) error
+	FindSessionWorkout(enrolmentId, week, day int) (*int, bool, error)
+	StartSession(enrolmentId, week, day int, workout *Workout) error
+}
+
+const workoutProgramColumns = `program_id, user_id, name, description, weeks, created_at`
+
+const programEnrolmentColumns = `e.enrolment_id, e.user_id, e.program_id, p.name AS program_name, p.weeks,
+	e.start_date, e.created_at,
+	(SELECT COUNT(DISTINCT (s.week, s.day)) FROM program_session s JOIN workout w ON w.workout_id = s.workout_id
+	 WHERE s.enrolment_id = e.enrolment_id AND w.ended_at IS NOT NULL) AS completed_sessions`
+
+// GetByUserId retrieves every program template of a user
+func (r *workoutProgramRepository) GetByUserId(userId int) ([]WorkoutProgram, error) {
+	db := GetDB().PostgreDBManager.RC
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var programs []WorkoutProgram
+	query := `SELECT ` + workoutProgramColumns + ` FROM workout_program WHERE user_id = $1 ORDER BY name ASC`
+	err := db.Select(&programs, query, userId)
+	return programs, err
+}
+
+// FindById retrieves one program template of a user
+func (r *workoutProgramRepository) FindById(userId, programId int) (*WorkoutProgram, error) {
+	db := GetDB().PostgreDBManager.RC
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var program WorkoutProgram
+	query := `SELECT ` + workoutProgramColumns + ` FROM workout_program WHERE user_id = $1 AND program_id = $2`
+	if err := db.Get(&program, query, userId, programId); err != nil {
+		return nil, err
+	}
+	return &program, nil
+}
+
+// Create stores a program template
+func (r *workoutProgramRepository) Create(program *WorkoutProgram) error {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	query := `INSERT INTO workout_program (user_id, name, description, weeks, created_at)
+	VALUES ($1, $2, $3, $4, NOW()) RETURNING program_id, created_at`
+	return db.QueryRowx(query, program.UserId, program.Name, program.Description, program.Weeks).Scan(&program.ProgramId, &program.CreatedAt)
+}
+
+// Update overwrites a program template; enrolments follow the new schedule.
+// Returns sql.ErrNoRows when the program does not belong to the user.
+func (r *workoutProgramRepository) Update(program *WorkoutProgram) error {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	query := `UPDATE workout_program SET name = $1, description = $2, weeks = $3
+	WHERE user_id = $4 AND program_id = $5 RETURNING created_at`
+	return db.QueryRowx(query, program.Name, program.Description, program.Weeks, program.UserId, program.ProgramId).Scan(&program.CreatedAt)
+}
+
+// Delete removes a program template with its enrolments; workouts started from
+// it are kept. Returns sql.ErrNoRows when the program does not belong to the user.
+func (r *workoutProgramRepository) Delete(userId, programId int) error {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id int
+	if err = tx.Get(&id, `SELECT program_id FROM workout_program WHERE user_id = $1 AND program_id = $2 FOR UPDATE`, userId, programId); err != nil {
+		return err
+	}
+	query := `DELETE FROM program_session WHERE enrolment_id IN
+	(SELECT enrolment_id FROM program_enrolment WHERE program_id = $1)`
+	if _, err = tx.Exec(query, programId); err != nil {
+		return fmt.Errorf("error deleting program sessions: %w", err)
+	}
+	if _, err = tx.Exec(`DELETE FROM program_enrolment WHERE program_id = $1`, programId); err != nil {
+		return fmt.Errorf("error deleting program enrolments: %w", err)
+	}
+	if _, err = tx.Exec(`DELETE FROM workout_program WHERE program_id = $1`, programId); err != nil {
+		return fmt.Errorf("error deleting workout program: %w", err)
+	}
+	return tx.Commit()
+}
+
+// Enrol starts following a program of the user from startDate. Returns
+// sql.ErrNoRows when the program does not belong to the user.
+func (r *workoutProgramRepository) Enrol(userId, programId int, startDate time.Time) (*ProgramEnrolment, error) {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var enrolmentId int
+	query := `INSERT INTO program_enrolment (user_id, program_id, start_date, created_at)
+	SELECT user_id, program_id, $3::date, NOW() FROM workout_program WHERE user_id = $1 AND program_id = $2
+	RETURNING enrolment_id`
+	if err := db.QueryRowx(query, userId, programId, startDate.Format(SQLDateFormat)).Scan(&enrolmentId); err != nil {
+		return nil, err
+	}
+	return findProgramEnrolment(db, userId, enrolmentId)
+}
+
+// GetEnrolments retrieves every enrolment of a user with its completion, latest start first
+func (r *workoutProgramRepository) GetEnrolments(userId int) ([]ProgramEnrolment, error) {
+	db := GetDB().PostgreDBManager.RC
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	var enrolments []ProgramEnrolment
+	query := `SELECT ` + programEnrolmentColumns + `
+ FROM program_enrolment e JOIN workout_program p ON p.program_id = e.program_id
+ WHERE e.user_id = $1 ORDER BY e.start_date DESC, e.enrolment_id DESC`
+	err := db.Select(&enrolments, query, userId)
+	return enrolments, err
+}
+
+// FindEnrolment retrieves one enrolment of a user with its completion
+func (r *workoutProgramRepository) FindEnrolment(userId, enrolmentId int) (*ProgramEnrolment, error) {
+	db := GetDB().PostgreDBManager.RC
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	return findProgramEnrolment(db, userId, enrolmentId)
+}
+
+// DeleteEnrolment stops following a program; workouts started from it are
+// kept. Returns sql.ErrNoRows when the enrolment does not belong to the user.
+func (r *workoutProgramRepository) DeleteEnrolment(userId, enrolmentId int) error {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(`DELETE FROM program_session WHERE enrolment_id IN
+	(SELECT enrolment_id FROM program_enrolment WHERE user_id = $1 AND enrolment_id = $2)`, userId, enrolmentId); err != nil {
+		return fmt.Errorf("error deleting program sessions: %w", err)
+	}
+	if err = requireOneRow(tx.Exec(`DELETE FROM program_enrolment WHERE user_id = $1 AND enrolment_id = $2`, userId, enrolmentId)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FindSessionWorkout returns the workout started for a program day, nil when
+// none was or it has been deleted since, and whether that workout has ended.
+// A finished workout is preferred over one started again later.
+func (r *workoutProgramRepository) FindSessionWorkout(enrolmentId, week, day int) (*int, bool, error) {
+	db := GetDB().PostgreDBManager.RC
+	if db == nil {
+		return nil, false, fmt.Errorf("database connection is nil")
+	}
+
+	var workouts []struct {
+		WorkoutId int  `db:"workout_id"`
+		Completed bool `db:"completed"`
+	}
+	query := `SELECT s.workout_id, w.ended_at IS NOT NULL AS completed
+	FROM program_session s JOIN workout w ON w.workout_id = s.workout_id
+	WHERE s.enrolment_id = $1 AND s.week = $2 AND s.day = $3
+	ORDER BY completed DESC, s.workout_id DESC LIMIT 1`
+	if err := db.Select(&workouts, query, enrolmentId, week, day); err != nil {
+		return nil, false, err
+	}
+	if len(workouts) == 0 {
+		return nil, false, nil
+	}
+	return &workouts[0].WorkoutId, workouts[0].Completed, nil
+}
+
+// StartSession stores the pre-filled workout of a program day and links it to
+// the enrolment in one transaction
+func (r *workoutProgramRepository) StartSession(enrolmentId, week, day int, workout *Workout) error {
+	db := GetDB().PostgreDBManager.RW
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err = insertWorkout(tx, workout); err != nil {
+		return err
+	}
+
+	sessionQuery := `INSERT INTO program_session (enrolment_id, week, day, workout_id, started_at)
+	VALUES ($1, $2, $3, $4, NOW())`
+	if _, err = tx.Exec(sessionQuery, enrolmentId, week, day, workout.WorkoutId); err != nil {
+		return fmt.Errorf("error creating program session: %w", err)
+	}
+	return tx.Commit()
+}
+
+func findProgramEnrolment(q sqlx.Queryer, userId, enrolmentId int) (*ProgramEnrolment, error) {
+	var enrolment ProgramEnrolment
+	query := `SELECT ` + programEnrolmentColumns + `
+ FROM program_enrolment e JOIN workout_program p ON p.program_id = e.program_id
+ WHERE e.user_id = $1 AND e.enrolment_id = $2`
+	if err := sqlx.Get(q, &enrolment, query, userId, enrolmentId); err != nil {
+		return nil, err
+	}
+	return &enrolment, nil
+}
@@ -2,6 +2,7 @@ package router
 
 import (
 	"github.com/WahyuSiddarta/be_saham_go/api"
+	"github.com/WahyuSiddarta/be_saham_go/blobstore"
 	"github.com/WahyuSiddarta/be_saham_go/models"
 	"github.com/WahyuSiddarta/be_saham_go/validator"
 	"github.com/labstack/echo/v4"
@@ -15,6 +16,15 @@ func (r *Router) setupProtectedRoutes(apiGroup *echo.Group) {
 	setupFoodNutritionRoutes(protectedGroup)
 	setupBodyMeasurementRoutes(protectedGroup)
 	setupExcerciseRoutes(protectedGroup)
+	setupWorkoutRoutes(protectedGroup)
+	setupPersonalRecordRoutes(protectedGroup)
+	setupWorkoutProgramRoutes(protectedGroup)
+	setupHydrationRoutes(protectedGroup)
+	setupMealPlanRoutes(protectedGroup)
+	setupGroceryListRoutes(protectedGroup)
+	setupFastingRoutes(protectedGroup)
+	setupEnergyRoutes(protectedGroup)
+	setupProgressPhotoRoutes(protectedGroup, r.blobStore)
 }
 
 func setupUserRoutes(group *echo.Group) {
@@ -24,10 +34,24 @@ func setupUserRoutes(group *echo.Group) {
 	// Initialize auth handlers
 	userRepo := models.NewUserRepository()
 	userHandlers := api.NewUserHandlers(userRepo)
+	usersGroup.Use(validator.ResolveUnits(userRepo))
 	usersGroup.GET("/personal-target", userHandlers.GetPersonalTarget)
+	usersGroup.GET("/personal-target/history", userHandlers.GetPersonalTargetHistory, validator.ValidateQuery(&validator.PersonalTargetHistoryQuery{}))
 	usersGroup.PUT("/personal-target/nutrition", userHandlers.UpdatePersonalNutritionTarget, validator.ValidateRequest(&validator.PersonalNutritionTargetRequest{}))
 	usersGroup.PUT("/personal-target/body-measurement", userHandlers.UpdatePersonalBodyMeasurementTarget, validator.ValidateRequest(&validator.PersonalBodyMeasurementTargetRequest{}))
 	usersGroup.PUT("/personal-target/exercise", userHandlers.UpdatePersonalExerciseTarget, validator.ValidateRequest(&validator.PersonalExerciseTargetRequest{}))
+	usersGroup.PUT("/personal-target/hydration", userHandlers.UpdatePersonalHydrationTarget, validator.ValidateRequest(&validator.PersonalHydrationTargetRequest{}))
+	usersGroup.GET("/timezone", userHandlers.GetUserTimezone)
+	usersGroup.PUT("/timezone", userHandlers.UpdateUserTimezone, validator.ValidateRequest(&validator.UserTimezoneRequest{}))
+	usersGroup.GET("/units", userHandlers.GetUnitPreference)
+	usersGroup.PUT("/units", userHandlers.UpdateUnitPreference, validator.ValidateRequest(&validator.UnitPreferenceRequest{}))
+
+	// Profile and recommended targets
+	profileHandlers := api.NewProfileHandlers(userRepo, models.NewBodyMeasurementRepository())
+	usersGroup.GET("/profile", profileHandlers.GetUserProfile)
+	usersGroup.PUT("/profile", profileHandlers.UpdateUserProfile, validator.ValidateRequest(&validator.UserProfileRequest{}))
+	usersGroup.GET("/personal-target/recommendation", profileHandlers.GetTargetRecommendation)
+	usersGroup.POST("/personal-target/recommendation/apply", profileHandlers.ApplyTargetRecommendation)
 }
 
 func setupExcerciseRoutes(group *echo.Group) {
@@ -36,13 +60,79 @@ func setupExcerciseRoutes(group *echo.Group) {
 
 	// Initialize exercise handlers
 	exerciseRepo := models.NewexcerciseRecordRepository()
-	exerciseHandler := api.NewExcerciseHandlers(exerciseRepo)
+	recordRepo := models.NewPersonalRecordRepository()
+	exerciseHandler := api.NewExcerciseHandlers(exerciseRepo, models.NewBodyMeasurementRepository(), recordRepo, models.NewExerciseCatalogRepository())
+	exerciseGroup.Use(validator.ResolveUnits(models.NewUserRepository()))
 
 	// Daily exercise routes
 	exerciseGroup.GET("", exerciseHandler.GetUserExcercises, validator.ValidateQuery(&validator.ExerciseRequest{}))
 	exerciseGroup.POST("", exerciseHandler.AddExercise, validator.ValidateRequest(&validator.ExcerciseMutationRequest{}))
 	exerciseGroup.PUT("/:exercise_id", exerciseHandler.UpdateExercise, validator.ValidateRequest(&validator.ExcerciseMutationRequest{}))
 	exerciseGroup.DELETE("/:exercise_id", exerciseHandler.DeleteExercise)
+	exerciseGroup.POST("/bulk", exerciseHandler.BulkExercises, validator.ValidateRequest(&validator.BulkRequest{}))
+
+	// GPX, TCX and FIT activity files logged as cardio sessions with their route
+	exerciseGroup.POST("/import", exerciseHandler.ImportActivity, validator.ValidateRequest(&validator.ExcerciseActivityImportRequest{}))
+	exerciseGroup.GET("/:exercise_id/route", exerciseHandler.GetExerciseRoute)
+
+	// Exercise catalog with MET values for calorie estimates
+	exerciseGroup.GET("/catalog", exerciseHandler.GetExerciseCatalog, validator.ValidateQuery(&validator.ExerciseCatalogQuery{}))
+	exerciseGroup.GET("/catalog/:catalog_id", exerciseHandler.GetExerciseCatalogEntry)
+}
+
+func setupWorkoutProgramRoutes(group *echo.Group) {
+	// Define workout program-related protected routes here
+	programGroup := group.Group("/workout-programs")
+
+	// Initialize workout program handlers
+	programHandler := api.NewWorkoutProgramHandlers(models.NewWorkoutProgramRepository(), models.NewPersonalRecordRepository(), models.NewExerciseCatalogRepository())
+	programGroup.Use(validator.ResolveUnits(models.NewUserRepository()))
+
+	// Program templates of weeks, days and prescribed exercises
+	programGroup.GET("", programHandler.GetWorkoutPrograms)
+	programGroup.POST("", programHandler.AddWorkoutProgram, validator.ValidateRequest(&validator.WorkoutProgramRequest{}))
+	programGroup.GET("/:program_id", programHandler.GetWorkoutProgram)
+	programGroup.PUT("/:program_id", programHandler.UpdateWorkoutProgram, validator.ValidateRequest(&validator.WorkoutProgramRequest{}))
+	programGroup.DELETE("/:program_id", programHandler.DeleteWorkoutProgram)
+	programGroup.POST("/:program_id/enrol", programHandler.EnrolWorkoutProgram, validator.ValidateRequest(&validator.ProgramEnrolRequest{}))
+
+	// Enrolments with completion, the session prescribed on ?date= (default today) and starting it as a workout
+	programGroup.GET("/enrolments", programHandler.GetProgramEnrolments)
+	programGroup.DELETE("/enrolments/:enrolment_id", programHandler.DeleteProgramEnrolment)
+	programGroup.GET("/enrolments/:enrolment_id/session", programHandler.GetProgramSession, validator.ValidateQuery(&validator.ProgramSessionQuery{}))
+	programGroup.POST("/enrolments/:enrolment_id/session/start", programHandler.StartProgramSession, validator.ValidateRequest(&validator.ProgramSessionStartRequest{}))
+}
+
+func setupPersonalRecordRoutes(group *echo.Group) {
+	// Define personal record-related protected routes here
+	recordGroup := group.Group("/personal-records")
+
+	// Initialize personal record handlers
+	recordHandler := api.NewPersonalRecordHandlers(models.NewPersonalRecordRepository(), models.NewExerciseCatalogRepository())
+	recordGroup.Use(validator.ResolveUnits(models.NewUserRepository()))
+
+	// Current bests, and every record of one exercise (?catalog_id= or ?name=) oldest first
+	recordGroup.GET("", recordHandler.GetPersonalRecords)
+	recordGroup.GET("/timeline", recordHandler.GetPersonalRecordTimeline, validator.ValidateQuery(&validator.PersonalRecordTimelineQuery{}))
+	recordGroup.POST("/recompute", recordHandler.RecomputePersonalRecords)
+}
+
+func setupWorkoutRoutes(group *echo.Group) {
+	// Define strength workout-related protected routes here
+	workoutGroup := group.Group("/workouts")
+
+	// Initialize workout handlers
+	userRepo := models.NewUserRepository()
+	workoutHandler := api.NewWorkoutHandlers(models.NewWorkoutRepository(), userRepo, models.NewPersonalRecordRepository(), models.NewexcerciseRecordRepository(), models.NewExerciseCatalogRepository())
+	workoutGroup.Use(validator.ResolveUnits(userRepo))
+
+	workoutGroup.GET("", workoutHandler.GetWorkouts, validator.ValidateQuery(&validator.WorkoutListRequest{}))
+	workoutGroup.POST("", workoutHandler.AddWorkout, validator.ValidateRequest(&validator.WorkoutRequest{}))
+	workoutGroup.GET("/:workout_id", workoutHandler.GetWorkout)
+	workoutGroup.PUT("/:workout_id", workoutHandler.UpdateWorkout, validator.ValidateRequest(&validator.WorkoutRequest{}))
+	workoutGroup.DELETE("/:workout_id", workoutHandler.DeleteWorkout)
+	// Sessions, volume per muscle group and best lifts for the Monday-based week containing :date (YYYY-MM-DD or "today")
+	workoutGroup.GET("/weeks/:date/stats", workoutHandler.GetWeeklyStrengthStats, validator.ValidateQuery(&validator.StrengthWeeklyStatsQuery{}))
 }
 
 func setupFoodNutritionRoutes(group *echo.Group) {
@@ -51,7 +141,10 @@ func setupFoodNutritionRoutes(group *echo.Group) {
 
 	// Initialize auth handlers
 	nutritionRepo := models.NewNutritionRepository()
-	nutritionHandler := api.NewNutritionHandlers(nutritionRepo)
+	userRepo := models.NewUserRepository()
+	fastingRepo := models.NewFastingRepository()
+	nutritionHandler := api.NewNutritionHandlers(nutritionRepo, userRepo, fastingRepo)
+	nutritionGroup.Use(validator.ResolveUnits(userRepo))
 
 	// Daily nutrition intake routes
 	nutritionGroup.GET("/today", nutritionHandler.GetTodaysNutritionIntake)
@@ -59,9 +152,29 @@ func setupFoodNutritionRoutes(group *echo.Group) {
 	nutritionGroup.PUT("/today/:food_id", nutritionHandler.UpdateNutritionIntake, validator.ValidateRequest(&validator.NutritionRequest{}))
 	nutritionGroup.DELETE("/today/:food_id", nutritionHandler.DeleteNutritionIntake)
 
+	// Batch upload for clients syncing after being offline
+	nutritionGroup.POST("/bulk", nutritionHandler.BulkNutritionIntake, validator.ValidateRequest(&validator.BulkRequest{}))
+
+	// Summary against personal targets, :date is YYYY-MM-DD or "today"
+	nutritionGroup.GET("/days/:date/summary", nutritionHandler.GetDailyNutritionSummary)
+	nutritionGroup.GET("/weeks/:date/summary", nutritionHandler.GetWeeklyNutritionSummary)
+
 	// Overview nutrition routes can be added here
-	nutritionGroup.GET("/chart", nutritionHandler.GetNutritionChartData)
+	nutritionGroup.GET("/chart", nutritionHandler.GetNutritionChartData, validator.ValidateQuery(&validator.NutritionChartQuery{}))
 	nutritionGroup.GET("/all-the-time", nutritionHandler.GetNutritionAllTime, validator.ValidateQuery(&validator.BodyMeasurementRequest{}))
+
+	// Quick logging: copy a previous meal and pick from frequent or recent foods
+	nutritionGroup.POST("/copy-meal", nutritionHandler.CopyMeal, validator.ValidateRequest(&validator.CopyMealRequest{}))
+	nutritionGroup.GET("/foods/frequent", nutritionHandler.GetFrequentFoods, validator.ValidateQuery(&validator.FrequentFoodsQuery{}))
+
+	// Saved meal templates
+	mealTemplateRepo := models.NewMealTemplateRepository()
+	mealTemplateHandler := api.NewMealTemplateHandlers(mealTemplateRepo, fastingRepo)
+	nutritionGroup.GET("/templates", mealTemplateHandler.GetMealTemplates)
+	nutritionGroup.POST("/templates", mealTemplateHandler.AddMealTemplate, validator.ValidateRequest(&validator.MealTemplateRequest{}))
+	nutritionGroup.PUT("/templates/:template_id", mealTemplateHandler.UpdateMealTemplate, validator.ValidateRequest(&validator.MealTemplateRequest{}))
+	nutritionGroup.DELETE("/templates/:template_id", mealTemplateHandler.DeleteMealTemplate)
+	nutritionGroup.POST("/templates/:template_id/apply", mealTemplateHandler.ApplyMealTemplate, validator.ValidateRequest(&validator.ApplyMealTemplateRequest{}))
 }
 
 func setupBodyMeasurementRoutes(group *echo.Group) {
@@ -70,10 +183,126 @@ func setupBodyMeasurementRoutes(group *echo.Group) {
 
 	// Initialize body measurement handlers
 	bodyMeasurementRepo := models.NewBodyMeasurementRepository()
-	bodyMeasurementHandler := api.NewBodyMeasurementHandlers(bodyMeasurementRepo)
+	userRepo := models.NewUserRepository()
+	bodyMeasurementHandler := api.NewBodyMeasurementHandlers(bodyMeasurementRepo, userRepo)
+	bodyMeasurementGroup.Use(validator.ResolveUnits(userRepo))
 
 	bodyMeasurementGroup.GET("", bodyMeasurementHandler.GetBodyMeasurements, validator.ValidateQuery(&validator.BodyMeasurementRequest{}))
+	bodyMeasurementGroup.GET("/trend", bodyMeasurementHandler.GetWeightTrend, validator.ValidateQuery(&validator.WeightTrendQuery{}))
+	bodyMeasurementGroup.GET("/flagged", bodyMeasurementHandler.GetFlaggedBodyMeasurements, validator.ValidateQuery(&validator.BodyMeasurementRequest{}))
 	bodyMeasurementGroup.POST("", bodyMeasurementHandler.AddBodyMeasurement, validator.ValidateRequest(&validator.BodyMeasurementCreateRequest{}))
 	bodyMeasurementGroup.PUT("/:measurement_id", bodyMeasurementHandler.UpdateBodyMeasurement, validator.ValidateRequest(&validator.BodyMeasurementCreateRequest{}))
 	bodyMeasurementGroup.DELETE("/:measurement_id", bodyMeasurementHandler.DeleteBodyMeasurement)
+	bodyMeasurementGroup.POST("/:measurement_id/review", bodyMeasurementHandler.ReviewBodyMeasurement)
+	bodyMeasurementGroup.POST("/bulk", bodyMeasurementHandler.BulkBodyMeasurements, validator.ValidateRequest(&validator.BulkRequest{}))
+	bodyMeasurementGroup.POST("/import", bodyMeasurementHandler.ImportBodyMeasurements, validator.ValidateRequest(&validator.BodyMeasurementImportRequest{}))
+}
+
+func setupHydrationRoutes(group *echo.Group) {
+	// Define hydration-related protected routes here
+	hydrationGroup := group.Group("/hydration-tracker")
+
+	// Initialize hydration handlers
+	hydrationRepo := models.NewHydrationRepository()
+	userRepo := models.NewUserRepository()
+	hydrationHandler := api.NewHydrationHandlers(hydrationRepo, userRepo)
+	hydrationGroup.Use(validator.ResolveUnits(userRepo))
+
+	hydrationGroup.GET("", hydrationHandler.GetHydrationLogs, validator.ValidateQuery(&validator.HydrationRequest{}))
+	hydrationGroup.POST("", hydrationHandler.AddHydration, validator.ValidateRequest(&validator.HydrationMutationRequest{}))
+	hydrationGroup.PUT("/:hydration_id", hydrationHandler.UpdateHydration, validator.ValidateRequest(&validator.HydrationMutationRequest{}))
+	hydrationGroup.DELETE("/:hydration_id", hydrationHandler.DeleteHydration)
+
+	// Daily total against the hydration target, :date is YYYY-MM-DD or "today"
+	hydrationGroup.GET("/days/:date", hydrationHandler.GetDailyHydration)
+	hydrationGroup.GET("/chart", hydrationHandler.GetHydrationChartData, validator.ValidateQuery(&validator.HydrationChartQuery{}))
+}
+
+func setupMealPlanRoutes(group *echo.Group) {
+	// Define meal plan-related protected routes here
+	mealPlanGroup := group.Group("/meal-plan")
+
+	// Initialize meal plan handlers
+	mealPlanRepo := models.NewMealPlanRepository()
+	nutritionRepo := models.NewNutritionRepository()
+	userRepo := models.NewUserRepository()
+	mealPlanHandler := api.NewMealPlanHandlers(mealPlanRepo, nutritionRepo, userRepo)
+
+	mealPlanGroup.GET("", mealPlanHandler.GetMealPlan, validator.ValidateQuery(&validator.MealPlanQuery{}))
+	mealPlanGroup.POST("", mealPlanHandler.AddMealPlanItem, validator.ValidateRequest(&validator.MealPlanItemRequest{}))
+	mealPlanGroup.POST("/templates/:template_id", mealPlanHandler.AddMealPlanTemplate, validator.ValidateRequest(&validator.MealPlanTemplateRequest{}))
+	mealPlanGroup.PUT("/:plan_item_id", mealPlanHandler.UpdateMealPlanItem, validator.ValidateRequest(&validator.MealPlanItemRequest{}))
+	mealPlanGroup.DELETE("/:plan_item_id", mealPlanHandler.DeleteMealPlanItem)
+
+	// Log a planned item as eaten, creating a food intake entry
+	mealPlanGroup.POST("/:plan_item_id/check-off", mealPlanHandler.CheckOffMealPlanItem)
+
+	// Plan vs actual for the Monday-based week containing :date (YYYY-MM-DD or "today")
+	mealPlanGroup.GET("/weeks/:date/adherence", mealPlanHandler.GetWeeklyMealPlanAdherence)
+}
+
+func setupGroceryListRoutes(group *echo.Group) {
+	// Define grocery list-related protected routes here
+	groceryGroup := group.Group("/grocery-lists")
+
+	// Initialize grocery list handlers
+	groceryRepo := models.NewGroceryListRepository()
+	mealPlanRepo := models.NewMealPlanRepository()
+	groceryHandler := api.NewGroceryListHandlers(groceryRepo, mealPlanRepo)
+
+	groceryGroup.GET("", groceryHandler.GetGroceryLists)
+	groceryGroup.POST("", groceryHandler.GenerateGroceryList, validator.ValidateRequest(&validator.GroceryListRequest{}))
+	groceryGroup.GET("/:list_id", groceryHandler.GetGroceryList)
+	groceryGroup.DELETE("/:list_id", groceryHandler.DeleteGroceryList)
+	groceryGroup.PUT("/:list_id/items/:item_id", groceryHandler.CheckGroceryItem, validator.ValidateRequest(&validator.GroceryItemCheckRequest{}))
+	groceryGroup.GET("/:list_id/export", groceryHandler.ExportGroceryList, validator.ValidateQuery(&validator.GroceryExportQuery{}))
+}
+
+func setupFastingRoutes(group *echo.Group) {
+	// Define intermittent fasting-related protected routes here
+	fastingGroup := group.Group("/fasting")
+
+	// Initialize fasting handlers
+	fastingRepo := models.NewFastingRepository()
+	fastingHandler := api.NewFastingHandlers(fastingRepo)
+
+	fastingGroup.GET("/status", fastingHandler.GetFastingStatus)
+	fastingGroup.POST("/start", fastingHandler.StartFast, validator.ValidateRequest(&validator.FastingStartRequest{}))
+	fastingGroup.POST("/stop", fastingHandler.StopFast, validator.ValidateRequest(&validator.FastingStopRequest{}))
+	fastingGroup.GET("/history", fastingHandler.GetFastingHistory, validator.ValidateQuery(&validator.FastingHistoryQuery{}))
+	fastingGroup.DELETE("/:session_id", fastingHandler.DeleteFast)
+
+	// Fasting hours for the Monday-based week containing :date (YYYY-MM-DD or "today")
+	fastingGroup.GET("/weeks/:date/stats", fastingHandler.GetWeeklyFastingStats)
+}
+
+func setupEnergyRoutes(group *echo.Group) {
+	// Define energy balance-related protected routes here
+	energyGroup := group.Group("/energy")
+
+	// Initialize energy handlers
+	userRepo := models.NewUserRepository()
+	energyHandler := api.NewEnergyHandlers(
+		models.NewNutritionRepository(),
+		models.NewexcerciseRecordRepository(),
+		models.NewBodyMeasurementRepository(),
+		userRepo,
+	)
+	energyGroup.Use(validator.ResolveUnits(userRepo))
+
+	energyGroup.GET("/balance", energyHandler.GetEnergyBalance, validator.ValidateQuery(&validator.EnergyBalanceQuery{}))
+	energyGroup.GET("/tdee", energyHandler.GetTDEEEstimate, validator.ValidateQuery(&validator.TDEEQuery{}))
+}
+
+func setupProgressPhotoRoutes(group *echo.Group, store blobstore.BlobStore) {
+	// Define progress photo-related protected routes here
+	progressPhotoGroup := group.Group("/progress-photos")
+
+	// Initialize progress photo handlers
+	progressPhotoHandler := api.NewProgressPhotoHandlers(models.NewProgressPhotoRepository(), store)
+
+	progressPhotoGroup.GET("", progressPhotoHandler.GetProgressPhotos, validator.ValidateQuery(&validator.ProgressPhotoRequest{}))
+	progressPhotoGroup.POST("", progressPhotoHandler.UploadProgressPhoto, validator.ValidateRequest(&validator.ProgressPhotoUploadRequest{}))
+	progressPhotoGroup.GET("/:photo_id", progressPhotoHandler.GetProgressPhoto)
+	progressPhotoGroup.DELETE("/:photo_id", progressPhotoHandler.DeleteProgressPhoto)
 }
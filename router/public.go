@@ -3,8 +3,11 @@ package router
 import (
 	"net/http"
 
+	"github.com/WahyuSiddarta/be_saham_go/api"
 	"github.com/WahyuSiddarta/be_saham_go/config"
 	"github.com/WahyuSiddarta/be_saham_go/helper"
+	"github.com/WahyuSiddarta/be_saham_go/models"
+	"github.com/WahyuSiddarta/be_saham_go/validator"
 	"github.com/labstack/echo/v4"
 )
 
@@ -22,6 +25,10 @@ func (r *Router) setupPublicRoutes(apiGroup *echo.Group) {
 		return helper.JsonResponse(c, http.StatusOK, healthData)
 	})
 
+	// Progress photo files, reachable only through the signed URLs handed out by the protected routes
+	progressPhotoHandler := api.NewProgressPhotoHandlers(models.NewProgressPhotoRepository(), r.blobStore)
+	rpub.GET("/progress-photos/:photo_id/:variant", progressPhotoHandler.ServeProgressPhoto, validator.ValidateQuery(&validator.ProgressPhotoFileQuery{}))
+
 	// Test panic recovery - accessible at /api/public/test-panic (for testing only)
 	rpub.GET("/test-panic", func(c echo.Context) error {
 		// This endpoint intentionally panics to test the recover middleware
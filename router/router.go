@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/WahyuSiddarta/be_saham_go/api"
+	"github.com/WahyuSiddarta/be_saham_go/blobstore"
 	"github.com/WahyuSiddarta/be_saham_go/config"
 	"github.com/WahyuSiddarta/be_saham_go/middleware"
 
@@ -15,6 +16,8 @@ var Logger *zerolog.Logger
 // Router handles all route setup and configuration
 type Router struct {
 	API *api.API
+
+	blobStore blobstore.BlobStore
 }
 
 // New creates a new Router instance
@@ -33,6 +36,13 @@ func (r *Router) SetupRoutes() error {
 		}
 	}()
 
+	store, err := blobstore.New(config.Get().Storage)
+	if err != nil {
+		Logger.Error().Err(err).Msg("Failed to initialize blob storage")
+		return err
+	}
+	r.blobStore = store
+
 	// Setup API group with middleware
 	apiGroup := r.API.Router.Group("/api")
 
@@ -53,7 +63,7 @@ func (r *Router) SetupRoutes() error {
 		port = ":" + port
 	}
 	Logger.Info().Msgf("Starting server on port %s", port)
-	err := r.API.Router.Start(port)
+	err = r.API.Router.Start(port)
 	if err != nil && err != http.ErrServerClosed {
 		Logger.Fatal().Err(err).Msgf("Failed to start server on port %s", port)
 		return err
@@ -0,0 +1,50 @@
+package validator
+
+import (
+	"encoding/json"
+
+	"github.com/WahyuSiddarta/be_saham_go/models"
+)
+
+// BulkRequest is the envelope for batch writes against one tracker. Items are
+// kept raw so each one is validated on its own with the tracker's request type.
+// Mode defaults to transactional (all or nothing); partial applies what it can.
+type BulkRequest struct {
+	Mode   string            `json:"mode,omitempty" validate:"omitempty,oneof=transactional partial"`
+	Create []json.RawMessage `json:"create,omitempty" validate:"max=500"`
+	Update []json.RawMessage `json:"update,omitempty" validate:"max=500"`
+	Delete []int             `json:"delete,omitempty" validate:"max=500,dive,gt=0"`
+}
+
+// BulkCreateItem holds the batch-only fields of a created item: when it was
+// logged on the device, as RFC3339. Defaults to the time of the upload.
+type BulkCreateItem struct {
+	LoggedAt string `json:"logged_at,omitempty" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+}
+
+// BulkUpdateItem holds the batch-only fields of an updated item.
+type BulkUpdateItem struct {
+	Id int `json:"id" validate:"required,gt=0"`
+}
+
+// ValidateBulkItem decodes one raw batch item into each target and validates
+// them, returning the combined errors in the usual ValidationError format.
+func ValidateBulkItem(raw json.RawMessage, targets ...interface{}) []ValidationError {
+	return ValidateBulkItemInUnits(raw, models.MetricUnits(), targets...)
+}
+
+// ValidateBulkItemInUnits is ValidateBulkItem for items sent in the given
+// units; targets implementing UnitConverter are converted before validation.
+func ValidateBulkItemInUnits(raw json.RawMessage, units models.UnitPreference, targets ...interface{}) []ValidationError {
+	var errs []ValidationError
+	for _, target := range targets {
+		if err := json.Unmarshal(raw, target); err != nil {
+			return []ValidationError{{Field: "item", Message: "Item is not a valid JSON object", Tag: "json"}}
+		}
+		if converter, ok := target.(UnitConverter); ok {
+			converter.ToMetric(units)
+		}
+		errs = append(errs, ValidateStruct(target)...)
+	}
+	return errs
+}
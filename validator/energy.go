@@ -0,0 +1,14 @@
+package validator
+
+// EnergyBalanceQuery represents query parameters for the daily energy balance; defaults to the last 30 days.
+type EnergyBalanceQuery struct {
+	From string `query:"from" validate:"omitempty,datetime=2006-01-02"`
+	To   string `query:"to" validate:"omitempty,datetime=2006-01-02"`
+}
+
+// TDEEQuery represents query parameters for the adaptive TDEE estimate.
+// The window ends on to, which defaults to yesterday because today is not fully logged yet.
+type TDEEQuery struct {
+	Window int    `query:"window" validate:"omitempty,min=14,max=90"`
+	To     string `query:"to" validate:"omitempty,datetime=2006-01-02"`
+}
@@ -4,10 +4,36 @@ package validator
 type ExerciseRequest struct {
 	Page int `json:"page" validate:"omitempty,gte=1"`
 }
+
+// ExcerciseMutationRequest logs an exercise. With CatalogId, Name and Type
+// default to the catalog exercise and Caloric, when omitted, is estimated
+// from its MET, the latest bodyweight and Minute.
 type ExcerciseMutationRequest struct {
-	Name      string `json:"name" validate:"required"`
-	Minute    *int   `json:"minute,omitempty" db:"minute" validate:"omitempty,gt=1"`
-	Caloric   int    `json:"caloric" validate:"required,gt=1"`
+	CatalogId int    `json:"catalog_id,omitempty" validate:"omitempty,gt=0"`
+	Name      string `json:"name" validate:"required_without=CatalogId"`
+	Minute    *int   `json:"minute,omitempty" db:"minute" validate:"required_without=Caloric,omitempty,gt=1"`
+	Caloric   int    `json:"caloric" validate:"required_without=CatalogId,omitempty,gt=1"`
 	Intensity string `json:"intensity" validate:"required,oneof=Low Medium High"`
-	Type      string `json:"type" db:"type" validate:"required,oneof=HIT WeightLifting Cardio"`
+	Type      string `json:"type" db:"type" validate:"required_without=CatalogId,omitempty,oneof=HIT WeightLifting Cardio Sports Flexibility Other"`
+	// DistanceKm is the distance covered, always in km
+	DistanceKm *float64 `json:"distance_km,omitempty" validate:"omitempty,gt=0,lte=1000"`
+}
+
+// ExerciseCatalogQuery filters the exercise catalog by name, category and muscle group
+type ExerciseCatalogQuery struct {
+	Q           string `query:"q" validate:"omitempty,max=100"`
+	Category    string `query:"category" validate:"omitempty,oneof=HIT WeightLifting Cardio Sports Flexibility Other"`
+	MuscleGroup string `query:"muscle_group" validate:"omitempty,oneof=chest back shoulders biceps triceps forearms core glutes quadriceps hamstrings calves full_body"`
+}
+
+// ExcerciseActivityImportRequest holds the multipart form fields sent with a
+// GPX, TCX or FIT activity file; the file itself is the "file" part. Format is
+// detected from the file name or content when empty, the catalog exercise from
+// the sport recorded in the file, and caloric, when omitted, is estimated.
+type ExcerciseActivityImportRequest struct {
+	Format    string `json:"format" form:"format" validate:"omitempty,oneof=gpx tcx fit"`
+	CatalogId int    `json:"catalog_id" form:"catalog_id" validate:"omitempty,gt=0"`
+	Name      string `json:"name" form:"name" validate:"omitempty,max=100"`
+	Intensity string `json:"intensity" form:"intensity" validate:"omitempty,oneof=Low Medium High"`
+	Caloric   int    `json:"caloric" form:"caloric" validate:"omitempty,gt=1"`
 }
@@ -0,0 +1,19 @@
+package validator
+
+// FastingStartRequest represents the request payload for starting a fast.
+// target_hours is required for the custom preset; started_at defaults to now.
+type FastingStartRequest struct {
+	Preset      string   `json:"preset" validate:"required,oneof=16:8 18:6 20:4 omad custom"`
+	TargetHours *float64 `json:"target_hours,omitempty" validate:"required_if=Preset custom,omitempty,gt=0,lte=72,decimal2"`
+	StartedAt   string   `json:"started_at,omitempty" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+}
+
+// FastingStopRequest represents the request payload for stopping the active fast; ended_at defaults to now.
+type FastingStopRequest struct {
+	EndedAt string `json:"ended_at,omitempty" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+}
+
+// FastingHistoryQuery represents query parameters for the fasting history.
+type FastingHistoryQuery struct {
+	Page int `query:"page" validate:"omitempty,gte=1"`
+}
@@ -0,0 +1,18 @@
+package validator
+
+// GroceryListRequest represents the request payload for generating a grocery list from the meal plan;
+// defaults to the seven days from today.
+type GroceryListRequest struct {
+	From string `json:"from,omitempty" validate:"omitempty,datetime=2006-01-02"`
+	To   string `json:"to,omitempty" validate:"omitempty,datetime=2006-01-02"`
+}
+
+// GroceryItemCheckRequest represents the request payload for ticking a grocery list item.
+type GroceryItemCheckRequest struct {
+	Checked *bool `json:"checked" validate:"required"`
+}
+
+// GroceryExportQuery represents query parameters for exporting a grocery list.
+type GroceryExportQuery struct {
+	Format string `query:"format" validate:"omitempty,oneof=text csv"`
+}
@@ -0,0 +1,22 @@
+package validator
+
+// HydrationRequest
+type HydrationRequest struct {
+	Page int `query:"page" validate:"omitempty,gte=1"`
+}
+
+// HydrationMutationRequest represents the request payload for logging a beverage.
+// When caffeine_mg is omitted it is estimated from the beverage type.
+type HydrationMutationRequest struct {
+	AmountMl     float64  `json:"amount_ml" validate:"required,gt=0,lte=5000,decimal2"`
+	BeverageType string   `json:"beverage_type" validate:"required,oneof=water coffee tea other"`
+	CaffeineMg   *float64 `json:"caffeine_mg,omitempty" validate:"omitempty,gte=0,lte=2000,decimal2"`
+}
+
+// HydrationChartQuery represents query parameters for the hydration history chart.
+type HydrationChartQuery struct {
+	From        string `query:"from" validate:"omitempty,datetime=2006-01-02"`
+	To          string `query:"to" validate:"omitempty,datetime=2006-01-02"`
+	Granularity string `query:"granularity" validate:"omitempty,oneof=day week month"`
+	Metrics     string `query:"metrics" validate:"omitempty,chart_metrics=hydration"`
+}
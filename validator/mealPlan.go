@@ -0,0 +1,21 @@
+package validator
+
+// MealPlanItemRequest represents the request payload for planning one food on a date.
+type MealPlanItemRequest struct {
+	Date string `json:"date" validate:"required,datetime=2006-01-02"`
+	NutritionRequest
+	IngredientAmountRequest
+}
+
+// MealPlanTemplateRequest represents the request payload for planning a meal template on a date;
+// category defaults to the template's own.
+type MealPlanTemplateRequest struct {
+	Date     string            `json:"date" validate:"required,datetime=2006-01-02"`
+	Category NutritionCategory `json:"category,omitempty" validate:"omitempty,nutrition_category"`
+}
+
+// MealPlanQuery represents query parameters for listing the meal plan; defaults to the seven days from today.
+type MealPlanQuery struct {
+	From string `query:"from" validate:"omitempty,datetime=2006-01-02"`
+	To   string `query:"to" validate:"omitempty,datetime=2006-01-02"`
+}
@@ -11,4 +11,43 @@ type BodyMeasurementCreateRequest struct {
 	FatPercentage float64 `json:"fat_percentage,omitempty" validate:"omitempty,gt=0,decimal2"`
 	NickCm        float64 `json:"nick_cm,omitempty" validate:"omitempty,gt=0,decimal2"`
 	WaistCm       float64 `json:"waist_cm,omitempty" validate:"omitempty,gt=0,decimal2"`
+
+	HipCm               float64 `json:"hip_cm,omitempty" validate:"omitempty,gt=0,decimal2"`
+	ChestCm             float64 `json:"chest_cm,omitempty" validate:"omitempty,gt=0,decimal2"`
+	ArmLeftCm           float64 `json:"arm_left_cm,omitempty" validate:"omitempty,gt=0,decimal2"`
+	ArmRightCm          float64 `json:"arm_right_cm,omitempty" validate:"omitempty,gt=0,decimal2"`
+	ThighLeftCm         float64 `json:"thigh_left_cm,omitempty" validate:"omitempty,gt=0,decimal2"`
+	ThighRightCm        float64 `json:"thigh_right_cm,omitempty" validate:"omitempty,gt=0,decimal2"`
+	CalfCm              float64 `json:"calf_cm,omitempty" validate:"omitempty,gt=0,decimal2"`
+	MuscleMassKg        float64 `json:"muscle_mass_kg,omitempty" validate:"omitempty,gt=0,decimal2,ltefield=Bodyweight"`
+	BoneMassKg          float64 `json:"bone_mass_kg,omitempty" validate:"omitempty,gt=0,decimal2,ltefield=Bodyweight"`
+	BodyWaterPercentage float64 `json:"body_water_percentage,omitempty" validate:"omitempty,gt=0,lte=100,decimal2"`
+	ScaleBmr            float64 `json:"scale_bmr,omitempty" validate:"omitempty,gt=0,decimal2"`
+	MetabolicAge        int     `json:"metabolic_age,omitempty" validate:"omitempty,gt=0,lte=120"`
+
+	// Confirm saves a measurement that failed the plausibility checks; it is flagged for review
+	Confirm bool `json:"confirm,omitempty"`
+}
+
+// WeightTrendQuery represents query parameters for the weight trend; defaults to the last 90 days.
+// A plateau is flagged when the trend stays flat for more than PlateauWeeks weeks.
+type WeightTrendQuery struct {
+	From         string `query:"from" validate:"omitempty,datetime=2006-01-02"`
+	To           string `query:"to" validate:"omitempty,datetime=2006-01-02"`
+	PlateauWeeks int    `query:"plateau_weeks" validate:"omitempty,gte=1,lte=12"`
+}
+
+// BodyMeasurementImportRequest holds the multipart form fields sent with a CSV
+// export of a scale app; the file itself is the "file" part. Profile is
+// detected from the headers when empty. Mapping is a JSON object of field to
+// CSV header and is required for the custom profile. DateFormat such as
+// "DD.MM.YYYY HH:mm" and DateOrder are only needed when detection gets it wrong.
+type BodyMeasurementImportRequest struct {
+	Profile      string `json:"profile" form:"profile" validate:"omitempty,oneof=withings renpho garmin fitbit eufy custom"`
+	Mapping      string `json:"mapping" form:"mapping" validate:"required_if=Profile custom,max=2000"`
+	DateFormat   string `json:"date_format" form:"date_format" validate:"omitempty,max=40"`
+	DateOrder    string `json:"date_order" form:"date_order" validate:"omitempty,oneof=dmy mdy"`
+	WeightUnit   string `json:"weight_unit" form:"weight_unit" validate:"omitempty,oneof=kg lb"`
+	DecimalComma bool   `json:"decimal_comma" form:"decimal_comma"`
+	DryRun       bool   `json:"dry_run" form:"dry_run"`
 }
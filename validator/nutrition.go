@@ -1,6 +1,7 @@
 package validator
 
 // CreateNutritionRequest represents the request payload for creating a nutrition intake entry.
+// Extended nutrients are optional and do not take part in caloric_calculation.
 type NutritionRequest struct {
 	Category     NutritionCategory `json:"category" db:"category" validate:"required,nutrition_category"`
 	Fat          float64           `json:"fat" db:"fat" validate:"gte=0,decimal2"`
@@ -8,6 +9,72 @@ type NutritionRequest struct {
 	Carbohydrate float64           `json:"carbohydrate" db:"carbohydrate" validate:"gte=0,decimal2"`
 	Caloric      float64           `json:"caloric" db:"caloric" validate:"gte=0,decimal2,caloric_calculation"`
 	Name         string            `json:"name" db:"name" validate:"required,min=1,max=255"`
+	ExtendedNutrientsRequest
+}
+
+// ExtendedNutrientsRequest holds the optional nutrients shared by every food payload.
+type ExtendedNutrientsRequest struct {
+	Fiber          *float64           `json:"fiber,omitempty" validate:"omitempty,gte=0,decimal2"`
+	Sugar          *float64           `json:"sugar,omitempty" validate:"omitempty,gte=0,decimal2"`
+	SaturatedFat   *float64           `json:"saturated_fat,omitempty" validate:"omitempty,gte=0,decimal2"`
+	Sodium         *float64           `json:"sodium,omitempty" validate:"omitempty,gte=0,decimal2"`
+	Cholesterol    *float64           `json:"cholesterol,omitempty" validate:"omitempty,gte=0,decimal2"`
+	Potassium      *float64           `json:"potassium,omitempty" validate:"omitempty,gte=0,decimal2"`
+	Micronutrients map[string]float64 `json:"micronutrients,omitempty" validate:"omitempty,max=50,dive,keys,min=1,max=64,endkeys,gte=0"`
+}
+
+// IngredientAmountRequest holds the optional shopping details used to build grocery lists.
+type IngredientAmountRequest struct {
+	Quantity  *float64 `json:"quantity,omitempty" validate:"omitempty,gt=0,decimal2"`
+	Unit      *string  `json:"unit,omitempty" validate:"required_with=Quantity,omitempty,oneof=g kg ml l piece"`
+	FoodGroup *string  `json:"food_group,omitempty" validate:"omitempty,oneof=produce meat_seafood dairy_eggs bakery pantry frozen beverages other"`
+}
+
+// MealTemplateItemRequest represents one food of a meal template.
+type MealTemplateItemRequest struct {
+	Fat          float64 `json:"fat" validate:"gte=0,decimal2"`
+	Protein      float64 `json:"protein" validate:"gte=0,decimal2"`
+	Carbohydrate float64 `json:"carbohydrate" validate:"gte=0,decimal2"`
+	Caloric      float64 `json:"caloric" validate:"gte=0,decimal2,caloric_calculation"`
+	Name         string  `json:"name" validate:"required,min=1,max=255"`
+	ExtendedNutrientsRequest
+	IngredientAmountRequest
+}
+
+// MealTemplateRequest represents the request payload for creating or replacing a meal template.
+type MealTemplateRequest struct {
+	Name     string                    `json:"name" validate:"required,min=1,max=100"`
+	Category NutritionCategory         `json:"category" validate:"required,nutrition_category"`
+	Items    []MealTemplateItemRequest `json:"items" validate:"required,min=1,max=50,dive"`
+}
+
+// ApplyMealTemplateRequest represents the request payload for logging a meal template; date defaults to today.
+type ApplyMealTemplateRequest struct {
+	Date string `json:"date,omitempty" validate:"omitempty,datetime=2006-01-02"`
+}
+
+// CopyMealRequest represents the request payload for copying a logged meal to another day.
+// to_date defaults to today and to_category to the source category.
+type CopyMealRequest struct {
+	FromDate   string            `json:"from_date" validate:"required,datetime=2006-01-02"`
+	Category   NutritionCategory `json:"category" validate:"required,nutrition_category"`
+	ToDate     string            `json:"to_date,omitempty" validate:"omitempty,datetime=2006-01-02"`
+	ToCategory NutritionCategory `json:"to_category,omitempty" validate:"omitempty,nutrition_category"`
+}
+
+// FrequentFoodsQuery represents query parameters for the frequent and recent foods list.
+type FrequentFoodsQuery struct {
+	Days  int    `query:"days" validate:"omitempty,min=1,max=365"`
+	Limit int    `query:"limit" validate:"omitempty,min=1,max=50"`
+	Sort  string `query:"sort" validate:"omitempty,oneof=frequent recent"`
+}
+
+// NutritionChartQuery represents query parameters for the nutrition history chart.
+type NutritionChartQuery struct {
+	From        string `query:"from" validate:"omitempty,datetime=2006-01-02"`
+	To          string `query:"to" validate:"omitempty,datetime=2006-01-02"`
+	Granularity string `query:"granularity" validate:"omitempty,oneof=day week month"`
+	Metrics     string `query:"metrics" validate:"omitempty,chart_metrics=nutrition"`
 }
 
 // NutritionCategory represents nutrition category, like breakfast, lunch, dinner, snack
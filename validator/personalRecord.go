@@ -0,0 +1,8 @@
+package validator
+
+// PersonalRecordTimelineQuery picks the exercise of a personal record
+// timeline: a catalog exercise, or an exercise logged by name
+type PersonalRecordTimelineQuery struct {
+	CatalogId int    `query:"catalog_id" validate:"omitempty,gt=0"`
+	Name      string `query:"name" validate:"required_without=CatalogId,omitempty,max=100"`
+}
@@ -6,6 +6,13 @@ type PersonalNutritionTargetRequest struct {
 	NutritionProtein float64 `json:"nutrition_protein" validate:"gte=0,decimal2"`
 	NutritionCarbs   float64 `json:"nutrition_carbohydrate" validate:"gte=0,decimal2"`
 	NutritionFat     float64 `json:"nutrition_fat" validate:"gte=0,decimal2"`
+
+	NutritionFiber        *float64 `json:"nutrition_fiber,omitempty" validate:"omitempty,gte=0,decimal2"`
+	NutritionSugar        *float64 `json:"nutrition_sugar,omitempty" validate:"omitempty,gte=0,decimal2"`
+	NutritionSaturatedFat *float64 `json:"nutrition_saturated_fat,omitempty" validate:"omitempty,gte=0,decimal2"`
+	NutritionSodium       *float64 `json:"nutrition_sodium,omitempty" validate:"omitempty,gte=0,decimal2"`
+	NutritionCholesterol  *float64 `json:"nutrition_cholesterol,omitempty" validate:"omitempty,gte=0,decimal2"`
+	NutritionPotassium    *float64 `json:"nutrition_potassium,omitempty" validate:"omitempty,gte=0,decimal2"`
 }
 
 // PersonalBodyMeasurementTargetRequest represents the request payload for updating personal nutrition targets.
@@ -13,6 +20,19 @@ type PersonalBodyMeasurementTargetRequest struct {
 	BodyWeight    float64 `json:"bodyweight" validate:"gte=0,decimal2"`
 	ViceralFat    float64 `json:"viceral_fat" validate:"gte=0,decimal2"`
 	FatPercentage float64 `json:"fat_percentage" validate:"gte=0,decimal2"`
+
+	HipCm               *float64 `json:"hip_cm,omitempty" validate:"omitempty,gt=0,decimal2"`
+	ChestCm             *float64 `json:"chest_cm,omitempty" validate:"omitempty,gt=0,decimal2"`
+	ArmLeftCm           *float64 `json:"arm_left_cm,omitempty" validate:"omitempty,gt=0,decimal2"`
+	ArmRightCm          *float64 `json:"arm_right_cm,omitempty" validate:"omitempty,gt=0,decimal2"`
+	ThighLeftCm         *float64 `json:"thigh_left_cm,omitempty" validate:"omitempty,gt=0,decimal2"`
+	ThighRightCm        *float64 `json:"thigh_right_cm,omitempty" validate:"omitempty,gt=0,decimal2"`
+	CalfCm              *float64 `json:"calf_cm,omitempty" validate:"omitempty,gt=0,decimal2"`
+	MuscleMassKg        *float64 `json:"muscle_mass_kg,omitempty" validate:"omitempty,gt=0,decimal2"`
+	BoneMassKg          *float64 `json:"bone_mass_kg,omitempty" validate:"omitempty,gt=0,decimal2"`
+	BodyWaterPercentage *float64 `json:"body_water_percentage,omitempty" validate:"omitempty,gt=0,lte=100,decimal2"`
+	ScaleBmr            *float64 `json:"scale_bmr,omitempty" validate:"omitempty,gt=0,decimal2"`
+	MetabolicAge        *int     `json:"metabolic_age,omitempty" validate:"omitempty,gt=0,lte=120"`
 }
 
 type PersonalExerciseTargetRequest struct {
@@ -22,3 +42,13 @@ type PersonalExerciseTargetRequest struct {
 	WeeklyWeightLiftingSessions int `json:"weekly_weight_lifting_sessions" validate:"gte=0"`
 	WeeklyCardioMinutes         int `json:"weekly_cardio_minutes" validate:"gte=0"`
 }
+
+// PersonalHydrationTargetRequest represents the request payload for updating the daily hydration target.
+type PersonalHydrationTargetRequest struct {
+	HydrationMl float64 `json:"hydration_ml" validate:"gte=0,lte=10000"`
+}
+
+// PersonalTargetHistoryQuery represents query parameters for listing target versions.
+type PersonalTargetHistoryQuery struct {
+	Page int `query:"page" validate:"omitempty,gte=1"`
+}
@@ -0,0 +1,22 @@
+package validator
+
+// ProgressPhotoUploadRequest holds the multipart form fields sent with a photo.
+// The image itself is the "photo" file part and is read by the handler.
+type ProgressPhotoUploadRequest struct {
+	Pose          string `json:"pose" form:"pose" validate:"required,oneof=front side back"`
+	MeasurementId int    `json:"measurement_id" form:"measurement_id" validate:"omitempty,gt=0"`
+	TakenAt       string `json:"taken_at" form:"taken_at" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+}
+
+// ProgressPhotoRequest represents query parameters for listing progress photos
+type ProgressPhotoRequest struct {
+	Page int    `query:"page" validate:"omitempty,gte=1"`
+	Pose string `query:"pose" validate:"omitempty,oneof=front side back"`
+}
+
+// ProgressPhotoFileQuery carries the signature of a signed progress photo URL
+type ProgressPhotoFileQuery struct {
+	Uid     int    `query:"uid" validate:"required,gt=0"`
+	Expires int64  `query:"expires" validate:"required,gt=0"`
+	Sig     string `query:"sig" validate:"required"`
+}
@@ -0,0 +1,172 @@
+package validator
+
+import (
+	"math"
+	"net/http"
+
+	"github.com/WahyuSiddarta/be_saham_go/helper"
+	"github.com/WahyuSiddarta/be_saham_go/models"
+	"github.com/labstack/echo/v4"
+)
+
+// UnitsHeader and UnitsQueryParam override the stored unit preference for one
+// request, with either a unit system ("metric", "imperial") or a list of units ("lb,kj")
+const (
+	UnitsHeader     = "X-Units"
+	UnitsQueryParam = "units"
+)
+
+// UnitPreferenceRequest represents the request payload for updating the user's display units.
+type UnitPreferenceRequest struct {
+	Weight string `json:"weight" validate:"required,oneof=kg lb"`
+	Length string `json:"length" validate:"required,oneof=cm in"`
+	Energy string `json:"energy" validate:"required,oneof=kcal kj"`
+	Volume string `json:"volume" validate:"required,oneof=ml fl_oz"`
+}
+
+// UnitConverter is implemented by request types holding unit-dependent values.
+// ToMetric runs after binding and before validation, so the validation rules
+// and everything after them only ever see canonical metric values.
+type UnitConverter interface {
+	ToMetric(units models.UnitPreference)
+}
+
+// ResolveUnits is a middleware that stores the units of the current request in
+// the context: the user's preference, overridden by the X-Units header or the
+// units query parameter. It must run before ValidateRequest.
+func ResolveUnits(userRepo models.UserRepository) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			var userId int = 1 // Replace with actual user ID retrieval logic
+
+			units, err := userRepo.FindUnitPreference(userId)
+			if err != nil && helper.Logger != nil {
+				helper.Logger.Warn().Err(err).Int("user_id", userId).Msg("Falling back to metric units")
+			}
+
+			override := c.Request().Header.Get(UnitsHeader)
+			if override == "" {
+				override = c.QueryParam(UnitsQueryParam)
+			}
+			if units, err = models.ParseUnitOverride(override, units); err != nil {
+				return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid units override", []ValidationError{{
+					Field:   UnitsQueryParam,
+					Message: err.Error(),
+					Tag:     "units",
+				}})
+			}
+
+			c.Set("request_units", units)
+			return next(c)
+		}
+	}
+}
+
+// GetRequestUnits retrieves the units resolved by ResolveUnits, metric when it didn't run
+func GetRequestUnits(c echo.Context) models.UnitPreference {
+	if units, ok := c.Get("request_units").(models.UnitPreference); ok {
+		return units
+	}
+	return models.MetricUnits()
+}
+
+// ToMetric converts the weights, lengths and scale BMR into kg, cm and kcal
+func (r *BodyMeasurementCreateRequest) ToMetric(units models.UnitPreference) {
+	r.Bodyweight = units.WeightToKg(r.Bodyweight)
+	r.MuscleMassKg = units.WeightToKg(r.MuscleMassKg)
+	r.BoneMassKg = units.WeightToKg(r.BoneMassKg)
+	r.NickCm = units.LengthToCm(r.NickCm)
+	r.WaistCm = units.LengthToCm(r.WaistCm)
+	r.HipCm = units.LengthToCm(r.HipCm)
+	r.ChestCm = units.LengthToCm(r.ChestCm)
+	r.ArmLeftCm = units.LengthToCm(r.ArmLeftCm)
+	r.ArmRightCm = units.LengthToCm(r.ArmRightCm)
+	r.ThighLeftCm = units.LengthToCm(r.ThighLeftCm)
+	r.ThighRightCm = units.LengthToCm(r.ThighRightCm)
+	r.CalfCm = units.LengthToCm(r.CalfCm)
+	r.ScaleBmr = units.EnergyToKcal(r.ScaleBmr)
+}
+
+// ToMetric converts the energy target into kcal; macronutrients are always grams
+func (r *PersonalNutritionTargetRequest) ToMetric(units models.UnitPreference) {
+	r.NutritionCaloric = units.EnergyToKcal(r.NutritionCaloric)
+}
+
+// ToMetric converts the weight, length and scale BMR targets into kg, cm and kcal
+func (r *PersonalBodyMeasurementTargetRequest) ToMetric(units models.UnitPreference) {
+	r.BodyWeight = units.WeightToKg(r.BodyWeight)
+	r.HipCm = models.ConvertOptional(r.HipCm, units.LengthToCm)
+	r.ChestCm = models.ConvertOptional(r.ChestCm, units.LengthToCm)
+	r.ArmLeftCm = models.ConvertOptional(r.ArmLeftCm, units.LengthToCm)
+	r.ArmRightCm = models.ConvertOptional(r.ArmRightCm, units.LengthToCm)
+	r.ThighLeftCm = models.ConvertOptional(r.ThighLeftCm, units.LengthToCm)
+	r.ThighRightCm = models.ConvertOptional(r.ThighRightCm, units.LengthToCm)
+	r.CalfCm = models.ConvertOptional(r.CalfCm, units.LengthToCm)
+	r.MuscleMassKg = models.ConvertOptional(r.MuscleMassKg, units.WeightToKg)
+	r.BoneMassKg = models.ConvertOptional(r.BoneMassKg, units.WeightToKg)
+	r.ScaleBmr = models.ConvertOptional(r.ScaleBmr, units.EnergyToKcal)
+}
+
+// ToMetric converts the weekly burn target into whole kcal
+func (r *PersonalExerciseTargetRequest) ToMetric(units models.UnitPreference) {
+	r.WeeklyExcerciseCaloric = int(math.Round(units.EnergyToKcal(float64(r.WeeklyExcerciseCaloric))))
+}
+
+// ToMetric converts the hydration target into ml
+func (r *PersonalHydrationTargetRequest) ToMetric(units models.UnitPreference) {
+	r.HydrationMl = units.VolumeToMl(r.HydrationMl)
+}
+
+// ToMetric converts the height into cm and the weekly goal rate into kg
+func (r *UserProfileRequest) ToMetric(units models.UnitPreference) {
+	r.HeightCm = models.ConvertOptional(r.HeightCm, units.LengthToCm)
+	r.GoalRateKg = units.WeightToKg(r.GoalRateKg)
+}
+
+// ToMetric converts the food energy into kcal; macronutrients are always grams
+func (r *NutritionRequest) ToMetric(units models.UnitPreference) {
+	r.Caloric = units.EnergyToKcal(r.Caloric)
+}
+
+// ToMetric converts the food energy of every item into kcal
+func (r *MealTemplateRequest) ToMetric(units models.UnitPreference) {
+	for i := range r.Items {
+		r.Items[i].Caloric = units.EnergyToKcal(r.Items[i].Caloric)
+	}
+}
+
+// ToMetric converts the beverage amount into ml
+func (r *HydrationMutationRequest) ToMetric(units models.UnitPreference) {
+	r.AmountMl = units.VolumeToMl(r.AmountMl)
+}
+
+// ToMetric converts the burned energy into whole kcal
+func (r *ExcerciseMutationRequest) ToMetric(units models.UnitPreference) {
+	r.Caloric = int(math.Round(units.EnergyToKcal(float64(r.Caloric))))
+}
+
+// ToMetric converts the burned energy into whole kcal
+func (r *ExcerciseActivityImportRequest) ToMetric(units models.UnitPreference) {
+	r.Caloric = int(math.Round(units.EnergyToKcal(float64(r.Caloric))))
+}
+
+// ToMetric converts the set weights into kg
+func (r *WorkoutRequest) ToMetric(units models.UnitPreference) {
+	for i := range r.Exercises {
+		for j := range r.Exercises[i].Sets {
+			r.Exercises[i].Sets[j].Weight = units.WeightToKg(r.Exercises[i].Sets[j].Weight)
+		}
+	}
+}
+
+// ToMetric converts the fixed prescribed weights into kg
+func (r *WorkoutProgramRequest) ToMetric(units models.UnitPreference) {
+	for i := range r.Weeks {
+		for j := range r.Weeks[i].Days {
+			for k := range r.Weeks[i].Days[j].Exercises {
+				exercise := &r.Weeks[i].Days[j].Exercises[k]
+				exercise.Weight = units.WeightToKg(exercise.Weight)
+			}
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package validator
+
+// UserTimezoneRequest represents the request payload for updating the user's IANA timezone.
+type UserTimezoneRequest struct {
+	Timezone string `json:"timezone" validate:"required,timezone"`
+}
+
+// UserProfileRequest represents the request payload for the profile target recommendations are calculated from.
+// GoalRateKg is the weekly change in kg and is required unless the goal is maintain.
+type UserProfileRequest struct {
+	Sex           *string  `json:"sex" validate:"omitempty,oneof=male female"`
+	BirthDate     *string  `json:"birth_date" validate:"omitempty,datetime=2006-01-02"`
+	HeightCm      *float64 `json:"height_cm" validate:"omitempty,gte=50,lte=272,decimal2"`
+	ActivityLevel *string  `json:"activity_level" validate:"omitempty,oneof=sedentary light moderate active very_active"`
+	Goal          string   `json:"goal" validate:"required,oneof=lose maintain gain"`
+	GoalRateKg    float64  `json:"goal_rate_kg" validate:"gte=0,lte=1,decimal2,required_unless=Goal maintain"`
+}
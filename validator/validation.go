@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"net/http"
 	"reflect"
+	"slices"
 	"strings"
 
 	"github.com/WahyuSiddarta/be_saham_go/helper"
+	"github.com/WahyuSiddarta/be_saham_go/models"
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 )
@@ -29,6 +31,7 @@ func init() {
 	validate.RegisterValidation("decimal2", validateDecimalPlaces)
 	validate.RegisterValidation("nutrition_category", validateNutritionCategory)
 	validate.RegisterValidation("caloric_calculation", validateCaloricCalculation)
+	validate.RegisterValidation("chart_metrics", validateChartMetrics)
 }
 
 // validateDecimalPlaces validates that a float64 has at most 2 decimal places
@@ -76,6 +79,16 @@ func validateCaloricCalculation(fl validator.FieldLevel) bool {
 	return abs(caloric-expectedCaloric) <= tolerance
 }
 
+// validateChartMetrics validates a comma-separated list of metrics of the chart series named by the tag param
+func validateChartMetrics(fl validator.FieldLevel) bool {
+	for _, metric := range strings.Split(fl.Field().String(), ",") {
+		if !slices.Contains(models.ChartMetrics[fl.Param()], strings.TrimSpace(metric)) {
+			return false
+		}
+	}
+	return true
+}
+
 // abs returns the absolute value of a float64
 func abs(x float64) float64 {
 	if x < 0 {
@@ -129,13 +142,32 @@ func getValidationMessage(err validator.FieldError) string {
 		return fmt.Sprintf("%s must be greater than %s", field, param)
 	case "gte":
 		return fmt.Sprintf("%s must be greater than or equal to %s", field, param)
+	case "lte":
+		return fmt.Sprintf("%s must be less than or equal to %s", field, param)
+	case "ltefield":
+		return fmt.Sprintf("%s must not be greater than %s", field, param)
 	case "decimal2":
 		return fmt.Sprintf("%s can only have a maximum of 2 decimal places", field)
 	case "nutrition_category":
 		return fmt.Sprintf("%s must be one of: breakfast, lunch, dinner, snack", field)
 	case "caloric_calculation":
 		return fmt.Sprintf("%s does not match the calculated value from macronutrients (fat×9 + protein×4 + carbohydrate×4)", field)
+	case "timezone":
+		return fmt.Sprintf("%s must be a valid IANA timezone, e.g. Asia/Jakarta", field)
+	case "chart_metrics":
+		return fmt.Sprintf("%s must be a comma-separated list of: %s", field, strings.Join(models.ChartMetrics[param], ", "))
+	case "required_with":
+		return fmt.Sprintf("%s is required when %s is set", field, param)
+	case "required_if":
+		return fmt.Sprintf("%s is required when %s", field, strings.Replace(param, " ", " is ", 1))
+	case "required_unless":
+		return fmt.Sprintf("%s is required unless %s", field, strings.Replace(param, " ", " is ", 1))
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", field, strings.ReplaceAll(param, " ", ", "))
 	case "datetime":
+		if param == "2006-01-02" {
+			return fmt.Sprintf("%s must be a date in YYYY-MM-DD format", field)
+		}
 		return "Invalid date format. Please use ISO 8601 format (YYYY-MM-DDTHH:MM:SSZ)"
 	default:
 		return fmt.Sprintf("%s is invalid", field)
@@ -157,6 +189,11 @@ func ValidateRequest(requestType interface{}) echo.MiddlewareFunc {
 				return helper.ErrorResponse(c, http.StatusBadRequest, "Invalid request format", nil)
 			}
 
+			// Convert values sent in the user's units before the rules see them
+			if converter, ok := req.(UnitConverter); ok {
+				converter.ToMetric(GetRequestUnits(c))
+			}
+
 			// Validate the struct
 			if errs := ValidateStruct(req); len(errs) > 0 {
 				if helper.Logger != nil {
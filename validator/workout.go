@@ -0,0 +1,41 @@
+package validator
+
+// WorkoutRequest represents the request payload for logging or replacing a
+// strength workout; started_at defaults to now.
+type WorkoutRequest struct {
+	Name      string                   `json:"name" validate:"required,min=1,max=100"`
+	StartedAt string                   `json:"started_at,omitempty" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	EndedAt   string                   `json:"ended_at,omitempty" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Notes     string                   `json:"notes,omitempty" validate:"omitempty,max=500"`
+	Exercises []WorkoutExerciseRequest `json:"exercises" validate:"required,min=1,max=30,dive"`
+}
+
+// WorkoutExerciseRequest is one exercise of a workout; name defaults to the
+// catalog exercise. Exercises with the same superset_group form a superset.
+type WorkoutExerciseRequest struct {
+	CatalogId     int                 `json:"catalog_id,omitempty" validate:"omitempty,gt=0"`
+	Name          string              `json:"name,omitempty" validate:"required_without=CatalogId,max=100"`
+	SupersetGroup int                 `json:"superset_group,omitempty" validate:"omitempty,gte=1,lte=30"`
+	Notes         string              `json:"notes,omitempty" validate:"omitempty,max=500"`
+	Sets          []WorkoutSetRequest `json:"sets" validate:"required,min=1,max=50,dive"`
+}
+
+// WorkoutSetRequest is one set; weight is 0 for bodyweight exercises and RPE
+// the rate of perceived exertion from 1 to 10.
+type WorkoutSetRequest struct {
+	Reps        int     `json:"reps" validate:"required,gte=1,lte=200"`
+	Weight      float64 `json:"weight" validate:"gte=0,lte=1000,decimal2"`
+	Rpe         float64 `json:"rpe,omitempty" validate:"omitempty,gte=1,lte=10"`
+	RestSeconds int     `json:"rest_seconds,omitempty" validate:"omitempty,gte=1,lte=3600"`
+	Warmup      bool    `json:"warmup"`
+}
+
+// WorkoutListRequest represents query parameters for listing workouts
+type WorkoutListRequest struct {
+	Page int `query:"page" validate:"omitempty,gte=1"`
+}
+
+// StrengthWeeklyStatsQuery picks the one-rep max formula of the weekly stats; defaults to epley.
+type StrengthWeeklyStatsQuery struct {
+	Formula string `query:"formula" validate:"omitempty,oneof=epley brzycki"`
+}
@@ -0,0 +1,53 @@
+package validator
+
+// WorkoutProgramRequest represents the request payload for creating or
+// replacing a program template of up to a year of weeks
+type WorkoutProgramRequest struct {
+	Name        string               `json:"name" validate:"required,min=1,max=100"`
+	Description string               `json:"description,omitempty" validate:"omitempty,max=1000"`
+	Weeks       []ProgramWeekRequest `json:"weeks" validate:"required,min=1,max=52,dive"`
+}
+
+// ProgramWeekRequest is one week of a program; a rest week has no days
+type ProgramWeekRequest struct {
+	Days []ProgramDayRequest `json:"days" validate:"max=7,dive"`
+}
+
+// ProgramDayRequest is one session of a week; day 1 is the weekday of the enrolment start date.
+type ProgramDayRequest struct {
+	Day       int                      `json:"day" validate:"required,gte=1,lte=7"`
+	Name      string                   `json:"name,omitempty" validate:"omitempty,max=100"`
+	Exercises []ProgramExerciseRequest `json:"exercises" validate:"required,min=1,max=30,dive"`
+}
+
+// ProgramExerciseRequest is one prescribed exercise; name defaults to the
+// catalog exercise. Percentage is of the user's best estimated one-rep max and
+// minutes prescribe a timed bout instead of sets.
+type ProgramExerciseRequest struct {
+	CatalogId  int     `json:"catalog_id,omitempty" validate:"omitempty,gt=0"`
+	Name       string  `json:"name,omitempty" validate:"required_without=CatalogId,max=100"`
+	Sets       int     `json:"sets,omitempty" validate:"required_without=Minutes,omitempty,gte=1,lte=50"`
+	Reps       int     `json:"reps,omitempty" validate:"required_without=Minutes,omitempty,gte=1,lte=200"`
+	Percentage float64 `json:"percentage,omitempty" validate:"omitempty,gt=0,lte=120"`
+	Weight     float64 `json:"weight,omitempty" validate:"omitempty,gt=0,lte=1000,decimal2"`
+	Minutes    int     `json:"minutes,omitempty" validate:"omitempty,gte=1,lte=600"`
+	Amrap      bool    `json:"amrap"`
+	Notes      string  `json:"notes,omitempty" validate:"omitempty,max=500"`
+}
+
+// ProgramEnrolRequest starts a program on a date; defaults to today.
+type ProgramEnrolRequest struct {
+	StartDate string `json:"start_date,omitempty" validate:"omitempty,datetime=2006-01-02"`
+}
+
+// ProgramSessionQuery picks the local date of the prescribed session; defaults to today.
+type ProgramSessionQuery struct {
+	Date string `query:"date" validate:"omitempty,datetime=2006-01-02"`
+}
+
+// ProgramSessionStartRequest picks the program day to start; defaults to
+// the one falling on today.
+type ProgramSessionStartRequest struct {
+	Week int `json:"week,omitempty" validate:"required_with=Day,omitempty,gte=1,lte=52"`
+	Day  int `json:"day,omitempty" validate:"required_with=Week,omitempty,gte=1,lte=7"`
+}